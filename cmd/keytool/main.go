@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/chainedpixel/go-dte-signer/configs"
+	"github.com/chainedpixel/go-dte-signer/internal/infrastructure/adapters"
+	"github.com/chainedpixel/go-dte-signer/internal/infrastructure/cypher"
+)
+
+// usage describes the commands of the tool
+const usage = `Usage: keytool <command> [flags]
+
+Manages the master keys encrypting the stored certificates. The certificates directory or
+database, and the keyring, are taken from config.yaml and the APP_ environment variables.
+
+Commands:
+  generate  Create a keyring file holding a new master key
+  encrypt   Encrypt in place every plaintext certificate with the active master key
+  rotate    Add a new active master key to the keyring and rewrap every certificate with it
+`
+
+// resealer is a certificate store able to encrypt or rewrap its certificates
+type resealer interface {
+	Reseal(ctx context.Context) (int, error)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "generate":
+		err = generate(os.Args[2:])
+	case "encrypt":
+		err = encrypt(os.Args[2:])
+	case "rotate":
+		err = rotate(os.Args[2:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// generate creates a keyring file holding a new master key
+func generate(args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+	path := flags.String("keyring", config.Encryption.Keyring, "keyring file to create")
+	id := flags.String("id", config.Encryption.MasterKeyID, "ID of the master key")
+	flags.Parse(args)
+
+	if *path == "" {
+		return errors.New("the keyring file is required, set -keyring or encryption.keyring")
+	}
+	if _, err := os.Stat(*path); err == nil {
+		return fmt.Errorf("keyring %s already exists, use rotate to add a master key", *path)
+	}
+
+	keyring := &cypher.Keyring{}
+	if err := keyring.Generate(*id); err != nil {
+		return err
+	}
+	if err := keyring.Write(*path); err != nil {
+		return err
+	}
+
+	fmt.Printf("Keyring %s created with master key %q\n", *path, *id)
+	return nil
+}
+
+// encrypt encrypts every plaintext certificate with the active master key
+func encrypt(args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	flags.Parse(args)
+
+	keyring, err := cypher.LoadKeyring(config.Encryption.Keyring, config.Encryption.MasterKey, config.Encryption.MasterKeyID)
+	if err != nil {
+		return fmt.Errorf("failed to load master keys: %w", err)
+	}
+	return reseal(config, keyring)
+}
+
+// rotate adds a new active master key to the keyring and rewraps every certificate with it
+func rotate(args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("rotate", flag.ExitOnError)
+	id := flags.String("id", "", "ID of the new master key, defaults to one derived from the current date")
+	flags.Parse(args)
+
+	// 1: Add the new master key to the keyring file before using it, so it is never lost
+	if config.Encryption.Keyring == "" {
+		return errors.New("rotation requires a keyring file, set encryption.keyring")
+	}
+	keyring, err := cypher.ReadKeyring(config.Encryption.Keyring)
+	if err != nil {
+		return err
+	}
+	if *id == "" {
+		*id = time.Now().UTC().Format("20060102150405")
+	}
+	previous := keyring.Active
+	if err := keyring.Generate(*id); err != nil {
+		return err
+	}
+	if err := keyring.Write(config.Encryption.Keyring); err != nil {
+		return err
+	}
+	fmt.Printf("Master key %q added to %s and made active, replacing %q\n", *id, config.Encryption.Keyring, previous)
+
+	// 2: Rewrap the data keys of every certificate
+	keyring, err = cypher.LoadKeyring(config.Encryption.Keyring, config.Encryption.MasterKey, config.Encryption.MasterKeyID)
+	if err != nil {
+		return fmt.Errorf("failed to load master keys: %w", err)
+	}
+	if err := reseal(config, keyring); err != nil {
+		return err
+	}
+
+	fmt.Println("Restart the signer replicas so they load the new master key; keep the previous keys until then")
+	return nil
+}
+
+// reseal encrypts or rewraps the certificates of the configured store with the active master key
+func reseal(config *configs.Config, keyring *cypher.Keyring) error {
+	keyEncryption, err := cypher.NewLocalKeyEncryptionService(keyring)
+	if err != nil {
+		return err
+	}
+	if !config.Encryption.Enabled {
+		fmt.Println("Warning: encryption.enabled is false, the signer will not open the encrypted certificates")
+	}
+
+	// 1: Open the configured certificate store
+	ctx := context.Background()
+	keyProcessor := cypher.NewKeyProcessor()
+	var store resealer
+	location := config.Filesystem.CertificatesDir
+	if config.Storage.Driver == "sqlite" {
+		sqliteRepository, err := adapters.NewSQLiteCertificateRepository(ctx, config.Storage.SQLite.Path,
+			time.Duration(config.Storage.SQLite.BusyTimeout)*time.Millisecond, keyProcessor, config.Filesystem.KeyPasswords, keyEncryption)
+		if err != nil {
+			return err
+		}
+		defer sqliteRepository.Close()
+		store = sqliteRepository
+		location = config.Storage.SQLite.Path
+	} else {
+		store = adapters.NewFileCertificateRepository(config.Filesystem.CertificatesDir, keyProcessor,
+			config.Filesystem.KeyPasswords, keyEncryption)
+	}
+
+	// 2: Encrypt or rewrap its certificates
+	count, err := store.Reseal(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d certificate(s) of %s sealed with master key %q\n", count, location, keyEncryption.ActiveKeyID())
+	return nil
+}
+
+// loadConfig loads the configuration of the signer
+func loadConfig() (*configs.Config, error) {
+	config, _, err := configs.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	return config, nil
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
@@ -19,7 +18,7 @@ func main() {
 	// Bootstrap the application
 	app, err := configs.Bootstrap()
 	if err != nil {
-		fmt.Println(err)
+		logs.Fatal("Application bootstrap failed: " + err.Error())
 	}
 
 	// Log startup information
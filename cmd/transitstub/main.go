@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/xml"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/internal/infrastructure/cypher"
+	"github.com/chainedpixel/go-dte-signer/internal/infrastructure/cypher/transitstub"
+)
+
+// keyFlags collects the repeated -key flags
+type keyFlags []string
+
+func main() {
+	var keys keyFlags
+	address := flag.String("addr", ":8200", "address to listen on")
+	mount := flag.String("mount", "transit", "path of the transit engine")
+	token := flag.String("token", "", "token expected in X-Vault-Token, any is accepted when empty")
+	fail := flag.Int64("fail", 0, "number of sign requests answered with 503 before succeeding, to exercise retries")
+	delay := flag.Duration("delay", 0, "delay added to every sign request, to exercise timeouts")
+	flag.Var(&keys, "key", "key as name=rsa|ec|ed25519 to generate it, or name=<file> to load a PEM or Hacienda .crt file (repeatable); "+
+		"a repeated name adds a new version of the key")
+	flag.Parse()
+
+	stub := transitstub.New(transitstub.Options{Mount: *mount, Token: *token, Fail: *fail, Delay: *delay})
+	for _, definition := range keys {
+		name, source, ok := strings.Cut(definition, "=")
+		if !ok || name == "" {
+			log.Fatalf("invalid key %q, expected name=source", definition)
+		}
+		key, err := loadKey(source)
+		if err != nil {
+			log.Fatalf("failed to load key %s: %v", name, err)
+		}
+		stub.AddKey(name, key)
+		log.Printf("key %s: %s", name, cypher.DescribeKey(key.Public()))
+	}
+
+	log.Printf("transit stub listening on %s%s", *address, stub.Prefix())
+	log.Fatal(http.ListenAndServe(*address, stub.Handler()))
+}
+
+// loadKey generates a key of the given type or loads it from a PEM or Hacienda .crt file
+func loadKey(source string) (crypto.Signer, error) {
+	switch source {
+	case "rsa", "ec", "ed25519":
+		return transitstub.GenerateKey(source)
+	}
+
+	content, err := os.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+	keyProcessor := cypher.NewKeyProcessor()
+	if !bytes.HasPrefix(bytes.TrimSpace(content), []byte("<")) {
+		bundle, err := keyProcessor.PEMToKeys(content, "")
+		if err != nil {
+			return nil, err
+		}
+		return bundle.PrivateKey, nil
+	}
+
+	var certificate models.Certificate
+	if err := xml.Unmarshal(content, &certificate); err != nil {
+		return nil, err
+	}
+	decoded, err := certificate.DecodePrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := keyProcessor.BytesToPrivateKey(decoded)
+	if err != nil {
+		return nil, err
+	}
+	return parsed.DecodedPrivateKey, nil
+}
+
+// String returns the keys given so far
+func (k *keyFlags) String() string {
+	return strings.Join(*k, ",")
+}
+
+// Set adds a key
+func (k *keyFlags) Set(value string) error {
+	*k = append(*k, value)
+	return nil
+}
@@ -3,8 +3,11 @@ package configs
 import (
 	"context"
 	"fmt"
+	"io"
+	"time"
 
 	"github.com/chainedpixel/go-dte-signer/internal/application/usecases"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/ports"
 	"github.com/chainedpixel/go-dte-signer/internal/domain/services"
 	"github.com/chainedpixel/go-dte-signer/internal/infrastructure/adapters"
 	"github.com/chainedpixel/go-dte-signer/internal/infrastructure/cypher"
@@ -16,8 +19,9 @@ import (
 
 // Application holds all application components
 type Application struct {
-	Server *server.Server
-	Config *Config
+	Server  *server.Server
+	Config  *Config
+	closers []io.Closer
 }
 
 // Bootstrap initializes the application
@@ -34,68 +38,213 @@ func Bootstrap() (*Application, error) {
 	logs.Info("Configuration loaded successfully")
 
 	// 2. Initialize logging
-	sv, err := initServerDependencies(config)
+	sv, closers, err := initServerDependencies(config)
 	if err != nil {
 		return nil, err
 	}
 
 	// 3. Return bootstrapped application
 	app := &Application{
-		Server: sv,
-		Config: config,
+		Server:  sv,
+		Config:  config,
+		closers: closers,
 	}
 
 	logs.Info("Application bootstrap completed successfully")
 	return app, nil
 }
 
-// Start starts the application
+// Start starts the application and releases its background resources once the server stops
 func (a *Application) Start(ctx context.Context) error {
+	defer a.close()
+
 	logs.Info(fmt.Sprintf("Starting server on port %s", a.Config.Server.Port))
 	return a.Server.Start(ctx)
 }
 
-func initServerDependencies(config *Config) (*server.Server, error) {
+// close releases the background resources of the application
+func (a *Application) close() {
+	for _, closer := range a.closers {
+		if err := closer.Close(); err != nil {
+			logs.Error("Failed to release application resource:", err)
+		}
+	}
+}
+
+func initServerDependencies(config *Config) (*server.Server, []io.Closer, error) {
 	// 1. Initialize translator
 	logs.Debug("Initializing translator...")
 	translator, err := i18n.NewTranslator(config.Locale.LocalesDir, config.Locale.DefaultLocale)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize translator: %w", err)
+		return nil, nil, fmt.Errorf("failed to initialize translator: %w", err)
 	}
 	logs.Info("Translator initialized successfully")
 
 	// 2. Initialize infrastructure components
 	logs.Debug("Initializing infrastructure components...")
 	keyProcessor := cypher.NewKeyProcessor()
-	jwsSigner := cypher.NewJWSSigner()
-	certificateRepository := adapters.NewFileCertificateRepository(
-		config.Filesystem.CertificatesDir,
-		keyProcessor,
-	)
+	algorithms, err := cypher.NewAlgorithmRegistry(config.Signing.Algorithms, config.Signing.NITAlgorithms)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize signature algorithms: %w", err)
+	}
+	headerPolicy := cypher.HeaderPolicy{
+		IncludeKeyID:      config.JWS.IncludeKeyID,
+		Type:              config.JWS.Type,
+		IncludeThumbprint: config.JWS.IncludeThumbprint,
+		IncludeTimestamp:  config.JWS.IncludeTimestamp,
+		Headers:           config.JWS.Headers,
+		NITHeaders:        config.JWS.NITHeaders,
+	}
+	if err := headerPolicy.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid JWS header configuration: %w", err)
+	}
+	keyEncryption, err := initKeyEncryption(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	certificateStore, healthReporters, closers, err := initCertificateStore(config, keyProcessor, keyEncryption)
+	if err != nil {
+		return nil, nil, err
+	}
+	var certificateRepository ports.CertificateRepository = certificateStore
+	var signerCache *cypher.SignerCache
+	if config.Cache.Enabled {
+		ttl := time.Duration(config.Cache.TTL) * time.Second
+		cachedRepository := adapters.NewCachedCertificateRepository(certificateRepository, ttl, config.Cache.MaxEntries)
+		signerCache = cypher.NewSignerCache(ttl, config.Cache.MaxEntries)
+		certificateRepository = cachedRepository
+		healthReporters = append(healthReporters, cachedRepository, signerCache)
+
+		// Drop the cached certificate and signers of a NIT whenever one of its certificates changes
+		certificateStore.OnChange(func(nit string) {
+			cachedRepository.Invalidate(nit)
+			signerCache.Invalidate(nit)
+		})
+	}
+	jwsSigner := cypher.NewJWSSigner(algorithms, headerPolicy, signerCache)
+	documentSigner, signerClosers, err := initDocumentSigner(config, jwsSigner, keyProcessor)
+	if err != nil {
+		return nil, nil, err
+	}
+	closers = append(closers, signerClosers...)
+	jwsVerifier := cypher.NewJWSVerifier(algorithms)
+	schemaValidator, err := adapters.NewDTESchemaValidator()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize DTE schemas: %w", err)
+	}
+	controlNumberRepository, controlNumberClosers, err := initControlNumberRepository(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	closers = append(closers, controlNumberClosers...)
 	logs.Info("Infrastructure components initialized successfully")
 
 	// 3. Initialize domain services
 	logs.Debug("Initializing domain services...")
-	signingService := services.NewSigningService(certificateRepository, jwsSigner)
+	certificateAdminService := services.NewCertificateAdminService(certificateStore)
+	var controlNumberService ports.ControlNumberService
+	if controlNumberRepository != nil {
+		controlNumberService = services.NewControlNumberService(controlNumberRepository)
+	}
+	var ruleValidators []ports.DocumentValidator
+	if config.Validation.Identificacion.Enabled {
+		ruleValidators = append(ruleValidators, services.NewIdentificacionValidator(services.IdentificacionRules{
+			Ambiente: config.Validation.Identificacion.Ambiente,
+			MaxAge:   time.Duration(config.Validation.Identificacion.MaxAge) * time.Hour,
+			MaxAhead: time.Duration(config.Validation.Identificacion.MaxAhead) * time.Minute,
+		}))
+	}
+	if config.Validation.Totals {
+		ruleValidators = append(ruleValidators, services.NewTotalsValidator())
+	}
+	signingService := services.NewSigningService(certificateRepository, documentSigner, jwsVerifier, schemaValidator, controlNumberService, services.SigningPolicy{
+		RequirePublicKeyPassword: config.Signing.RequirePublicPassword,
+		Canonicalize:             config.Signing.Canonicalize,
+		RejectExpired:            config.Expiry.Strict,
+		ValidateSchema:           config.Validation.Schema,
+		EnforceIssuer:            config.Validation.Issuer.Enabled,
+		AllowedIssuers:           config.Validation.Issuer.Allowed,
+		AssignControlNumber:      config.ControlNumbers.Autofill,
+	}, ruleValidators...)
 	logs.Info("Domain services initialized successfully")
 
 	// 4. Initialize application use cases
 	logs.Debug("Initializing application use cases...")
-	documentSigningUseCase := usecases.NewDocumentSigningUseCase(signingService, translator)
-	healthCheckUseCase := usecases.NewHealthCheckUseCase()
+	documentSigningUseCase := usecases.NewDocumentSigningUseCase(signingService, translator, usecases.BatchOptions{
+		Concurrency: config.Signing.BatchConcurrency,
+		MaxItems:    config.Signing.BatchMaxItems,
+	})
+	documentVerificationUseCase := usecases.NewDocumentVerificationUseCase(signingService, translator)
+	certificateAdminUseCase := usecases.NewCertificateAdminUseCase(certificateAdminService, translator)
+	if config.Expiry.Enabled {
+		expiryMonitor := usecases.NewCertificateExpiryMonitor(certificateAdminService, config.Expiry.Thresholds,
+			time.Duration(config.Expiry.Interval)*time.Second)
+		expiryMonitor.Start(context.Background())
+		healthReporters = append(healthReporters, expiryMonitor)
+		closers = append(closers, expiryMonitor)
+
+		// Check again whenever a certificate changes, so the report does not wait for the next interval
+		certificateStore.OnChange(func(nit string) {
+			expiryMonitor.Check(context.Background())
+		})
+	}
+	if config.Preflight.Enabled {
+		preflight := usecases.NewCertificatePreflight(certificateStore, documentSigner, jwsVerifier, keyProcessor)
+		report, err := preflight.Run(context.Background())
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to run certificate preflight: %w", err)
+		}
+		if err := report.Err(); err != nil && config.Preflight.Strict {
+			return nil, nil, err
+		}
+		healthReporters = append(healthReporters, preflight)
+
+		// Check again whenever a certificate changes, so the report describes the stored certificates
+		certificateStore.OnChange(func(nit string) {
+			if _, err := preflight.Run(context.Background()); err != nil {
+				logs.Error("Failed to run certificate preflight:", err)
+			}
+		})
+	}
+	var controlNumberUseCase *usecases.ControlNumberUseCase
+	if controlNumberService != nil {
+		controlNumberUseCase = usecases.NewControlNumberUseCase(controlNumberService, translator)
+	}
+	healthCheckUseCase := usecases.NewHealthCheckUseCase(healthReporters...)
 	logs.Info("Application use cases initialized successfully")
 
 	// 5. Initialize HTTP handlers
 	logs.Debug("Initializing HTTP handlers...")
-	signHandler := handlers.NewSignHandler(documentSigningUseCase, config.Server.SignerRoute)
+	signHandler := handlers.NewSignHandler(documentSigningUseCase, config.Server.SignerRoute, config.Server.MaxBodySize)
+	batchSignHandler := handlers.NewBatchSignHandler(documentSigningUseCase, config.Server.BatchSignerRoute, config.Server.BatchMaxBodySize)
+	verifyHandler := handlers.NewVerifyHandler(documentVerificationUseCase, config.Server.VerifierRoute, config.Server.MaxBodySize)
 	healthHandler := handlers.NewHealthHandler(healthCheckUseCase, config.Server.HealthRoute)
+	certificateAdminHandler := handlers.NewCertificateAdminHandler(certificateAdminUseCase, config.Server.AdminRoute, config.Admin.Token, config.Server.MaxBodySize)
+	var controlNumberHandler *handlers.ControlNumberHandler
+	if controlNumberUseCase != nil {
+		controlNumberHandler = handlers.NewControlNumberHandler(controlNumberUseCase, config.Server.ControlNumberRoute, config.ControlNumbers.Token, config.Server.MaxBodySize)
+	}
 	logs.Info("HTTP handlers initialized successfully")
 
 	// 6. Initialize router and register routes
 	logs.Debug("Initializing router...")
 	router := adapters.NewRouter()
 	router.RegisterHandler(signHandler)
+	router.RegisterHandler(batchSignHandler)
+	router.RegisterHandler(verifyHandler)
 	router.RegisterHandler(healthHandler)
+	if config.Admin.Token != "" {
+		router.RegisterHandler(certificateAdminHandler)
+	} else {
+		logs.Warn("Certificate administration API disabled, set admin.token to enable it")
+	}
+	if controlNumberHandler != nil {
+		if config.ControlNumbers.Token != "" {
+			router.RegisterHandler(controlNumberHandler)
+		} else {
+			logs.Warn("Control number API disabled, set controlnumbers.token to enable it")
+		}
+	}
 	logs.Info("Router initialized successfully")
 
 	// 7. Initialize server
@@ -108,5 +257,167 @@ func initServerDependencies(config *Config) (*server.Server, error) {
 	)
 	logs.Info("Server initialized successfully")
 
-	return httpServer, nil
+	return httpServer, closers, nil
+}
+
+// certificateStore is a certificate store notifying the changes made to its certificates
+type certificateStore interface {
+	ports.CertificateStore
+	OnChange(listener func(nit string))
+}
+
+// initCertificateStore opens the certificate store selected by the storage driver, along with
+// the health reporters and resources to release it brings
+func initCertificateStore(config *Config, keyProcessor *cypher.KeyProcessor, keyEncryption ports.KeyEncryptionService) (certificateStore, []usecases.HealthReporter, []io.Closer, error) {
+	fileRepository := adapters.NewFileCertificateRepository(
+		config.Filesystem.CertificatesDir,
+		keyProcessor,
+		config.Filesystem.KeyPasswords,
+		keyEncryption,
+	)
+
+	if config.Storage.Driver != "sqlite" {
+		if !config.Filesystem.Watch {
+			return fileRepository, nil, nil, nil
+		}
+		if err := fileRepository.Watch(context.Background()); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to watch certificates directory: %w", err)
+		}
+		return fileRepository, []usecases.HealthReporter{fileRepository}, []io.Closer{fileRepository}, nil
+	}
+
+	sqliteRepository, err := adapters.NewSQLiteCertificateRepository(
+		context.Background(),
+		config.Storage.SQLite.Path,
+		time.Duration(config.Storage.SQLite.BusyTimeout)*time.Millisecond,
+		keyProcessor,
+		config.Filesystem.KeyPasswords,
+		keyEncryption,
+	)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to initialize certificates database: %w", err)
+	}
+
+	// Copy the certificates directory into the database, keeping the certificates already stored
+	if config.Storage.SQLite.Import {
+		if _, err := sqliteRepository.Import(context.Background(), fileRepository); err != nil {
+			sqliteRepository.Close()
+			return nil, nil, nil, fmt.Errorf("failed to import certificates directory: %w", err)
+		}
+	}
+
+	// Notify the changes made by other replicas sharing the database
+	if config.Storage.SQLite.PollInterval > 0 {
+		if err := sqliteRepository.Watch(context.Background(), time.Duration(config.Storage.SQLite.PollInterval)*time.Millisecond); err != nil {
+			sqliteRepository.Close()
+			return nil, nil, nil, fmt.Errorf("failed to watch certificates database: %w", err)
+		}
+	}
+
+	return sqliteRepository, []usecases.HealthReporter{sqliteRepository}, []io.Closer{sqliteRepository}, nil
+}
+
+// initControlNumberRepository opens the repository of the numeroControl correlatives selected by its
+// driver, along with the resources to release it, or nil when the correlatives are disabled
+func initControlNumberRepository(config *Config) (ports.ControlNumberRepository, []io.Closer, error) {
+	if !config.ControlNumbers.Enabled {
+		return nil, nil, nil
+	}
+
+	if config.ControlNumbers.Driver != "sqlite" {
+		repository, err := adapters.NewFileControlNumberRepository(config.ControlNumbers.Path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize control numbers: %w", err)
+		}
+		logs.Info("Control numbers file:", config.ControlNumbers.Path)
+		return repository, nil, nil
+	}
+
+	// Correlatives are kept in the certificates database, so replicas sharing it share them
+	repository, err := adapters.NewSQLiteControlNumberRepository(
+		context.Background(),
+		config.Storage.SQLite.Path,
+		time.Duration(config.Storage.SQLite.BusyTimeout)*time.Millisecond,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize control numbers: %w", err)
+	}
+	return repository, []io.Closer{repository}, nil
+}
+
+// initKeyEncryption creates the service encrypting certificates at rest, or nil when encryption is disabled
+func initKeyEncryption(config *Config) (ports.KeyEncryptionService, error) {
+	if !config.Encryption.Enabled {
+		return nil, nil
+	}
+
+	keyring, err := cypher.LoadKeyring(config.Encryption.Keyring, config.Encryption.MasterKey, config.Encryption.MasterKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load master keys: %w", err)
+	}
+	keyEncryption, err := cypher.NewLocalKeyEncryptionService(keyring)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize certificate encryption: %w", err)
+	}
+
+	logs.Info("Certificate encryption enabled with master key:", keyEncryption.ActiveKeyID())
+	return keyEncryption, nil
+}
+
+// initDocumentSigner returns the document signer. Certificates mapped to a key of the PKCS#11
+// token or of the transit service sign through that key, the rest with their own key.
+func initDocumentSigner(config *Config, jwsSigner *cypher.JWSSigner, keyProcessor *cypher.KeyProcessor) (ports.DocumentSigner, []io.Closer, error) {
+	var documentSigner ports.DocumentSigner = jwsSigner
+	var closers []io.Closer
+
+	// 1: Keys held on a PKCS#11 token
+	if config.PKCS11.Enabled {
+		keyStore, err := cypher.NewPKCS11KeyStore(cypher.PKCS11Options{
+			Module:      config.PKCS11.Module,
+			TokenLabel:  config.PKCS11.TokenLabel,
+			Pin:         config.PKCS11.Pin,
+			MaxSessions: config.PKCS11.MaxSessions,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize PKCS#11 token: %w", err)
+		}
+
+		// Fail on startup rather than on the first document of a NIT whose key is missing
+		for key, label := range config.PKCS11.Keys {
+			if _, err := keyStore.Signer(label); err != nil {
+				keyStore.Close()
+				return nil, nil, fmt.Errorf("failed to load PKCS#11 key of %s: %w", key, err)
+			}
+		}
+
+		logs.Info(fmt.Sprintf("PKCS#11 token %s ready with %d key(s)", config.PKCS11.TokenLabel, len(config.PKCS11.Keys)))
+		documentSigner = cypher.NewExternalKeySigner(documentSigner, keyStore, config.PKCS11.Keys, keyProcessor)
+		closers = append(closers, keyStore)
+	}
+
+	// 2: Keys held by a remote transit service
+	if config.Transit.Enabled {
+		keyStore := cypher.NewTransitKeyStore(cypher.TransitOptions{
+			Address:      config.Transit.Address,
+			Mount:        config.Transit.Mount,
+			Token:        config.Transit.Token,
+			Timeout:      time.Duration(config.Transit.Timeout) * time.Millisecond,
+			Retries:      config.Transit.Retries,
+			RetryBackoff: time.Duration(config.Transit.RetryBackoff) * time.Millisecond,
+			KeyRefresh:   time.Duration(config.Transit.KeyRefresh) * time.Millisecond,
+		})
+
+		// The service may become reachable later, so missing keys are only reported
+		for key, name := range config.Transit.Keys {
+			if _, err := keyStore.Signer(name); err != nil {
+				logs.Warn(fmt.Sprintf("Transit key of %s unavailable: %v", key, err))
+			}
+		}
+
+		logs.Info(fmt.Sprintf("Transit signing enabled at %s with %d key(s)", config.Transit.Address, len(config.Transit.Keys)))
+		documentSigner = cypher.NewExternalKeySigner(documentSigner, keyStore, config.Transit.Keys, keyProcessor)
+		closers = append(closers, keyStore)
+	}
+
+	return documentSigner, closers, nil
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/chainedpixel/go-dte-signer/pkg/logs"
@@ -12,19 +13,37 @@ import (
 
 // Config holds all configuration for the application
 type Config struct {
-	Server     ServerConfig     `mapstructure:"server"`
-	Locale     LocaleConfig     `mapstructure:"locale"`
-	Filesystem FilesystemConfig `mapstructure:"filesystem"`
-	Log        LogConfig        `mapstructure:"log"`
+	Server         ServerConfig         `mapstructure:"server"`
+	Locale         LocaleConfig         `mapstructure:"locale"`
+	Filesystem     FilesystemConfig     `mapstructure:"filesystem"`
+	Storage        StorageConfig        `mapstructure:"storage"`
+	Encryption     EncryptionConfig     `mapstructure:"encryption"`
+	PKCS11         PKCS11Config         `mapstructure:"pkcs11"`
+	Transit        TransitConfig        `mapstructure:"transit"`
+	Signing        SigningConfig        `mapstructure:"signing"`
+	Validation     ValidationConfig     `mapstructure:"validation"`
+	ControlNumbers ControlNumbersConfig `mapstructure:"controlnumbers"`
+	JWS            JWSConfig            `mapstructure:"jws"`
+	Cache          CacheConfig          `mapstructure:"cache"`
+	Admin          AdminConfig          `mapstructure:"admin"`
+	Expiry         ExpiryConfig         `mapstructure:"expiry"`
+	Preflight      PreflightConfig      `mapstructure:"preflight"`
+	Log            LogConfig            `mapstructure:"log"`
 }
 
 // ServerConfig holds server-related configuration
 type ServerConfig struct {
-	Port         string `mapstructure:"port"`
-	SignerRoute  string `mapstructure:"signerroute"`
-	HealthRoute  string `mapstructure:"healthroute"`
-	ReadTimeout  int    `mapstructure:"readtimeout"`
-	WriteTimeout int    `mapstructure:"writetimeout"`
+	Port               string `mapstructure:"port"`
+	SignerRoute        string `mapstructure:"signerroute"`
+	BatchSignerRoute   string `mapstructure:"batchsignerroute"`
+	VerifierRoute      string `mapstructure:"verifierroute"`
+	AdminRoute         string `mapstructure:"adminroute"`
+	ControlNumberRoute string `mapstructure:"controlnumberroute"`
+	HealthRoute        string `mapstructure:"healthroute"`
+	ReadTimeout        int    `mapstructure:"readtimeout"`
+	WriteTimeout       int    `mapstructure:"writetimeout"`
+	MaxBodySize        int64  `mapstructure:"maxbodysize"`
+	BatchMaxBodySize   int64  `mapstructure:"batchmaxbodysize"`
 }
 
 // LocaleConfig holds localization configuration
@@ -35,7 +54,131 @@ type LocaleConfig struct {
 
 // FilesystemConfig holds filesystem configuration
 type FilesystemConfig struct {
-	CertificatesDir string `mapstructure:"certificatesdir"`
+	CertificatesDir string            `mapstructure:"certificatesdir"`
+	Watch           bool              `mapstructure:"watch"`
+	KeyPasswords    map[string]string `mapstructure:"keypasswords"`
+}
+
+// StorageConfig holds the certificate storage configuration
+type StorageConfig struct {
+	Driver string       `mapstructure:"driver"`
+	SQLite SQLiteConfig `mapstructure:"sqlite"`
+}
+
+// SQLiteConfig holds the SQLite certificate database configuration
+type SQLiteConfig struct {
+	Path         string `mapstructure:"path"`
+	BusyTimeout  int    `mapstructure:"busytimeout"`
+	Import       bool   `mapstructure:"import"`
+	PollInterval int    `mapstructure:"pollinterval"`
+}
+
+// EncryptionConfig holds the configuration of the encryption of certificates at rest
+type EncryptionConfig struct {
+	Enabled     bool   `mapstructure:"enabled"`
+	Keyring     string `mapstructure:"keyring"`
+	MasterKey   string `mapstructure:"masterkey"`
+	MasterKeyID string `mapstructure:"masterkeyid"`
+}
+
+// PKCS11Config holds the configuration of the PKCS#11 token holding signing keys
+type PKCS11Config struct {
+	Enabled     bool              `mapstructure:"enabled"`
+	Module      string            `mapstructure:"module"`
+	TokenLabel  string            `mapstructure:"tokenlabel"`
+	Pin         string            `mapstructure:"pin"`
+	MaxSessions int               `mapstructure:"maxsessions"`
+	Keys        map[string]string `mapstructure:"keys"`
+}
+
+// TransitConfig holds the configuration of the remote key service speaking the Vault Transit API
+type TransitConfig struct {
+	Enabled      bool              `mapstructure:"enabled"`
+	Address      string            `mapstructure:"address"`
+	Mount        string            `mapstructure:"mount"`
+	Token        string            `mapstructure:"token"`
+	Timeout      int               `mapstructure:"timeout"`
+	Retries      int               `mapstructure:"retries"`
+	RetryBackoff int               `mapstructure:"retrybackoff"`
+	KeyRefresh   int               `mapstructure:"keyrefresh"`
+	Keys         map[string]string `mapstructure:"keys"`
+}
+
+// SigningConfig holds signing policy configuration
+type SigningConfig struct {
+	RequirePublicPassword bool              `mapstructure:"requirepublicpassword"`
+	Algorithms            map[string]string `mapstructure:"algorithms"`
+	NITAlgorithms         map[string]string `mapstructure:"nitalgorithms"`
+	BatchConcurrency      int               `mapstructure:"batchconcurrency"`
+	BatchMaxItems         int               `mapstructure:"batchmaxitems"`
+	Canonicalize          bool              `mapstructure:"canonicalize"`
+}
+
+// ValidationConfig holds the checks applied to documents before they are signed
+type ValidationConfig struct {
+	Schema         bool                 `mapstructure:"schema"`
+	Identificacion IdentificacionConfig `mapstructure:"identificacion"`
+	Issuer         IssuerConfig         `mapstructure:"issuer"`
+	Totals         bool                 `mapstructure:"totals"`
+}
+
+// IdentificacionConfig holds the consistency checks of the identificacion block of documents
+type IdentificacionConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	Ambiente string `mapstructure:"ambiente"`
+	MaxAge   int    `mapstructure:"maxage"`
+	MaxAhead int    `mapstructure:"maxahead"`
+}
+
+// ControlNumbersConfig holds the configuration of the persistent numeroControl correlatives
+type ControlNumbersConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	Driver   string `mapstructure:"driver"`
+	Path     string `mapstructure:"path"`
+	Token    string `mapstructure:"token"`
+	Autofill bool   `mapstructure:"autofill"`
+}
+
+// JWSConfig holds the protected headers emitted with every signature
+type JWSConfig struct {
+	IncludeKeyID      bool                              `mapstructure:"includekid"`
+	Type              string                            `mapstructure:"type"`
+	IncludeThumbprint bool                              `mapstructure:"includethumbprint"`
+	IncludeTimestamp  bool                              `mapstructure:"includetimestamp"`
+	Headers           map[string]interface{}            `mapstructure:"headers"`
+	NITHeaders        map[string]map[string]interface{} `mapstructure:"nitheaders"`
+}
+
+// CacheConfig holds the in-memory certificate and signer cache configuration
+type CacheConfig struct {
+	Enabled    bool `mapstructure:"enabled"`
+	TTL        int  `mapstructure:"ttl"`
+	MaxEntries int  `mapstructure:"maxentries"`
+}
+
+// AdminConfig holds the certificate administration API configuration
+type AdminConfig struct {
+	Token string `mapstructure:"token"`
+}
+
+// ExpiryConfig holds the certificate expiry monitoring configuration
+type ExpiryConfig struct {
+	Enabled    bool  `mapstructure:"enabled"`
+	Interval   int   `mapstructure:"interval"`
+	Thresholds []int `mapstructure:"thresholds"`
+	Strict     bool  `mapstructure:"strict"`
+}
+
+// PreflightConfig holds the configuration of the certificate self-test run on startup
+type PreflightConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	Strict  bool `mapstructure:"strict"`
+}
+
+// IssuerConfig holds the check that documents are issued by the NIT signing them
+type IssuerConfig struct {
+	Enabled bool                `mapstructure:"enabled"`
+	Allowed map[string][]string `mapstructure:"allowed"`
 }
 
 // LogConfig holds logging configuration
@@ -52,12 +195,73 @@ func LoadConfig() (*Config, bool, error) {
 	// Set default values
 	v.SetDefault("server.port", "8113")
 	v.SetDefault("server.signerroute", "/signer")
+	v.SetDefault("server.batchsignerroute", "/sign/batch")
+	v.SetDefault("server.verifierroute", "/verify")
+	v.SetDefault("server.adminroute", "/admin/certificates")
+	v.SetDefault("server.controlnumberroute", "/control-numbers")
 	v.SetDefault("server.healthroute", "/health")
 	v.SetDefault("server.readtimeout", 15)
 	v.SetDefault("server.writetimeout", 15)
+	v.SetDefault("server.maxbodysize", 1<<20)
+	v.SetDefault("server.batchmaxbodysize", 32<<20)
 	v.SetDefault("locale.defaultlocale", "es")
 	v.SetDefault("locale.localesdir", "./configs/locales")
 	v.SetDefault("filesystem.certificatesdir", "./uploads/test/")
+	v.SetDefault("filesystem.watch", true)
+	v.SetDefault("storage.driver", "filesystem")
+	v.SetDefault("storage.sqlite.path", "./data/certificates.db")
+	v.SetDefault("storage.sqlite.busytimeout", 5000)
+	v.SetDefault("storage.sqlite.import", false)
+	v.SetDefault("storage.sqlite.pollinterval", 5000)
+	v.SetDefault("encryption.enabled", false)
+	v.SetDefault("encryption.keyring", "")
+	v.SetDefault("encryption.masterkey", "")
+	v.SetDefault("encryption.masterkeyid", "default")
+	v.SetDefault("pkcs11.enabled", false)
+	v.SetDefault("pkcs11.module", "")
+	v.SetDefault("pkcs11.tokenlabel", "")
+	v.SetDefault("pkcs11.pin", "")
+	v.SetDefault("pkcs11.maxsessions", 0)
+	v.SetDefault("transit.enabled", false)
+	v.SetDefault("transit.address", "http://127.0.0.1:8200")
+	v.SetDefault("transit.mount", "transit")
+	v.SetDefault("transit.token", "")
+	v.SetDefault("transit.timeout", 5000)
+	v.SetDefault("transit.retries", 2)
+	v.SetDefault("transit.retrybackoff", 200)
+	v.SetDefault("transit.keyrefresh", 300000)
+	v.SetDefault("signing.requirepublicpassword", false)
+	v.SetDefault("signing.algorithms.rsa", "RS512")
+	v.SetDefault("signing.algorithms.ed25519", "EdDSA")
+	v.SetDefault("signing.batchconcurrency", 0)
+	v.SetDefault("signing.batchmaxitems", 1000)
+	v.SetDefault("signing.canonicalize", false)
+	v.SetDefault("validation.schema", false)
+	v.SetDefault("validation.identificacion.enabled", false)
+	v.SetDefault("validation.identificacion.ambiente", "00")
+	v.SetDefault("validation.identificacion.maxage", 72)
+	v.SetDefault("validation.identificacion.maxahead", 10)
+	v.SetDefault("validation.issuer.enabled", false)
+	v.SetDefault("validation.totals", false)
+	v.SetDefault("controlnumbers.enabled", false)
+	v.SetDefault("controlnumbers.driver", "file")
+	v.SetDefault("controlnumbers.path", "./data/control-numbers.json")
+	v.SetDefault("controlnumbers.token", "")
+	v.SetDefault("controlnumbers.autofill", false)
+	v.SetDefault("jws.includekid", false)
+	v.SetDefault("jws.type", "")
+	v.SetDefault("jws.includethumbprint", false)
+	v.SetDefault("jws.includetimestamp", false)
+	v.SetDefault("cache.enabled", true)
+	v.SetDefault("cache.ttl", 300)
+	v.SetDefault("cache.maxentries", 1000)
+	v.SetDefault("admin.token", "")
+	v.SetDefault("expiry.enabled", true)
+	v.SetDefault("expiry.interval", 3600)
+	v.SetDefault("expiry.thresholds", []int{30, 15, 7})
+	v.SetDefault("expiry.strict", false)
+	v.SetDefault("preflight.enabled", true)
+	v.SetDefault("preflight.strict", false)
 	v.SetDefault("log.level", "info")
 	v.SetDefault("log.format", "text")
 	v.SetDefault("log.dir", "./logs")
@@ -114,6 +318,11 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("server port is required")
 	}
 
+	// Validate expiry configuration
+	if config.Expiry.Enabled && config.Expiry.Interval <= 0 {
+		return fmt.Errorf("expiry check interval must be positive")
+	}
+
 	// Validate locale configuration
 	if config.Locale.DefaultLocale == "" {
 		return fmt.Errorf("default locale is required")
@@ -134,16 +343,119 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("failed to create certificates directory: %w", err)
 	}
 
+	// Validate storage configuration and ensure the database directory exists
+	switch config.Storage.Driver {
+	case "filesystem":
+	case "sqlite":
+		if config.Storage.SQLite.Path == "" {
+			return fmt.Errorf("sqlite database path is required")
+		}
+		if config.Storage.SQLite.BusyTimeout < 0 {
+			return fmt.Errorf("sqlite busy timeout must not be negative")
+		}
+		if config.Storage.SQLite.PollInterval < 0 {
+			return fmt.Errorf("sqlite poll interval must not be negative")
+		}
+		if err := os.MkdirAll(filepath.Dir(config.Storage.SQLite.Path), 0755); err != nil {
+			return fmt.Errorf("failed to create database directory: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown storage driver: %s", config.Storage.Driver)
+	}
+
+	// Validate encryption configuration
+	if config.Encryption.Enabled && config.Encryption.Keyring == "" && config.Encryption.MasterKey == "" {
+		return fmt.Errorf("encryption requires a keyring file or a master key")
+	}
+
+	// Validate PKCS#11 configuration
+	if config.PKCS11.Enabled && (config.PKCS11.Module == "" || config.PKCS11.TokenLabel == "") {
+		return fmt.Errorf("pkcs11 requires a module and a token label")
+	}
+
+	// Validate transit configuration, a certificate signs through a single external key
+	if config.Transit.Enabled {
+		if config.Transit.Address == "" || config.Transit.Timeout <= 0 || config.Transit.Retries < 0 || config.Transit.KeyRefresh < 0 {
+			return fmt.Errorf("transit requires an address, a positive timeout, non-negative retries and a non-negative key refresh")
+		}
+		if config.PKCS11.Enabled {
+			for key := range config.Transit.Keys {
+				if _, ok := config.PKCS11.Keys[key]; ok {
+					return fmt.Errorf("%s has both a PKCS#11 and a transit key", key)
+				}
+			}
+		}
+	}
+
+	// Validate control numbers configuration
+	if config.ControlNumbers.Enabled {
+		switch config.ControlNumbers.Driver {
+		case "file":
+			if config.ControlNumbers.Path == "" {
+				return fmt.Errorf("control numbers file path is required")
+			}
+		case "sqlite":
+			if config.Storage.SQLite.Path == "" {
+				return fmt.Errorf("sqlite database path is required")
+			}
+			if err := os.MkdirAll(filepath.Dir(config.Storage.SQLite.Path), 0755); err != nil {
+				return fmt.Errorf("failed to create database directory: %w", err)
+			}
+		default:
+			return fmt.Errorf("unknown control numbers driver: %s", config.ControlNumbers.Driver)
+		}
+	}
+
+	// Validate identificacion checks configuration
+	if config.Validation.Identificacion.Enabled {
+		if ambiente := config.Validation.Identificacion.Ambiente; ambiente != "00" && ambiente != "01" {
+			return fmt.Errorf("validation ambiente must be \"00\" or \"01\", got %q", ambiente)
+		}
+		if config.Validation.Identificacion.MaxAge <= 0 || config.Validation.Identificacion.MaxAhead < 0 {
+			return fmt.Errorf("validation requires a positive maxage and a non-negative maxahead")
+		}
+	}
+
 	return nil
 }
 
 // logConfigDetails logs the configuration details
 func logConfigDetails(config *Config) {
 	logs.Debug("Configuration loaded successfully")
-	logs.Debug(fmt.Sprintf("Server configuration: port=%s, readTimeout=%d, writeTimeout=%d",
-		config.Server.Port, config.Server.ReadTimeout, config.Server.WriteTimeout))
+	logs.Debug(fmt.Sprintf("Server configuration: port=%s, readTimeout=%d, writeTimeout=%d, maxBodySize=%d, batchMaxBodySize=%d",
+		config.Server.Port, config.Server.ReadTimeout, config.Server.WriteTimeout, config.Server.MaxBodySize, config.Server.BatchMaxBodySize))
 	logs.Debug(fmt.Sprintf("Locale configuration: defaultLocale=%s, localesDir=%s",
 		config.Locale.DefaultLocale, config.Locale.LocalesDir))
-	logs.Debug(fmt.Sprintf("Filesystem configuration: certificatesDir=%s",
-		config.Filesystem.CertificatesDir))
+	logs.Debug(fmt.Sprintf("Filesystem configuration: certificatesDir=%s, watch=%t",
+		config.Filesystem.CertificatesDir, config.Filesystem.Watch))
+	logs.Debug(fmt.Sprintf("Storage configuration: driver=%s, sqlitePath=%s, busyTimeout=%d, import=%t, pollInterval=%d",
+		config.Storage.Driver, config.Storage.SQLite.Path, config.Storage.SQLite.BusyTimeout, config.Storage.SQLite.Import,
+		config.Storage.SQLite.PollInterval))
+	logs.Debug(fmt.Sprintf("Encryption configuration: enabled=%t, keyring=%s, masterKeyID=%s, masterKey=%t",
+		config.Encryption.Enabled, config.Encryption.Keyring, config.Encryption.MasterKeyID, config.Encryption.MasterKey != ""))
+	logs.Debug(fmt.Sprintf("PKCS#11 configuration: enabled=%t, module=%s, tokenLabel=%s, maxSessions=%d, keys=%v",
+		config.PKCS11.Enabled, config.PKCS11.Module, config.PKCS11.TokenLabel, config.PKCS11.MaxSessions, config.PKCS11.Keys))
+	logs.Debug(fmt.Sprintf("Transit configuration: enabled=%t, address=%s, mount=%s, timeout=%d, retries=%d, retryBackoff=%d, keyRefresh=%d, keys=%v",
+		config.Transit.Enabled, config.Transit.Address, config.Transit.Mount, config.Transit.Timeout,
+		config.Transit.Retries, config.Transit.RetryBackoff, config.Transit.KeyRefresh, config.Transit.Keys))
+	logs.Debug(fmt.Sprintf("Signing configuration: requirePublicPassword=%t, algorithms=%v, nitAlgorithms=%v, batchConcurrency=%d, batchMaxItems=%d, canonicalize=%t",
+		config.Signing.RequirePublicPassword, config.Signing.Algorithms, config.Signing.NITAlgorithms,
+		config.Signing.BatchConcurrency, config.Signing.BatchMaxItems, config.Signing.Canonicalize))
+	logs.Debug(fmt.Sprintf("Validation configuration: schema=%t, identificacion=%t, ambiente=%s, maxAge=%d, maxAhead=%d, totals=%t",
+		config.Validation.Schema, config.Validation.Identificacion.Enabled, config.Validation.Identificacion.Ambiente,
+		config.Validation.Identificacion.MaxAge, config.Validation.Identificacion.MaxAhead, config.Validation.Totals))
+	logs.Debug(fmt.Sprintf("Issuer configuration: enabled=%t, allowed=%v",
+		config.Validation.Issuer.Enabled, config.Validation.Issuer.Allowed))
+	logs.Debug(fmt.Sprintf("Control numbers configuration: enabled=%t, driver=%s, path=%s, route=%s, autofill=%t, api=%t",
+		config.ControlNumbers.Enabled, config.ControlNumbers.Driver, config.ControlNumbers.Path,
+		config.Server.ControlNumberRoute, config.ControlNumbers.Autofill, config.ControlNumbers.Token != ""))
+	logs.Debug(fmt.Sprintf("JWS configuration: includeKid=%t, type=%s, includeThumbprint=%t, includeTimestamp=%t",
+		config.JWS.IncludeKeyID, config.JWS.Type, config.JWS.IncludeThumbprint, config.JWS.IncludeTimestamp))
+	logs.Debug(fmt.Sprintf("Cache configuration: enabled=%t, ttl=%d, maxEntries=%d",
+		config.Cache.Enabled, config.Cache.TTL, config.Cache.MaxEntries))
+	logs.Debug(fmt.Sprintf("Admin configuration: route=%s, enabled=%t",
+		config.Server.AdminRoute, config.Admin.Token != ""))
+	logs.Debug(fmt.Sprintf("Expiry configuration: enabled=%t, interval=%d, thresholds=%v, strict=%t",
+		config.Expiry.Enabled, config.Expiry.Interval, config.Expiry.Thresholds, config.Expiry.Strict))
+	logs.Debug(fmt.Sprintf("Preflight configuration: enabled=%t, strict=%t", config.Preflight.Enabled, config.Preflight.Strict))
 }
@@ -0,0 +1,80 @@
+package usecases
+
+import (
+	"context"
+	"time"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/ports"
+	"github.com/chainedpixel/go-dte-signer/pkg/i18n"
+	"github.com/chainedpixel/go-dte-signer/pkg/response"
+)
+
+// CertificateAdminUseCase handles certificate administration operations
+type CertificateAdminUseCase struct {
+	adminService ports.CertificateAdminService
+	translator   *i18n.Translator
+}
+
+// NewCertificateAdminUseCase creates a new certificate administration use case
+func NewCertificateAdminUseCase(adminService ports.CertificateAdminService, translator *i18n.Translator) *CertificateAdminUseCase {
+	return &CertificateAdminUseCase{
+		adminService: adminService,
+		translator:   translator,
+	}
+}
+
+// CertificateDeletedOutput represents the response of a deleted certificate
+type CertificateDeletedOutput struct {
+	NIT     string `json:"nit"`
+	ID      string `json:"_id,omitempty"`
+	Deleted bool   `json:"deleted"`
+}
+
+// CertificateExpiryInput represents the expiry date set by an administrator; null clears it
+type CertificateExpiryInput struct {
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// List returns a summary of every stored certificate
+func (uc *CertificateAdminUseCase) List(ctx context.Context) (*response.Response, error) {
+	summaries, err := uc.adminService.ListCertificates(ctx)
+	if err != nil {
+		return newErrorResponse(uc.translator, err), nil
+	}
+	return response.NewSuccessResponse(summaries), nil
+}
+
+// Upload stores a certificate file, replacing the existing certificate of its NIT only when replace is set
+func (uc *CertificateAdminUseCase) Upload(ctx context.Context, content []byte, replace bool) (*response.Response, error) {
+	summary, err := uc.adminService.UploadCertificate(ctx, content, replace)
+	if err != nil {
+		return newErrorResponse(uc.translator, err), nil
+	}
+	return response.NewSuccessResponse(summary), nil
+}
+
+// SetActive activates or deactivates a certificate of a NIT
+func (uc *CertificateAdminUseCase) SetActive(ctx context.Context, nit string, id string, active bool) (*response.Response, error) {
+	summary, err := uc.adminService.SetCertificateActive(ctx, nit, id, active)
+	if err != nil {
+		return newErrorResponse(uc.translator, err), nil
+	}
+	return response.NewSuccessResponse(summary), nil
+}
+
+// SetExpiry sets or clears the expiry date of a certificate of a NIT
+func (uc *CertificateAdminUseCase) SetExpiry(ctx context.Context, nit string, id string, input CertificateExpiryInput) (*response.Response, error) {
+	summary, err := uc.adminService.SetCertificateExpiry(ctx, nit, id, input.ExpiresAt)
+	if err != nil {
+		return newErrorResponse(uc.translator, err), nil
+	}
+	return response.NewSuccessResponse(summary), nil
+}
+
+// Delete removes a certificate of a NIT
+func (uc *CertificateAdminUseCase) Delete(ctx context.Context, nit string, id string) (*response.Response, error) {
+	if err := uc.adminService.DeleteCertificate(ctx, nit, id); err != nil {
+		return newErrorResponse(uc.translator, err), nil
+	}
+	return response.NewSuccessResponse(&CertificateDeletedOutput{NIT: nit, ID: id, Deleted: true}), nil
+}
@@ -0,0 +1,179 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/ports"
+	"github.com/chainedpixel/go-dte-signer/pkg/logs"
+)
+
+// CertificateExpiryMonitor periodically checks the expiry date of the active certificates and
+// logs a warning each time one of them crosses a threshold
+type CertificateExpiryMonitor struct {
+	adminService ports.CertificateAdminService
+	thresholds   []int
+	interval     time.Duration
+
+	mutex  sync.Mutex
+	report CertificateExpiryReport
+	warned map[string]int
+	stop   chan struct{}
+}
+
+// CertificateExpiryReport lists the active certificates expiring within the largest threshold
+type CertificateExpiryReport struct {
+	Thresholds []int                 `json:"thresholds"`
+	CheckedAt  time.Time             `json:"checkedAt"`
+	Expiring   []ExpiringCertificate `json:"expiring"`
+}
+
+// ExpiringCertificate describes a certificate close to, or past, its expiry date
+type ExpiringCertificate struct {
+	NIT       string    `json:"nit"`
+	ID        string    `json:"_id"`
+	File      string    `json:"file,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	DaysLeft  int       `json:"daysLeft"`
+	Expired   bool      `json:"expired"`
+}
+
+// NewCertificateExpiryMonitor creates a new expiry monitor warning at the given number of days before expiry
+func NewCertificateExpiryMonitor(adminService ports.CertificateAdminService, thresholds []int, interval time.Duration) *CertificateExpiryMonitor {
+	sorted := append([]int{}, thresholds...)
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+
+	return &CertificateExpiryMonitor{
+		adminService: adminService,
+		thresholds:   sorted,
+		interval:     interval,
+		report:       CertificateExpiryReport{Thresholds: sorted, Expiring: []ExpiringCertificate{}},
+		warned:       make(map[string]int),
+	}
+}
+
+// Start checks the certificates right away and then on every interval until Close is called
+func (m *CertificateExpiryMonitor) Start(ctx context.Context) {
+	m.Check(ctx)
+
+	m.mutex.Lock()
+	m.stop = make(chan struct{})
+	stop := m.stop
+	m.mutex.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.Check(ctx)
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the periodic checks
+func (m *CertificateExpiryMonitor) Close() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+	return nil
+}
+
+// Check updates the report with the certificates expiring within the largest threshold and
+// logs the ones that crossed a threshold since the previous check
+func (m *CertificateExpiryMonitor) Check(ctx context.Context) {
+	// 1: List the stored certificates
+	summaries, err := m.adminService.ListCertificates(ctx)
+	if err != nil {
+		logs.Error("Failed to check certificate expiry:", err)
+		return
+	}
+
+	// 2: Keep the active certificates within the largest threshold
+	now := time.Now()
+	expiring := []ExpiringCertificate{}
+	for _, summary := range summaries {
+		if !summary.Valid || !summary.Active || summary.ExpiresAt == nil {
+			continue
+		}
+		daysLeft := int(summary.ExpiresAt.Sub(now).Hours() / 24)
+		if summary.Expired || m.level(daysLeft) >= 0 {
+			expiring = append(expiring, ExpiringCertificate{
+				NIT:       summary.NIT,
+				ID:        summary.ID,
+				File:      summary.File,
+				ExpiresAt: *summary.ExpiresAt,
+				DaysLeft:  daysLeft,
+				Expired:   summary.Expired,
+			})
+		}
+	}
+	sort.Slice(expiring, func(i, j int) bool {
+		return expiring[i].ExpiresAt.Before(expiring[j].ExpiresAt)
+	})
+
+	// 3: Log the certificates whose threshold changed and update the report
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	warned := make(map[string]int, len(expiring))
+	for _, certificate := range expiring {
+		key := fmt.Sprintf("%s|%s|%s|%s", certificate.NIT, certificate.ID, certificate.File, certificate.ExpiresAt)
+		level := len(m.thresholds)
+		if !certificate.Expired {
+			level = m.level(certificate.DaysLeft)
+		}
+		if previous, ok := m.warned[key]; !ok || previous != level {
+			m.warn(certificate)
+		}
+		warned[key] = level
+	}
+	m.warned = warned
+	m.report = CertificateExpiryReport{Thresholds: m.thresholds, CheckedAt: now, Expiring: expiring}
+}
+
+// HealthName returns the key under which the expiring certificates are reported in the health check
+func (m *CertificateExpiryMonitor) HealthName() string {
+	return "certificateExpiry"
+}
+
+// HealthDetails returns the report of the last check for the health check
+func (m *CertificateExpiryMonitor) HealthDetails(ctx context.Context) interface{} {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.report
+}
+
+// level returns the index of the smallest threshold reached by the days left, or -1 when none is
+func (m *CertificateExpiryMonitor) level(daysLeft int) int {
+	level := -1
+	for i, threshold := range m.thresholds {
+		if daysLeft <= threshold {
+			level = i
+		}
+	}
+	return level
+}
+
+// warn logs an expiring or expired certificate
+func (m *CertificateExpiryMonitor) warn(certificate ExpiringCertificate) {
+	if certificate.Expired {
+		logs.Error(fmt.Sprintf("Certificate %s of NIT %s expired on %s", certificate.ID, certificate.NIT,
+			certificate.ExpiresAt.Format(time.RFC3339)))
+		return
+	}
+	logs.Warn(fmt.Sprintf("Certificate %s of NIT %s expires in %d day(s), on %s", certificate.ID, certificate.NIT,
+		certificate.DaysLeft, certificate.ExpiresAt.Format(time.RFC3339)))
+}
@@ -0,0 +1,161 @@
+package usecases
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/pkg/logs"
+)
+
+// summaryAdminService lists a fixed set of certificate summaries, counting the listings
+type summaryAdminService struct {
+	mu        sync.Mutex
+	summaries []models.CertificateSummary
+	listings  int
+}
+
+func (s *summaryAdminService) ListCertificates(ctx context.Context) ([]models.CertificateSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listings++
+	return append([]models.CertificateSummary{}, s.summaries...), nil
+}
+
+func (s *summaryAdminService) UploadCertificate(ctx context.Context, content []byte, replace bool) (*models.CertificateSummary, error) {
+	return nil, nil
+}
+
+func (s *summaryAdminService) SetCertificateActive(ctx context.Context, nit string, id string, active bool) (*models.CertificateSummary, error) {
+	return nil, nil
+}
+
+func (s *summaryAdminService) SetCertificateExpiry(ctx context.Context, nit string, id string, expiresAt *time.Time) (*models.CertificateSummary, error) {
+	return nil, nil
+}
+
+func (s *summaryAdminService) DeleteCertificate(ctx context.Context, nit string, id string) error {
+	return nil
+}
+
+func (s *summaryAdminService) setSummaries(summaries ...models.CertificateSummary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.summaries = summaries
+}
+
+// expiringSummary returns a valid certificate summary expiring in the given number of days, plus an hour
+func expiringSummary(id string, days int, active bool) models.CertificateSummary {
+	expiresAt := time.Now().Add(time.Duration(days)*24*time.Hour + time.Hour)
+	return models.CertificateSummary{NIT: "06140101780010", ID: id, Active: active, Valid: true, ExpiresAt: &expiresAt}
+}
+
+// captureLogs records the warnings and errors logged from now on
+func captureLogs(t *testing.T) *test.Hook {
+	t.Helper()
+	if err := logs.InitLogger("warn"); err != nil {
+		t.Fatal(err)
+	}
+	return test.NewLocal(logs.Logger)
+}
+
+// loggedAt returns the messages recorded at a level
+func loggedAt(hook *test.Hook, level logrus.Level) []string {
+	var messages []string
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == level {
+			messages = append(messages, entry.Message)
+		}
+	}
+	return messages
+}
+
+// expiredSummary returns a valid certificate summary that expired two days ago
+func expiredSummary(id string) models.CertificateSummary {
+	expiresAt := time.Now().Add(-48 * time.Hour)
+	return models.CertificateSummary{NIT: "06140101780010", ID: id, Active: true, Valid: true, ExpiresAt: &expiresAt, Expired: true}
+}
+
+func TestCertificateExpiryMonitorCheck(t *testing.T) {
+	hook := captureLogs(t)
+	ctx := context.Background()
+	expired := expiredSummary("expired")
+	later := expiringSummary("later", 20, true)
+	invalid := expiringSummary("invalid", 1, true)
+	invalid.Valid = false
+	unbounded := models.CertificateSummary{NIT: "06140101780010", ID: "unbounded", Active: true, Valid: true}
+	service := &summaryAdminService{}
+	service.setSummaries(later, expiringSummary("distant", 90, true), expiringSummary("soon", 5, true),
+		expiringSummary("inactive", 1, false), invalid, unbounded, expired)
+	monitor := NewCertificateExpiryMonitor(service, []int{7, 30, 1}, time.Hour)
+
+	// 1: The active certificates within the largest threshold are reported by expiry date
+	monitor.Check(ctx)
+	report := monitor.HealthDetails(ctx).(CertificateExpiryReport)
+	var ids []string
+	for _, certificate := range report.Expiring {
+		ids = append(ids, certificate.ID)
+	}
+	if len(ids) != 3 || ids[0] != "expired" || ids[1] != "soon" || ids[2] != "later" {
+		t.Fatalf("expiring certificates = %v, want expired, soon and later", ids)
+	}
+	if !report.Expiring[0].Expired || report.Expiring[1].Expired || report.Expiring[1].DaysLeft != 5 {
+		t.Errorf("expiring certificates = %+v, want their days left and expired flags", report.Expiring)
+	}
+	if len(report.Thresholds) != 3 || report.Thresholds[0] != 30 {
+		t.Errorf("thresholds = %v, want them from the largest", report.Thresholds)
+	}
+
+	// 2: Expiring certificates are logged as warnings, expired ones as errors
+	if warnings := loggedAt(hook, logrus.WarnLevel); len(warnings) != 2 {
+		t.Errorf("warnings = %v, want one per expiring certificate", warnings)
+	}
+	if errors := loggedAt(hook, logrus.ErrorLevel); len(errors) != 1 {
+		t.Errorf("errors = %v, want one for the expired certificate", errors)
+	}
+
+	// 3: Certificates are not logged again while they stay within the same threshold
+	hook.Reset()
+	monitor.Check(ctx)
+	if entries := hook.AllEntries(); len(entries) != 0 {
+		t.Errorf("logged %d messages without threshold changes", len(entries))
+	}
+
+	// 4: A certificate crossing a smaller threshold is logged again
+	service.setSummaries(later, expiringSummary("soon", 0, true), expired)
+	monitor.Check(ctx)
+	if entries := hook.AllEntries(); len(entries) != 1 || entries[0].Level != logrus.WarnLevel {
+		t.Errorf("logged %d messages, want a warning for the certificate that crossed a threshold", len(entries))
+	}
+}
+
+func TestCertificateExpiryMonitorChecksPeriodically(t *testing.T) {
+	service := &summaryAdminService{}
+	monitor := NewCertificateExpiryMonitor(service, []int{30}, 10*time.Millisecond)
+
+	monitor.Start(context.Background())
+	time.Sleep(100 * time.Millisecond)
+	if err := monitor.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	service.mu.Lock()
+	listings := service.listings
+	service.mu.Unlock()
+	if listings < 3 {
+		t.Errorf("checked %d times in 100ms, want a check every 10ms", listings)
+	}
+
+	// No check runs once closed
+	time.Sleep(50 * time.Millisecond)
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	if service.listings > listings+1 {
+		t.Errorf("checked %d times after Close()", service.listings-listings)
+	}
+}
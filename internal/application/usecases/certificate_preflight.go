@@ -0,0 +1,221 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/ports"
+	"github.com/chainedpixel/go-dte-signer/pkg/logs"
+)
+
+// Outcomes of the check of a certificate
+const (
+	PreflightPassed  = "passed"
+	PreflightFailed  = "failed"
+	PreflightSkipped = "skipped"
+)
+
+// preflightDocument is the document signed by the self-test
+var preflightDocument = map[string]string{"preflight": "go-dte-signer"}
+
+// CertificatePreflight checks that every stored certificate loads, holds a consistent key pair and
+// produces signatures that verify, so broken certificates are found before a document needs them
+type CertificatePreflight struct {
+	certStore        ports.CertificateStore
+	documentSigner   ports.DocumentSigner
+	documentVerifier ports.DocumentVerifier
+	keyProcessor     ports.KeyProcessor
+
+	mutex  sync.Mutex
+	report CertificatePreflightReport
+}
+
+// CertificatePreflightReport describes the result of the last check of the stored certificates
+type CertificatePreflightReport struct {
+	CheckedAt time.Time          `json:"checkedAt"`
+	Passed    int                `json:"passed"`
+	Failed    int                `json:"failed"`
+	Skipped   int                `json:"skipped"`
+	Results   []CertificateCheck `json:"results"`
+}
+
+// CertificateCheck describes the check of a single certificate
+type CertificateCheck struct {
+	NIT     string `json:"nit"`
+	ID      string `json:"_id,omitempty"`
+	File    string `json:"file,omitempty"`
+	Active  bool   `json:"activo"`
+	KeyType string `json:"keyType,omitempty"`
+	Status  string `json:"status"`
+	// Stage is the step that failed: load, keys, sign or verify
+	Stage string `json:"stage,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// NewCertificatePreflight creates a new certificate preflight
+func NewCertificatePreflight(certStore ports.CertificateStore, documentSigner ports.DocumentSigner, documentVerifier ports.DocumentVerifier, keyProcessor ports.KeyProcessor) *CertificatePreflight {
+	return &CertificatePreflight{
+		certStore:        certStore,
+		documentSigner:   documentSigner,
+		documentVerifier: documentVerifier,
+		keyProcessor:     keyProcessor,
+		report:           CertificatePreflightReport{Results: []CertificateCheck{}},
+	}
+}
+
+// Run checks every stored certificate, logs the failures and returns the report. Inactive
+// certificates are only required to load. Certificates whose key is held by PKCS#11 or Transit
+// have no private key in memory, so for them the key pair check is left to the test signature.
+func (p *CertificatePreflight) Run(ctx context.Context) (CertificatePreflightReport, error) {
+	// 1: List the stored certificates
+	summaries, err := p.certStore.List(ctx)
+	if err != nil {
+		return CertificatePreflightReport{}, fmt.Errorf("failed to list certificates: %w", err)
+	}
+
+	// 2: Check every certificate, loading the active ones of each NIT once
+	report := CertificatePreflightReport{CheckedAt: time.Now(), Results: make([]CertificateCheck, 0, len(summaries))}
+	loaded := make(map[string][]*models.Certificate)
+	loadErrors := make(map[string]error)
+	for _, summary := range summaries {
+		if summary.Valid && summary.Active {
+			if _, ok := loaded[summary.NIT]; !ok && loadErrors[summary.NIT] == nil {
+				certificates, err := p.certStore.ListByNIT(ctx, summary.NIT)
+				if err != nil {
+					loadErrors[summary.NIT] = err
+				}
+				loaded[summary.NIT] = certificates
+			}
+		}
+
+		check := p.check(ctx, summary, loaded[summary.NIT], loadErrors[summary.NIT])
+		switch check.Status {
+		case PreflightPassed:
+			report.Passed++
+		case PreflightFailed:
+			report.Failed++
+			logs.Error(fmt.Sprintf("Certificate %s of NIT %s failed the %s check: %s", check.label(), check.NIT, check.Stage, check.Error))
+		default:
+			report.Skipped++
+		}
+		report.Results = append(report.Results, check)
+	}
+
+	logs.Info(fmt.Sprintf("Certificate preflight completed: %d passed, %d failed, %d skipped",
+		report.Passed, report.Failed, report.Skipped))
+
+	// 3: Keep the report for the health check
+	p.mutex.Lock()
+	p.report = report
+	p.mutex.Unlock()
+
+	return report, nil
+}
+
+// check checks a single certificate with the loaded active certificates of its NIT
+func (p *CertificatePreflight) check(ctx context.Context, summary models.CertificateSummary, certificates []*models.Certificate, loadErr error) CertificateCheck {
+	check := CertificateCheck{
+		NIT:     summary.NIT,
+		ID:      summary.ID,
+		File:    summary.File,
+		Active:  summary.Active,
+		KeyType: summary.KeyType,
+		Status:  PreflightPassed,
+	}
+
+	// 1: The certificate must load
+	if !summary.Valid {
+		return check.fail("load", summary.Error)
+	}
+	if !summary.Active {
+		check.Status = PreflightSkipped
+		return check
+	}
+	if loadErr != nil {
+		return check.fail("load", loadErr.Error())
+	}
+	var certificate *models.Certificate
+	for _, candidate := range certificates {
+		if candidate.ID == summary.ID {
+			certificate = candidate
+			break
+		}
+	}
+	if certificate == nil {
+		return check.fail("load", "certificate not returned by the store")
+	}
+
+	// 2: Its private key must be valid and belong to its public key
+	if err := p.keyProcessor.CheckKeys(certificate); err != nil {
+		return check.fail("keys", err.Error())
+	}
+
+	// 3: A signature made with it must verify with its public key
+	signed, err := p.documentSigner.Sign(ctx, certificate, preflightDocument, models.SigningOptions{})
+	if err != nil {
+		return check.fail("sign", err.Error())
+	}
+	result, err := p.documentVerifier.Verify(ctx, certificate, signed.Serialized, nil)
+	if err != nil {
+		return check.fail("verify", err.Error())
+	}
+	if !result.Valid {
+		return check.fail("verify", "signature does not verify")
+	}
+
+	return check
+}
+
+// Broken returns the failed checks of certificates that are active, or whose state is unknown because they did not load
+func (r CertificatePreflightReport) Broken() []CertificateCheck {
+	var broken []CertificateCheck
+	for _, check := range r.Results {
+		if check.Status == PreflightFailed && (check.Active || check.Stage == "load") {
+			broken = append(broken, check)
+		}
+	}
+	return broken
+}
+
+// Err returns an error describing the broken certificates, which prevents the service from starting
+// in strict mode, or nil when none is broken
+func (r CertificatePreflightReport) Err() error {
+	broken := r.Broken()
+	if len(broken) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d certificate(s) failed the preflight, see the log; NIT %s failed the %s check: %s",
+		len(broken), broken[0].NIT, broken[0].Stage, broken[0].Error)
+}
+
+// HealthName returns the key under which the preflight report is given in the health check
+func (p *CertificatePreflight) HealthName() string {
+	return "certificatePreflight"
+}
+
+// HealthDetails returns the report of the last check for the health check
+func (p *CertificatePreflight) HealthDetails(ctx context.Context) interface{} {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.report
+}
+
+// fail marks the check as failed at the given stage
+func (c CertificateCheck) fail(stage string, message string) CertificateCheck {
+	c.Status = PreflightFailed
+	c.Stage = stage
+	c.Error = message
+	return c
+}
+
+// label identifies the certificate in the logs, by ID or by file when it did not load
+func (c CertificateCheck) label() string {
+	if c.ID != "" {
+		return c.ID
+	}
+	return c.File
+}
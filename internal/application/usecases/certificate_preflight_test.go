@@ -0,0 +1,198 @@
+package usecases
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/ports"
+	"github.com/chainedpixel/go-dte-signer/internal/infrastructure/adapters"
+	"github.com/chainedpixel/go-dte-signer/internal/infrastructure/cypher"
+)
+
+// preflightStore lists a fixed set of certificates, as a store holding them would
+type preflightStore struct {
+	ports.CertificateStore
+	summaries    []models.CertificateSummary
+	certificates map[string][]*models.Certificate
+}
+
+func (s *preflightStore) List(ctx context.Context) ([]models.CertificateSummary, error) {
+	return s.summaries, nil
+}
+
+func (s *preflightStore) ListByNIT(ctx context.Context, nit string) ([]*models.Certificate, error) {
+	return s.certificates[nit], nil
+}
+
+func newPreflightKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newTestPreflight(store ports.CertificateStore) *CertificatePreflight {
+	algorithms := cypher.DefaultAlgorithmRegistry()
+	return NewCertificatePreflight(store, cypher.NewJWSSigner(algorithms, cypher.HeaderPolicy{}, nil),
+		cypher.NewJWSVerifier(algorithms), cypher.NewKeyProcessor())
+}
+
+// checkOf returns the check of a NIT in the report
+func checkOf(t *testing.T, report CertificatePreflightReport, nit string) CertificateCheck {
+	t.Helper()
+	for _, check := range report.Results {
+		if check.NIT == nit {
+			return check
+		}
+	}
+	t.Fatalf("no check for NIT %s in %+v", nit, report.Results)
+	return CertificateCheck{}
+}
+
+func TestCertificatePreflightChecksKeyPairs(t *testing.T) {
+	good, other := newPreflightKey(t), newPreflightKey(t)
+	certificate := func(nit string, active bool, private crypto.Signer, public crypto.PublicKey) *models.Certificate {
+		return &models.Certificate{ID: nit + "-id", NIT: nit, Active: active, DecodedPrivateKey: private, DecodedPublicKey: public}
+	}
+	store := &preflightStore{
+		summaries: []models.CertificateSummary{
+			{NIT: "06140101780010", ID: "06140101780010-id", Active: true, Valid: true},
+			{NIT: "06140101780011", ID: "06140101780011-id", Active: true, Valid: true},
+			{NIT: "06140101780012", ID: "06140101780012-id", Active: false, Valid: true},
+			{NIT: "06140101780013", ID: "06140101780013-id", Active: true, Valid: true},
+		},
+		certificates: map[string][]*models.Certificate{
+			"06140101780010": {certificate("06140101780010", true, good, good.Public())},
+			// The private key does not belong to the public key of the certificate
+			"06140101780011": {certificate("06140101780011", true, good, other.Public())},
+			// An inactive certificate is only loaded, so its mismatched keys go unnoticed
+			"06140101780012": {certificate("06140101780012", false, good, other.Public())},
+			// A key held outside the process is only checked by the test signature
+			"06140101780013": {certificate("06140101780013", true, nil, other.Public())},
+		},
+	}
+
+	report, err := newTestPreflight(store).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	tests := []struct {
+		nit    string
+		status string
+		stage  string
+	}{
+		{"06140101780010", PreflightPassed, ""},
+		{"06140101780011", PreflightFailed, "keys"},
+		{"06140101780012", PreflightSkipped, ""},
+		{"06140101780013", PreflightFailed, "sign"},
+	}
+	for _, tt := range tests {
+		check := checkOf(t, report, tt.nit)
+		if check.Status != tt.status || check.Stage != tt.stage {
+			t.Errorf("check of %s = %s at %q (%s), want %s at %q", tt.nit, check.Status, check.Stage, check.Error, tt.status, tt.stage)
+		}
+	}
+	if report.Passed != 1 || report.Failed != 2 || report.Skipped != 1 {
+		t.Errorf("report counts %d passed, %d failed, %d skipped", report.Passed, report.Failed, report.Skipped)
+	}
+
+	broken := report.Broken()
+	if len(broken) != 2 || broken[0].NIT != "06140101780011" || broken[1].NIT != "06140101780013" {
+		t.Errorf("Broken() = %+v", broken)
+	}
+	if err := report.Err(); err == nil || !strings.Contains(err.Error(), "2 certificate(s)") || !strings.Contains(err.Error(), "public_key_mismatch") {
+		t.Errorf("Err() = %v, want the two broken certificates and the key mismatch", err)
+	}
+}
+
+func TestCertificatePreflightWithCertificateFiles(t *testing.T) {
+	basePath := t.TempDir()
+	good, other := newPreflightKey(t), newPreflightKey(t)
+	writePEM := func(name string, blocks ...*pem.Block) {
+		t.Helper()
+		var content []byte
+		for _, block := range blocks {
+			content = append(content, pem.EncodeToMemory(block)...)
+		}
+		if err := os.WriteFile(filepath.Join(basePath, name), content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	privateKey := func(key *ecdsa.PrivateKey) *pem.Block {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "preflight"},
+		NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour)}
+	otherCertificate, err := x509.CreateCertificate(rand.Reader, template, template, other.Public(), other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writePEM("06140101780010.pem", privateKey(good))
+	// The key and the certificate of the file belong to different key pairs
+	writePEM("06140101780011.pem", privateKey(good), &pem.Block{Type: "CERTIFICATE", Bytes: otherCertificate})
+	if err := os.WriteFile(filepath.Join(basePath, "06140101780012.crt"), []byte("<CertificadoMH><_id>"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	passwords := map[string]string{"06140101780010": "secret", "06140101780011": "secret"}
+	store := adapters.NewFileCertificateRepository(basePath, cypher.NewKeyProcessor(), passwords, nil)
+	report, err := newTestPreflight(store).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if check := checkOf(t, report, "06140101780010"); check.Status != PreflightPassed {
+		t.Errorf("check of the valid key = %+v", check)
+	}
+	mismatched := checkOf(t, report, "06140101780011")
+	if mismatched.Status != PreflightFailed || mismatched.Stage != "load" || !strings.Contains(mismatched.Error, "public_key_mismatch") {
+		t.Errorf("check of the mismatched key pair = %+v", mismatched)
+	}
+	corrupt := checkOf(t, report, "06140101780012")
+	if corrupt.Status != PreflightFailed || corrupt.Stage != "load" {
+		t.Errorf("check of the corrupt file = %+v", corrupt)
+	}
+
+	// Files that do not load are broken whatever their state, so strict mode refuses to start
+	if broken := report.Broken(); len(broken) != 2 {
+		t.Errorf("Broken() = %+v, want the mismatched and the corrupt files", broken)
+	}
+	if err := report.Err(); err == nil || !strings.HasPrefix(err.Error(), "2 certificate(s) failed the preflight") {
+		t.Errorf("Err() = %v", err)
+	}
+
+	// Once the broken files are removed, nothing prevents the start
+	for _, file := range []string{"06140101780011.pem", "06140101780012.crt"} {
+		if err := os.Remove(filepath.Join(basePath, file)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	report, err = newTestPreflight(store).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if broken := report.Broken(); len(broken) != 0 || report.Err() != nil {
+		t.Errorf("Broken() = %+v, Err() = %v, want no broken certificate", broken, report.Err())
+	}
+}
@@ -0,0 +1,69 @@
+package usecases
+
+import (
+	"context"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/ports"
+	"github.com/chainedpixel/go-dte-signer/pkg/i18n"
+	"github.com/chainedpixel/go-dte-signer/pkg/response"
+)
+
+// ControlNumberUseCase handles the allocation of numeroControl values
+type ControlNumberUseCase struct {
+	controlNumberService ports.ControlNumberService
+	translator           *i18n.Translator
+}
+
+// NewControlNumberUseCase creates a new control number use case
+func NewControlNumberUseCase(controlNumberService ports.ControlNumberService, translator *i18n.Translator) *ControlNumberUseCase {
+	return &ControlNumberUseCase{
+		controlNumberService: controlNumberService,
+		translator:           translator,
+	}
+}
+
+// ControlNumberInput identifies the series of a NIT and, when advancing it, its new correlative
+type ControlNumberInput struct {
+	TipoDte         string `json:"tipoDte"`
+	Establecimiento string `json:"establecimiento"`
+	PuntoVenta      string `json:"puntoVenta"`
+	Sequence        int64  `json:"sequence"`
+}
+
+// Next allocates the next numeroControl of a series
+func (uc *ControlNumberUseCase) Next(ctx context.Context, nit string, input ControlNumberInput) (*response.Response, error) {
+	number, err := uc.controlNumberService.NextControlNumber(ctx, input.series(nit))
+	if err != nil {
+		return newErrorResponse(uc.translator, err), nil
+	}
+	return response.NewSuccessResponse(number), nil
+}
+
+// Current returns the last numeroControl allocated from a series
+func (uc *ControlNumberUseCase) Current(ctx context.Context, nit string, input ControlNumberInput) (*response.Response, error) {
+	number, err := uc.controlNumberService.CurrentControlNumber(ctx, input.series(nit))
+	if err != nil {
+		return newErrorResponse(uc.translator, err), nil
+	}
+	return response.NewSuccessResponse(number), nil
+}
+
+// Advance raises the correlative of a series to at least the given one
+func (uc *ControlNumberUseCase) Advance(ctx context.Context, nit string, input ControlNumberInput) (*response.Response, error) {
+	number, err := uc.controlNumberService.AdvanceControlNumber(ctx, input.series(nit), input.Sequence)
+	if err != nil {
+		return newErrorResponse(uc.translator, err), nil
+	}
+	return response.NewSuccessResponse(number), nil
+}
+
+// series returns the series of a NIT identified by the input
+func (i ControlNumberInput) series(nit string) models.ControlNumberSeries {
+	return models.ControlNumberSeries{
+		NIT:             nit,
+		TipoDte:         i.TipoDte,
+		Establecimiento: i.Establecimiento,
+		PuntoVenta:      i.PuntoVenta,
+	}
+}
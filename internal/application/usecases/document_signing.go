@@ -2,8 +2,9 @@ package usecases
 
 import (
 	"context"
-	"errors"
-	"fmt"
+	"encoding/base64"
+	"encoding/json"
+	"time"
 
 	errPackage "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
 	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
@@ -16,28 +17,78 @@ import (
 type DocumentSigningUseCase struct {
 	signingService ports.SigningService
 	translator     *i18n.Translator
+	batch          BatchOptions
 }
 
 // NewDocumentSigningUseCase creates a new document signing use case
-func NewDocumentSigningUseCase(signingService ports.SigningService, translator *i18n.Translator) *DocumentSigningUseCase {
+func NewDocumentSigningUseCase(signingService ports.SigningService, translator *i18n.Translator, batch BatchOptions) *DocumentSigningUseCase {
 	return &DocumentSigningUseCase{
 		signingService: signingService,
 		translator:     translator,
+		batch:          batch,
 	}
 }
 
 // DocumentSigningInput represents the input for document signing
 type DocumentSigningInput struct {
-	PublicKeyPassword  string      `json:"passwordPub"`
-	PrivateKeyPassword string      `json:"passwordPri"`
-	NIT                string      `json:"nit"`
-	DocumentName       string      `json:"nombreDocumento"`
-	SignatureName      string      `json:"nombreFirma"`
-	CompactSerialized  string      `json:"compactSerialization"`
-	DocumentJSON       interface{} `json:"dteJson"`
-	Document           string      `json:"dte"`
-	Active             bool        `json:"activo"`
-	Path               string      `json:"path"`
+	PublicKeyPassword  string                 `json:"passwordPub"`
+	PrivateKeyPassword string                 `json:"passwordPri"`
+	NIT                string                 `json:"nit"`
+	DocumentName       string                 `json:"nombreDocumento"`
+	SignatureName      string                 `json:"nombreFirma"`
+	CompactSerialized  string                 `json:"compactSerialization"`
+	DocumentJSON       json.RawMessage        `json:"dteJson"`
+	Document           string                 `json:"dte"`
+	Active             bool                   `json:"activo"`
+	Path               string                 `json:"path"`
+	Headers            map[string]interface{} `json:"jwsHeaders"`
+	Serialization      string                 `json:"serialization"`
+	Detached           bool                   `json:"detached"`
+	UnencodedPayload   bool                   `json:"unencodedPayload"`
+	Signers            []SignerInput          `json:"signers"`
+	Canonicalize       bool                   `json:"canonicalize"`
+	CertificateID      string                 `json:"certificateId"`
+	ValidateSchema     *bool                  `json:"validateSchema"`
+	// AssignControlNumber replaces the numeroControl of the document with the next one of its series,
+	// identified by the establishment and point of sale codes, or those of the emisor when omitted
+	AssignControlNumber bool   `json:"assignNumeroControl"`
+	Establishment       string `json:"establecimiento"`
+	PointOfSale         string `json:"puntoVenta"`
+	// Metadata requests the signature metadata along with the JWS; set by the handler, not by the body
+	Metadata bool `json:"-"`
+}
+
+// SignerInput represents an additional signer of a multi-signer request
+type SignerInput struct {
+	NIT                string `json:"nit"`
+	PrivateKeyPassword string `json:"passwordPri"`
+	PublicKeyPassword  string `json:"passwordPub"`
+	CertificateID      string `json:"certificateId"`
+}
+
+// SignedDocumentOutput represents a signed document in a serialization other than the legacy compact JWS.
+// When the service wrote the numeroControl into the document, NumeroControl returns it and, for detached
+// signatures, Payload returns the base64url encoded bytes that were signed, which the caller must send along.
+type SignedDocumentOutput struct {
+	JWS           interface{} `json:"jws"`
+	Serialization string      `json:"serialization"`
+	Detached      bool        `json:"detached"`
+	Unencoded     bool        `json:"unencodedPayload"`
+	NumeroControl string      `json:"numeroControl,omitempty"`
+	Payload       string      `json:"payload,omitempty"`
+}
+
+// SignedDocumentMetadataOutput represents a signed document along with its signature metadata
+type SignedDocumentMetadataOutput struct {
+	SignedDocumentOutput
+	PayloadDigest    models.PayloadDigests     `json:"payloadDigest"`
+	Algorithm        string                    `json:"alg"`
+	KeyID            string                    `json:"kid,omitempty"`
+	CertificateID    string                    `json:"certificateId"`
+	NIT              string                    `json:"nit"`
+	CodigoGeneracion string                    `json:"codigoGeneracion,omitempty"`
+	SignedAt         time.Time                 `json:"signedAt"`
+	Signatures       []models.SignatureDetails `json:"signatures,omitempty"`
 }
 
 // Execute processes a document signing request
@@ -50,47 +101,107 @@ func (uc *DocumentSigningUseCase) Execute(ctx context.Context, input DocumentSig
 
 	// 2. Map input to domain model
 	request := &models.CertificateRequest{
-		PublicKeyPassword:  input.PublicKeyPassword,
-		PrivateKeyPassword: input.PrivateKeyPassword,
-		NIT:                input.NIT,
-		DocumentName:       input.DocumentName,
-		SignatureName:      input.SignatureName,
-		CompactSerialized:  input.CompactSerialized,
-		DocumentJSON:       input.DocumentJSON,
-		Document:           input.Document,
-		Active:             input.Active,
-		Path:               input.Path,
+		PublicKeyPassword:   input.PublicKeyPassword,
+		PrivateKeyPassword:  input.PrivateKeyPassword,
+		NIT:                 input.NIT,
+		DocumentName:        input.DocumentName,
+		SignatureName:       input.SignatureName,
+		CompactSerialized:   input.CompactSerialized,
+		DocumentJSON:        input.DocumentJSON,
+		Document:            input.Document,
+		Active:              input.Active,
+		Path:                input.Path,
+		Headers:             input.Headers,
+		Serialization:       input.Serialization,
+		Detached:            input.Detached,
+		UnencodedPayload:    input.UnencodedPayload,
+		Canonicalize:        input.Canonicalize,
+		CertificateID:       input.CertificateID,
+		ValidateSchema:      input.ValidateSchema,
+		AssignControlNumber: input.AssignControlNumber,
+		Establishment:       input.Establishment,
+		PointOfSale:         input.PointOfSale,
+	}
+	for _, signer := range input.Signers {
+		request.Signers = append(request.Signers, models.SignerCredentials{
+			NIT:                signer.NIT,
+			PrivateKeyPassword: signer.PrivateKeyPassword,
+			PublicKeyPassword:  signer.PublicKeyPassword,
+			CertificateID:      signer.CertificateID,
+		})
 	}
 
 	// 3. Call domain service to sign document
-	jws, err := uc.signingService.SignDocument(ctx, request)
+	signed, err := uc.signingService.SignDocument(ctx, request)
 	if err != nil {
 		return uc.createErrorResponse(err), nil
 	}
 
-	return response.NewSuccessResponse(jws), nil
+	// 4. Return the signature metadata when requested
+	if input.Metadata {
+		return response.NewSuccessResponse(newSignedDocumentMetadataOutput(signed)), nil
+	}
+
+	// 5. Keep the Hacienda response format for compact attached signatures, whose payload carries any assigned numeroControl
+	if signed.IsLegacy() {
+		return response.NewSuccessResponse(signed.Serialized), nil
+	}
+
+	return response.NewSuccessResponse(newSignedDocumentOutput(signed)), nil
+}
+
+// newSignedDocumentOutput maps a signed document to its response body
+func newSignedDocumentOutput(signed *models.SignedDocument) *SignedDocumentOutput {
+	var jws interface{} = signed.Serialized
+	if signed.Serialization != models.SerializationCompact {
+		jws = json.RawMessage(signed.Serialized)
+	}
+
+	output := &SignedDocumentOutput{
+		JWS:           jws,
+		Serialization: signed.Serialization,
+		Detached:      signed.Detached,
+		Unencoded:     signed.Unencoded,
+		NumeroControl: signed.AssignedControlNumber,
+	}
+	// A detached JWS of a document changed by the service only verifies against the bytes that were signed
+	if signed.Detached && signed.AssignedControlNumber != "" {
+		output.Payload = base64.RawURLEncoding.EncodeToString(signed.Payload)
+	}
+	return output
+}
+
+// newSignedDocumentMetadataOutput maps a signed document and its metadata to its response body.
+// The top-level signature fields describe the first signer; every signature is listed for multi-signer documents.
+func newSignedDocumentMetadataOutput(signed *models.SignedDocument) *SignedDocumentMetadataOutput {
+	output := &SignedDocumentMetadataOutput{
+		SignedDocumentOutput: *newSignedDocumentOutput(signed),
+		PayloadDigest:        signed.PayloadDigests(),
+		CodigoGeneracion:     signed.CodigoGeneracion(),
+		SignedAt:             signed.SignedAt,
+	}
+	output.NumeroControl = signed.NumeroControl()
+	if len(signed.Signatures) > 0 {
+		first := signed.Signatures[0]
+		output.Algorithm = first.Algorithm
+		output.KeyID = first.KeyID
+		output.CertificateID = first.CertificateID
+		output.NIT = first.NIT
+	}
+	if len(signed.Signatures) > 1 {
+		output.Signatures = signed.Signatures
+	}
+	return output
 }
 
 // validateInput validates the document signing input
 func (uc *DocumentSigningUseCase) validateInput(input DocumentSigningInput) bool {
-	return input.NIT != "" && input.PrivateKeyPassword != "" && input.DocumentJSON != nil
+	hasMainSigner := input.NIT != "" && input.PrivateKeyPassword != ""
+	hasDocument := len(input.DocumentJSON) > 0 && string(input.DocumentJSON) != "null"
+	return (hasMainSigner || len(input.Signers) > 0) && hasDocument
 }
 
 // createErrorResponse creates an error response from a domain error
 func (uc *DocumentSigningUseCase) createErrorResponse(err error) *response.Response {
-	var domainErr errPackage.DomainError
-	ok := errors.As(err, &domainErr)
-	if !ok {
-		// Default to internal server error
-		return response.NewErrorResponse("500", uc.translator.T("internal_server_error"))
-	}
-
-	// Translate the error message
-	translatedMsg := uc.translator.T(domainErr.Message)
-	if domainErr.Code == errPackage.CodePasswordInvalid {
-		// Special case for password invalid, which needs formatting
-		translatedMsg = fmt.Sprintf(translatedMsg, domainErr.Message)
-	}
-
-	return response.NewErrorResponse(domainErr.Code, translatedMsg)
+	return newErrorResponse(uc.translator, err)
 }
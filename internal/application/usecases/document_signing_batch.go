@@ -0,0 +1,78 @@
+package usecases
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	errPackage "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/pkg/logs"
+	"github.com/chainedpixel/go-dte-signer/pkg/response"
+)
+
+// BatchOptions holds the limits applied to batch signing requests
+type BatchOptions struct {
+	// Concurrency is the maximum number of documents signed at the same time; defaults to the number of CPUs
+	Concurrency int
+	// MaxItems is the maximum number of documents accepted in a single batch; zero means no limit
+	MaxItems int
+}
+
+// ExecuteBatch signs several documents with bounded concurrency. The body of the returned
+// response holds one response per input, in the same order, so a failing document does
+// not fail the whole batch.
+func (uc *DocumentSigningUseCase) ExecuteBatch(ctx context.Context, inputs []DocumentSigningInput) (*response.Response, error) {
+	// 1. Validate the batch size
+	if len(inputs) == 0 {
+		return uc.createErrorResponse(errPackage.NewRequiredDataError("required_data")), nil
+	}
+	if uc.batch.MaxItems > 0 && len(inputs) > uc.batch.MaxItems {
+		return uc.createErrorResponse(errPackage.NewDomainError("batch_too_large", errPackage.CodeInvalid)), nil
+	}
+
+	concurrency := uc.batch.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
+	// 2. Sign every document, keeping the results in input order
+	results := make([]*response.Response, len(inputs))
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, input := range inputs {
+		// Stop scheduling new documents once the request is cancelled
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			results[i] = uc.createErrorResponse(ctx.Err())
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, input DocumentSigningInput) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			results[i] = uc.executeBatchItem(ctx, input)
+		}(i, input)
+	}
+	wg.Wait()
+
+	return response.NewSuccessResponse(results), nil
+}
+
+// executeBatchItem signs a single batch document, turning unexpected failures into error responses
+func (uc *DocumentSigningUseCase) executeBatchItem(ctx context.Context, input DocumentSigningInput) (resp *response.Response) {
+	defer func() {
+		if r := recover(); r != nil {
+			logs.Error("PANIC while signing batch document:", r)
+			resp = uc.createErrorResponse(nil)
+		}
+	}()
+
+	resp, err := uc.Execute(ctx, input)
+	if err != nil {
+		return uc.createErrorResponse(err)
+	}
+	return resp
+}
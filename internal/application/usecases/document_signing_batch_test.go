@@ -0,0 +1,159 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	errPackage "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/pkg/response"
+)
+
+// batchSigningService signs every document with its NIT as the JWS, failing for the NITs it is given
+// and panicking for "panic". It records the highest number of documents signed at the same time.
+type batchSigningService struct {
+	failing map[string]error
+	delay   time.Duration
+
+	active    atomic.Int32
+	mu        sync.Mutex
+	maxActive int32
+}
+
+func (s *batchSigningService) SignDocument(ctx context.Context, request *models.CertificateRequest) (*models.SignedDocument, error) {
+	active := s.active.Add(1)
+	defer s.active.Add(-1)
+	s.mu.Lock()
+	if active > s.maxActive {
+		s.maxActive = active
+	}
+	s.mu.Unlock()
+
+	time.Sleep(s.delay)
+	if request.NIT == "panic" {
+		panic("signer failure")
+	}
+	if err := s.failing[request.NIT]; err != nil {
+		return nil, err
+	}
+	return &models.SignedDocument{Serialized: request.NIT, Serialization: models.SerializationCompact}, nil
+}
+
+func (s *batchSigningService) VerifyDocument(ctx context.Context, request *models.CertificateRequest) (*models.VerificationResult, error) {
+	return &models.VerificationResult{Valid: true}, nil
+}
+
+// batchInputs returns one signing input per NIT
+func batchInputs(nits ...string) []DocumentSigningInput {
+	inputs := make([]DocumentSigningInput, 0, len(nits))
+	for _, nit := range nits {
+		inputs = append(inputs, DocumentSigningInput{NIT: nit, PrivateKeyPassword: "secret", DocumentJSON: json.RawMessage(`{"a":1}`)})
+	}
+	return inputs
+}
+
+// checkErrorResponse fails unless the response is an error with the given code
+func checkErrorResponse(t *testing.T, resp *response.Response, code string) {
+	t.Helper()
+	body, ok := resp.Body.(response.ErrorBody)
+	if resp.Status != "error" || !ok || body.Code != code {
+		t.Errorf("response = %+v, want an error with code %s", resp, code)
+	}
+}
+
+func TestExecuteBatchReturnsOneResultPerDocument(t *testing.T) {
+	service := &batchSigningService{failing: map[string]error{
+		"06142803901121": errPackage.NewDomainError("no_file_found", errPackage.CodeCertNotFound),
+	}}
+	useCase := NewDocumentSigningUseCase(service, newTestTranslator(t), BatchOptions{Concurrency: 2, MaxItems: 10})
+	inputs := batchInputs("06140101780010", "06142803901121", "panic", "06140202780020")
+	inputs = append(inputs, DocumentSigningInput{NIT: "06140303780030"})
+
+	resp, err := useCase.ExecuteBatch(context.Background(), inputs)
+	if err != nil {
+		t.Fatalf("ExecuteBatch() error = %v", err)
+	}
+	results, ok := resp.Body.([]*response.Response)
+	if resp.Status != "OK" || !ok || len(results) != len(inputs) {
+		t.Fatalf("ExecuteBatch() = %+v, want %d results", resp, len(inputs))
+	}
+
+	// Every document keeps its position, and a failing one does not fail the others
+	for _, i := range []int{0, 3} {
+		if results[i].Status != "OK" || results[i].Body != inputs[i].NIT {
+			t.Errorf("result %d = %+v, want the JWS of %s", i, results[i], inputs[i].NIT)
+		}
+	}
+	checkErrorResponse(t, results[1], errPackage.CodeCertNotFound)
+	checkErrorResponse(t, results[2], "500")
+	checkErrorResponse(t, results[4], errPackage.CodeRequiredData)
+}
+
+func TestExecuteBatchRejectsInvalidBatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		inputs   []DocumentSigningInput
+		wantCode string
+	}{
+		{"empty batch", nil, errPackage.CodeRequiredData},
+		{"batch over the limit", batchInputs("1", "2", "3"), errPackage.CodeInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &batchSigningService{}
+			useCase := NewDocumentSigningUseCase(service, newTestTranslator(t), BatchOptions{MaxItems: 2})
+			resp, err := useCase.ExecuteBatch(context.Background(), tt.inputs)
+			if err != nil {
+				t.Fatalf("ExecuteBatch() error = %v", err)
+			}
+			checkErrorResponse(t, resp, tt.wantCode)
+			if service.maxActive != 0 {
+				t.Error("a document of a rejected batch was signed")
+			}
+		})
+	}
+}
+
+func TestExecuteBatchBoundsConcurrency(t *testing.T) {
+	service := &batchSigningService{delay: 20 * time.Millisecond}
+	useCase := NewDocumentSigningUseCase(service, newTestTranslator(t), BatchOptions{Concurrency: 3})
+
+	resp, err := useCase.ExecuteBatch(context.Background(), batchInputs("1", "2", "3", "4", "5", "6", "7", "8", "9"))
+	if err != nil || resp.Status != "OK" {
+		t.Fatalf("ExecuteBatch() = %+v, %v", resp, err)
+	}
+	if service.maxActive > 3 {
+		t.Errorf("signed %d documents at the same time, want at most 3", service.maxActive)
+	}
+	if service.maxActive < 2 {
+		t.Errorf("signed %d documents at the same time, want them signed concurrently", service.maxActive)
+	}
+}
+
+func TestExecuteBatchStopsOnceCancelled(t *testing.T) {
+	// The slow document keeps the only slot busy, so the cancellation is seen while scheduling the rest
+	service := &batchSigningService{delay: 50 * time.Millisecond}
+	useCase := NewDocumentSigningUseCase(service, newTestTranslator(t), BatchOptions{Concurrency: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	resp, err := useCase.ExecuteBatch(ctx, batchInputs("1", "2", "3", "4"))
+	if err != nil {
+		t.Fatalf("ExecuteBatch() error = %v", err)
+	}
+	results := resp.Body.([]*response.Response)
+	var skipped int
+	for _, result := range results {
+		if result.Status != "OK" {
+			skipped++
+		}
+	}
+	// The first document may still take the free slot; the rest are never scheduled
+	if skipped < len(results)-1 {
+		t.Errorf("skipped %d of %d documents of a cancelled batch", skipped, len(results))
+	}
+}
@@ -0,0 +1,213 @@
+package usecases
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/pkg/i18n"
+)
+
+// signedDocumentService returns a fixed signed document for every request
+type signedDocumentService struct {
+	signed  *models.SignedDocument
+	request *models.CertificateRequest
+}
+
+func (s *signedDocumentService) SignDocument(ctx context.Context, request *models.CertificateRequest) (*models.SignedDocument, error) {
+	s.request = request
+	return s.signed, nil
+}
+
+func (s *signedDocumentService) VerifyDocument(ctx context.Context, request *models.CertificateRequest) (*models.VerificationResult, error) {
+	return &models.VerificationResult{Valid: true}, nil
+}
+
+func newTestTranslator(t *testing.T) *i18n.Translator {
+	t.Helper()
+	translator, err := i18n.NewTranslator("../../../configs/locales", "en")
+	if err != nil {
+		t.Fatalf("NewTranslator() error = %v", err)
+	}
+	return translator
+}
+
+// signingResponseBody executes a signing request and returns the JSON body of its response
+func signingResponseBody(t *testing.T, service *signedDocumentService, input DocumentSigningInput) interface{} {
+	t.Helper()
+	useCase := NewDocumentSigningUseCase(service, newTestTranslator(t), BatchOptions{MaxItems: 10})
+	resp, err := useCase.Execute(context.Background(), input)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if resp.Status != "OK" {
+		t.Fatalf("Execute() = %+v, want a success response", resp)
+	}
+	encoded, err := json.Marshal(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body interface{}
+	if err := json.Unmarshal(encoded, &body); err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestDocumentSigningReturnsAssignedControlNumber(t *testing.T) {
+	const numeroControl = "DTE-01-M001P001-000000000000042"
+	payload := []byte(`{"identificacion": {"numeroControl":"` + numeroControl + `", "tipoDte": "01"}}`)
+	input := DocumentSigningInput{NIT: "06140101780010", PrivateKeyPassword: "secret", DocumentJSON: json.RawMessage(`{"identificacion": {}}`)}
+
+	tests := []struct {
+		name          string
+		signed        models.SignedDocument
+		metadata      bool
+		wantNumero    string
+		wantPayload   bool
+		wantLegacyJWS bool
+	}{
+		{
+			name:        "detached signature of an assigned document",
+			signed:      models.SignedDocument{Serialization: models.SerializationFlattened, Detached: true, AssignedControlNumber: numeroControl},
+			wantNumero:  numeroControl,
+			wantPayload: true,
+		},
+		{
+			name:        "detached unencoded signature of an assigned document",
+			signed:      models.SignedDocument{Serialization: models.SerializationCompact, Detached: true, Unencoded: true, AssignedControlNumber: numeroControl},
+			wantNumero:  numeroControl,
+			wantPayload: true,
+		},
+		{
+			name:        "detached signature with metadata of an assigned document",
+			signed:      models.SignedDocument{Serialization: models.SerializationGeneral, Detached: true, AssignedControlNumber: numeroControl},
+			metadata:    true,
+			wantNumero:  numeroControl,
+			wantPayload: true,
+		},
+		{
+			name:       "attached signature of an assigned document",
+			signed:     models.SignedDocument{Serialization: models.SerializationFlattened, AssignedControlNumber: numeroControl},
+			wantNumero: numeroControl,
+		},
+		{
+			name:   "detached signature of a document signed as sent",
+			signed: models.SignedDocument{Serialization: models.SerializationFlattened, Detached: true},
+		},
+		{
+			name:       "metadata of a document signed as sent",
+			signed:     models.SignedDocument{Serialization: models.SerializationFlattened, Detached: true},
+			metadata:   true,
+			wantNumero: numeroControl,
+		},
+		{
+			name:          "legacy signature of an assigned document",
+			signed:        models.SignedDocument{Serialization: models.SerializationCompact, AssignedControlNumber: numeroControl},
+			wantLegacyJWS: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed := tt.signed
+			signed.Serialized = `{"signature":"c2ln"}`
+			if signed.Serialization == models.SerializationCompact {
+				signed.Serialized = "header.payload.signature"
+			}
+			signed.Payload = payload
+			input.Metadata = tt.metadata
+
+			body := signingResponseBody(t, &signedDocumentService{signed: &signed}, input)
+			if tt.wantLegacyJWS {
+				if body != signed.Serialized {
+					t.Errorf("body = %v, want the compact JWS, which carries the assigned numeroControl", body)
+				}
+				return
+			}
+
+			output := body.(map[string]interface{})
+			if got, _ := output["numeroControl"].(string); got != tt.wantNumero {
+				t.Errorf("numeroControl = %q, want %q", got, tt.wantNumero)
+			}
+			encoded, hasPayload := output["payload"].(string)
+			if hasPayload != tt.wantPayload {
+				t.Fatalf("payload returned = %v, want %v", hasPayload, tt.wantPayload)
+			}
+			if hasPayload {
+				decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+				if err != nil || string(decoded) != string(payload) {
+					t.Errorf("payload = %q (%v), want the base64url encoded signed bytes", decoded, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDocumentSigningReturnsSignatureMetadata(t *testing.T) {
+	payload := []byte(`{"identificacion":{"codigoGeneracion":"0C7A5BD4-2F1E-4C59-9A4B-7E0B1E3D5A10","numeroControl":"DTE-01-M001P001-000000000000007"}}`)
+	signedAt := time.Date(2026, 3, 1, 10, 30, 0, 0, time.UTC)
+	first := models.SignatureDetails{NIT: "06140101780010", CertificateID: "current", Algorithm: "RS512", KeyID: "current", Header: map[string]interface{}{"alg": "RS512", "kid": "current"}}
+	second := models.SignatureDetails{NIT: "06142803901121", CertificateID: "other", Algorithm: "ES256", Header: map[string]interface{}{"alg": "ES256"}}
+
+	tests := []struct {
+		name           string
+		signed         models.SignedDocument
+		wantSignatures int
+	}{
+		{
+			name:   "single signer compact signature",
+			signed: models.SignedDocument{Serialized: "header.payload.signature", Serialization: models.SerializationCompact, Signatures: []models.SignatureDetails{first}},
+		},
+		{
+			name:           "several signers",
+			signed:         models.SignedDocument{Serialized: `{"signatures":[]}`, Serialization: models.SerializationGeneral, Signatures: []models.SignatureDetails{first, second}},
+			wantSignatures: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed := tt.signed
+			signed.Payload = payload
+			signed.SignedAt = signedAt
+			input := DocumentSigningInput{NIT: "06140101780010", PrivateKeyPassword: "secret", DocumentJSON: payload, Metadata: true}
+
+			output := signingResponseBody(t, &signedDocumentService{signed: &signed}, input).(map[string]interface{})
+
+			// The top-level fields describe the first signer, even for the legacy compact signature
+			digest := sha256.Sum256(payload)
+			want := map[string]interface{}{
+				"jws":              signed.Serialized,
+				"serialization":    signed.Serialization,
+				"alg":              "RS512",
+				"kid":              "current",
+				"certificateId":    "current",
+				"nit":              "06140101780010",
+				"codigoGeneracion": "0C7A5BD4-2F1E-4C59-9A4B-7E0B1E3D5A10",
+				"numeroControl":    "DTE-01-M001P001-000000000000007",
+				"signedAt":         "2026-03-01T10:30:00Z",
+			}
+			if signed.Serialization != models.SerializationCompact {
+				want["jws"] = map[string]interface{}{"signatures": []interface{}{}}
+			}
+			for name, value := range want {
+				if !reflect.DeepEqual(output[name], value) {
+					t.Errorf("%s = %v, want %v", name, output[name], value)
+				}
+			}
+			digests, _ := output["payloadDigest"].(map[string]interface{})
+			if digests["sha256"] != hex.EncodeToString(digest[:]) || len(digests["sha512"].(string)) != 128 {
+				t.Errorf("payloadDigest = %v, want the digests of the signed payload", digests)
+			}
+			signatures, _ := output["signatures"].([]interface{})
+			if len(signatures) != tt.wantSignatures {
+				t.Errorf("signatures = %v, want %d, listed only for several signers", output["signatures"], tt.wantSignatures)
+			}
+		})
+	}
+}
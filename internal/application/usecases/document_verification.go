@@ -0,0 +1,86 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+
+	errPackage "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/ports"
+	"github.com/chainedpixel/go-dte-signer/pkg/i18n"
+	"github.com/chainedpixel/go-dte-signer/pkg/response"
+)
+
+// DocumentVerificationUseCase handles signed document verification operations
+type DocumentVerificationUseCase struct {
+	signingService ports.SigningService
+	translator     *i18n.Translator
+}
+
+// NewDocumentVerificationUseCase creates a new document verification use case
+func NewDocumentVerificationUseCase(signingService ports.SigningService, translator *i18n.Translator) *DocumentVerificationUseCase {
+	return &DocumentVerificationUseCase{
+		signingService: signingService,
+		translator:     translator,
+	}
+}
+
+// DocumentVerificationInput represents the input for document verification.
+// The JWS is taken from compactSerialization or, for JSON serializations, from jws,
+// dteJson carries the payload of detached signatures and nits lists the signers of
+// multi-signer documents. certificateId restricts single-signer verification to one certificate of the NIT.
+type DocumentVerificationInput struct {
+	NIT               string          `json:"nit"`
+	NITs              []string        `json:"nits"`
+	CompactSerialized string          `json:"compactSerialization"`
+	JWS               json.RawMessage `json:"jws"`
+	DocumentJSON      json.RawMessage `json:"dteJson"`
+	Canonicalize      bool            `json:"canonicalize"`
+	CertificateID     string          `json:"certificateId"`
+}
+
+// Execute processes a document verification request
+func (uc *DocumentVerificationUseCase) Execute(ctx context.Context, input DocumentVerificationInput) (*response.Response, error) {
+	// 1. Validate input
+	serialized := uc.serializedJWS(input)
+	if (input.NIT == "" && len(input.NITs) == 0) || serialized == "" {
+		err := errPackage.NewRequiredDataError(uc.translator.T("required_data"))
+		return newErrorResponse(uc.translator, err), nil
+	}
+
+	// 2. Map input to domain model
+	request := &models.CertificateRequest{
+		NIT:               input.NIT,
+		CompactSerialized: serialized,
+		Canonicalize:      input.Canonicalize,
+		CertificateID:     input.CertificateID,
+	}
+	for _, nit := range input.NITs {
+		request.Signers = append(request.Signers, models.SignerCredentials{NIT: nit})
+	}
+	request.DocumentJSON = input.DocumentJSON
+
+	// 3. Call domain service to verify the document
+	result, err := uc.signingService.VerifyDocument(ctx, request)
+	if err != nil {
+		return newErrorResponse(uc.translator, err), nil
+	}
+
+	return response.NewSuccessResponse(result), nil
+}
+
+// serializedJWS returns the JWS to verify, accepting JSON serializations either as objects or as strings
+func (uc *DocumentVerificationUseCase) serializedJWS(input DocumentVerificationInput) string {
+	if input.CompactSerialized != "" {
+		return input.CompactSerialized
+	}
+	if len(input.JWS) == 0 {
+		return ""
+	}
+
+	var text string
+	if err := json.Unmarshal(input.JWS, &text); err == nil {
+		return text
+	}
+	return string(input.JWS)
+}
@@ -0,0 +1,113 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	errPackage "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/pkg/response"
+)
+
+// verifyingDocumentService accepts every JWS, recording the verification request it receives
+type verifyingDocumentService struct {
+	request *models.CertificateRequest
+}
+
+func (s *verifyingDocumentService) SignDocument(ctx context.Context, request *models.CertificateRequest) (*models.SignedDocument, error) {
+	return nil, errPackage.NewDomainError("internal_server_error", errPackage.CodeInvalid)
+}
+
+func (s *verifyingDocumentService) VerifyDocument(ctx context.Context, request *models.CertificateRequest) (*models.VerificationResult, error) {
+	s.request = request
+	return &models.VerificationResult{Valid: true, NIT: request.NIT}, nil
+}
+
+func TestDocumentVerificationMapsTheRequest(t *testing.T) {
+	const flattened = `{"payload":"e30","protected":"eyJhbGciOiJSUzUxMiJ9","signature":"c2ln"}`
+	tests := []struct {
+		name     string
+		input    DocumentVerificationInput
+		want     *models.CertificateRequest
+		wantCode string
+	}{
+		{
+			name:  "compact serialization",
+			input: DocumentVerificationInput{NIT: "06140101780010", CompactSerialized: "header.payload.signature", CertificateID: "current"},
+			want:  &models.CertificateRequest{NIT: "06140101780010", CompactSerialized: "header.payload.signature", CertificateID: "current"},
+		},
+		{
+			name:  "JSON serialization given as an object",
+			input: DocumentVerificationInput{NIT: "06140101780010", JWS: json.RawMessage(flattened)},
+			want:  &models.CertificateRequest{NIT: "06140101780010", CompactSerialized: flattened},
+		},
+		{
+			name:  "JSON serialization given as a string",
+			input: DocumentVerificationInput{NIT: "06140101780010", JWS: mustMarshal(t, flattened)},
+			want:  &models.CertificateRequest{NIT: "06140101780010", CompactSerialized: flattened},
+		},
+		{
+			name: "detached signature of several signers",
+			input: DocumentVerificationInput{
+				NITs:              []string{"06140101780010", "06140202780020"},
+				CompactSerialized: "header..signature",
+				DocumentJSON:      json.RawMessage(`{"a":1}`),
+				Canonicalize:      true,
+			},
+			want: &models.CertificateRequest{
+				CompactSerialized: "header..signature",
+				DocumentJSON:      json.RawMessage(`{"a":1}`),
+				Canonicalize:      true,
+				Signers:           []models.SignerCredentials{{NIT: "06140101780010"}, {NIT: "06140202780020"}},
+			},
+		},
+		{
+			name:     "missing NIT",
+			input:    DocumentVerificationInput{CompactSerialized: "header.payload.signature"},
+			wantCode: errPackage.CodeRequiredData,
+		},
+		{
+			name:     "missing JWS",
+			input:    DocumentVerificationInput{NIT: "06140101780010"},
+			wantCode: errPackage.CodeRequiredData,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &verifyingDocumentService{}
+			useCase := NewDocumentVerificationUseCase(service, newTestTranslator(t))
+			resp, err := useCase.Execute(context.Background(), tt.input)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			if tt.wantCode != "" {
+				body, ok := resp.Body.(response.ErrorBody)
+				if resp.Status != "error" || !ok || body.Code != tt.wantCode {
+					t.Errorf("Execute() = %+v, want an error with code %s", resp, tt.wantCode)
+				}
+				if service.request != nil {
+					t.Error("an incomplete request reached the signing service")
+				}
+				return
+			}
+			if resp.Status != "OK" {
+				t.Fatalf("Execute() = %+v, want a success response", resp)
+			}
+			if !reflect.DeepEqual(service.request, tt.want) {
+				t.Errorf("VerifyDocument() request = %+v, want %+v", service.request, tt.want)
+			}
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, value interface{}) json.RawMessage {
+	t.Helper()
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
@@ -0,0 +1,33 @@
+package usecases
+
+import (
+	"errors"
+
+	errPackage "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/pkg/i18n"
+	"github.com/chainedpixel/go-dte-signer/pkg/response"
+)
+
+// newErrorResponse creates a translated error response from a domain error
+func newErrorResponse(translator *i18n.Translator, err error) *response.Response {
+	var domainErr errPackage.DomainError
+	ok := errors.As(err, &domainErr)
+	if !ok {
+		// Default to internal server error
+		return response.NewErrorResponse("500", translator.T("internal_server_error"))
+	}
+
+	// Translate the error message
+	translatedMsg := translator.T(domainErr.Message)
+	if domainErr.Code == errPackage.CodePasswordInvalid {
+		// Special case for password invalid, which carries the NIT to format into the message
+		translatedMsg = translator.T("password_invalid", domainErr.Message)
+	}
+
+	// Errors with details list every violation after the message, as the Hacienda signer does
+	if len(domainErr.Details) > 0 {
+		return response.NewErrorResponse(domainErr.Code, append([]string{translatedMsg}, domainErr.Details...))
+	}
+
+	return response.NewErrorResponse(domainErr.Code, translatedMsg)
+}
@@ -8,23 +8,35 @@ import (
 	"github.com/chainedpixel/go-dte-signer/pkg/response"
 )
 
+// HealthReporter contributes the details of a component to the health check
+type HealthReporter interface {
+	// HealthName returns the key under which the component is reported
+	HealthName() string
+
+	// HealthDetails returns the current details of the component
+	HealthDetails(ctx context.Context) interface{}
+}
+
 // HealthCheckUseCase handles health check operations
 type HealthCheckUseCase struct {
 	startTime time.Time
+	reporters []HealthReporter
 }
 
 // HealthCheckOutput represents the health check response data
 type HealthCheckOutput struct {
-	Status    string    `json:"status"`
-	Uptime    string    `json:"uptime"`
-	Timestamp time.Time `json:"timestamp"`
-	GoVersion string    `json:"goVersion"`
+	Status     string                 `json:"status"`
+	Uptime     string                 `json:"uptime"`
+	Timestamp  time.Time              `json:"timestamp"`
+	GoVersion  string                 `json:"goVersion"`
+	Components map[string]interface{} `json:"components,omitempty"`
 }
 
-// NewHealthCheckUseCase creates a new health check use case
-func NewHealthCheckUseCase() *HealthCheckUseCase {
+// NewHealthCheckUseCase creates a new health check use case that includes the details of the given reporters
+func NewHealthCheckUseCase(reporters ...HealthReporter) *HealthCheckUseCase {
 	return &HealthCheckUseCase{
 		startTime: time.Now(),
+		reporters: reporters,
 	}
 }
 
@@ -44,5 +56,13 @@ func (uc *HealthCheckUseCase) Execute(ctx context.Context) (*response.Response,
 		GoVersion: runtime.Version(),
 	}
 
+	// 4. Collect the details of every reporting component
+	if len(uc.reporters) > 0 {
+		output.Components = make(map[string]interface{}, len(uc.reporters))
+		for _, reporter := range uc.reporters {
+			output.Components[reporter.HealthName()] = reporter.HealthDetails(ctx)
+		}
+	}
+
 	return response.NewSuccessResponse(output), nil
 }
@@ -6,6 +6,8 @@ import "fmt"
 type DomainError struct {
 	Code    string
 	Message string
+	// Details lists the individual violations behind the error, such as the invalid fields of a document
+	Details []string
 }
 
 // Error returns the error message
@@ -24,6 +26,18 @@ const (
 	CodeStrToJSONConversion = "811"
 	CodeFileNotFound        = "812"
 	CodePasswordInvalid     = "813"
+	CodeSignatureInvalid    = "814"
+	CodeCertificateExpired  = "815"
+	CodeKeyUnavailable      = "816"
+	CodeDocumentInvalid     = "817"
+	CodeGenerationCode      = "818"
+	CodeControlNumber       = "819"
+	CodeEnvironment         = "820"
+	CodeIssueDate           = "821"
+	CodeOperationType       = "822"
+	CodeIssuerMismatch      = "823"
+	CodeTotals              = "824"
+	CodeCertificateExists   = "825"
 )
 
 // NewDomainError creates a new domain error with the given message and code
@@ -42,10 +56,28 @@ func NewRequiredDataError(msg string) DomainError {
 	}
 }
 
-// NewPasswordInvalidError creates a new password invalid error
-func NewPasswordInvalidError(msg string) DomainError {
+// NewPasswordInvalidError creates a new password invalid error for the given NIT
+func NewPasswordInvalidError(nit string) DomainError {
 	return DomainError{
 		Code:    CodePasswordInvalid,
+		Message: nit,
+	}
+}
+
+// NewDocumentInvalidError creates a new invalid document error listing every violation found
+func NewDocumentInvalidError(msg string, details []string) DomainError {
+	return DomainError{
+		Code:    CodeDocumentInvalid,
+		Message: msg,
+		Details: details,
+	}
+}
+
+// NewDocumentRuleError creates a new error for a DTE breaking a rule, detailing the offending fields
+func NewDocumentRuleError(msg string, code string, details ...string) DomainError {
+	return DomainError{
+		Code:    code,
 		Message: msg,
+		Details: details,
 	}
 }
@@ -1,18 +1,55 @@
 package models
 
 import (
-	"crypto/rsa"
+	"crypto"
 	"encoding/base64"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"time"
 )
 
+// nitPattern matches the 14 digit NIT or the 9 digit DUI of a taxpayer
+var nitPattern = regexp.MustCompile(`^[0-9]{9,14}$`)
+
+// IsValidNIT reports whether the value is a well-formed NIT or DUI
+func IsValidNIT(nit string) bool {
+	return nitPattern.MatchString(nit)
+}
+
 // Certificate represents a digital certificate used for signing
 type Certificate struct {
-	ID                string          `json:"_id" xml:"_id"`
-	Active            bool            `json:"activo" xml:"activo"`
-	NIT               string          `json:"nit" xml:"nit"`
-	PrivateKey        Key             `json:"privateKey" xml:"privateKey"`
-	PublicKey         Key             `json:"publicKey" xml:"publicKey"`
-	DecodedPrivateKey *rsa.PrivateKey `json:"-" xml:"-"` // Not mapped to XML/JSON, for internal use only
+	ID                string           `json:"_id" xml:"_id"`
+	Active            bool             `json:"activo" xml:"activo"`
+	NIT               string           `json:"nit" xml:"nit"`
+	PrivateKey        Key              `json:"privateKey" xml:"privateKey"`
+	PublicKey         Key              `json:"publicKey" xml:"publicKey"`
+	DecodedPrivateKey crypto.Signer    `json:"-" xml:"-"` // Not mapped to XML/JSON, for internal use only
+	DecodedPublicKey  crypto.PublicKey `json:"-" xml:"-"` // Not mapped to XML/JSON, for internal use only
+	NotBefore         time.Time        `json:"-" xml:"-"` // Start of the validity window, zero when unbounded
+	NotAfter          time.Time        `json:"-" xml:"-"` // End of the validity window, zero when unbounded
+	Priority          int              `json:"-" xml:"-"` // Preference among the certificates of a NIT valid at the same time
+	IssuedAt          time.Time        `json:"-" xml:"-"` // Issue date of the key material, zero when unknown
+	ExpiresAt         time.Time        `json:"-" xml:"-"` // Expiry date of the key material, zero when unknown
+}
+
+// CertificateSummary describes a stored certificate without its key material
+type CertificateSummary struct {
+	NIT         string     `json:"nit"`
+	ID          string     `json:"_id"`
+	File        string     `json:"file,omitempty"`
+	Active      bool       `json:"activo"`
+	KeyType     string     `json:"keyType"`
+	Fingerprint string     `json:"fingerprint"`
+	NotBefore   *time.Time `json:"notBefore,omitempty"`
+	NotAfter    *time.Time `json:"notAfter,omitempty"`
+	Priority    int        `json:"priority"`
+	IssuedAt    *time.Time `json:"issuedAt,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	Expired     bool       `json:"expired"`
+	Selected    bool       `json:"selected"`
+	Valid       bool       `json:"valid"`
+	Error       string     `json:"error,omitempty"`
 }
 
 // Key represents a cryptographic key
@@ -29,31 +66,185 @@ func (c *Certificate) DecodePrivateKey() ([]byte, error) {
 	return base64.StdEncoding.DecodeString(c.PrivateKey.Encoded)
 }
 
+// DecodePublicKey decodes the base64-encoded public key
+func (c *Certificate) DecodePublicKey() ([]byte, error) {
+	return base64.StdEncoding.DecodeString(c.PublicKey.Encoded)
+}
+
 // IsActive returns whether the certificate is active
 func (c *Certificate) IsActive() bool {
 	return c.Active
 }
 
+// IsValidAt reports whether the time falls within the validity window of the certificate
+func (c *Certificate) IsValidAt(at time.Time) bool {
+	if !c.NotBefore.IsZero() && at.Before(c.NotBefore) {
+		return false
+	}
+	return c.NotAfter.IsZero() || at.Before(c.NotAfter)
+}
+
+// IsExpiredAt reports whether the key material of the certificate has expired at the given time
+func (c *Certificate) IsExpiredAt(at time.Time) bool {
+	return !c.ExpiresAt.IsZero() && !at.Before(c.ExpiresAt)
+}
+
+// SortCertificates orders the certificates of a NIT by preference: highest priority first,
+// then the most recent validity window, then by ID
+func SortCertificates(certificates []*Certificate) {
+	sort.SliceStable(certificates, func(i, j int) bool {
+		a, b := certificates[i], certificates[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if !a.NotBefore.Equal(b.NotBefore) {
+			return a.NotBefore.After(b.NotBefore)
+		}
+		return a.ID < b.ID
+	})
+}
+
+// SelectCertificate returns the preferred active certificate valid at the given time, or nil when there is none.
+// Certificates whose key material has expired are only selected when no other is available.
+func SelectCertificate(certificates []*Certificate, at time.Time) *Certificate {
+	candidates := make([]*Certificate, 0, len(certificates))
+	for _, certificate := range certificates {
+		if certificate.IsActive() && certificate.IsValidAt(at) {
+			candidates = append(candidates, certificate)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	SortCertificates(candidates)
+	for _, candidate := range candidates {
+		if !candidate.IsExpiredAt(at) {
+			return candidate
+		}
+	}
+	return candidates[0]
+}
+
 // HasPrivateKey checks if the certificate has a private key
 func (c *Certificate) HasPrivateKey() bool {
 	return c.PrivateKey.Encoded != ""
 }
 
+// HasPublicKey checks if the certificate has a public key
+func (c *Certificate) HasPublicKey() bool {
+	return c.PublicKey.Encoded != ""
+}
+
+// VerificationKey returns the public key used to verify signatures made with this certificate
+func (c *Certificate) VerificationKey() crypto.PublicKey {
+	if c.DecodedPublicKey != nil {
+		return c.DecodedPublicKey
+	}
+	if c.DecodedPrivateKey != nil {
+		return c.DecodedPrivateKey.Public()
+	}
+	return nil
+}
+
 // CertificateRequest contains the request data for signing a document
 type CertificateRequest struct {
-	PublicKeyPassword  string      `json:"passwordPub"`
-	PrivateKeyPassword string      `json:"passwordPri"`
-	NIT                string      `json:"nit"`
-	DocumentName       string      `json:"nombreDocumento"`
-	SignatureName      string      `json:"nombreFirma"`
-	CompactSerialized  string      `json:"compactSerialization"`
-	DocumentJSON       interface{} `json:"dteJson"`
-	Document           string      `json:"dte"`
-	Active             bool        `json:"activo"`
-	Path               string      `json:"path"`
+	PublicKeyPassword  string                 `json:"passwordPub"`
+	PrivateKeyPassword string                 `json:"passwordPri"`
+	NIT                string                 `json:"nit"`
+	DocumentName       string                 `json:"nombreDocumento"`
+	SignatureName      string                 `json:"nombreFirma"`
+	CompactSerialized  string                 `json:"compactSerialization"`
+	DocumentJSON       json.RawMessage        `json:"dteJson"`
+	Document           string                 `json:"dte"`
+	Active             bool                   `json:"activo"`
+	Path               string                 `json:"path"`
+	Headers            map[string]interface{} `json:"jwsHeaders"`
+	Serialization      string                 `json:"serialization"`
+	Detached           bool                   `json:"detached"`
+	UnencodedPayload   bool                   `json:"unencodedPayload"`
+	Signers            []SignerCredentials    `json:"signers"`
+	Canonicalize       bool                   `json:"canonicalize"`
+	CertificateID      string                 `json:"certificateId"`
+	ValidateSchema     *bool                  `json:"validateSchema"`
+	// AssignControlNumber replaces the numeroControl of the document with the next one of its series
+	AssignControlNumber bool   `json:"assignNumeroControl"`
+	Establishment       string `json:"establecimiento"`
+	PointOfSale         string `json:"puntoVenta"`
+}
+
+// SignerCredentials identifies a certificate taking part in a multi-signer request
+type SignerCredentials struct {
+	NIT                string `json:"nit"`
+	PrivateKeyPassword string `json:"passwordPri"`
+	PublicKeyPassword  string `json:"passwordPub"`
+	CertificateID      string `json:"certificateId"`
 }
 
 // Validate checks if the certificate request contains the required fields
 func (r *CertificateRequest) Validate() bool {
-	return r.NIT != "" && r.PrivateKeyPassword != "" && r.DocumentJSON != nil
+	if !r.HasDocument() {
+		return false
+	}
+
+	credentials := r.Credentials()
+	if len(credentials) == 0 {
+		return false
+	}
+
+	seen := make(map[string]bool, len(credentials))
+	for _, c := range credentials {
+		if c.NIT == "" || c.PrivateKeyPassword == "" || seen[c.NIT] {
+			return false
+		}
+		seen[c.NIT] = true
+	}
+
+	return true
+}
+
+// Credentials returns the credentials of every signer of the request, starting with the main NIT
+func (r *CertificateRequest) Credentials() []SignerCredentials {
+	credentials := make([]SignerCredentials, 0, len(r.Signers)+1)
+	if r.NIT != "" || len(r.Signers) == 0 {
+		credentials = append(credentials, SignerCredentials{
+			NIT:                r.NIT,
+			PrivateKeyPassword: r.PrivateKeyPassword,
+			PublicKeyPassword:  r.PublicKeyPassword,
+			CertificateID:      r.CertificateID,
+		})
+	}
+	return append(credentials, r.Signers...)
+}
+
+// SignerNITs returns the NIT of every signer of the request, starting with the main NIT
+func (r *CertificateRequest) SignerNITs() []string {
+	credentials := r.Credentials()
+	nits := make([]string, 0, len(credentials))
+	for _, c := range credentials {
+		if c.NIT != "" {
+			nits = append(nits, c.NIT)
+		}
+	}
+	return nits
+}
+
+// SigningOptions returns the JWS options requested for the document
+func (r *CertificateRequest) SigningOptions() SigningOptions {
+	return SigningOptions{
+		Headers:       r.Headers,
+		Serialization: r.Serialization,
+		Detached:      r.Detached,
+		Unencoded:     r.UnencodedPayload,
+	}
+}
+
+// HasDocument reports whether the request carries a document JSON
+func (r *CertificateRequest) HasDocument() bool {
+	return len(r.DocumentJSON) > 0 && string(r.DocumentJSON) != "null"
+}
+
+// ValidateVerification checks if the request contains the fields required to verify a JWS
+func (r *CertificateRequest) ValidateVerification() bool {
+	return len(r.SignerNITs()) > 0 && r.CompactSerialized != ""
 }
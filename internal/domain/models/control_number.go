@@ -0,0 +1,49 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// MaxControlNumberSequence is the last correlative of a series, the largest number of 15 digits
+const MaxControlNumberSequence int64 = 999999999999999
+
+var (
+	// tipoDtePattern matches the two digit code of a DTE type
+	tipoDtePattern = regexp.MustCompile(`^[0-9]{2}$`)
+	// locationCodePattern matches the four character code of an establishment or point of sale
+	locationCodePattern = regexp.MustCompile(`^[A-Z0-9]{4}$`)
+)
+
+// ControlNumberSeries identifies a numeroControl correlative: the documents of a DTE type issued
+// by a NIT at a point of sale of one of its establishments
+type ControlNumberSeries struct {
+	NIT             string `json:"nit"`
+	TipoDte         string `json:"tipoDte"`
+	Establecimiento string `json:"establecimiento"`
+	PuntoVenta      string `json:"puntoVenta"`
+}
+
+// ControlNumber is a numeroControl allocated from a series
+type ControlNumber struct {
+	ControlNumberSeries
+	Sequence      int64  `json:"sequence"`
+	NumeroControl string `json:"numeroControl,omitempty"`
+}
+
+// Validate checks that the series has a valid NIT, a two digit DTE type and four character
+// establishment and point of sale codes
+func (s ControlNumberSeries) Validate() bool {
+	return IsValidNIT(s.NIT) && tipoDtePattern.MatchString(s.TipoDte) &&
+		locationCodePattern.MatchString(s.Establecimiento) && locationCodePattern.MatchString(s.PuntoVenta)
+}
+
+// Key identifies the series as "<nit>/<tipoDte>/<establecimiento>/<puntoVenta>"
+func (s ControlNumberSeries) Key() string {
+	return s.NIT + "/" + s.TipoDte + "/" + s.Establecimiento + "/" + s.PuntoVenta
+}
+
+// Format returns the numeroControl of a correlative of the series, DTE-<tipoDte>-<establecimiento><puntoVenta>-<15 digits>
+func (s ControlNumberSeries) Format(sequence int64) string {
+	return fmt.Sprintf("DTE-%s-%s%s-%015d", s.TipoDte, s.Establecimiento, s.PuntoVenta, sequence)
+}
@@ -0,0 +1,71 @@
+package models
+
+// Hacienda environments of a DTE
+const (
+	AmbientePruebas    = "00"
+	AmbienteProduccion = "01"
+)
+
+// Transmission models and operation types of a DTE
+const (
+	ModeloPrevio          = 1
+	ModeloDiferido        = 2
+	OperacionNormal       = 1
+	OperacionContingencia = 2
+)
+
+// TipoDteComprobanteDonacion is the DTE type of the Comprobante de Donación, issued by its donatario
+const TipoDteComprobanteDonacion = "15"
+
+// DTE holds the parts of a Documento Tributario Electrónico checked before it is signed
+type DTE struct {
+	Identificacion *DTEIdentificacion `json:"identificacion"`
+	Emisor         *DTEEmisor         `json:"emisor"`
+	Donatario      *DTEDonatario      `json:"donatario"`
+}
+
+// DTEIdentificacion holds the identificacion block of a DTE
+type DTEIdentificacion struct {
+	Version          int     `json:"version"`
+	Ambiente         string  `json:"ambiente"`
+	TipoDte          string  `json:"tipoDte"`
+	NumeroControl    string  `json:"numeroControl"`
+	CodigoGeneracion string  `json:"codigoGeneracion"`
+	TipoModelo       int     `json:"tipoModelo"`
+	TipoOperacion    int     `json:"tipoOperacion"`
+	TipoContingencia *int    `json:"tipoContingencia"`
+	MotivoContin     *string `json:"motivoContin"`
+	FecEmi           string  `json:"fecEmi"`
+	HorEmi           string  `json:"horEmi"`
+	TipoMoneda       string  `json:"tipoMoneda"`
+}
+
+// DTEEmisor holds the issuer of a DTE
+type DTEEmisor struct {
+	NIT           string  `json:"nit"`
+	CodEstable    *string `json:"codEstable"`
+	CodPuntoVenta *string `json:"codPuntoVenta"`
+}
+
+// DTEDonatario holds the recipient of a donation, who issues the Comprobante de Donación
+type DTEDonatario struct {
+	TipoDocumento string `json:"tipoDocumento"`
+	NumDocumento  string `json:"numDocumento"`
+}
+
+// IssuerNIT returns the NIT of the taxpayer issuing the document and its JSON path: emisor.nit,
+// which for the Factura de Sujeto Excluido (14) is the buyer issuing on behalf of the excluded
+// subject, or donatario.numDocumento for the Comprobante de Donación (15). The NIT is empty when
+// the document does not state it.
+func (d DTE) IssuerNIT() (string, string) {
+	if d.Identificacion != nil && d.Identificacion.TipoDte == TipoDteComprobanteDonacion {
+		if d.Donatario == nil {
+			return "", "$.donatario.numDocumento"
+		}
+		return d.Donatario.NumDocumento, "$.donatario.numDocumento"
+	}
+	if d.Emisor == nil {
+		return "", "$.emisor.nit"
+	}
+	return d.Emisor.NIT, "$.emisor.nit"
+}
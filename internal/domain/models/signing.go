@@ -0,0 +1,101 @@
+package models
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Supported JWS serializations
+const (
+	SerializationCompact   = "compact"
+	SerializationFlattened = "flattened"
+	SerializationGeneral   = "general"
+)
+
+// SigningOptions holds per-request options that shape the produced JWS
+type SigningOptions struct {
+	// Headers are additional protected header parameters requested by the client
+	Headers map[string]interface{}
+	// Serialization is one of compact (default), flattened or general
+	Serialization string
+	// Detached removes the payload from the serialized JWS (RFC 7515 Appendix F)
+	Detached bool
+	// Unencoded signs the payload without base64url encoding it (RFC 7797, b64=false); requires Detached
+	Unencoded bool
+}
+
+// Validate checks that the serialization is known and that unencoded payloads are detached
+func (o SigningOptions) Validate() bool {
+	switch o.Serialization {
+	case "", SerializationCompact, SerializationFlattened, SerializationGeneral:
+	default:
+		return false
+	}
+	return !o.Unencoded || o.Detached
+}
+
+// SignedDocument represents a serialized JWS produced by the signer
+type SignedDocument struct {
+	Serialized    string
+	Serialization string
+	Detached      bool
+	Unencoded     bool
+	// Payload holds the exact bytes that were signed
+	Payload []byte
+	// AssignedControlNumber is the numeroControl the service wrote into the document, empty when it kept its own
+	AssignedControlNumber string
+	// SignedAt is the time the document was signed
+	SignedAt time.Time
+	// Signatures describes every signature of the document, in serialization order
+	Signatures []SignatureDetails
+}
+
+// PayloadDigests holds the hex encoded digests of a signed payload
+type PayloadDigests struct {
+	SHA256 string `json:"sha256"`
+	SHA512 string `json:"sha512"`
+}
+
+// PayloadDigests returns the SHA-256 and SHA-512 digests of the signed payload
+func (d *SignedDocument) PayloadDigests() PayloadDigests {
+	digest256 := sha256.Sum256(d.Payload)
+	digest512 := sha512.Sum512(d.Payload)
+	return PayloadDigests{
+		SHA256: hex.EncodeToString(digest256[:]),
+		SHA512: hex.EncodeToString(digest512[:]),
+	}
+}
+
+// CodigoGeneracion returns the identificacion.codigoGeneracion of the signed DTE, if present
+func (d *SignedDocument) CodigoGeneracion() string {
+	var document struct {
+		Identificacion struct {
+			CodigoGeneracion string `json:"codigoGeneracion"`
+		} `json:"identificacion"`
+	}
+	if err := json.Unmarshal(d.Payload, &document); err != nil {
+		return ""
+	}
+	return document.Identificacion.CodigoGeneracion
+}
+
+// NumeroControl returns the identificacion.numeroControl of the signed DTE, if present
+func (d *SignedDocument) NumeroControl() string {
+	var document struct {
+		Identificacion struct {
+			NumeroControl string `json:"numeroControl"`
+		} `json:"identificacion"`
+	}
+	if err := json.Unmarshal(d.Payload, &document); err != nil {
+		return ""
+	}
+	return document.Identificacion.NumeroControl
+}
+
+// IsLegacy reports whether the document is the compact attached JWS returned by the Hacienda signer
+func (d *SignedDocument) IsLegacy() bool {
+	return d.Serialization == SerializationCompact && !d.Detached && !d.Unencoded
+}
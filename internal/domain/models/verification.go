@@ -0,0 +1,23 @@
+package models
+
+// VerificationResult represents the outcome of verifying a signed document
+type VerificationResult struct {
+	Valid         bool                   `json:"valid"`
+	NIT           string                 `json:"nit"`
+	Algorithm     string                 `json:"algorithm"`
+	KeyID         string                 `json:"kid,omitempty"`
+	Serialization string                 `json:"serialization"`
+	Detached      bool                   `json:"detached"`
+	Header        map[string]interface{} `json:"header"`
+	Payload       interface{}            `json:"payload"`
+	Signatures    []SignatureDetails     `json:"signatures,omitempty"`
+}
+
+// SignatureDetails describes one signature of a document and the certificate that produced it
+type SignatureDetails struct {
+	NIT           string                 `json:"nit"`
+	CertificateID string                 `json:"certificateId,omitempty"`
+	Algorithm     string                 `json:"algorithm"`
+	KeyID         string                 `json:"kid,omitempty"`
+	Header        map[string]interface{} `json:"header"`
+}
@@ -2,15 +2,62 @@ package ports
 
 import (
 	"context"
+	"time"
 
 	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
 )
 
 // CertificateRepository defines the operations for certificate data access
 type CertificateRepository interface {
-	// GetByNIT retrieves a certificate by NIT
+	// GetByNIT retrieves the preferred active certificate of a NIT that is valid now
 	GetByNIT(ctx context.Context, nit string) (*models.Certificate, error)
 
+	// GetByID retrieves a specific certificate of a NIT, which must be active and valid now
+	GetByID(ctx context.Context, nit string, id string) (*models.Certificate, error)
+
+	// ListByNIT retrieves every active certificate of a NIT regardless of its validity window, in preference order
+	ListByNIT(ctx context.Context, nit string) ([]*models.Certificate, error)
+
 	// VerifyPassword checks if the password is valid for the certificate
 	VerifyPassword(ctx context.Context, certificate *models.Certificate, password string) (bool, error)
+
+	// VerifyPublicPassword checks if the password is valid for the certificate public key
+	VerifyPublicPassword(ctx context.Context, certificate *models.Certificate, password string) (bool, error)
+}
+
+// CertificateStore extends the certificate repository with the write operations used to administer certificates
+type CertificateStore interface {
+	CertificateRepository
+
+	// List returns a summary of every stored certificate, including inactive and invalid ones
+	List(ctx context.Context) ([]models.CertificateSummary, error)
+
+	// Save validates and stores a certificate file, replacing the certificate of the same NIT only when replace is set
+	Save(ctx context.Context, content []byte, replace bool) (*models.CertificateSummary, error)
+
+	// SetActive activates or deactivates a certificate of a NIT; the ID is only required when the NIT has several
+	SetActive(ctx context.Context, nit string, id string, active bool) (*models.CertificateSummary, error)
+
+	// SetExpiry sets or, when nil, clears the expiry date of a certificate of a NIT; the ID is only required when the NIT has several
+	SetExpiry(ctx context.Context, nit string, id string, expiresAt *time.Time) (*models.CertificateSummary, error)
+
+	// Delete removes a certificate of a NIT; the ID is only required when the NIT has several
+	Delete(ctx context.Context, nit string, id string) error
+}
+
+// ControlNumberRepository defines the persistence of the numeroControl correlatives. Every
+// operation is atomic, so concurrent requests never receive the same correlative.
+type ControlNumberRepository interface {
+	// Next increments the correlative of a series, starting at 1, and returns it
+	Next(ctx context.Context, series models.ControlNumberSeries) (int64, error)
+
+	// Current returns the last correlative allocated from a series, 0 when none was
+	Current(ctx context.Context, series models.ControlNumberSeries) (int64, error)
+
+	// Advance raises the correlative of a series to at least the given one, never lowering it, and returns it
+	Advance(ctx context.Context, series models.ControlNumberSeries, sequence int64) (int64, error)
+
+	// Release returns the given correlative to its series while it is still the last one allocated, so it is
+	// allocated again, and reports whether it did; a later allocation or advance keeps it taken
+	Release(ctx context.Context, series models.ControlNumberSeries, sequence int64) (bool, error)
 }
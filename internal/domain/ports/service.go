@@ -2,6 +2,8 @@ package ports
 
 import (
 	"context"
+	"crypto"
+	"time"
 
 	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
 )
@@ -9,17 +11,94 @@ import (
 // SigningService defines the operations for signing documents
 type SigningService interface {
 	// SignDocument signs a document with the specified certificate
-	SignDocument(ctx context.Context, request *models.CertificateRequest) (string, error)
+	SignDocument(ctx context.Context, request *models.CertificateRequest) (*models.SignedDocument, error)
+
+	// VerifyDocument verifies a signed document against the certificate of the specified NIT
+	VerifyDocument(ctx context.Context, request *models.CertificateRequest) (*models.VerificationResult, error)
+}
+
+// CertificateAdminService defines the operations for administering certificates
+type CertificateAdminService interface {
+	// ListCertificates returns a summary of every stored certificate
+	ListCertificates(ctx context.Context) ([]models.CertificateSummary, error)
+
+	// UploadCertificate validates and stores a certificate file
+	UploadCertificate(ctx context.Context, content []byte, replace bool) (*models.CertificateSummary, error)
+
+	// SetCertificateActive activates or deactivates a certificate of a NIT
+	SetCertificateActive(ctx context.Context, nit string, id string, active bool) (*models.CertificateSummary, error)
+
+	// SetCertificateExpiry sets or clears the expiry date of a certificate of a NIT
+	SetCertificateExpiry(ctx context.Context, nit string, id string, expiresAt *time.Time) (*models.CertificateSummary, error)
+
+	// DeleteCertificate removes a certificate of a NIT
+	DeleteCertificate(ctx context.Context, nit string, id string) error
+}
+
+// ControlNumberService defines the allocation of numeroControl values from persistent correlatives
+type ControlNumberService interface {
+	// NextControlNumber allocates the next numeroControl of a series
+	NextControlNumber(ctx context.Context, series models.ControlNumberSeries) (*models.ControlNumber, error)
+
+	// CurrentControlNumber returns the last numeroControl allocated from a series
+	CurrentControlNumber(ctx context.Context, series models.ControlNumberSeries) (*models.ControlNumber, error)
+
+	// AdvanceControlNumber raises the correlative of a series to at least the given one, e.g. to continue the correlative of an ERP
+	AdvanceControlNumber(ctx context.Context, series models.ControlNumberSeries, sequence int64) (*models.ControlNumber, error)
+
+	// ReleaseControlNumber returns an allocated numeroControl that was never used to its series, when no later one was allocated
+	ReleaseControlNumber(ctx context.Context, number *models.ControlNumber) (bool, error)
 }
 
 // KeyProcessor defines operations for processing cryptographic keys
 type KeyProcessor interface {
-	// BytesToPrivateKey converts a byte array to an RSA private key
+	// BytesToPrivateKey converts a PKCS#8 byte array to a private key
 	BytesToPrivateKey(bytes []byte) (*models.Certificate, error)
+
+	// BytesToPublicKey converts a DER-encoded SubjectPublicKeyInfo to a public key
+	BytesToPublicKey(bytes []byte) (crypto.PublicKey, error)
+
+	// CheckKeys validates the private key of a certificate and checks that it belongs to its public key
+	CheckKeys(certificate *models.Certificate) error
 }
 
 // DocumentSigner defines operations for signing documents
 type DocumentSigner interface {
 	// Sign signs a document with the provided certificate
-	Sign(ctx context.Context, certificate *models.Certificate, documentData interface{}) (string, error)
+	Sign(ctx context.Context, certificate *models.Certificate, documentData interface{}, options models.SigningOptions) (*models.SignedDocument, error)
+
+	// SignMulti signs a document with several certificates, producing one signature per certificate
+	SignMulti(ctx context.Context, certificates []*models.Certificate, documentData interface{}, options models.SigningOptions) (*models.SignedDocument, error)
+}
+
+// DocumentVerifier defines operations for verifying signed documents
+type DocumentVerifier interface {
+	// Verify verifies a serialized JWS with the provided certificate; the payload is only used for detached signatures
+	Verify(ctx context.Context, certificate *models.Certificate, serialized string, detachedPayload []byte) (*models.VerificationResult, error)
+
+	// VerifyMulti verifies that every signature of a JWS belongs to one signer and every signer produced one of them.
+	// Each signer is given as its candidate certificates in preference order.
+	VerifyMulti(ctx context.Context, signers [][]*models.Certificate, serialized string, detachedPayload []byte) (*models.VerificationResult, error)
+}
+
+// DocumentValidator defines the validation of a DTE before it is signed
+type DocumentValidator interface {
+	// Validate checks a DTE, returning a domain error that lists every violation found
+	Validate(ctx context.Context, document []byte) error
+}
+
+// KeyEncryptionService defines the envelope encryption of the key material stored at rest. Each
+// content is encrypted with its own data key, which is wrapped by a master key.
+type KeyEncryptionService interface {
+	// Encrypt seals a content with a new data key wrapped by the active master key
+	Encrypt(ctx context.Context, plaintext []byte) ([]byte, error)
+
+	// Decrypt opens an envelope sealed with any of the known master keys
+	Decrypt(ctx context.Context, envelope []byte) ([]byte, error)
+
+	// Rewrap wraps the data key of an envelope with the active master key, leaving its content untouched
+	Rewrap(ctx context.Context, envelope []byte) ([]byte, error)
+
+	// IsEncrypted reports whether a content is an envelope
+	IsEncrypted(content []byte) bool
 }
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/ports"
+)
+
+// CertificateAdminService implements the ports.CertificateAdminService interface
+type CertificateAdminService struct {
+	certStore ports.CertificateStore
+}
+
+// NewCertificateAdminService creates a new certificate administration service
+func NewCertificateAdminService(certStore ports.CertificateStore) *CertificateAdminService {
+	return &CertificateAdminService{
+		certStore: certStore,
+	}
+}
+
+// ListCertificates returns a summary of every stored certificate
+func (s *CertificateAdminService) ListCertificates(ctx context.Context) ([]models.CertificateSummary, error) {
+	return s.certStore.List(ctx)
+}
+
+// UploadCertificate validates and stores a certificate file
+func (s *CertificateAdminService) UploadCertificate(ctx context.Context, content []byte, replace bool) (*models.CertificateSummary, error) {
+	// 1: Validate the request
+	if len(content) == 0 {
+		return nil, errors.NewRequiredDataError("required_data")
+	}
+
+	// 2: Store the certificate, the store validates its content
+	return s.certStore.Save(ctx, content, replace)
+}
+
+// SetCertificateActive activates or deactivates a certificate of a NIT
+func (s *CertificateAdminService) SetCertificateActive(ctx context.Context, nit string, id string, active bool) (*models.CertificateSummary, error) {
+	// 1: Validate the NIT, it names the certificate file
+	if !models.IsValidNIT(nit) {
+		return nil, errors.NewDomainError("invalid_nit", errors.CodeInvalid)
+	}
+
+	// 2: Update the certificate
+	return s.certStore.SetActive(ctx, nit, id, active)
+}
+
+// SetCertificateExpiry sets or clears the expiry date of a certificate of a NIT
+func (s *CertificateAdminService) SetCertificateExpiry(ctx context.Context, nit string, id string, expiresAt *time.Time) (*models.CertificateSummary, error) {
+	// 1: Validate the NIT, it names the certificate file
+	if !models.IsValidNIT(nit) {
+		return nil, errors.NewDomainError("invalid_nit", errors.CodeInvalid)
+	}
+
+	// 2: Update the certificate metadata
+	return s.certStore.SetExpiry(ctx, nit, id, expiresAt)
+}
+
+// DeleteCertificate removes a certificate of a NIT
+func (s *CertificateAdminService) DeleteCertificate(ctx context.Context, nit string, id string) error {
+	// 1: Validate the NIT, it names the certificate file
+	if !models.IsValidNIT(nit) {
+		return errors.NewDomainError("invalid_nit", errors.CodeInvalid)
+	}
+
+	// 2: Remove the certificate
+	return s.certStore.Delete(ctx, nit, id)
+}
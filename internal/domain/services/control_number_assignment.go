@@ -0,0 +1,193 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/pkg/jcs"
+)
+
+// prepareDocument returns the bytes to sign for the document of a request and, when its
+// numeroControl must be assigned, the series to allocate it from. The document then carries the
+// placeholder numeroControl of its series, so it is checked as it will be signed, while the
+// correlative is only allocated by assignControlNumber once every check has passed.
+func (s *SigningService) prepareDocument(request *models.CertificateRequest, nit string) ([]byte, *models.ControlNumberSeries, error) {
+	// 1: Process the document as before when no control number can be assigned
+	if s.controlNumbers == nil {
+		if request.AssignControlNumber {
+			return nil, nil, errors.NewDomainError("numero_control_disabled", errors.CodeControlNumber)
+		}
+		documentData, err := documentBytes(request.DocumentJSON, s.canonicalize(request))
+		return documentData, nil, err
+	}
+	documentData, err := documentBytes(request.DocumentJSON, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 2: Write the placeholder of the series of the document when its numeroControl is assigned
+	series, err := s.controlNumberSeries(request, nit, documentData)
+	if err != nil {
+		return nil, nil, err
+	}
+	if series != nil {
+		documentData, err = setMember(documentData, "identificacion", "numeroControl", series.Format(0))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// 3: Canonicalize the document when requested; the correlative replaces a string of the same form, so it stays canonical
+	if s.canonicalize(request) {
+		canonical, err := jcs.Transform(documentData)
+		if err != nil {
+			return nil, nil, errors.NewDomainError("invalid_document_json", errors.CodeStrToJSONConversion)
+		}
+		documentData = canonical
+	}
+	return documentData, series, nil
+}
+
+// controlNumberSeries returns the series of the numeroControl to assign to a document, or nil when it keeps its own.
+// The series is that of the issuer of the document, or the signing NIT when it states none, its tipoDte, and the
+// establishment and point of sale of the request or, when omitted, of its emisor. The issuer must be the signing
+// NIT or one of the issuers it may sign for, so a document never takes a correlative of another taxpayer.
+func (s *SigningService) controlNumberSeries(request *models.CertificateRequest, nit string, documentData []byte) (*models.ControlNumberSeries, error) {
+	// 1: Decode the fields identifying the series; fields of unexpected types are left to the validators
+	var dte models.DTE
+	if err := json.Unmarshal(documentData, &dte); err != nil {
+		if _, ok := err.(*json.UnmarshalTypeError); !ok {
+			return nil, errors.NewDomainError("invalid_document_json", errors.CodeStrToJSONConversion)
+		}
+	}
+	if dte.Identificacion == nil {
+		return nil, errors.NewDocumentInvalidError("dte_identificacion_invalid", []string{"$.identificacion: is required"})
+	}
+	if !request.AssignControlNumber && (!s.policy.AssignControlNumber || dte.Identificacion.NumeroControl != "") {
+		return nil, nil
+	}
+
+	// 2: Identify the series, refusing issuers the signing NIT may not sign for
+	series := &models.ControlNumberSeries{
+		NIT:             nit,
+		TipoDte:         dte.Identificacion.TipoDte,
+		Establecimiento: request.Establishment,
+		PuntoVenta:      request.PointOfSale,
+	}
+	if issuer, path := dte.IssuerNIT(); issuer != "" {
+		if !s.mayIssue(nit, issuer) {
+			return nil, errors.NewDocumentRuleError("emisor_nit_mismatch", errors.CodeIssuerMismatch,
+				fmt.Sprintf("%s: %q is not the signing NIT %q, its numeroControl cannot be assigned", path, issuer, nit))
+		}
+		series.NIT = strings.ReplaceAll(issuer, "-", "")
+	}
+	if dte.Emisor != nil && series.Establecimiento == "" && dte.Emisor.CodEstable != nil {
+		series.Establecimiento = *dte.Emisor.CodEstable
+	}
+	if dte.Emisor != nil && series.PuntoVenta == "" && dte.Emisor.CodPuntoVenta != nil {
+		series.PuntoVenta = *dte.Emisor.CodPuntoVenta
+	}
+	if !series.Validate() {
+		return nil, errors.NewDocumentRuleError("numero_control_series_invalid", errors.CodeControlNumber,
+			fmt.Sprintf("$.identificacion.numeroControl: NIT %q, tipoDte %q, establecimiento %q and puntoVenta %q do not identify a series",
+				series.NIT, series.TipoDte, series.Establecimiento, series.PuntoVenta))
+	}
+	return series, nil
+}
+
+// assignControlNumber allocates the next correlative of a series and writes it over the placeholder of the document
+func (s *SigningService) assignControlNumber(ctx context.Context, series *models.ControlNumberSeries, documentData []byte) ([]byte, *models.ControlNumber, error) {
+	number, err := s.controlNumbers.NextControlNumber(ctx, *series)
+	if err != nil {
+		return nil, nil, err
+	}
+	documentData, err = setMember(documentData, "identificacion", "numeroControl", number.NumeroControl)
+	if err != nil {
+		s.releaseControlNumber(ctx, number)
+		return nil, nil, err
+	}
+	return documentData, number, nil
+}
+
+// releaseControlNumber returns the numeroControl of a document that could not be signed to its series. It stays
+// taken, leaving a gap, when another document already took a later one or the repository fails; the error of
+// the signature is the one reported either way.
+func (s *SigningService) releaseControlNumber(ctx context.Context, number *models.ControlNumber) {
+	// The number is returned even when the signature failed because the request was cancelled
+	_, _ = s.controlNumbers.ReleaseControlNumber(context.WithoutCancel(ctx), number)
+}
+
+// setMember sets a string member of a top-level object of a document, leaving the rest of the
+// document byte for byte as it is. A missing member is added first in the object.
+func setMember(document []byte, object string, member string, value string) ([]byte, error) {
+	invalid := errors.NewDomainError("invalid_document_json", errors.CodeStrToJSONConversion)
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, invalid
+	}
+
+	// 1: Find the object among the members of the document
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, invalid
+	}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, invalid
+		}
+		if key != object {
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return nil, invalid
+			}
+			continue
+		}
+		if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+			return nil, invalid
+		}
+		objectStart := decoder.InputOffset()
+
+		// 2: Replace the value of the member when present
+		empty := true
+		for decoder.More() {
+			empty = false
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, invalid
+			}
+			var current json.RawMessage
+			if err := decoder.Decode(&current); err != nil {
+				return nil, invalid
+			}
+			if key == member {
+				end := decoder.InputOffset()
+				start := end - int64(len(current))
+				return concat(document[:start], encoded, document[end:]), nil
+			}
+		}
+
+		// 3: Otherwise add it first in the object
+		added := append(append([]byte(nil), fmt.Sprintf("%q:", member)...), encoded...)
+		if !empty {
+			added = append(added, ',')
+		}
+		return concat(document[:objectStart], added, document[objectStart:]), nil
+	}
+
+	return nil, invalid
+}
+
+// concat joins byte slices into a new one
+func concat(parts ...[]byte) []byte {
+	var joined []byte
+	for _, part := range parts {
+		joined = append(joined, part...)
+	}
+	return joined
+}
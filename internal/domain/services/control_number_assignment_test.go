@@ -0,0 +1,426 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/ports"
+)
+
+const (
+	testNIT      = "06140101780010"
+	testOtherNIT = "06142803901121"
+)
+
+// testDocument returns a factura issued by a NIT at M001/P001 with the given numeroControl member, omitted when empty
+func testDocument(issuer string, numeroControl string) string {
+	member := ""
+	if numeroControl != "" {
+		member = `"numeroControl": ` + numeroControl + `, `
+	}
+	return `{"identificacion": {"version": 1, "tipoDte": "01", ` + member + `"ambiente": "00"}, ` +
+		`"emisor": {"nit": "` + issuer + `", "codEstable": "M001", "codPuntoVenta": "P001"}, "resumen": {"totalPagar": 1.10}}`
+}
+
+// newTestSigningService returns a signing service assigning control numbers from an in-memory repository
+func newTestSigningService(policy SigningPolicy, schemaValidator ports.DocumentValidator, ruleValidators ...ports.DocumentValidator) (*SigningService, *fakeControlNumberRepository) {
+	repository := &fakeControlNumberRepository{}
+	service := NewSigningService(newTestCertificateRepository(testNIT), fakeDocumentSigner{}, nil, schemaValidator,
+		NewControlNumberService(repository), policy, ruleValidators...)
+	return service, repository
+}
+
+func TestSignDocumentAssignsControlNumber(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		assign   bool
+		autofill bool
+		want     string
+		// wantAssigned is the numeroControl reported as written by the service
+		wantAssigned string
+	}{
+		{
+			name:         "autofill adds a missing numeroControl",
+			document:     testDocument(testNIT, ""),
+			autofill:     true,
+			want:         `{"identificacion": {"numeroControl":"DTE-01-M001P001-000000000000001","version": 1, "tipoDte": "01", "ambiente": "00"}, "emisor": {"nit": "06140101780010", "codEstable": "M001", "codPuntoVenta": "P001"}, "resumen": {"totalPagar": 1.10}}`,
+			wantAssigned: "DTE-01-M001P001-000000000000001",
+		},
+		{
+			name:         "autofill replaces a null numeroControl",
+			document:     testDocument(testNIT, "null"),
+			autofill:     true,
+			want:         testDocument(testNIT, `"DTE-01-M001P001-000000000000001"`),
+			wantAssigned: "DTE-01-M001P001-000000000000001",
+		},
+		{
+			name:     "autofill keeps a stated numeroControl",
+			document: testDocument(testNIT, `"DTE-01-ABCD1234-000000000000077"`),
+			autofill: true,
+			want:     testDocument(testNIT, `"DTE-01-ABCD1234-000000000000077"`),
+		},
+		{
+			name:         "assignNumeroControl replaces a stated numeroControl",
+			document:     testDocument(testNIT, `"DTE-01-ABCD1234-000000000000077"`),
+			assign:       true,
+			want:         testDocument(testNIT, `"DTE-01-M001P001-000000000000001"`),
+			wantAssigned: "DTE-01-M001P001-000000000000001",
+		},
+		{
+			name:         "dashes of the issuer NIT are ignored",
+			document:     testDocument("0614-010178-001-0", `""`),
+			autofill:     true,
+			want:         testDocument("0614-010178-001-0", `"DTE-01-M001P001-000000000000001"`),
+			wantAssigned: "DTE-01-M001P001-000000000000001",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _ := newTestSigningService(SigningPolicy{AssignControlNumber: tt.autofill}, nil)
+			signed, err := service.SignDocument(context.Background(), &models.CertificateRequest{
+				NIT:                 testNIT,
+				PrivateKeyPassword:  "secret",
+				DocumentJSON:        json.RawMessage(tt.document),
+				AssignControlNumber: tt.assign,
+			})
+			if err != nil {
+				t.Fatalf("SignDocument() error = %v", err)
+			}
+			if string(signed.Payload) != tt.want {
+				t.Errorf("SignDocument() payload\n got %s\nwant %s", signed.Payload, tt.want)
+			}
+			if signed.AssignedControlNumber != tt.wantAssigned {
+				t.Errorf("SignDocument() assigned numeroControl %q, want %q", signed.AssignedControlNumber, tt.wantAssigned)
+			}
+		})
+	}
+}
+
+func TestSignDocumentAllocatesOnlyAfterValidation(t *testing.T) {
+	rejected := errors.NewDocumentRuleError("dte_totals_invalid", errors.CodeTotals, "$.resumen.totalPagar: rejected")
+	var validated []string
+	recordPlaceholder := validatorFunc(func(document []byte) error {
+		var dte models.DTE
+		if err := json.Unmarshal(document, &dte); err != nil {
+			return err
+		}
+		validated = append(validated, dte.Identificacion.NumeroControl)
+		return nil
+	})
+
+	tests := []struct {
+		name     string
+		policy   SigningPolicy
+		schema   ports.DocumentValidator
+		rules    []ports.DocumentValidator
+		issuer   string
+		wantCode string
+	}{
+		{
+			name:     "rejected by a rule validator",
+			rules:    []ports.DocumentValidator{recordPlaceholder, validatorFunc(func([]byte) error { return rejected })},
+			issuer:   testNIT,
+			wantCode: errors.CodeTotals,
+		},
+		{
+			name:     "rejected by the schema",
+			policy:   SigningPolicy{ValidateSchema: true},
+			schema:   validatorFunc(func([]byte) error { return errors.NewDocumentInvalidError("dte_schema_invalid", nil) }),
+			issuer:   testNIT,
+			wantCode: errors.CodeDocumentInvalid,
+		},
+		{
+			name:     "issued by another taxpayer",
+			policy:   SigningPolicy{EnforceIssuer: true},
+			issuer:   testOtherNIT,
+			wantCode: errors.CodeIssuerMismatch,
+		},
+		{
+			name:     "issued by another taxpayer without the issuer check",
+			issuer:   testOtherNIT,
+			wantCode: errors.CodeIssuerMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validated = nil
+			tt.policy.AssignControlNumber = true
+			service, repository := newTestSigningService(tt.policy, tt.schema, tt.rules...)
+			_, err := service.SignDocument(context.Background(), &models.CertificateRequest{
+				NIT:                testNIT,
+				PrivateKeyPassword: "secret",
+				DocumentJSON:       json.RawMessage(testDocument(tt.issuer, "")),
+			})
+			if code := domainCode(err); code != tt.wantCode {
+				t.Fatalf("SignDocument() error = %v, want code %s", err, tt.wantCode)
+			}
+			if repository.allocated != 0 {
+				t.Errorf("SignDocument() allocated %d correlatives for a rejected document", repository.allocated)
+			}
+			for _, numeroControl := range validated {
+				if numeroControl != "DTE-01-M001P001-000000000000000" {
+					t.Errorf("validators saw numeroControl %q, want the placeholder of the series", numeroControl)
+				}
+			}
+		})
+	}
+}
+
+func TestSignDocumentAssignsFromAllowedIssuerSeries(t *testing.T) {
+	service, repository := newTestSigningService(SigningPolicy{
+		AssignControlNumber: true,
+		AllowedIssuers:      map[string][]string{testNIT: {testOtherNIT}},
+	}, nil)
+	_, err := service.SignDocument(context.Background(), &models.CertificateRequest{
+		NIT:                testNIT,
+		PrivateKeyPassword: "secret",
+		DocumentJSON:       json.RawMessage(testDocument(testOtherNIT, "")),
+	})
+	if err != nil {
+		t.Fatalf("SignDocument() error = %v", err)
+	}
+	series := models.ControlNumberSeries{NIT: testOtherNIT, TipoDte: "01", Establecimiento: "M001", PuntoVenta: "P001"}
+	if got := repository.sequences[series.Key()]; got != 1 {
+		t.Errorf("correlative of the issuer series = %d, want 1", got)
+	}
+}
+
+func TestSignDocumentCanonicalizesAssignedDocument(t *testing.T) {
+	service, _ := newTestSigningService(SigningPolicy{AssignControlNumber: true, Canonicalize: true}, nil)
+	signed, err := service.SignDocument(context.Background(), &models.CertificateRequest{
+		NIT:                testNIT,
+		PrivateKeyPassword: "secret",
+		DocumentJSON:       json.RawMessage(testDocument(testNIT, "")),
+	})
+	if err != nil {
+		t.Fatalf("SignDocument() error = %v", err)
+	}
+	want := `{"emisor":{"codEstable":"M001","codPuntoVenta":"P001","nit":"06140101780010"},` +
+		`"identificacion":{"ambiente":"00","numeroControl":"DTE-01-M001P001-000000000000001","tipoDte":"01","version":1},` +
+		`"resumen":{"totalPagar":1.1}}`
+	if string(signed.Payload) != want {
+		t.Errorf("SignDocument() payload\n got %s\nwant %s", signed.Payload, want)
+	}
+}
+
+// failingDocumentSigner fails every signature, after running its hook
+type failingDocumentSigner struct {
+	beforeFailing func()
+}
+
+func (s failingDocumentSigner) Sign(ctx context.Context, certificate *models.Certificate, documentData interface{}, options models.SigningOptions) (*models.SignedDocument, error) {
+	if s.beforeFailing != nil {
+		s.beforeFailing()
+	}
+	return nil, errors.NewDomainError("signing_error", errors.CodeCertNotFound)
+}
+
+func (s failingDocumentSigner) SignMulti(ctx context.Context, certificates []*models.Certificate, documentData interface{}, options models.SigningOptions) (*models.SignedDocument, error) {
+	return s.Sign(ctx, nil, documentData, options)
+}
+
+func TestSignDocumentReleasesControlNumberOfFailedSignature(t *testing.T) {
+	series := models.ControlNumberSeries{NIT: testNIT, TipoDte: "01", Establecimiento: "M001", PuntoVenta: "P001"}
+	request := func() *models.CertificateRequest {
+		return &models.CertificateRequest{
+			NIT:                testNIT,
+			PrivateKeyPassword: "secret",
+			DocumentJSON:       json.RawMessage(testDocument(testNIT, "")),
+		}
+	}
+
+	tests := []struct {
+		name string
+		// concurrent allocates another correlative of the series while the signature is in progress
+		concurrent  bool
+		wantCurrent int64
+		wantRelease int
+	}{
+		{name: "returned while it is the last of its series", wantCurrent: 0, wantRelease: 1},
+		{name: "kept once a later one was allocated", concurrent: true, wantCurrent: 2, wantRelease: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeControlNumberRepository{}
+			signer := failingDocumentSigner{}
+			if tt.concurrent {
+				signer.beforeFailing = func() {
+					if _, err := repository.Next(context.Background(), series); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+			service := NewSigningService(newTestCertificateRepository(testNIT), signer, nil, nil,
+				NewControlNumberService(repository), SigningPolicy{AssignControlNumber: true})
+
+			// A cancelled request still returns its number
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if _, err := service.SignDocument(ctx, request()); domainCode(err) != errors.CodeCertNotFound {
+				t.Fatalf("SignDocument() error = %v, want the error of the signer", err)
+			}
+			if repository.released != tt.wantRelease || repository.sequences[series.Key()] != tt.wantCurrent {
+				t.Errorf("released %d correlative(s), series at %d, want %d and %d",
+					repository.released, repository.sequences[series.Key()], tt.wantRelease, tt.wantCurrent)
+			}
+		})
+	}
+
+	// The next document takes the returned number
+	repository := &fakeControlNumberRepository{}
+	failing := NewSigningService(newTestCertificateRepository(testNIT), failingDocumentSigner{}, nil, nil,
+		NewControlNumberService(repository), SigningPolicy{AssignControlNumber: true})
+	if _, err := failing.SignDocument(context.Background(), request()); err == nil {
+		t.Fatal("SignDocument() error = nil, want the error of the signer")
+	}
+	signing := NewSigningService(newTestCertificateRepository(testNIT), fakeDocumentSigner{}, nil, nil,
+		NewControlNumberService(repository), SigningPolicy{AssignControlNumber: true})
+	signed, err := signing.SignDocument(context.Background(), request())
+	if err != nil {
+		t.Fatalf("SignDocument() error = %v", err)
+	}
+	if signed.AssignedControlNumber != "DTE-01-M001P001-000000000000001" {
+		t.Errorf("numeroControl after a failed signature = %q, want the returned one", signed.AssignedControlNumber)
+	}
+}
+
+func TestSignDocumentAssignsUniqueControlNumbersConcurrently(t *testing.T) {
+	service, _ := newTestSigningService(SigningPolicy{AssignControlNumber: true}, nil)
+
+	const documents = 100
+	numbers := make(chan string, documents)
+	var wg sync.WaitGroup
+	for i := 0; i < documents; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			signed, err := service.SignDocument(context.Background(), &models.CertificateRequest{
+				NIT:                testNIT,
+				PrivateKeyPassword: "secret",
+				DocumentJSON:       json.RawMessage(testDocument(testNIT, "")),
+			})
+			if err != nil {
+				t.Errorf("SignDocument() error = %v", err)
+				return
+			}
+			numbers <- signed.NumeroControl()
+		}()
+	}
+	wg.Wait()
+	close(numbers)
+
+	seen := make(map[string]bool, documents)
+	for number := range numbers {
+		if seen[number] {
+			t.Errorf("numeroControl %s assigned twice", number)
+		}
+		seen[number] = true
+	}
+	if len(seen) != documents {
+		t.Errorf("assigned %d numbers, want %d", len(seen), documents)
+	}
+}
+
+func TestSignDocumentRejectsAssignmentWithoutService(t *testing.T) {
+	service := NewSigningService(newTestCertificateRepository(testNIT), fakeDocumentSigner{}, nil, nil, nil, SigningPolicy{})
+	_, err := service.SignDocument(context.Background(), &models.CertificateRequest{
+		NIT:                 testNIT,
+		PrivateKeyPassword:  "secret",
+		DocumentJSON:        json.RawMessage(testDocument(testNIT, "")),
+		AssignControlNumber: true,
+	})
+	if code := domainCode(err); code != errors.CodeControlNumber {
+		t.Errorf("SignDocument() error = %v, want code %s", err, errors.CodeControlNumber)
+	}
+}
+
+func TestSignDocumentRejectsInvalidSeries(t *testing.T) {
+	service, repository := newTestSigningService(SigningPolicy{}, nil)
+	_, err := service.SignDocument(context.Background(), &models.CertificateRequest{
+		NIT:                 testNIT,
+		PrivateKeyPassword:  "secret",
+		DocumentJSON:        json.RawMessage(testDocument(testNIT, "")),
+		AssignControlNumber: true,
+		Establishment:       "m-01",
+	})
+	if code := domainCode(err); code != errors.CodeControlNumber {
+		t.Errorf("SignDocument() error = %v, want code %s", err, errors.CodeControlNumber)
+	}
+	if repository.allocated != 0 {
+		t.Errorf("SignDocument() allocated %d correlatives for an invalid series", repository.allocated)
+	}
+}
+
+func TestSetMember(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "replaces the member",
+			document: `{"identificacion":{"tipoDte":"01","numeroControl":"old"}}`,
+			want:     `{"identificacion":{"tipoDte":"01","numeroControl":"NEW"}}`,
+		},
+		{
+			name:     "replaces a null member keeping the whitespace",
+			document: "{ \"identificacion\" : {\n  \"numeroControl\" :  null ,\n  \"tipoDte\": \"01\"\n} }",
+			want:     "{ \"identificacion\" : {\n  \"numeroControl\" :  \"NEW\" ,\n  \"tipoDte\": \"01\"\n} }",
+		},
+		{
+			name:     "adds a missing member first",
+			document: `{"identificacion":{"tipoDte":"01"}}`,
+			want:     `{"identificacion":{"numeroControl":"NEW","tipoDte":"01"}}`,
+		},
+		{
+			name:     "adds a member to an empty object",
+			document: `{"identificacion":{}}`,
+			want:     `{"identificacion":{"numeroControl":"NEW"}}`,
+		},
+		{
+			name:     "ignores members of the same name in other objects",
+			document: `{"documentoRelacionado":{"numeroControl":"other"},"identificacion":{"numeroControl":"old"},"x":{"numeroControl":1}}`,
+			want:     `{"documentoRelacionado":{"numeroControl":"other"},"identificacion":{"numeroControl":"NEW"},"x":{"numeroControl":1}}`,
+		},
+		{
+			name:     "keeps escaped strings and numbers untouched",
+			document: `{"a":"é\"","identificacion":{"numeroControl":"old","n":1.50e2}}`,
+			want:     `{"a":"é\"","identificacion":{"numeroControl":"NEW","n":1.50e2}}`,
+		},
+		{
+			name:     "fails without the object",
+			document: `{"emisor":{}}`,
+			wantErr:  true,
+		},
+		{
+			name:     "fails when the object is not an object",
+			document: `{"identificacion":"01"}`,
+			wantErr:  true,
+		},
+		{
+			name:     "fails when the document is not an object",
+			document: `[{"identificacion":{}}]`,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := setMember([]byte(tt.document), "identificacion", "numeroControl", "NEW")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setMember() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != tt.want {
+				t.Errorf("setMember()\n got %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/ports"
+)
+
+// ControlNumberService implements the ports.ControlNumberService interface
+type ControlNumberService struct {
+	repository ports.ControlNumberRepository
+}
+
+// NewControlNumberService creates a new control number service
+func NewControlNumberService(repository ports.ControlNumberRepository) *ControlNumberService {
+	return &ControlNumberService{
+		repository: repository,
+	}
+}
+
+// NextControlNumber allocates the next numeroControl of a series
+func (s *ControlNumberService) NextControlNumber(ctx context.Context, series models.ControlNumberSeries) (*models.ControlNumber, error) {
+	// 1: Validate the series
+	if !series.Validate() {
+		return nil, errors.NewDomainError("numero_control_series_invalid", errors.CodeControlNumber)
+	}
+
+	// 2: Allocate the next correlative, the repository fails once the series is exhausted
+	sequence, err := s.repository.Next(ctx, series)
+	if err != nil {
+		return nil, err
+	}
+
+	return newControlNumber(series, sequence), nil
+}
+
+// CurrentControlNumber returns the last numeroControl allocated from a series
+func (s *ControlNumberService) CurrentControlNumber(ctx context.Context, series models.ControlNumberSeries) (*models.ControlNumber, error) {
+	// 1: Validate the series
+	if !series.Validate() {
+		return nil, errors.NewDomainError("numero_control_series_invalid", errors.CodeControlNumber)
+	}
+
+	// 2: Read the last correlative
+	sequence, err := s.repository.Current(ctx, series)
+	if err != nil {
+		return nil, err
+	}
+
+	return newControlNumber(series, sequence), nil
+}
+
+// AdvanceControlNumber raises the correlative of a series to at least the given one
+func (s *ControlNumberService) AdvanceControlNumber(ctx context.Context, series models.ControlNumberSeries, sequence int64) (*models.ControlNumber, error) {
+	// 1: Validate the series and the correlative
+	if !series.Validate() {
+		return nil, errors.NewDomainError("numero_control_series_invalid", errors.CodeControlNumber)
+	}
+	if sequence < 0 || sequence > models.MaxControlNumberSequence {
+		return nil, errors.NewDomainError("numero_control_sequence_invalid", errors.CodeControlNumber)
+	}
+
+	// 2: Raise the correlative, keeping it when it is already higher
+	current, err := s.repository.Advance(ctx, series, sequence)
+	if err != nil {
+		return nil, err
+	}
+
+	return newControlNumber(series, current), nil
+}
+
+// ReleaseControlNumber returns an allocated numeroControl that was never used to its series, when no later one was allocated
+func (s *ControlNumberService) ReleaseControlNumber(ctx context.Context, number *models.ControlNumber) (bool, error) {
+	// 1: Validate the series and the correlative
+	if !number.ControlNumberSeries.Validate() {
+		return false, errors.NewDomainError("numero_control_series_invalid", errors.CodeControlNumber)
+	}
+	if number.Sequence < 1 || number.Sequence > models.MaxControlNumberSequence {
+		return false, errors.NewDomainError("numero_control_sequence_invalid", errors.CodeControlNumber)
+	}
+
+	// 2: Lower the correlative only while it is still the last of its series
+	return s.repository.Release(ctx, number.ControlNumberSeries, number.Sequence)
+}
+
+// newControlNumber describes a correlative of a series, without a numeroControl while none was allocated
+func newControlNumber(series models.ControlNumberSeries, sequence int64) *models.ControlNumber {
+	number := &models.ControlNumber{ControlNumberSeries: series, Sequence: sequence}
+	if sequence > 0 {
+		number.NumeroControl = series.Format(sequence)
+	}
+	return number
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+)
+
+func TestControlNumberService(t *testing.T) {
+	repository := &fakeControlNumberRepository{}
+	service := NewControlNumberService(repository)
+	series := models.ControlNumberSeries{NIT: testNIT, TipoDte: "03", Establecimiento: "M001", PuntoVenta: "P001"}
+	ctx := context.Background()
+
+	current, err := service.CurrentControlNumber(ctx, series)
+	if err != nil || current.Sequence != 0 || current.NumeroControl != "" {
+		t.Fatalf("CurrentControlNumber() = %+v, %v, want an empty series", current, err)
+	}
+	for want := int64(1); want <= 3; want++ {
+		number, err := service.NextControlNumber(ctx, series)
+		if err != nil {
+			t.Fatalf("NextControlNumber() error = %v", err)
+		}
+		if number.Sequence != want || number.NumeroControl != fmt.Sprintf("DTE-03-M001P001-%015d", want) {
+			t.Errorf("NextControlNumber() = %+v, want correlative %d", number, want)
+		}
+	}
+
+	advanced, err := service.AdvanceControlNumber(ctx, series, 41)
+	if err != nil || advanced.Sequence != 41 {
+		t.Fatalf("AdvanceControlNumber(41) = %+v, %v", advanced, err)
+	}
+	kept, err := service.AdvanceControlNumber(ctx, series, 5)
+	if err != nil || kept.Sequence != 41 {
+		t.Errorf("AdvanceControlNumber(5) = %+v, %v, want the correlative kept at 41", kept, err)
+	}
+	next, err := service.NextControlNumber(ctx, series)
+	if err != nil || next.NumeroControl != "DTE-03-M001P001-000000000000042" {
+		t.Errorf("NextControlNumber() = %+v, %v, want correlative 42", next, err)
+	}
+
+	if _, err := service.AdvanceControlNumber(ctx, series, models.MaxControlNumberSequence+1); domainCode(err) != errors.CodeControlNumber {
+		t.Errorf("AdvanceControlNumber() beyond the last correlative error = %v", err)
+	}
+	invalid := series
+	invalid.PuntoVenta = "P1"
+	if _, err := service.NextControlNumber(ctx, invalid); domainCode(err) != errors.CodeControlNumber {
+		t.Errorf("NextControlNumber() of an invalid series error = %v", err)
+	}
+	if repository.allocated != 4 {
+		t.Errorf("repository allocated %d correlatives, want 4", repository.allocated)
+	}
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+)
+
+var (
+	// generationCodePattern matches an uppercase UUID v4
+	generationCodePattern = regexp.MustCompile(`^[0-9A-F]{8}-[0-9A-F]{4}-4[0-9A-F]{3}-[89AB][0-9A-F]{3}-[0-9A-F]{12}$`)
+	// controlNumberPattern matches DTE-<tipoDte>-<establishment and point of sale>-<sequence>
+	controlNumberPattern = regexp.MustCompile(`^DTE-([0-9]{2})-[A-Z0-9]{8}-[0-9]{15}$`)
+)
+
+// salvadoranTime is the time zone of the issue dates, El Salvador does not observe daylight saving time
+var salvadoranTime = time.FixedZone("CST", -6*60*60)
+
+// IdentificacionRules holds the rules applied to the identificacion block of a DTE
+type IdentificacionRules struct {
+	// Ambiente is the Hacienda environment the service signs for, "00" (pruebas) or "01" (producción)
+	Ambiente string
+	// MaxAge is how long before signing a document may have been issued
+	MaxAge time.Duration
+	// MaxAhead is how far ahead of the clock of the service a document may have been issued
+	MaxAhead time.Duration
+}
+
+// IdentificacionValidator implements the ports.DocumentValidator interface, checking that the
+// identificacion block of a DTE is consistent before it is signed
+type IdentificacionValidator struct {
+	rules IdentificacionRules
+	now   func() time.Time
+}
+
+// NewIdentificacionValidator creates a new identificacion validator
+func NewIdentificacionValidator(rules IdentificacionRules) *IdentificacionValidator {
+	return &IdentificacionValidator{
+		rules: rules,
+		now:   time.Now,
+	}
+}
+
+// Validate checks the identificacion block of a document, returning the first rule it breaks
+func (v *IdentificacionValidator) Validate(ctx context.Context, document []byte) error {
+	// 1: Decode the identificacion block
+	var dte models.DTE
+	if err := json.Unmarshal(document, &dte); err != nil {
+		if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+			return errors.NewDocumentInvalidError("dte_identificacion_invalid", []string{
+				fmt.Sprintf("$.%s: must be %s", typeErr.Field, typeErr.Type),
+			})
+		}
+		return errors.NewDomainError("invalid_document_json", errors.CodeStrToJSONConversion)
+	}
+	identificacion := dte.Identificacion
+	if identificacion == nil {
+		return errors.NewDocumentInvalidError("dte_identificacion_invalid", []string{"$.identificacion: is required"})
+	}
+
+	// 2: The generation code must be an uppercase UUID v4
+	if !generationCodePattern.MatchString(identificacion.CodigoGeneracion) {
+		return errors.NewDocumentRuleError("codigo_generacion_invalid", errors.CodeGenerationCode,
+			fmt.Sprintf("$.identificacion.codigoGeneracion: %q is not an uppercase UUID v4", identificacion.CodigoGeneracion))
+	}
+
+	// 3: The control number must follow the Hacienda format and belong to the DTE type
+	match := controlNumberPattern.FindStringSubmatch(identificacion.NumeroControl)
+	if match == nil {
+		return errors.NewDocumentRuleError("numero_control_invalid", errors.CodeControlNumber,
+			fmt.Sprintf("$.identificacion.numeroControl: %q does not match DTE-<tipoDte>-<8 characters>-<15 digits>", identificacion.NumeroControl))
+	}
+	if match[1] != identificacion.TipoDte {
+		return errors.NewDocumentRuleError("numero_control_type_mismatch", errors.CodeControlNumber,
+			fmt.Sprintf("$.identificacion.numeroControl: type %q differs from tipoDte %q", match[1], identificacion.TipoDte))
+	}
+
+	// 4: The document must be issued for the environment of the service
+	if identificacion.Ambiente != v.rules.Ambiente {
+		return errors.NewDocumentRuleError("ambiente_mismatch", errors.CodeEnvironment,
+			fmt.Sprintf("$.identificacion.ambiente: %q, the service signs for %q", identificacion.Ambiente, v.rules.Ambiente))
+	}
+
+	// 5: The issue date and time must be valid and within the allowed window
+	if err := v.checkIssueTime(identificacion); err != nil {
+		return err
+	}
+
+	// 6: The transmission model, operation type and contingency fields must agree
+	return checkOperation(identificacion)
+}
+
+// checkIssueTime checks that fecEmi and horEmi are valid and within the window allowed around the current time
+func (v *IdentificacionValidator) checkIssueTime(identificacion *models.DTEIdentificacion) error {
+	issuedAt, err := time.ParseInLocation("2006-01-02 15:04:05", identificacion.FecEmi+" "+identificacion.HorEmi, salvadoranTime)
+	if err != nil {
+		return errors.NewDocumentRuleError("fecha_emision_invalid", errors.CodeIssueDate,
+			fmt.Sprintf("$.identificacion.fecEmi: %q %q is not a valid YYYY-MM-DD date and HH:MM:SS time", identificacion.FecEmi, identificacion.HorEmi))
+	}
+
+	now := v.now()
+	if issuedAt.Before(now.Add(-v.rules.MaxAge)) || issuedAt.After(now.Add(v.rules.MaxAhead)) {
+		return errors.NewDocumentRuleError("fecha_emision_out_of_window", errors.CodeIssueDate,
+			fmt.Sprintf("$.identificacion.fecEmi: %s %s is outside the window from %s before to %s after %s",
+				identificacion.FecEmi, identificacion.HorEmi, v.rules.MaxAge, v.rules.MaxAhead,
+				now.In(salvadoranTime).Format("2006-01-02 15:04:05")))
+	}
+	return nil
+}
+
+// checkOperation checks that a document is either issued in the prior model under normal operation,
+// without contingency fields, or in the deferred model under contingency, stating its cause
+func checkOperation(identificacion *models.DTEIdentificacion) error {
+	invalid := func(detail string) error {
+		return errors.NewDocumentRuleError("tipo_operacion_invalid", errors.CodeOperationType, detail)
+	}
+
+	switch identificacion.TipoOperacion {
+	case models.OperacionNormal:
+		if identificacion.TipoModelo != models.ModeloPrevio {
+			return invalid(fmt.Sprintf("$.identificacion.tipoModelo: %d, normal operation requires the prior model (%d)", identificacion.TipoModelo, models.ModeloPrevio))
+		}
+		if identificacion.TipoContingencia != nil {
+			return invalid("$.identificacion.tipoContingencia: must be null under normal operation")
+		}
+		if identificacion.MotivoContin != nil {
+			return invalid("$.identificacion.motivoContin: must be null under normal operation")
+		}
+	case models.OperacionContingencia:
+		if identificacion.TipoModelo != models.ModeloDiferido {
+			return invalid(fmt.Sprintf("$.identificacion.tipoModelo: %d, contingency requires the deferred model (%d)", identificacion.TipoModelo, models.ModeloDiferido))
+		}
+		if identificacion.TipoContingencia == nil || *identificacion.TipoContingencia < 1 || *identificacion.TipoContingencia > 5 {
+			return invalid("$.identificacion.tipoContingencia: contingency requires a type from 1 to 5")
+		}
+		if *identificacion.TipoContingencia == 5 && (identificacion.MotivoContin == nil || *identificacion.MotivoContin == "") {
+			return invalid("$.identificacion.motivoContin: contingency type 5 requires its reason")
+		}
+	default:
+		return invalid(fmt.Sprintf("$.identificacion.tipoOperacion: %d is not normal (%d) or contingency (%d)",
+			identificacion.TipoOperacion, models.OperacionNormal, models.OperacionContingencia))
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+)
+
+// identificacionDocument builds a DTE whose identificacion block is a valid Factura issued at noon
+// of 2026-10-16, after applying the given changes to it
+func identificacionDocument(t *testing.T, changes map[string]interface{}) []byte {
+	t.Helper()
+	identificacion := map[string]interface{}{
+		"version": 1, "ambiente": "00", "tipoDte": "01", "numeroControl": "DTE-01-M001P001-000000000000001",
+		"codigoGeneracion": "6F1E3B2A-4C5D-4E6F-8A9B-0C1D2E3F4A5B", "tipoModelo": 1, "tipoOperacion": 1,
+		"tipoContingencia": nil, "motivoContin": nil, "fecEmi": "2026-10-16", "horEmi": "12:00:00", "tipoMoneda": "USD",
+	}
+	for field, value := range changes {
+		identificacion[field] = value
+	}
+	document, err := json.Marshal(map[string]interface{}{"identificacion": identificacion})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return document
+}
+
+func newTestIdentificacionValidator() *IdentificacionValidator {
+	validator := NewIdentificacionValidator(IdentificacionRules{Ambiente: "00", MaxAge: 24 * time.Hour, MaxAhead: 5 * time.Minute})
+	validator.now = func() time.Time {
+		return time.Date(2026, 10, 16, 12, 0, 0, 0, salvadoranTime)
+	}
+	return validator
+}
+
+func TestIdentificacionValidator(t *testing.T) {
+	contingency := map[string]interface{}{"tipoModelo": 2, "tipoOperacion": 2, "tipoContingencia": 1}
+
+	tests := []struct {
+		name     string
+		document []byte
+		wantCode string
+		wantMsg  string
+		// wantDetail is part of the detail of the broken rule
+		wantDetail string
+	}{
+		{name: "valid", document: identificacionDocument(t, nil)},
+
+		// Generation code
+		{
+			name:     "lowercase generation code",
+			document: identificacionDocument(t, map[string]interface{}{"codigoGeneracion": "6f1e3b2a-4c5d-4e6f-8a9b-0c1d2e3f4a5b"}),
+			wantCode: errors.CodeGenerationCode, wantMsg: "codigo_generacion_invalid", wantDetail: "$.identificacion.codigoGeneracion",
+		},
+		{
+			name:     "generation code of another UUID version",
+			document: identificacionDocument(t, map[string]interface{}{"codigoGeneracion": "6F1E3B2A-4C5D-1E6F-8A9B-0C1D2E3F4A5B"}),
+			wantCode: errors.CodeGenerationCode, wantMsg: "codigo_generacion_invalid",
+		},
+		{
+			name:     "generation code of another UUID variant",
+			document: identificacionDocument(t, map[string]interface{}{"codigoGeneracion": "6F1E3B2A-4C5D-4E6F-CA9B-0C1D2E3F4A5B"}),
+			wantCode: errors.CodeGenerationCode, wantMsg: "codigo_generacion_invalid",
+		},
+		{
+			name:     "generation code without hyphens",
+			document: identificacionDocument(t, map[string]interface{}{"codigoGeneracion": "6F1E3B2A4C5D4E6F8A9B0C1D2E3F4A5B"}),
+			wantCode: errors.CodeGenerationCode, wantMsg: "codigo_generacion_invalid",
+		},
+
+		// Control number
+		{
+			name:     "control number with a short sequence",
+			document: identificacionDocument(t, map[string]interface{}{"numeroControl": "DTE-01-M001P001-00000000000001"}),
+			wantCode: errors.CodeControlNumber, wantMsg: "numero_control_invalid", wantDetail: "$.identificacion.numeroControl",
+		},
+		{
+			name:     "control number with a lowercase establishment",
+			document: identificacionDocument(t, map[string]interface{}{"numeroControl": "DTE-01-m001p001-000000000000001"}),
+			wantCode: errors.CodeControlNumber, wantMsg: "numero_control_invalid",
+		},
+		{
+			name:     "control number without prefix",
+			document: identificacionDocument(t, map[string]interface{}{"numeroControl": "01-M001P001-000000000000001"}),
+			wantCode: errors.CodeControlNumber, wantMsg: "numero_control_invalid",
+		},
+		{
+			name:     "control number of another type",
+			document: identificacionDocument(t, map[string]interface{}{"numeroControl": "DTE-03-M001P001-000000000000001"}),
+			wantCode: errors.CodeControlNumber, wantMsg: "numero_control_type_mismatch", wantDetail: `type "03" differs from tipoDte "01"`,
+		},
+
+		// Environment
+		{
+			name:     "production document",
+			document: identificacionDocument(t, map[string]interface{}{"ambiente": "01"}),
+			wantCode: errors.CodeEnvironment, wantMsg: "ambiente_mismatch",
+		},
+
+		// Issue date window
+		{
+			name:     "issued at the oldest allowed time",
+			document: identificacionDocument(t, map[string]interface{}{"fecEmi": "2026-10-15"}),
+		},
+		{
+			name:     "issued before the window",
+			document: identificacionDocument(t, map[string]interface{}{"fecEmi": "2026-10-15", "horEmi": "11:59:59"}),
+			wantCode: errors.CodeIssueDate, wantMsg: "fecha_emision_out_of_window",
+		},
+		{
+			name:     "issued at the latest allowed time",
+			document: identificacionDocument(t, map[string]interface{}{"horEmi": "12:05:00"}),
+		},
+		{
+			name:     "issued after the window",
+			document: identificacionDocument(t, map[string]interface{}{"horEmi": "12:05:01"}),
+			wantCode: errors.CodeIssueDate, wantMsg: "fecha_emision_out_of_window",
+		},
+		{
+			name:     "impossible date",
+			document: identificacionDocument(t, map[string]interface{}{"fecEmi": "2026-02-30"}),
+			wantCode: errors.CodeIssueDate, wantMsg: "fecha_emision_invalid",
+		},
+		{
+			name:     "time without seconds",
+			document: identificacionDocument(t, map[string]interface{}{"horEmi": "12:00"}),
+			wantCode: errors.CodeIssueDate, wantMsg: "fecha_emision_invalid",
+		},
+
+		// Transmission model, operation type and contingency
+		{name: "contingency", document: identificacionDocument(t, contingency)},
+		{
+			name: "contingency type 5 with its reason",
+			document: identificacionDocument(t, map[string]interface{}{
+				"tipoModelo": 2, "tipoOperacion": 2, "tipoContingencia": 5, "motivoContin": "Falla de energía",
+			}),
+		},
+		{
+			name: "contingency type 5 without reason",
+			document: identificacionDocument(t, map[string]interface{}{
+				"tipoModelo": 2, "tipoOperacion": 2, "tipoContingencia": 5, "motivoContin": "",
+			}),
+			wantCode: errors.CodeOperationType, wantMsg: "tipo_operacion_invalid", wantDetail: "$.identificacion.motivoContin",
+		},
+		{
+			name:     "contingency without type",
+			document: identificacionDocument(t, map[string]interface{}{"tipoModelo": 2, "tipoOperacion": 2}),
+			wantCode: errors.CodeOperationType, wantMsg: "tipo_operacion_invalid", wantDetail: "$.identificacion.tipoContingencia",
+		},
+		{
+			name:     "contingency of an unknown type",
+			document: identificacionDocument(t, map[string]interface{}{"tipoModelo": 2, "tipoOperacion": 2, "tipoContingencia": 6}),
+			wantCode: errors.CodeOperationType, wantMsg: "tipo_operacion_invalid", wantDetail: "$.identificacion.tipoContingencia",
+		},
+		{
+			name:     "contingency in the prior model",
+			document: identificacionDocument(t, map[string]interface{}{"tipoOperacion": 2, "tipoContingencia": 1}),
+			wantCode: errors.CodeOperationType, wantMsg: "tipo_operacion_invalid", wantDetail: "$.identificacion.tipoModelo",
+		},
+		{
+			name:     "normal operation in the deferred model",
+			document: identificacionDocument(t, map[string]interface{}{"tipoModelo": 2}),
+			wantCode: errors.CodeOperationType, wantMsg: "tipo_operacion_invalid", wantDetail: "$.identificacion.tipoModelo",
+		},
+		{
+			name:     "normal operation with a contingency type",
+			document: identificacionDocument(t, map[string]interface{}{"tipoContingencia": 1}),
+			wantCode: errors.CodeOperationType, wantMsg: "tipo_operacion_invalid", wantDetail: "$.identificacion.tipoContingencia",
+		},
+		{
+			name:     "normal operation with a contingency reason",
+			document: identificacionDocument(t, map[string]interface{}{"motivoContin": "Falla de energía"}),
+			wantCode: errors.CodeOperationType, wantMsg: "tipo_operacion_invalid", wantDetail: "$.identificacion.motivoContin",
+		},
+		{
+			name:     "unknown operation type",
+			document: identificacionDocument(t, map[string]interface{}{"tipoOperacion": 3}),
+			wantCode: errors.CodeOperationType, wantMsg: "tipo_operacion_invalid", wantDetail: "$.identificacion.tipoOperacion",
+		},
+
+		// Malformed documents
+		{
+			name:     "missing identificacion",
+			document: []byte(`{"emisor": {}}`),
+			wantCode: errors.CodeDocumentInvalid, wantMsg: "dte_identificacion_invalid",
+		},
+		{
+			name:     "identificacion member of the wrong type",
+			document: identificacionDocument(t, map[string]interface{}{"tipoModelo": "1"}),
+			wantCode: errors.CodeDocumentInvalid, wantMsg: "dte_identificacion_invalid", wantDetail: "tipoModelo",
+		},
+		{
+			name:     "malformed JSON",
+			document: []byte(`{"identificacion": `),
+			wantCode: errors.CodeStrToJSONConversion, wantMsg: "invalid_document_json",
+		},
+	}
+	validator := newTestIdentificacionValidator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.Validate(context.Background(), tt.document)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			domainErr, ok := err.(errors.DomainError)
+			if !ok || domainErr.Code != tt.wantCode || domainErr.Message != tt.wantMsg {
+				t.Fatalf("Validate() error = %v, want %s: %s", err, tt.wantCode, tt.wantMsg)
+			}
+			if tt.wantDetail != "" && (len(domainErr.Details) != 1 || !strings.Contains(domainErr.Details[0], tt.wantDetail)) {
+				t.Errorf("Validate() details = %q, want one containing %q", domainErr.Details, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestIdentificacionValidatorReadsIssueTimesInSalvadoranTime(t *testing.T) {
+	validator := newTestIdentificacionValidator()
+	// Noon in El Salvador is 18:00 UTC, whatever the time zone of the service
+	validator.now = func() time.Time {
+		return time.Date(2026, 10, 16, 18, 0, 0, 0, time.UTC)
+	}
+	if err := validator.Validate(context.Background(), identificacionDocument(t, map[string]interface{}{"horEmi": "12:05:00"})); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if err := validator.Validate(context.Background(), identificacionDocument(t, map[string]interface{}{"horEmi": "18:00:00"})); domainCode(err) != errors.CodeIssueDate {
+		t.Errorf("Validate() error = %v, want a document issued six hours ahead rejected", err)
+	}
+}
@@ -4,72 +4,348 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/chainedpixel/go-dte-signer/internal/domain/errors"
 	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
 	"github.com/chainedpixel/go-dte-signer/internal/domain/ports"
+	"github.com/chainedpixel/go-dte-signer/pkg/jcs"
 )
 
+// SigningPolicy holds the rules applied by the signing service to every request
+type SigningPolicy struct {
+	// RequirePublicKeyPassword makes passwordPub mandatory on signing requests
+	RequirePublicKeyPassword bool
+	// Canonicalize signs every document in its RFC 8785 canonical form, not only when requested
+	Canonicalize bool
+	// RejectExpired refuses to sign with certificates whose key material has expired
+	RejectExpired bool
+	// ValidateSchema checks every document against the JSON schema of its DTE type, unless the request disables it
+	ValidateSchema bool
+	// EnforceIssuer refuses documents whose issuer NIT is not the NIT signing them
+	EnforceIssuer bool
+	// AllowedIssuers lists, per signing NIT, the issuers it may also sign for as agent or representative; "*" allows any
+	AllowedIssuers map[string][]string
+	// AssignControlNumber fills the numeroControl of documents sent without one from its series
+	AssignControlNumber bool
+}
+
 // SigningService implements the ports.SigningService interface
 type SigningService struct {
-	certRepo       ports.CertificateRepository
-	documentSigner ports.DocumentSigner
+	certRepo         ports.CertificateRepository
+	documentSigner   ports.DocumentSigner
+	documentVerifier ports.DocumentVerifier
+	schemaValidator  ports.DocumentValidator
+	controlNumbers   ports.ControlNumberService
+	ruleValidators   []ports.DocumentValidator
+	policy           SigningPolicy
 }
 
-// NewSigningService creates a new signing service
-func NewSigningService(certRepo ports.CertificateRepository, documentSigner ports.DocumentSigner) *SigningService {
+// NewSigningService creates a new signing service. The control number service, which may be nil,
+// assigns numeroControl values; the rule validators check every document, after its schema, before it is signed.
+func NewSigningService(certRepo ports.CertificateRepository, documentSigner ports.DocumentSigner, documentVerifier ports.DocumentVerifier, schemaValidator ports.DocumentValidator, controlNumbers ports.ControlNumberService, policy SigningPolicy, ruleValidators ...ports.DocumentValidator) *SigningService {
 	return &SigningService{
-		certRepo:       certRepo,
-		documentSigner: documentSigner,
+		certRepo:         certRepo,
+		documentSigner:   documentSigner,
+		documentVerifier: documentVerifier,
+		schemaValidator:  schemaValidator,
+		controlNumbers:   controlNumbers,
+		ruleValidators:   ruleValidators,
+		policy:           policy,
 	}
 }
 
 // SignDocument signs a document with the specified certificate
-func (s *SigningService) SignDocument(ctx context.Context, request *models.CertificateRequest) (string, error) {
+func (s *SigningService) SignDocument(ctx context.Context, request *models.CertificateRequest) (*models.SignedDocument, error) {
 	// 1: Validate the request
 	if !request.Validate() {
-		return "", errors.NewRequiredDataError("required_data")
+		return nil, errors.NewRequiredDataError("required_data")
+	}
+	options := request.SigningOptions()
+	if !options.Validate() {
+		return nil, errors.NewDomainError("invalid_signing_options", errors.CodeInvalid)
+	}
+
+	// 2: Retrieve and authorize the certificate of every signer
+	credentials := request.Credentials()
+	certificates := make([]*models.Certificate, 0, len(credentials))
+	for _, c := range credentials {
+		certificate, err := s.authorizeCertificate(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, certificate)
 	}
 
-	// 2: Retrieve the certificate by NIT
-	certificate, err := s.certRepo.GetByNIT(ctx, request.NIT)
+	// 3: Process the document JSON, with a placeholder numeroControl when one is assigned
+	documentData, series, err := s.prepareDocument(request, credentials[0].NIT)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// 3: Verify the private key password
-	valid, err := s.certRepo.VerifyPassword(ctx, certificate, request.PrivateKeyPassword)
+	// 4: Check the document is issued by the main signer
+	if s.policy.EnforceIssuer {
+		if err := s.checkIssuer(credentials[0].NIT, documentData); err != nil {
+			return nil, err
+		}
+	}
+
+	// 5: Validate the document against the schema of its DTE type, then against the document rules
+	if s.validateSchema(request) {
+		if err := s.schemaValidator.Validate(ctx, documentData); err != nil {
+			return nil, err
+		}
+	}
+	for _, validator := range s.ruleValidators {
+		if err := validator.Validate(ctx, documentData); err != nil {
+			return nil, err
+		}
+	}
+
+	// 6: Allocate the numeroControl only now, so rejected documents leave no gap in its series; a failed signature returns it
+	var number *models.ControlNumber
+	if series != nil {
+		documentData, number, err = s.assignControlNumber(ctx, series, documentData)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 7: Sign the document, once per signer
+	var signed *models.SignedDocument
+	if len(certificates) == 1 {
+		signed, err = s.documentSigner.Sign(ctx, certificates[0], documentData, options)
+	} else {
+		signed, err = s.documentSigner.SignMulti(ctx, certificates, documentData, options)
+	}
 	if err != nil {
-		return "", err
+		if number != nil {
+			s.releaseControlNumber(ctx, number)
+		}
+		return nil, err
 	}
 
-	// 4: Check if the password is valid
-	if !valid {
-		return "", errors.NewPasswordInvalidError(fmt.Sprintf("password_invalid", request.NIT))
-	}
-
-	// 5: Process the document JSON
-	var documentData []byte
-	switch v := request.DocumentJSON.(type) {
-	case string:
-		documentData = []byte(v)
-	case []byte:
-		documentData = v
-	default:
-		// Marshal the document JSON to a string
+	// 8: Return the signed JWS, along with the numeroControl written into the document
+	if number != nil {
+		signed.AssignedControlNumber = number.NumeroControl
+	}
+	return signed, nil
+}
+
+// VerifyDocument verifies a signed document against the certificate of the specified NIT
+func (s *SigningService) VerifyDocument(ctx context.Context, request *models.CertificateRequest) (*models.VerificationResult, error) {
+	// 1: Validate the request
+	if !request.ValidateVerification() {
+		return nil, errors.NewRequiredDataError("required_data")
+	}
+
+	// 2: Process the detached payload, if any
+	var detachedPayload []byte
+	if request.HasDocument() {
 		var err error
-		documentData, err = json.Marshal(request.DocumentJSON)
+		detachedPayload, err = documentBytes(request.DocumentJSON, s.canonicalize(request))
 		if err != nil {
-			return "", errors.NewDomainError("json_to_string_conversion", errors.CodeJSONToStrConversion)
+			return nil, err
 		}
 	}
 
-	// 6: Sign the document
-	signedJWS, err := s.documentSigner.Sign(ctx, certificate, documentData)
+	// 3: Verify a single signature with the certificates of the NIT, so documents signed before a rotation still verify
+	nits := request.SignerNITs()
+	if len(nits) == 1 {
+		for _, c := range request.Credentials() {
+			if c.NIT != "" {
+				return s.verifySingle(ctx, c, request.CompactSerialized, detachedPayload)
+			}
+		}
+	}
+
+	// 4: Retrieve the candidate certificates of every expected signer, so each may have signed with any of them
+	signers := make([][]*models.Certificate, 0, len(nits))
+	for _, c := range request.Credentials() {
+		if c.NIT == "" {
+			continue
+		}
+		certificates, err := s.candidateCertificates(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		signers = append(signers, certificates)
+	}
+
+	// 5: Verify the JWS with the certificate keys
+	return s.documentVerifier.VerifyMulti(ctx, signers, request.CompactSerialized, detachedPayload)
+}
+
+// verifySingle verifies a single-signer JWS with the pinned certificate of the NIT or, failing
+// that, with each of its active certificates in preference order
+func (s *SigningService) verifySingle(ctx context.Context, signer models.SignerCredentials, compactSerialized string, detachedPayload []byte) (*models.VerificationResult, error) {
+	// 1: Retrieve the candidate certificates, ignoring their validity windows
+	certificates, err := s.candidateCertificates(ctx, signer)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	// 2: Return the first successful verification, or the error of the preferred certificate
+	var firstErr error
+	for _, certificate := range certificates {
+		result, err := s.documentVerifier.Verify(ctx, certificate, compactSerialized, detachedPayload)
+		if err == nil {
+			return result, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// candidateCertificates returns the pinned certificate of a signer or, when none is pinned, every active
+// certificate of its NIT in preference order, ignoring their validity windows
+func (s *SigningService) candidateCertificates(ctx context.Context, signer models.SignerCredentials) ([]*models.Certificate, error) {
+	certificates, err := s.certRepo.ListByNIT(ctx, signer.NIT)
+	if err != nil {
+		return nil, err
+	}
+	if signer.CertificateID == "" {
+		return certificates, nil
+	}
+
+	var pinned []*models.Certificate
+	for _, certificate := range certificates {
+		if certificate.ID == signer.CertificateID {
+			pinned = append(pinned, certificate)
+		}
+	}
+	if len(pinned) == 0 {
+		return nil, errors.NewDomainError("certificate_id_not_found", errors.CodeCertNotFound)
+	}
+	return pinned, nil
+}
+
+// authorizeCertificate retrieves the certificate of a signer and checks its passwords
+func (s *SigningService) authorizeCertificate(ctx context.Context, credentials models.SignerCredentials) (*models.Certificate, error) {
+	// 1: Check the public key password is present when mandatory
+	if s.policy.RequirePublicKeyPassword && credentials.PublicKeyPassword == "" {
+		return nil, errors.NewRequiredDataError("required_data")
+	}
+
+	// 2: Retrieve the pinned certificate, or the one currently valid for the NIT
+	var certificate *models.Certificate
+	var err error
+	if credentials.CertificateID != "" {
+		certificate, err = s.certRepo.GetByID(ctx, credentials.NIT, credentials.CertificateID)
+	} else {
+		certificate, err = s.certRepo.GetByNIT(ctx, credentials.NIT)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.policy.RejectExpired && certificate.IsExpiredAt(time.Now()) {
+		return nil, errors.NewDomainError("certificate_expired", errors.CodeCertificateExpired)
+	}
+
+	// 3: Verify the private key password
+	valid, err := s.certRepo.VerifyPassword(ctx, certificate, credentials.PrivateKeyPassword)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		return nil, errors.NewPasswordInvalidError(credentials.NIT)
+	}
+
+	// 4: Verify the public key password when supplied
+	if credentials.PublicKeyPassword != "" {
+		valid, err = s.certRepo.VerifyPublicPassword(ctx, certificate, credentials.PublicKeyPassword)
+		if err != nil {
+			return nil, err
+		}
+		if !valid {
+			return nil, errors.NewPasswordInvalidError(credentials.NIT)
+		}
+	}
+
+	return certificate, nil
+}
+
+// checkIssuer checks that the issuer NIT of a document is the signing NIT, or one of the issuers it may sign for
+func (s *SigningService) checkIssuer(nit string, documentData []byte) error {
+	// 1: Extract the issuer NIT; fields of unexpected types are left to the validators
+	var dte models.DTE
+	if err := json.Unmarshal(documentData, &dte); err != nil {
+		if _, ok := err.(*json.UnmarshalTypeError); !ok {
+			return errors.NewDomainError("invalid_document_json", errors.CodeStrToJSONConversion)
+		}
+	}
+	issuer, path := dte.IssuerNIT()
+	if issuer == "" {
+		return errors.NewDocumentRuleError("emisor_nit_missing", errors.CodeIssuerMismatch, path+": is required")
+	}
+
+	// 2: Accept the signing NIT itself and its allowed issuers
+	if s.mayIssue(nit, issuer) {
+		return nil
+	}
+
+	return errors.NewDocumentRuleError("emisor_nit_mismatch", errors.CodeIssuerMismatch,
+		fmt.Sprintf("%s: %q is not the signing NIT %q", path, issuer, nit))
+}
+
+// mayIssue reports whether a signing NIT may sign the documents of an issuer: its own, and those of its allowed issuers
+func (s *SigningService) mayIssue(nit string, issuer string) bool {
+	if sameNIT(issuer, nit) {
+		return true
+	}
+	for _, allowed := range s.policy.AllowedIssuers[nit] {
+		if allowed == "*" || sameNIT(issuer, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameNIT reports whether two NITs are equal, ignoring the dashes of their formatted form
+func sameNIT(a string, b string) bool {
+	return strings.ReplaceAll(a, "-", "") == strings.ReplaceAll(b, "-", "")
+}
+
+// canonicalize reports whether the document of the request must be signed in canonical form
+func (s *SigningService) canonicalize(request *models.CertificateRequest) bool {
+	return s.policy.Canonicalize || request.Canonicalize
+}
+
+// validateSchema reports whether the document of the request must be validated against its schema
+func (s *SigningService) validateSchema(request *models.CertificateRequest) bool {
+	if request.ValidateSchema != nil {
+		return *request.ValidateSchema
+	}
+	return s.policy.ValidateSchema
+}
+
+// documentBytes returns the bytes to sign for the document JSON of a request. The document is
+// signed exactly as received; a JSON string carries the document as text. When canonicalize is
+// set the document is transformed with the JSON Canonicalization Scheme (RFC 8785) first.
+func documentBytes(document json.RawMessage, canonicalize bool) ([]byte, error) {
+	// 1: Unwrap documents sent as JSON text
+	documentData := []byte(document)
+	var text string
+	if err := json.Unmarshal(document, &text); err == nil {
+		documentData = []byte(text)
+	}
+
+	// 2: Ensure the document is valid JSON
+	if !json.Valid(documentData) {
+		return nil, errors.NewDomainError("invalid_document_json", errors.CodeStrToJSONConversion)
+	}
+
+	// 3: Canonicalize the document when requested
+	if canonicalize {
+		canonical, err := jcs.Transform(documentData)
+		if err != nil {
+			return nil, errors.NewDomainError("invalid_document_json", errors.CodeStrToJSONConversion)
+		}
+		return canonical, nil
 	}
 
-	// 7: Return the signed JWS
-	return signedJWS, nil
+	return documentData, nil
 }
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+)
+
+// fakeCertificateRepository serves a fixed set of certificates per NIT, accepting the password "secret"
+type fakeCertificateRepository struct {
+	certificates map[string][]*models.Certificate
+}
+
+func (r *fakeCertificateRepository) GetByNIT(ctx context.Context, nit string) (*models.Certificate, error) {
+	if certificate := models.SelectCertificate(r.certificates[nit], time.Now()); certificate != nil {
+		return certificate, nil
+	}
+	return nil, errors.NewDomainError("no_file_found", errors.CodeCertNotFound)
+}
+
+func (r *fakeCertificateRepository) GetByID(ctx context.Context, nit string, id string) (*models.Certificate, error) {
+	for _, certificate := range r.certificates[nit] {
+		if certificate.ID == id {
+			return certificate, nil
+		}
+	}
+	return nil, errors.NewDomainError("certificate_id_not_found", errors.CodeCertNotFound)
+}
+
+func (r *fakeCertificateRepository) ListByNIT(ctx context.Context, nit string) ([]*models.Certificate, error) {
+	if len(r.certificates[nit]) == 0 {
+		return nil, errors.NewDomainError("no_file_found", errors.CodeCertNotFound)
+	}
+	return r.certificates[nit], nil
+}
+
+func (r *fakeCertificateRepository) VerifyPassword(ctx context.Context, certificate *models.Certificate, password string) (bool, error) {
+	return password == "secret", nil
+}
+
+func (r *fakeCertificateRepository) VerifyPublicPassword(ctx context.Context, certificate *models.Certificate, password string) (bool, error) {
+	return password == "secret", nil
+}
+
+// fakeDocumentSigner returns the document it is given as the payload of the signed document
+type fakeDocumentSigner struct{}
+
+func (s fakeDocumentSigner) Sign(ctx context.Context, certificate *models.Certificate, documentData interface{}, options models.SigningOptions) (*models.SignedDocument, error) {
+	return &models.SignedDocument{Payload: documentData.([]byte)}, nil
+}
+
+func (s fakeDocumentSigner) SignMulti(ctx context.Context, certificates []*models.Certificate, documentData interface{}, options models.SigningOptions) (*models.SignedDocument, error) {
+	return &models.SignedDocument{Payload: documentData.([]byte)}, nil
+}
+
+// fakeControlNumberRepository keeps the correlatives in memory, counting the allocations and releases
+type fakeControlNumberRepository struct {
+	mu        sync.Mutex
+	sequences map[string]int64
+	allocated int
+	released  int
+}
+
+func (r *fakeControlNumberRepository) Next(ctx context.Context, series models.ControlNumberSeries) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sequences == nil {
+		r.sequences = make(map[string]int64)
+	}
+	r.sequences[series.Key()]++
+	r.allocated++
+	return r.sequences[series.Key()], nil
+}
+
+func (r *fakeControlNumberRepository) Current(ctx context.Context, series models.ControlNumberSeries) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sequences[series.Key()], nil
+}
+
+func (r *fakeControlNumberRepository) Advance(ctx context.Context, series models.ControlNumberSeries, sequence int64) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sequences == nil {
+		r.sequences = make(map[string]int64)
+	}
+	if sequence > r.sequences[series.Key()] {
+		r.sequences[series.Key()] = sequence
+	}
+	return r.sequences[series.Key()], nil
+}
+
+func (r *fakeControlNumberRepository) Release(ctx context.Context, series models.ControlNumberSeries, sequence int64) (bool, error) {
+	// Like a database, a release fails with the context it is given
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sequences[series.Key()] != sequence {
+		return false, nil
+	}
+	r.sequences[series.Key()]--
+	r.released++
+	return true, nil
+}
+
+// validatorFunc adapts a function to the ports.DocumentValidator interface
+type validatorFunc func(document []byte) error
+
+func (f validatorFunc) Validate(ctx context.Context, document []byte) error {
+	return f(document)
+}
+
+// newTestCertificateRepository returns a repository with one certificate for each NIT
+func newTestCertificateRepository(nits ...string) *fakeCertificateRepository {
+	repository := &fakeCertificateRepository{certificates: make(map[string][]*models.Certificate)}
+	for _, nit := range nits {
+		repository.certificates[nit] = []*models.Certificate{{ID: nit, NIT: nit, Active: true}}
+	}
+	return repository
+}
+
+// domainCode returns the code of a domain error, or an empty string for any other error
+func domainCode(err error) string {
+	if domainErr, ok := err.(errors.DomainError); ok {
+		return domainErr.Code
+	}
+	return ""
+}
+
+func TestSignDocumentChecksPublicKeyPassword(t *testing.T) {
+	tests := []struct {
+		name           string
+		require        bool
+		publicPassword string
+		wantCode       string
+	}{
+		{"optional password left out", false, "", ""},
+		{"optional password given", false, "secret", ""},
+		{"optional password mistaken", false, "wrong", errors.CodePasswordInvalid},
+		{"required password left out", true, "", errors.CodeRequiredData},
+		{"required password given", true, "secret", ""},
+		{"required password mistaken", true, "wrong", errors.CodePasswordInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _ := newTestSigningService(SigningPolicy{RequirePublicKeyPassword: tt.require}, nil)
+			request := &models.CertificateRequest{
+				NIT:                testNIT,
+				PrivateKeyPassword: "secret",
+				PublicKeyPassword:  tt.publicPassword,
+				DocumentJSON:       json.RawMessage(testDocument(testNIT, `"DTE-01-M001P001-000000000000001"`)),
+			}
+
+			_, err := service.SignDocument(context.Background(), request)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Fatalf("SignDocument() error = %v", err)
+				}
+				return
+			}
+			if domainCode(err) != tt.wantCode {
+				t.Errorf("SignDocument() error = %v, want code %s", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestSignDocumentChecksTheIssuer(t *testing.T) {
+	donation := func(donatario string) string {
+		member := ""
+		if donatario != "" {
+			member = `"donatario": {"tipoDocumento": "36", "numDocumento": "` + donatario + `"}, `
+		}
+		return `{"identificacion": {"version": 1, "tipoDte": "15", "numeroControl": "DTE-15-M001P001-000000000000001", "ambiente": "00"}, ` +
+			member + `"donante": {"tipoDocumento": "36", "numDocumento": "` + testOtherNIT + `"}}`
+	}
+	enforced := SigningPolicy{EnforceIssuer: true}
+
+	tests := []struct {
+		name        string
+		policy      SigningPolicy
+		document    string
+		wantMessage string
+		wantDetail  string
+	}{
+		{name: "issued by the signing NIT", policy: enforced, document: testDocument(testNIT, `"DTE-01-M001P001-000000000000001"`)},
+		{name: "issued by the formatted signing NIT", policy: enforced, document: testDocument("0614-010178-001-0", `"DTE-01-M001P001-000000000000001"`)},
+		{
+			name: "issued by another taxpayer", policy: enforced, document: testDocument(testOtherNIT, `"DTE-01-M001P001-000000000000001"`),
+			wantMessage: "emisor_nit_mismatch", wantDetail: `$.emisor.nit: "06142803901121" is not the signing NIT "06140101780010"`,
+		},
+		{
+			name:     "issued by an allowed issuer",
+			policy:   SigningPolicy{EnforceIssuer: true, AllowedIssuers: map[string][]string{testNIT: {"0614-280390-112-1"}}},
+			document: testDocument(testOtherNIT, `"DTE-01-M001P001-000000000000001"`),
+		},
+		{
+			name:     "issued by any taxpayer when all are allowed",
+			policy:   SigningPolicy{EnforceIssuer: true, AllowedIssuers: map[string][]string{testNIT: {"*"}}},
+			document: testDocument(testOtherNIT, `"DTE-01-M001P001-000000000000001"`),
+		},
+		{
+			name:        "issued by an issuer allowed to another signing NIT",
+			policy:      SigningPolicy{EnforceIssuer: true, AllowedIssuers: map[string][]string{testOtherNIT: {"*"}}},
+			document:    testDocument(testOtherNIT, `"DTE-01-M001P001-000000000000001"`),
+			wantMessage: "emisor_nit_mismatch",
+		},
+		{
+			name: "without issuer", policy: enforced, document: `{"identificacion": {"version": 1, "tipoDte": "01", "ambiente": "00"}}`,
+			wantMessage: "emisor_nit_missing", wantDetail: "$.emisor.nit: is required",
+		},
+		{name: "issued by another taxpayer without the issuer check", document: testDocument(testOtherNIT, `"DTE-01-M001P001-000000000000001"`)},
+		{name: "donation received by the signing NIT", policy: enforced, document: donation(testNIT)},
+		{
+			name: "donation received by another taxpayer", policy: enforced, document: donation(testOtherNIT),
+			wantMessage: "emisor_nit_mismatch", wantDetail: `$.donatario.numDocumento: "06142803901121" is not the signing NIT "06140101780010"`,
+		},
+		{
+			name: "donation without donatario", policy: enforced, document: donation(""),
+			wantMessage: "emisor_nit_missing", wantDetail: "$.donatario.numDocumento: is required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _ := newTestSigningService(tt.policy, nil)
+			_, err := service.SignDocument(context.Background(), &models.CertificateRequest{
+				NIT:                testNIT,
+				PrivateKeyPassword: "secret",
+				DocumentJSON:       json.RawMessage(tt.document),
+			})
+			if tt.wantMessage == "" {
+				if err != nil {
+					t.Fatalf("SignDocument() error = %v", err)
+				}
+				return
+			}
+			domainErr, ok := err.(errors.DomainError)
+			if !ok || domainErr.Code != errors.CodeIssuerMismatch || domainErr.Message != tt.wantMessage {
+				t.Fatalf("SignDocument() error = %v, want %s %s", err, errors.CodeIssuerMismatch, tt.wantMessage)
+			}
+			if tt.wantDetail != "" && (len(domainErr.Details) != 1 || domainErr.Details[0] != tt.wantDetail) {
+				t.Errorf("details = %q, want %q", domainErr.Details, tt.wantDetail)
+			}
+		})
+	}
+}
@@ -0,0 +1,373 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+)
+
+// maxTotalsViolations bounds the discrepancies reported for a single document
+const maxTotalsViolations = 50
+
+var (
+	// lineTolerance is the difference accepted between an amount of a line and its recomputed value
+	lineTolerance = big.NewRat(1, 100)
+	// totalTolerance is the difference accepted between an amount of the resumen and its recomputed value
+	totalTolerance = big.NewRat(1, 100)
+	// ivaRate is the IVA rate, 13%
+	ivaRate = big.NewRat(13, 100)
+	// ivaIncludedRate is the IVA contained in a price that includes it, 13/113
+	ivaIncludedRate = big.NewRat(13, 113)
+	// ivaWithholdingRate is the rate of the IVA retention and perception of large taxpayers, 1%
+	ivaWithholdingRate = big.NewRat(1, 100)
+)
+
+// retentionRates maps the IVA retention codes of the Comprobante de Retención to their rates
+var retentionRates = map[string]*big.Rat{
+	"22": big.NewRat(1, 100),
+	"C4": big.NewRat(13, 100),
+}
+
+// totalsRules recomputes the totals of the DTE types that have them
+var totalsRules = map[string]func(check *totalsCheck){
+	"01": checkSalesTotals,
+	"03": checkSalesTotals,
+	"04": checkSalesTotals,
+	"05": checkSalesTotals,
+	"06": checkSalesTotals,
+	"07": checkRetentionTotals,
+	"08": checkSettlementTotals,
+	"11": checkExportTotals,
+	"14": checkExcludedSubjectTotals,
+	"15": checkDonationTotals,
+}
+
+// TotalsValidator implements the ports.DocumentValidator interface, recomputing the line amounts
+// and the resumen of a DTE from its cuerpoDocumento the way Hacienda checks them
+type TotalsValidator struct{}
+
+// NewTotalsValidator creates a new totals validator
+func NewTotalsValidator() *TotalsValidator {
+	return &TotalsValidator{}
+}
+
+// totalsCheck holds a document being checked and the discrepancies found
+type totalsCheck struct {
+	tipoDte    string
+	lines      []amounts
+	resumen    amounts
+	violations []string
+}
+
+// amounts holds the members of a JSON object, with numbers kept exact
+type amounts map[string]interface{}
+
+// Validate recomputes the totals of a document, listing every discrepancy by its JSON path
+func (v *TotalsValidator) Validate(ctx context.Context, document []byte) error {
+	// 1: Decode the document, keeping numbers exact
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	var dte struct {
+		Identificacion struct {
+			TipoDte string `json:"tipoDte"`
+		} `json:"identificacion"`
+		CuerpoDocumento []amounts `json:"cuerpoDocumento"`
+		Resumen         amounts   `json:"resumen"`
+	}
+	if err := decoder.Decode(&dte); err != nil {
+		if _, ok := err.(*json.UnmarshalTypeError); ok {
+			return errors.NewDocumentInvalidError("dte_totals_invalid", []string{
+				"$: cuerpoDocumento must be an array and resumen an object",
+			})
+		}
+		return errors.NewDomainError("invalid_document_json", errors.CodeStrToJSONConversion)
+	}
+
+	// 2: Recompute the totals of the DTE types that have them
+	rules, ok := totalsRules[dte.Identificacion.TipoDte]
+	if !ok || dte.Resumen == nil {
+		return nil
+	}
+	check := &totalsCheck{tipoDte: dte.Identificacion.TipoDte, lines: dte.CuerpoDocumento, resumen: dte.Resumen}
+	rules(check)
+	if len(check.violations) == 0 {
+		return nil
+	}
+
+	violations := check.violations
+	if len(violations) > maxTotalsViolations {
+		violations = append(violations[:maxTotalsViolations], fmt.Sprintf("%d more discrepancy(ies)", len(violations)-maxTotalsViolations))
+	}
+	return errors.NewDocumentRuleError("dte_totals_invalid", errors.CodeTotals, violations...)
+}
+
+// checkSalesTotals checks the Factura, Comprobante de Crédito Fiscal, Nota de Remisión, Nota de
+// Crédito and Nota de Débito. Factura prices include IVA; the rest add it as tributo 20.
+func checkSalesTotals(check *totalsCheck) {
+	factura := check.tipoDte == "01"
+
+	// 1: Every line sells its quantity at its price, less its discount, as non-subject, exempt or taxed
+	for i, line := range check.lines {
+		sold := sum(line.get("ventaNoSuj"), line.get("ventaExenta"), line.get("ventaGravada"))
+		check.line(i, saleField(line), sold, lineAmount(line, "precioUni"),
+			"cantidad × precioUni − montoDescu", "ventaNoSuj + ventaExenta + ventaGravada")
+		if factura {
+			check.line(i, "ivaItem", line.get("ivaItem"), mul(line.get("ventaGravada"), ivaIncludedRate), "ventaGravada × 13/113", "")
+		}
+	}
+
+	// 2: The resumen adds up the lines, then applies the global discounts
+	resumen := check.resumen
+	check.total("totalNoSuj", check.sumLines("ventaNoSuj"), "Σ ventaNoSuj")
+	check.total("totalExenta", check.sumLines("ventaExenta"), "Σ ventaExenta")
+	check.total("totalGravada", check.sumLines("ventaGravada"), "Σ ventaGravada")
+	check.total("subTotalVentas", sum(resumen.get("totalNoSuj"), resumen.get("totalExenta"), resumen.get("totalGravada")),
+		"totalNoSuj + totalExenta + totalGravada")
+	globalDiscounts := sum(resumen.get("descuNoSuj"), resumen.get("descuExenta"), resumen.get("descuGravada"))
+	check.total("totalDescu", sum(check.sumLines("montoDescu"), globalDiscounts),
+		"Σ montoDescu + descuNoSuj + descuExenta + descuGravada")
+	check.total("subTotal", sub(resumen.get("subTotalVentas"), globalDiscounts),
+		"subTotalVentas − descuNoSuj − descuExenta − descuGravada")
+
+	// 3: IVA is 13% of the taxed sales, contained in the Factura prices and added as tributo 20 otherwise.
+	// The Factura IVA adds up the rounded IVA of its lines, so it does not drift from them as lines are added.
+	taxed := sub(resumen.get("totalGravada"), resumen.get("descuGravada"))
+	taxBase := taxed
+	if factura {
+		iva := sub(check.sumLines("ivaItem"), mul(resumen.get("descuGravada"), ivaIncludedRate))
+		check.total("totalIva", iva, "Σ ivaItem − descuGravada × 13/113")
+		taxBase = sub(taxed, iva)
+	} else {
+		check.ivaTributo(taxed)
+	}
+
+	// 4: The 1% IVA retention and perception, when applied, are computed on the taxed sales without IVA
+	check.withholding("ivaRete1", taxBase)
+	if !factura {
+		check.withholding("ivaPerci1", taxBase)
+	}
+
+	// 5: The operation adds the other taxes, the payment settles the retentions and perceptions
+	check.total("montoTotalOperacion", sum(resumen.get("subTotal"), check.sumTributos()), "subTotal + Σ tributos.valor")
+	if _, ok := resumen["totalPagar"]; ok {
+		payable := sum(resumen.get("montoTotalOperacion"), resumen.get("totalNoGravado"), resumen.get("ivaPerci1"), resumen.get("saldoFavor"))
+		check.total("totalPagar", sub(payable, sum(resumen.get("ivaRete1"), resumen.get("reteRenta"))),
+			"montoTotalOperacion + totalNoGravado + ivaPerci1 + saldoFavor − ivaRete1 − reteRenta")
+	}
+}
+
+// checkRetentionTotals checks the Comprobante de Retención
+func checkRetentionTotals(check *totalsCheck) {
+	for i, line := range check.lines {
+		code, _ := line["codigoRetencionMH"].(string)
+		if rate, ok := retentionRates[code]; ok {
+			check.line(i, "ivaRetenido", line.get("ivaRetenido"), mul(line.get("montoSujetoGrav"), rate),
+				"montoSujetoGrav × "+rate.FloatString(2)+" (codigoRetencionMH "+code+")", "")
+		}
+	}
+	check.total("totalSujetoRetencion", check.sumLines("montoSujetoGrav"), "Σ montoSujetoGrav")
+	check.total("totalIVAretenido", check.sumLines("ivaRetenido"), "Σ ivaRetenido")
+}
+
+// checkSettlementTotals checks the Comprobante de Liquidación
+func checkSettlementTotals(check *totalsCheck) {
+	resumen := check.resumen
+	check.total("totalNoSuj", check.sumLines("ventaNoSuj"), "Σ ventaNoSuj")
+	check.total("totalExenta", check.sumLines("ventaExenta"), "Σ ventaExenta")
+	check.total("totalGravada", check.sumLines("ventaGravada"), "Σ ventaGravada")
+	check.total("totalExportacion", check.sumLines("exportaciones"), "Σ exportaciones")
+	check.total("subTotalVentas", sum(resumen.get("totalNoSuj"), resumen.get("totalExenta"), resumen.get("totalGravada"), resumen.get("totalExportacion")),
+		"totalNoSuj + totalExenta + totalGravada + totalExportacion")
+	check.ivaTributo(resumen.get("totalGravada"))
+	check.total("montoTotalOperacion", sum(resumen.get("subTotalVentas"), check.sumTributos()), "subTotalVentas + Σ tributos.valor")
+}
+
+// checkExportTotals checks the Factura de Exportación
+func checkExportTotals(check *totalsCheck) {
+	for i, line := range check.lines {
+		check.line(i, "ventaGravada", line.get("ventaGravada"), lineAmount(line, "precioUni"), "cantidad × precioUni − montoDescu", "")
+	}
+
+	resumen := check.resumen
+	check.total("totalGravada", check.sumLines("ventaGravada"), "Σ ventaGravada")
+	check.total("totalDescu", sum(check.sumLines("montoDescu"), resumen.get("descuento")), "Σ montoDescu + descuento")
+	check.total("montoTotalOperacion", sum(sub(resumen.get("totalGravada"), resumen.get("descuento")), resumen.get("seguro"), resumen.get("flete")),
+		"totalGravada − descuento + seguro + flete")
+	check.total("totalPagar", sum(resumen.get("montoTotalOperacion"), resumen.get("totalNoGravado")), "montoTotalOperacion + totalNoGravado")
+}
+
+// checkExcludedSubjectTotals checks the Factura de Sujeto Excluido
+func checkExcludedSubjectTotals(check *totalsCheck) {
+	for i, line := range check.lines {
+		check.line(i, "compra", line.get("compra"), lineAmount(line, "precioUni"), "cantidad × precioUni − montoDescu", "")
+	}
+
+	resumen := check.resumen
+	check.total("totalCompra", check.sumLines("compra"), "Σ compra")
+	check.total("totalDescu", sum(check.sumLines("montoDescu"), resumen.get("descu")), "Σ montoDescu + descu")
+	check.total("subTotal", sub(resumen.get("totalCompra"), resumen.get("descu")), "totalCompra − descu")
+	check.total("totalPagar", sub(resumen.get("subTotal"), sum(resumen.get("ivaRete1"), resumen.get("reteRenta"))),
+		"subTotal − ivaRete1 − reteRenta")
+}
+
+// checkDonationTotals checks the Comprobante de Donación
+func checkDonationTotals(check *totalsCheck) {
+	check.total("valorTotal", check.sumLines("valor"), "Σ valor")
+}
+
+// line reports a line amount that differs from its recomputed value by more than the line tolerance;
+// statedFormula names the stated amount when it adds up several fields
+func (c *totalsCheck) line(index int, field string, stated *big.Rat, expected *big.Rat, formula string, statedFormula string) {
+	if withinTolerance(stated, expected, lineTolerance) {
+		return
+	}
+	statedAmount := formatAmount(stated, 8)
+	if statedFormula != "" {
+		statedAmount = statedFormula + " = " + statedAmount
+	}
+	c.violations = append(c.violations, fmt.Sprintf("$.cuerpoDocumento[%d].%s: %s, expected %s = %s",
+		index, field, statedAmount, formula, formatAmount(expected, 8)))
+}
+
+// total reports a resumen amount that differs from its recomputed value by more than the total tolerance
+func (c *totalsCheck) total(field string, expected *big.Rat, formula string) {
+	stated := c.resumen.get(field)
+	if withinTolerance(stated, expected, totalTolerance) {
+		return
+	}
+	c.violations = append(c.violations, fmt.Sprintf("$.resumen.%s: %s, expected %s = %s",
+		field, formatAmount(stated, 2), formula, formatAmount(expected, 2)))
+}
+
+// ivaTributo checks that the IVA tributo (20) of the resumen is 13% of the taxed sales, and is present when they are not zero
+func (c *totalsCheck) ivaTributo(taxed *big.Rat) {
+	for i, tributo := range c.tributos() {
+		if codigo, _ := tributo["codigo"].(string); codigo == "20" {
+			expected := mul(taxed, ivaRate)
+			if !withinTolerance(tributo.get("valor"), expected, totalTolerance) {
+				c.violations = append(c.violations, fmt.Sprintf("$.resumen.tributos[%d].valor: %s, expected IVA (totalGravada − descuGravada) × 0.13 = %s",
+					i, formatAmount(tributo.get("valor"), 2), formatAmount(expected, 2)))
+			}
+			return
+		}
+	}
+	if taxed.Sign() > 0 && c.tipoDte != "04" {
+		c.violations = append(c.violations, fmt.Sprintf("$.resumen.tributos: IVA (20) missing, expected (totalGravada − descuGravada) × 0.13 = %s",
+			formatAmount(mul(taxed, ivaRate), 2)))
+	}
+}
+
+// withholding checks that a 1% IVA retention or perception is zero or 1% of its base
+func (c *totalsCheck) withholding(field string, base *big.Rat) {
+	stated := c.resumen.get(field)
+	expected := mul(base, ivaWithholdingRate)
+	if stated.Sign() == 0 || withinTolerance(stated, expected, totalTolerance) {
+		return
+	}
+	c.violations = append(c.violations, fmt.Sprintf("$.resumen.%s: %s, expected 0 or 1%% of the taxed sales without IVA = %s",
+		field, formatAmount(stated, 2), formatAmount(expected, 2)))
+}
+
+// sumLines adds up an amount of every line
+func (c *totalsCheck) sumLines(field string) *big.Rat {
+	total := new(big.Rat)
+	for _, line := range c.lines {
+		total.Add(total, line.get(field))
+	}
+	return total
+}
+
+// tributos returns the tributos of the resumen
+func (c *totalsCheck) tributos() []amounts {
+	list, _ := c.resumen["tributos"].([]interface{})
+	tributos := make([]amounts, 0, len(list))
+	for _, item := range list {
+		if tributo, ok := item.(map[string]interface{}); ok {
+			tributos = append(tributos, tributo)
+		}
+	}
+	return tributos
+}
+
+// sumTributos adds up the valor of the tributos of the resumen
+func (c *totalsCheck) sumTributos() *big.Rat {
+	total := new(big.Rat)
+	for _, tributo := range c.tributos() {
+		total.Add(total, tributo.get("valor"))
+	}
+	return total
+}
+
+// get returns an amount, zero when it is missing, null or not a number
+func (a amounts) get(field string) *big.Rat {
+	value := new(big.Rat)
+	if number, ok := a[field].(json.Number); ok {
+		if _, ok := value.SetString(number.String()); !ok {
+			return new(big.Rat)
+		}
+	}
+	return value
+}
+
+// lineAmount returns the quantity of a line at the given price less its discount
+func lineAmount(line amounts, price string) *big.Rat {
+	return sub(mul(line.get("cantidad"), line.get(price)), line.get("montoDescu"))
+}
+
+// saleField names the sale amount of a line, the only one that is not zero or else ventaGravada
+func saleField(line amounts) string {
+	field := "ventaGravada"
+	count := 0
+	for _, candidate := range []string{"ventaNoSuj", "ventaExenta", "ventaGravada"} {
+		if line.get(candidate).Sign() != 0 {
+			field = candidate
+			count++
+		}
+	}
+	if count > 1 {
+		return "ventaGravada"
+	}
+	return field
+}
+
+// withinTolerance reports whether two amounts differ by at most the tolerance
+func withinTolerance(stated *big.Rat, expected *big.Rat, tolerance *big.Rat) bool {
+	difference := new(big.Rat).Sub(stated, expected)
+	return difference.Abs(difference).Cmp(tolerance) <= 0
+}
+
+// formatAmount formats an amount with up to the given decimals, keeping at least two
+func formatAmount(amount *big.Rat, decimals int) string {
+	formatted := amount.FloatString(decimals)
+	if decimals > 2 {
+		formatted = strings.TrimRight(formatted, "0")
+		if point := strings.IndexByte(formatted, '.'); len(formatted)-point-1 < 2 {
+			formatted += strings.Repeat("0", 2-(len(formatted)-point-1))
+		}
+	}
+	return formatted
+}
+
+// sum adds amounts
+func sum(values ...*big.Rat) *big.Rat {
+	total := new(big.Rat)
+	for _, value := range values {
+		total.Add(total, value)
+	}
+	return total
+}
+
+// sub subtracts an amount from another
+func sub(a *big.Rat, b *big.Rat) *big.Rat {
+	return new(big.Rat).Sub(a, b)
+}
+
+// mul multiplies two amounts
+func mul(a *big.Rat, b *big.Rat) *big.Rat {
+	return new(big.Rat).Mul(a, b)
+}
@@ -0,0 +1,319 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+)
+
+// totalsDocument builds a DTE of the given type with its lines and resumen
+func totalsDocument(t *testing.T, tipoDte string, lines []map[string]interface{}, resumen map[string]interface{}) []byte {
+	t.Helper()
+	document, err := json.Marshal(map[string]interface{}{
+		"identificacion":  map[string]interface{}{"tipoDte": tipoDte},
+		"cuerpoDocumento": lines,
+		"resumen":         resumen,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return document
+}
+
+// ivaTributo returns the IVA tributo of a resumen with the given value
+func ivaTributo(valor float64) []map[string]interface{} {
+	return []map[string]interface{}{{"codigo": "20", "descripcion": "Impuesto al Valor Agregado 13%", "valor": valor}}
+}
+
+// salesResumen returns a resumen of a sales DTE taxed at the given amount, without discounts nor withholdings
+func salesResumen(noSuj, exenta, gravada float64) map[string]interface{} {
+	return map[string]interface{}{
+		"totalNoSuj": noSuj, "totalExenta": exenta, "totalGravada": gravada, "subTotalVentas": noSuj + exenta + gravada,
+		"descuNoSuj": 0, "descuExenta": 0, "descuGravada": 0, "totalDescu": 0, "subTotal": noSuj + exenta + gravada,
+		"ivaRete1": 0, "reteRenta": 0, "totalNoGravado": 0,
+	}
+}
+
+// facturaDocument returns a valid Factura: one taxed and one exempt line, prices including IVA
+func facturaDocument() ([]map[string]interface{}, map[string]interface{}) {
+	lines := []map[string]interface{}{
+		{"cantidad": 3, "precioUni": 1.13, "montoDescu": 0, "ventaNoSuj": 0, "ventaExenta": 0, "ventaGravada": 3.39, "ivaItem": 0.39},
+		{"cantidad": 1, "precioUni": 10, "montoDescu": 0, "ventaNoSuj": 0, "ventaExenta": 10, "ventaGravada": 0, "ivaItem": 0},
+	}
+	resumen := salesResumen(0, 10, 3.39)
+	resumen["totalIva"] = 0.39
+	resumen["montoTotalOperacion"] = 13.39
+	resumen["totalPagar"] = 13.39
+	return lines, resumen
+}
+
+// creditoFiscalDocument returns a valid document of a sales type adding IVA as tributo 20
+func creditoFiscalDocument() ([]map[string]interface{}, map[string]interface{}) {
+	lines := []map[string]interface{}{
+		{"cantidad": 2, "precioUni": 5, "montoDescu": 0, "ventaNoSuj": 0, "ventaExenta": 0, "ventaGravada": 10},
+		{"cantidad": 4, "precioUni": 2.5, "montoDescu": 1, "ventaNoSuj": 9, "ventaExenta": 0, "ventaGravada": 0},
+	}
+	resumen := salesResumen(9, 0, 10)
+	resumen["tributos"] = ivaTributo(1.3)
+	resumen["ivaPerci1"] = 0
+	resumen["totalDescu"] = 1
+	resumen["montoTotalOperacion"] = 20.3
+	resumen["totalPagar"] = 20.3
+	return lines, resumen
+}
+
+func TestTotalsValidator(t *testing.T) {
+	tests := []struct {
+		name    string
+		tipoDte string
+		// build returns the lines and resumen of a valid document, which modify may break
+		build  func() ([]map[string]interface{}, map[string]interface{})
+		modify func(lines []map[string]interface{}, resumen map[string]interface{})
+		// want lists the start of every expected violation, none for a valid document
+		want []string
+	}{
+		{name: "factura", tipoDte: "01", build: facturaDocument},
+		{
+			name: "factura with a taxed global discount", tipoDte: "01", build: facturaDocument,
+			modify: func(lines []map[string]interface{}, resumen map[string]interface{}) {
+				resumen["descuGravada"], resumen["totalDescu"], resumen["subTotal"] = 1.13, 1.13, 12.26
+				resumen["totalIva"], resumen["montoTotalOperacion"], resumen["totalPagar"] = 0.26, 12.26, 12.26
+			},
+		},
+		{
+			name: "factura with 1% IVA retention", tipoDte: "01", build: facturaDocument,
+			modify: func(lines []map[string]interface{}, resumen map[string]interface{}) {
+				resumen["ivaRete1"], resumen["totalPagar"] = 0.03, 13.36
+			},
+		},
+		{
+			name: "factura with a wrong totalIva", tipoDte: "01", build: facturaDocument,
+			modify: func(lines []map[string]interface{}, resumen map[string]interface{}) { resumen["totalIva"] = 0.5 },
+			want:   []string{"$.resumen.totalIva: 0.50, expected Σ ivaItem − descuGravada × 13/113 = 0.39"},
+		},
+		{
+			name: "factura with a wrong ivaItem", tipoDte: "01", build: facturaDocument,
+			modify: func(lines []map[string]interface{}, resumen map[string]interface{}) {
+				lines[0]["ivaItem"], resumen["totalIva"] = 0.45, 0.45
+			},
+			want: []string{"$.cuerpoDocumento[0].ivaItem: 0.45, expected ventaGravada × 13/113 = 0.39"},
+		},
+		{
+			name: "factura with a line that does not add up", tipoDte: "01", build: facturaDocument,
+			modify: func(lines []map[string]interface{}, resumen map[string]interface{}) { lines[1]["cantidad"] = 2 },
+			want:   []string{"$.cuerpoDocumento[1].ventaExenta: ventaNoSuj + ventaExenta + ventaGravada = 10.00, expected cantidad × precioUni − montoDescu = 20.00"},
+		},
+		{name: "credito fiscal", tipoDte: "03", build: creditoFiscalDocument},
+		{
+			name: "credito fiscal with a wrong IVA tributo", tipoDte: "03", build: creditoFiscalDocument,
+			modify: func(lines []map[string]interface{}, resumen map[string]interface{}) {
+				resumen["tributos"], resumen["montoTotalOperacion"], resumen["totalPagar"] = ivaTributo(1.2), 20.2, 20.2
+			},
+			want: []string{"$.resumen.tributos[0].valor: 1.20, expected IVA (totalGravada − descuGravada) × 0.13 = 1.30"},
+		},
+		{
+			name: "credito fiscal without IVA tributo", tipoDte: "03", build: creditoFiscalDocument,
+			modify: func(lines []map[string]interface{}, resumen map[string]interface{}) {
+				delete(resumen, "tributos")
+				resumen["montoTotalOperacion"], resumen["totalPagar"] = 19, 19
+			},
+			want: []string{"$.resumen.tributos: IVA (20) missing"},
+		},
+		{
+			name: "credito fiscal with a wrong perception", tipoDte: "03", build: creditoFiscalDocument,
+			modify: func(lines []map[string]interface{}, resumen map[string]interface{}) {
+				resumen["ivaPerci1"], resumen["totalPagar"] = 0.5, 20.8
+			},
+			want: []string{"$.resumen.ivaPerci1: 0.50, expected 0 or 1% of the taxed sales without IVA = 0.10"},
+		},
+		{
+			name: "nota de remision without IVA tributo", tipoDte: "04", build: creditoFiscalDocument,
+			modify: func(lines []map[string]interface{}, resumen map[string]interface{}) {
+				delete(resumen, "tributos")
+				delete(resumen, "totalPagar")
+				resumen["montoTotalOperacion"] = 19
+			},
+		},
+		{
+			name: "nota de remision with a wrong subTotalVentas", tipoDte: "04", build: creditoFiscalDocument,
+			modify: func(lines []map[string]interface{}, resumen map[string]interface{}) { resumen["subTotalVentas"] = 18 },
+			want: []string{
+				"$.resumen.subTotalVentas: 18.00, expected totalNoSuj + totalExenta + totalGravada = 19.00",
+				"$.resumen.subTotal: 19.00, expected subTotalVentas − descuNoSuj − descuExenta − descuGravada = 18.00",
+			},
+		},
+		{name: "nota de credito", tipoDte: "05", build: creditoFiscalDocument},
+		{
+			name: "nota de credito with a wrong line discount", tipoDte: "05", build: creditoFiscalDocument,
+			modify: func(lines []map[string]interface{}, resumen map[string]interface{}) { lines[1]["montoDescu"] = 0 },
+			want: []string{
+				"$.cuerpoDocumento[1].ventaNoSuj: ventaNoSuj + ventaExenta + ventaGravada = 9.00, expected cantidad × precioUni − montoDescu = 10.00",
+				"$.resumen.totalDescu: 1.00, expected Σ montoDescu + descuNoSuj + descuExenta + descuGravada = 0.00",
+			},
+		},
+		{name: "nota de debito", tipoDte: "06", build: creditoFiscalDocument},
+		{
+			name: "nota de debito with a wrong totalPagar", tipoDte: "06", build: creditoFiscalDocument,
+			modify: func(lines []map[string]interface{}, resumen map[string]interface{}) { resumen["totalPagar"] = 19 },
+			want:   []string{"$.resumen.totalPagar: 19.00, expected montoTotalOperacion + totalNoGravado + ivaPerci1 + saldoFavor − ivaRete1 − reteRenta = 20.30"},
+		},
+		{name: "comprobante de retencion", tipoDte: "07", build: retentionDocument},
+		{
+			name: "comprobante de retencion at the wrong rate", tipoDte: "07", build: retentionDocument,
+			modify: func(lines []map[string]interface{}, resumen map[string]interface{}) {
+				lines[0]["ivaRetenido"], resumen["totalIVAretenido"] = 13, 26
+			},
+			want: []string{"$.cuerpoDocumento[0].ivaRetenido: 13.00, expected montoSujetoGrav × 0.01 (codigoRetencionMH 22) = 1.00"},
+		},
+		{name: "comprobante de liquidacion", tipoDte: "08", build: settlementDocument},
+		{
+			name: "comprobante de liquidacion without the IVA in the operation", tipoDte: "08", build: settlementDocument,
+			modify: func(lines []map[string]interface{}, resumen map[string]interface{}) {
+				resumen["montoTotalOperacion"] = 150
+			},
+			want: []string{"$.resumen.montoTotalOperacion: 150.00, expected subTotalVentas + Σ tributos.valor = 163.00"},
+		},
+		{name: "factura de exportacion", tipoDte: "11", build: exportDocument},
+		{
+			name: "factura de exportacion without freight", tipoDte: "11", build: exportDocument,
+			modify: func(lines []map[string]interface{}, resumen map[string]interface{}) {
+				resumen["montoTotalOperacion"], resumen["totalPagar"] = 105, 105
+			},
+			want: []string{"$.resumen.montoTotalOperacion: 105.00, expected totalGravada − descuento + seguro + flete = 115.00"},
+		},
+		{name: "factura de sujeto excluido", tipoDte: "14", build: excludedSubjectDocument},
+		{
+			name: "factura de sujeto excluido without the income tax retention", tipoDte: "14", build: excludedSubjectDocument,
+			modify: func(lines []map[string]interface{}, resumen map[string]interface{}) { resumen["totalPagar"] = 100 },
+			want:   []string{"$.resumen.totalPagar: 100.00, expected subTotal − ivaRete1 − reteRenta = 90.00"},
+		},
+		{name: "comprobante de donacion", tipoDte: "15", build: donationDocument},
+		{
+			name: "comprobante de donacion with a wrong valorTotal", tipoDte: "15", build: donationDocument,
+			modify: func(lines []map[string]interface{}, resumen map[string]interface{}) { resumen["valorTotal"] = 70 },
+			want:   []string{"$.resumen.valorTotal: 70.00, expected Σ valor = 75.00"},
+		},
+		{
+			name: "documento contable de liquidacion is not checked", tipoDte: "09", build: donationDocument,
+			modify: func(lines []map[string]interface{}, resumen map[string]interface{}) { resumen["valorTotal"] = 70 },
+		},
+	}
+
+	validator := NewTotalsValidator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, resumen := tt.build()
+			if tt.modify != nil {
+				tt.modify(lines, resumen)
+			}
+
+			err := validator.Validate(context.Background(), totalsDocument(t, tt.tipoDte, lines, resumen))
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			domainErr, ok := err.(errors.DomainError)
+			if !ok || domainErr.Code != errors.CodeTotals || domainErr.Message != "dte_totals_invalid" {
+				t.Fatalf("Validate() error = %v, want %s: dte_totals_invalid", err, errors.CodeTotals)
+			}
+			if len(domainErr.Details) != len(tt.want) {
+				t.Fatalf("Validate() details = %q, want %d violation(s)", domainErr.Details, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(domainErr.Details[i], want) {
+					t.Errorf("violation %d = %q, want %q", i, domainErr.Details[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestTotalsValidatorFacturaIvaFollowsItsLines(t *testing.T) {
+	// Each line rounds its IVA, 0.115 to 0.01, so the total drifts from the IVA of the total taxed sales
+	for _, count := range []int{1, 10, 100, 1000} {
+		t.Run(fmt.Sprintf("%d lines", count), func(t *testing.T) {
+			lines := make([]map[string]interface{}, count)
+			for i := range lines {
+				lines[i] = map[string]interface{}{"cantidad": 1, "precioUni": 0.1, "montoDescu": 0, "ventaGravada": 0.1, "ivaItem": 0.01}
+			}
+			gravada := float64(count) / 10
+			resumen := salesResumen(0, 0, gravada)
+			resumen["totalIva"] = float64(count) / 100
+			resumen["montoTotalOperacion"], resumen["totalPagar"] = gravada, gravada
+
+			if err := NewTotalsValidator().Validate(context.Background(), totalsDocument(t, "01", lines, resumen)); err != nil {
+				t.Errorf("Validate() error = %v", err)
+			}
+
+			// The IVA of the total taxed sales no longer matches the lines
+			resumen["totalIva"] = float64(count) * 0.1 * 13 / 113
+			err := NewTotalsValidator().Validate(context.Background(), totalsDocument(t, "01", lines, resumen))
+			if count >= 10 && domainCode(err) != errors.CodeTotals {
+				t.Errorf("Validate() of a totalIva that differs from Σ ivaItem error = %v, want code %s", err, errors.CodeTotals)
+			}
+		})
+	}
+}
+
+func TestTotalsValidatorRejectsMalformedBlocks(t *testing.T) {
+	err := NewTotalsValidator().Validate(context.Background(), []byte(`{"identificacion":{"tipoDte":"01"},"cuerpoDocumento":{},"resumen":{}}`))
+	if domainCode(err) != errors.CodeDocumentInvalid {
+		t.Errorf("Validate() error = %v, want code %s", err, errors.CodeDocumentInvalid)
+	}
+	err = NewTotalsValidator().Validate(context.Background(), []byte(`{"identificacion":`))
+	if domainCode(err) != errors.CodeStrToJSONConversion {
+		t.Errorf("Validate() error = %v, want code %s", err, errors.CodeStrToJSONConversion)
+	}
+}
+
+// retentionDocument returns a valid Comprobante de Retención, at 1% and at 13%
+func retentionDocument() ([]map[string]interface{}, map[string]interface{}) {
+	lines := []map[string]interface{}{
+		{"codigoRetencionMH": "22", "montoSujetoGrav": 100, "ivaRetenido": 1},
+		{"codigoRetencionMH": "C4", "montoSujetoGrav": 100, "ivaRetenido": 13},
+	}
+	return lines, map[string]interface{}{"totalSujetoRetencion": 200, "totalIVAretenido": 14}
+}
+
+// settlementDocument returns a valid Comprobante de Liquidación
+func settlementDocument() ([]map[string]interface{}, map[string]interface{}) {
+	lines := []map[string]interface{}{
+		{"ventaNoSuj": 0, "ventaExenta": 0, "ventaGravada": 100, "exportaciones": 0},
+		{"ventaNoSuj": 0, "ventaExenta": 50, "ventaGravada": 0, "exportaciones": 0},
+	}
+	resumen := map[string]interface{}{
+		"totalNoSuj": 0, "totalExenta": 50, "totalGravada": 100, "totalExportacion": 0, "subTotalVentas": 150,
+		"tributos": ivaTributo(13), "montoTotalOperacion": 163,
+	}
+	return lines, resumen
+}
+
+// exportDocument returns a valid Factura de Exportación with insurance and freight
+func exportDocument() ([]map[string]interface{}, map[string]interface{}) {
+	lines := []map[string]interface{}{{"cantidad": 2, "precioUni": 50, "montoDescu": 0, "ventaGravada": 100}}
+	resumen := map[string]interface{}{
+		"totalGravada": 100, "descuento": 0, "totalDescu": 0, "seguro": 5, "flete": 10,
+		"montoTotalOperacion": 115, "totalNoGravado": 0, "totalPagar": 115,
+	}
+	return lines, resumen
+}
+
+// excludedSubjectDocument returns a valid Factura de Sujeto Excluido with an income tax retention
+func excludedSubjectDocument() ([]map[string]interface{}, map[string]interface{}) {
+	lines := []map[string]interface{}{{"cantidad": 1, "precioUni": 100, "montoDescu": 0, "compra": 100}}
+	resumen := map[string]interface{}{
+		"totalCompra": 100, "descu": 0, "totalDescu": 0, "subTotal": 100, "ivaRete1": 0, "reteRenta": 10, "totalPagar": 90,
+	}
+	return lines, resumen
+}
+
+// donationDocument returns a valid Comprobante de Donación
+func donationDocument() ([]map[string]interface{}, map[string]interface{}) {
+	return []map[string]interface{}{{"valor": 50}, {"valor": 25}}, map[string]interface{}{"valorTotal": 75}
+}
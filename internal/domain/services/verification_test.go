@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+)
+
+// recordingVerifier accepts every JWS, recording the candidate certificates of each signer it was given
+type recordingVerifier struct {
+	signers [][]string
+}
+
+func (v *recordingVerifier) Verify(ctx context.Context, certificate *models.Certificate, serialized string, detachedPayload []byte) (*models.VerificationResult, error) {
+	return &models.VerificationResult{Valid: true, NIT: certificate.NIT}, nil
+}
+
+func (v *recordingVerifier) VerifyMulti(ctx context.Context, signers [][]*models.Certificate, serialized string, detachedPayload []byte) (*models.VerificationResult, error) {
+	for _, candidates := range signers {
+		ids := make([]string, 0, len(candidates))
+		for _, certificate := range candidates {
+			ids = append(ids, certificate.ID)
+		}
+		v.signers = append(v.signers, ids)
+	}
+	return &models.VerificationResult{Valid: true}, nil
+}
+
+func TestVerifyDocumentGivesEveryCertificateOfEachSigner(t *testing.T) {
+	repository := &fakeCertificateRepository{certificates: map[string][]*models.Certificate{
+		testNIT:      {{ID: "current", NIT: testNIT, Active: true}, {ID: "rotated", NIT: testNIT, Active: true}},
+		testOtherNIT: {{ID: "other-current", NIT: testOtherNIT, Active: true}, {ID: "other-rotated", NIT: testOtherNIT, Active: true}},
+	}}
+
+	tests := []struct {
+		name     string
+		pinned   string
+		want     [][]string
+		wantCode string
+	}{
+		{"every certificate of both NITs", "", [][]string{{"current", "rotated"}, {"other-current", "other-rotated"}}, ""},
+		{"a pinned certificate of the second signer", "other-rotated", [][]string{{"current", "rotated"}, {"other-rotated"}}, ""},
+		{"an unknown pinned certificate", "missing", nil, errors.CodeCertNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := &recordingVerifier{}
+			service := NewSigningService(repository, fakeDocumentSigner{}, verifier, nil, nil, SigningPolicy{})
+			request := &models.CertificateRequest{
+				NIT:               testNIT,
+				CompactSerialized: "jws",
+				Signers:           []models.SignerCredentials{{NIT: testOtherNIT, CertificateID: tt.pinned}},
+			}
+
+			_, err := service.VerifyDocument(context.Background(), request)
+			if tt.wantCode != "" {
+				if domainCode(err) != tt.wantCode {
+					t.Fatalf("VerifyDocument() error = %v, want code %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyDocument() error = %v", err)
+			}
+			if !reflect.DeepEqual(verifier.signers, tt.want) {
+				t.Errorf("VerifyMulti() candidates = %v, want %v", verifier.signers, tt.want)
+			}
+		})
+	}
+}
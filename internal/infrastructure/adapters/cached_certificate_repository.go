@@ -0,0 +1,103 @@
+package adapters
+
+import (
+	"context"
+	"time"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/ports"
+	"github.com/chainedpixel/go-dte-signer/pkg/cache"
+	"github.com/chainedpixel/go-dte-signer/pkg/logs"
+)
+
+// CachedCertificateRepository decorates a certificate repository with an in-memory cache of the parsed
+// certificates of each NIT. The certificate to use is selected from the cached ones on every request,
+// so a certificate entering or leaving its validity window takes effect without waiting for the TTL.
+type CachedCertificateRepository struct {
+	repository ports.CertificateRepository
+	cache      *cache.Cache[string, []*models.Certificate]
+}
+
+// NewCachedCertificateRepository creates a new cached certificate repository
+func NewCachedCertificateRepository(repository ports.CertificateRepository, ttl time.Duration, maxEntries int) *CachedCertificateRepository {
+	return &CachedCertificateRepository{
+		repository: repository,
+		cache:      cache.New[string, []*models.Certificate](ttl, maxEntries),
+	}
+}
+
+// GetByNIT retrieves the preferred certificate of a NIT that is valid now, selected among its cached certificates
+func (r *CachedCertificateRepository) GetByNIT(ctx context.Context, nit string) (*models.Certificate, error) {
+	certificates, err := r.certificates(ctx, nit)
+	if err != nil {
+		return nil, err
+	}
+	return currentCertificate(nit, certificates)
+}
+
+// GetByID retrieves a specific certificate of a NIT among its cached certificates, which must be valid now
+func (r *CachedCertificateRepository) GetByID(ctx context.Context, nit string, id string) (*models.Certificate, error) {
+	certificates, err := r.certificates(ctx, nit)
+	if err != nil {
+		return nil, err
+	}
+	return pinnedCertificate(nit, id, certificates)
+}
+
+// ListByNIT retrieves every active certificate of a NIT. The list is only used to verify
+// signatures and its order depends on the current time, so it is not cached.
+func (r *CachedCertificateRepository) ListByNIT(ctx context.Context, nit string) ([]*models.Certificate, error) {
+	return r.repository.ListByNIT(ctx, nit)
+}
+
+// certificates returns every active certificate of a NIT, from the cache when available
+func (r *CachedCertificateRepository) certificates(ctx context.Context, nit string) ([]*models.Certificate, error) {
+	if certificates, ok := r.cache.Get(nit); ok {
+		return certificates, nil
+	}
+
+	certificates, err := r.repository.ListByNIT(ctx, nit)
+	if err != nil {
+		return nil, err
+	}
+
+	r.cache.Set(nit, certificates)
+	return certificates, nil
+}
+
+// VerifyPassword checks if the password is valid for the certificate
+func (r *CachedCertificateRepository) VerifyPassword(ctx context.Context, certificate *models.Certificate, password string) (bool, error) {
+	return r.repository.VerifyPassword(ctx, certificate, password)
+}
+
+// VerifyPublicPassword checks if the password is valid for the certificate public key
+func (r *CachedCertificateRepository) VerifyPublicPassword(ctx context.Context, certificate *models.Certificate, password string) (bool, error) {
+	return r.repository.VerifyPublicPassword(ctx, certificate, password)
+}
+
+// Invalidate removes the cached certificates of a NIT
+func (r *CachedCertificateRepository) Invalidate(nit string) {
+	r.cache.Delete(nit)
+	logs.Debug("Certificate cache invalidated for NIT:", nit)
+}
+
+// InvalidateAll removes every cached certificate
+func (r *CachedCertificateRepository) InvalidateAll() {
+	r.cache.Purge()
+	logs.Debug("Certificate cache invalidated")
+}
+
+// Stats returns the cache usage counters
+func (r *CachedCertificateRepository) Stats() cache.Stats {
+	return r.cache.Stats()
+}
+
+// HealthName returns the key under which the cache is reported in the health check
+func (r *CachedCertificateRepository) HealthName() string {
+	return "certificateCache"
+}
+
+// HealthDetails returns the cache usage counters for the health check
+func (r *CachedCertificateRepository) HealthDetails(ctx context.Context) interface{} {
+	return r.Stats()
+}
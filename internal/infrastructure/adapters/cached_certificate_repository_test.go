@@ -0,0 +1,88 @@
+package adapters
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/ports"
+)
+
+// listingRepository serves a fixed list of certificates, counting how many times it is read
+type listingRepository struct {
+	ports.CertificateRepository
+	certificates []*models.Certificate
+	reads        atomic.Int32
+}
+
+func (r *listingRepository) ListByNIT(ctx context.Context, nit string) ([]*models.Certificate, error) {
+	r.reads.Add(1)
+	return append([]*models.Certificate(nil), r.certificates...), nil
+}
+
+func TestCachedCertificateRepositoryFollowsValidityWindows(t *testing.T) {
+	nit := "06140101780010"
+	start := time.Now()
+	current := &models.Certificate{ID: "current", NIT: nit, Active: true, NotAfter: start.Add(400 * time.Millisecond)}
+	next := &models.Certificate{ID: "next", NIT: nit, Active: true, NotBefore: start.Add(200 * time.Millisecond), Priority: 1}
+	inner := &listingRepository{certificates: []*models.Certificate{current, next}}
+	repository := NewCachedCertificateRepository(inner, time.Hour, 10)
+	ctx := context.Background()
+
+	expectSelected := func(want string) {
+		t.Helper()
+		certificate, err := repository.GetByNIT(ctx, nit)
+		if err != nil {
+			t.Fatalf("GetByNIT() error = %v", err)
+		}
+		if certificate.ID != want {
+			t.Errorf("GetByNIT() = %s, want %s", certificate.ID, want)
+		}
+	}
+
+	// 1: Before the reissued certificate starts, the current one signs
+	expectSelected("current")
+	if _, err := repository.GetByID(ctx, nit, "next"); err == nil {
+		t.Error("GetByID() of a certificate not yet valid succeeded")
+	}
+
+	// 2: Once it starts, it takes over by priority although the entry is still cached
+	time.Sleep(time.Until(start.Add(250 * time.Millisecond)))
+	expectSelected("next")
+	if certificate, err := repository.GetByID(ctx, nit, "current"); err != nil || certificate.ID != "current" {
+		t.Errorf("GetByID(current) = %v, %v", certificate, err)
+	}
+
+	// 3: After its window closes, the previous certificate can no longer be pinned
+	time.Sleep(time.Until(start.Add(450 * time.Millisecond)))
+	expectSelected("next")
+	_, err := repository.GetByID(ctx, nit, "current")
+	if domainErr, ok := err.(domainErrors.DomainError); !ok || domainErr.Message != "certificate_not_valid" {
+		t.Errorf("GetByID(current) error = %v, want certificate_not_valid", err)
+	}
+
+	if reads := inner.reads.Load(); reads != 1 {
+		t.Errorf("the certificates were read %d times, want 1", reads)
+	}
+
+	// 4: Invalidating the NIT reads its certificates again
+	repository.Invalidate(nit)
+	expectSelected("next")
+	if reads := inner.reads.Load(); reads != 2 {
+		t.Errorf("the certificates were read %d times after invalidating, want 2", reads)
+	}
+}
+
+func TestCachedCertificateRepositoryWithoutValidCertificate(t *testing.T) {
+	nit := "06140101780010"
+	expired := &models.Certificate{ID: "expired", NIT: nit, Active: true, NotAfter: time.Now().Add(-time.Minute)}
+	repository := NewCachedCertificateRepository(&listingRepository{certificates: []*models.Certificate{expired}}, time.Hour, 10)
+
+	_, err := repository.GetByNIT(context.Background(), nit)
+	if domainErr, ok := err.(domainErrors.DomainError); !ok || domainErr.Code != domainErrors.CodeCertNotFound {
+		t.Errorf("GetByNIT() error = %v, want code %s", err, domainErrors.CodeCertNotFound)
+	}
+}
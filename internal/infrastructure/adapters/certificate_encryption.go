@@ -0,0 +1,60 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/ports"
+	"github.com/chainedpixel/go-dte-signer/internal/infrastructure/cypher"
+	"github.com/chainedpixel/go-dte-signer/pkg/logs"
+)
+
+// openCertificate returns the plaintext content of a stored certificate, decrypting it when it is
+// encrypted at rest. Plaintext content is returned as it is.
+func openCertificate(encryption ports.KeyEncryptionService, content []byte) ([]byte, error) {
+	if !cypher.IsEnvelope(content) {
+		return content, nil
+	}
+	if encryption == nil {
+		logs.Error("Certificate is encrypted but no master key is configured")
+		return nil, domainErrors.NewDomainError("certificate_encrypted", domainErrors.CodeInvalid)
+	}
+
+	plaintext, err := encryption.Decrypt(context.Background(), content)
+	if err != nil {
+		logs.Error("Failed to decrypt certificate:", err)
+		return nil, domainErrors.NewDomainError("certificate_decryption_failed", domainErrors.CodeInvalid)
+	}
+	return plaintext, nil
+}
+
+// sealCertificate encrypts the content of a certificate before storing it, unless encryption is disabled
+func sealCertificate(encryption ports.KeyEncryptionService, content []byte) ([]byte, error) {
+	if encryption == nil {
+		return content, nil
+	}
+
+	sealed, err := encryption.Encrypt(context.Background(), content)
+	if err != nil {
+		logs.Error("Failed to encrypt certificate:", err)
+		return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+	return sealed, nil
+}
+
+// resealCertificate encrypts a plaintext certificate, or wraps the data key of an encrypted one
+// with the active master key. It reports whether the content changed.
+func resealCertificate(ctx context.Context, encryption ports.KeyEncryptionService, content []byte) ([]byte, bool, error) {
+	var sealed []byte
+	var err error
+	if encryption.IsEncrypted(content) {
+		sealed, err = encryption.Rewrap(ctx, content)
+	} else {
+		sealed, err = encryption.Encrypt(ctx, content)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return sealed, !bytes.Equal(sealed, content), nil
+}
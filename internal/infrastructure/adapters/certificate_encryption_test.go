@@ -0,0 +1,150 @@
+package adapters
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/ports"
+	"github.com/chainedpixel/go-dte-signer/internal/infrastructure/cypher"
+)
+
+// resealer is a certificate store able to encrypt or rewrap its certificates
+type resealer interface {
+	Reseal(ctx context.Context) (int, error)
+}
+
+// resealableStore is a certificate store able to encrypt its certificates, along with a way to read them as stored
+type resealableStore struct {
+	name string
+	open func(t *testing.T, encryption ports.KeyEncryptionService) ports.CertificateStore
+	// stored returns the raw content of the only stored certificate
+	stored func(t *testing.T) []byte
+}
+
+// resealableStores returns the file and SQLite stores, each one over its own storage
+func resealableStores(t *testing.T, nit string) []resealableStore {
+	basePath := t.TempDir()
+	databasePath := filepath.Join(t.TempDir(), "certificates.db")
+	return []resealableStore{
+		{
+			name: "file",
+			open: func(t *testing.T, encryption ports.KeyEncryptionService) ports.CertificateStore {
+				return NewFileCertificateRepository(basePath, cypher.NewKeyProcessor(), nil, encryption)
+			},
+			stored: func(t *testing.T) []byte {
+				content, err := os.ReadFile(filepath.Join(basePath, nit+".crt"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return content
+			},
+		},
+		{
+			name: "sqlite",
+			open: func(t *testing.T, encryption ports.KeyEncryptionService) ports.CertificateStore {
+				repository, err := NewSQLiteCertificateRepository(context.Background(), databasePath, 5*time.Second, cypher.NewKeyProcessor(), nil, encryption)
+				if err != nil {
+					t.Fatalf("NewSQLiteCertificateRepository() error = %v", err)
+				}
+				t.Cleanup(func() { repository.Close() })
+				return repository
+			},
+			stored: func(t *testing.T) []byte {
+				repository, err := NewSQLiteCertificateRepository(context.Background(), databasePath, 5*time.Second, cypher.NewKeyProcessor(), nil, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer repository.Close()
+				var content []byte
+				if err := repository.db.QueryRow(`SELECT content FROM certificates`).Scan(&content); err != nil {
+					t.Fatal(err)
+				}
+				return content
+			},
+		},
+	}
+}
+
+// checkReadable fails unless the certificate of a NIT can be read from a store
+func checkReadable(t *testing.T, store ports.CertificateStore, nit string) {
+	t.Helper()
+	certificate, err := store.GetByNIT(context.Background(), nit)
+	if err != nil {
+		t.Fatalf("GetByNIT() error = %v", err)
+	}
+	if certificate.DecodedPrivateKey == nil {
+		t.Fatal("GetByNIT() returned a certificate without its private key")
+	}
+}
+
+// checkSealedWith fails unless a stored content is an envelope wrapped by the given master key
+func checkSealedWith(t *testing.T, content []byte, keyID string) {
+	t.Helper()
+	stored, err := cypher.EnvelopeKeyID(content)
+	if err != nil {
+		t.Fatalf("stored certificate is not encrypted: %v", err)
+	}
+	if stored != keyID {
+		t.Errorf("stored certificate wrapped by %s, want %s", stored, keyID)
+	}
+}
+
+func TestCertificateStoresResealWithRotatedMasterKeys(t *testing.T) {
+	const nit = "06140101780010"
+	ctx := context.Background()
+
+	for _, store := range resealableStores(t, nit) {
+		t.Run(store.name, func(t *testing.T) {
+			keyring := &cypher.Keyring{}
+			if err := keyring.Generate("2025"); err != nil {
+				t.Fatal(err)
+			}
+			encryption, err := cypher.NewLocalKeyEncryptionService(keyring)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// 1: Certificates stored before encryption was enabled are still read
+			content, _ := haciendaCertificate(t, nit, nit+"-id", true)
+			if _, err := store.open(t, nil).Save(ctx, content, false); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			encrypted := store.open(t, encryption)
+			checkReadable(t, encrypted, nit)
+
+			// 2: Resealing encrypts them, after which a store without the master key cannot read them
+			resealed, err := encrypted.(resealer).Reseal(ctx)
+			if err != nil || resealed != 1 {
+				t.Fatalf("Reseal() = %d, %v, want 1 certificate encrypted", resealed, err)
+			}
+			checkSealedWith(t, store.stored(t), "2025")
+			checkReadable(t, store.open(t, encryption), nit)
+			if _, err := store.open(t, nil).GetByNIT(ctx, nit); err == nil {
+				t.Error("GetByNIT() of an encrypted certificate succeeded without the master key")
+			}
+
+			// 3: After a rotation, resealing rewraps them with the new master key
+			if err := keyring.Generate("2026"); err != nil {
+				t.Fatal(err)
+			}
+			rotated, err := cypher.NewLocalKeyEncryptionService(keyring)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resealed, err = store.open(t, rotated).(resealer).Reseal(ctx)
+			if err != nil || resealed != 1 {
+				t.Fatalf("Reseal() after the rotation = %d, %v, want 1 certificate rewrapped", resealed, err)
+			}
+			checkSealedWith(t, store.stored(t), "2026")
+			checkReadable(t, store.open(t, rotated), nit)
+
+			// 4: Resealing again leaves them untouched
+			if resealed, err := store.open(t, rotated).(resealer).Reseal(ctx); err != nil || resealed != 0 {
+				t.Errorf("Reseal() of rewrapped certificates = %d, %v, want none", resealed, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package adapters
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/pkg/logs"
+)
+
+// Supported certificate file formats
+const (
+	formatHacienda = "mh"
+	formatPKCS12   = "pkcs12"
+	formatPEM      = "pem"
+)
+
+// certificateExtensions lists the supported certificate file extensions, in lookup order
+var certificateExtensions = []string{".crt", ".p12", ".pfx", ".pem", ".key"}
+
+// isCertificateFile reports whether the file has a supported certificate extension
+func isCertificateFile(file string) bool {
+	extension := strings.ToLower(filepath.Ext(file))
+	for _, supported := range certificateExtensions {
+		if extension == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// findCertificateFile returns the single certificate file of a NIT, trying every supported extension
+func (r *FileCertificateRepository) findCertificateFile(nit string) (string, bool) {
+	for _, extension := range certificateExtensions {
+		file := filepath.Join(r.basePath, nit+extension)
+		if _, err := os.Stat(file); err == nil {
+			return file, true
+		}
+	}
+	return r.certificatePath(nit), false
+}
+
+// certificateDirectory returns the directory holding the certificates of a NIT
+func (r *FileCertificateRepository) certificateDirectory(nit string) string {
+	return filepath.Join(r.basePath, nit)
+}
+
+// hasCertificateDirectory reports whether the NIT keeps its certificates in a directory
+func (r *FileCertificateRepository) hasCertificateDirectory(nit string) bool {
+	info, err := os.Stat(r.certificateDirectory(nit))
+	return err == nil && info.IsDir()
+}
+
+// certificateFiles returns the certificate files of a NIT: its single file, when present,
+// followed by the files of its directory in name order
+func (r *FileCertificateRepository) certificateFiles(nit string) ([]string, error) {
+	var files []string
+	if file, ok := r.findCertificateFile(nit); ok {
+		files = append(files, file)
+	}
+
+	entries, err := os.ReadDir(r.certificateDirectory(nit))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return files, nil
+		}
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && isCertificateFile(entry.Name()) {
+			files = append(files, filepath.Join(r.certificateDirectory(nit), entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+// certificateNITs returns the NIT of every certificate file and certificate directory of the
+// base directory. When a NIT has several single files, the first one in extension order is used.
+func (r *FileCertificateRepository) certificateNITs() ([]string, error) {
+	entries, err := os.ReadDir(r.basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	nits := make(map[string]bool)
+	files := make(map[string]int)
+	for _, entry := range entries {
+		switch {
+		case entry.IsDir() && models.IsValidNIT(entry.Name()):
+			nits[entry.Name()] = true
+		case !entry.IsDir() && isCertificateFile(entry.Name()):
+			nits[nitFromFile(entry.Name())] = true
+			files[nitFromFile(entry.Name())]++
+		}
+	}
+
+	result := make([]string, 0, len(nits))
+	for nit := range nits {
+		if files[nit] > 1 {
+			file, _ := r.findCertificateFile(nit)
+			logs.Warn("Several certificate files for NIT", nit, "using", filepath.Base(file))
+		}
+		result = append(result, nit)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// detectCertificateFormat detects the format of a certificate file from its content,
+// falling back to its extension for binary content
+func detectCertificateFormat(file string, content []byte) string {
+	trimmed := bytes.TrimSpace(content)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return formatHacienda
+	case bytes.HasPrefix(trimmed, []byte("-----BEGIN")):
+		return formatPEM
+	}
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".p12", ".pfx":
+		return formatPKCS12
+	case ".pem", ".key":
+		return formatPEM
+	case ".crt":
+		return formatHacienda
+	}
+
+	// DER content is a PKCS#12 file
+	if len(trimmed) > 0 && trimmed[0] == 0x30 {
+		return formatPKCS12
+	}
+	return formatHacienda
+}
+
+// parseCertificateFile parses a certificate file of a NIT, in any supported format, into a certificate
+func (r *FileCertificateRepository) parseCertificateFile(nit string, file string, content []byte) (*models.Certificate, error) {
+	format := detectCertificateFormat(file, content)
+	if format == formatHacienda {
+		return r.parser.parseHacienda(content)
+	}
+
+	// PKCS#12 and PEM files do not carry the NIT nor the password hash: the NIT is the file
+	// or directory name and the password, configured per NIT, is the one expected on signing requests
+	password, ok := r.keyPassword(nit, file)
+	if !ok {
+		logs.Error("No password configured for the key of NIT:", nit)
+		return nil, domainErrors.NewDomainError("key_password_missing", domainErrors.CodeInvalid)
+	}
+
+	return r.parser.parseKey(nit, format, content, password)
+}
+
+// keyPassword returns the password of a key file: the one configured for the file of the NIT
+// directory (<nit>/<file name without extension>) or, failing that, the one of the NIT
+func (r *FileCertificateRepository) keyPassword(nit string, file string) (string, bool) {
+	if filepath.Dir(file) == r.certificateDirectory(nit) {
+		// Configuration keys are case insensitive
+		key := strings.ToLower(nit + "/" + nitFromFile(file))
+		if password, ok := r.passwords[key]; ok {
+			return password, true
+		}
+	}
+	password, ok := r.passwords[nit]
+	return password, ok
+}
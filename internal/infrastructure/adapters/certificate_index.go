@@ -0,0 +1,312 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/pkg/logs"
+)
+
+// reloadDelay groups the bursts of events produced while a file is being written
+const reloadDelay = 200 * time.Millisecond
+
+// certificateEntry is a certificate file of the index along with its load result
+type certificateEntry struct {
+	file        string
+	certificate *models.Certificate
+	err         error
+	loadedAt    time.Time
+}
+
+// CertificateIndexReport summarizes the indexed certificates directory
+type CertificateIndexReport struct {
+	Directory string                   `json:"directory"`
+	Watching  bool                     `json:"watching"`
+	Loaded    []string                 `json:"loaded"`
+	Invalid   []InvalidCertificateFile `json:"invalid,omitempty"`
+}
+
+// InvalidCertificateFile describes a certificate file that could not be loaded
+type InvalidCertificateFile struct {
+	File     string    `json:"file"`
+	Error    string    `json:"error"`
+	LoadedAt time.Time `json:"loadedAt"`
+}
+
+// Index loads every certificate file of the directory. Invalid files are kept in the
+// index with their error, so requests for them fail with it and they are reported in the health check.
+func (r *FileCertificateRepository) Index(ctx context.Context) error {
+	nits, err := r.certificateNITs()
+	if err != nil {
+		return fmt.Errorf("failed to list certificates directory: %w", err)
+	}
+
+	entries := make(map[string][]*certificateEntry, len(nits))
+	for _, nit := range nits {
+		entries[nit] = r.loadNIT(nit)
+	}
+
+	r.mutex.Lock()
+	r.entries = entries
+	r.indexed = true
+	r.mutex.Unlock()
+
+	report := r.Report()
+	logs.Info(fmt.Sprintf("Certificates directory indexed: %d loaded, %d invalid", len(report.Loaded), len(report.Invalid)))
+	return nil
+}
+
+// Watch keeps the index up to date with the certificate files added, replaced or removed from
+// the directory and the NIT directories until Close is called. The directory is indexed first if it was not already.
+func (r *FileCertificateRepository) Watch(ctx context.Context) error {
+	if !r.isIndexed() {
+		if err := r.Index(ctx); err != nil {
+			return err
+		}
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create certificates watcher: %w", err)
+	}
+	if err := watcher.Add(r.basePath); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch certificates directory: %w", err)
+	}
+	nits, err := r.certificateNITs()
+	if err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to list certificates directory: %w", err)
+	}
+	for _, nit := range nits {
+		if r.hasCertificateDirectory(nit) {
+			r.watchDirectory(watcher, nit)
+		}
+	}
+
+	r.mutex.Lock()
+	r.watcher = watcher
+	r.mutex.Unlock()
+
+	go r.watch(watcher)
+	logs.Info("Watching certificates directory:", r.basePath)
+	return nil
+}
+
+// Close stops watching the certificates directory
+func (r *FileCertificateRepository) Close() error {
+	r.mutex.Lock()
+	watcher := r.watcher
+	r.watcher = nil
+	for nit, timer := range r.pending {
+		timer.Stop()
+		delete(r.pending, nit)
+	}
+	r.mutex.Unlock()
+
+	if watcher == nil {
+		return nil
+	}
+	return watcher.Close()
+}
+
+// OnChange registers a listener called with the NIT of every certificate file or manifest that changes
+func (r *FileCertificateRepository) OnChange(listener func(nit string)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.listeners = append(r.listeners, listener)
+}
+
+// Report returns the loaded and invalid certificate files of the index
+func (r *FileCertificateRepository) Report() CertificateIndexReport {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	report := CertificateIndexReport{
+		Directory: r.basePath,
+		Watching:  r.watcher != nil,
+		Loaded:    []string{},
+	}
+	for nit, entries := range r.entries {
+		loaded := false
+		for _, entry := range entries {
+			if entry.err != nil {
+				report.Invalid = append(report.Invalid, InvalidCertificateFile{
+					File:     r.relativePath(entry.file),
+					Error:    entry.err.Error(),
+					LoadedAt: entry.loadedAt,
+				})
+				continue
+			}
+			loaded = true
+		}
+		if loaded {
+			report.Loaded = append(report.Loaded, nit)
+		}
+	}
+
+	sort.Strings(report.Loaded)
+	sort.Slice(report.Invalid, func(i, j int) bool {
+		return report.Invalid[i].File < report.Invalid[j].File
+	})
+	return report
+}
+
+// HealthName returns the key under which the index is reported in the health check
+func (r *FileCertificateRepository) HealthName() string {
+	return "certificates"
+}
+
+// HealthDetails returns the index report for the health check
+func (r *FileCertificateRepository) HealthDetails(ctx context.Context) interface{} {
+	if !r.isIndexed() {
+		return nil
+	}
+	return r.Report()
+}
+
+// watch processes the watcher events until the watcher is closed
+func (r *FileCertificateRepository) watch(watcher *fsnotify.Watcher) {
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			nit, ok := r.eventNIT(event.Name)
+			if !ok || event.Op == fsnotify.Chmod {
+				continue
+			}
+			// A new NIT directory is watched as well; its files are read by the reload
+			if event.Has(fsnotify.Create) && filepath.Dir(event.Name) == filepath.Clean(r.basePath) && r.hasCertificateDirectory(nit) {
+				r.watchDirectory(watcher, nit)
+			}
+			r.scheduleReload(nit)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logs.Error("Certificates watcher error:", err)
+		}
+	}
+}
+
+// eventNIT returns the NIT affected by a watcher event: a certificate file or NIT directory
+// of the base directory, or a certificate file or manifest of a NIT directory
+func (r *FileCertificateRepository) eventNIT(name string) (string, bool) {
+	base := filepath.Base(name)
+	dir := filepath.Dir(name)
+	if dir == filepath.Clean(r.basePath) {
+		if isCertificateFile(base) {
+			return nitFromFile(base), true
+		}
+		return base, models.IsValidNIT(base)
+	}
+
+	nit := filepath.Base(dir)
+	if filepath.Dir(dir) == filepath.Clean(r.basePath) && models.IsValidNIT(nit) && (isCertificateFile(base) || base == manifestFile) {
+		return nit, true
+	}
+	return "", false
+}
+
+// watchDirectory adds a NIT directory to the watcher
+func (r *FileCertificateRepository) watchDirectory(watcher *fsnotify.Watcher, nit string) {
+	if err := watcher.Add(r.certificateDirectory(nit)); err != nil {
+		logs.Error("Failed to watch certificates of NIT:", nit, err)
+	}
+}
+
+// scheduleReload reloads the certificates of a NIT once its events have settled
+func (r *FileCertificateRepository) scheduleReload(nit string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if timer, ok := r.pending[nit]; ok {
+		timer.Reset(reloadDelay)
+		return
+	}
+	r.pending[nit] = time.AfterFunc(reloadDelay, func() {
+		r.mutex.Lock()
+		delete(r.pending, nit)
+		r.mutex.Unlock()
+
+		r.reload(nit)
+	})
+}
+
+// reload updates the index entries of a NIT and notifies the listeners
+func (r *FileCertificateRepository) reload(nit string) {
+	// 1: Load the certificate files of the NIT, if any is left
+	entries := r.loadNIT(nit)
+
+	// 2: Update the index, if any
+	r.mutex.Lock()
+	if r.indexed && len(entries) > 0 {
+		r.entries[nit] = entries
+	} else if r.indexed {
+		delete(r.entries, nit)
+	}
+	listeners := append([]func(nit string){}, r.listeners...)
+	r.mutex.Unlock()
+
+	if len(entries) == 0 {
+		logs.Info("Certificates removed for NIT:", nit)
+	} else {
+		logs.Info(fmt.Sprintf("Certificates reloaded for NIT %s: %d file(s)", nit, len(entries)))
+	}
+
+	// 3: Notify the listeners
+	for _, listener := range listeners {
+		listener(nit)
+	}
+}
+
+// loadEntry loads a certificate file of a NIT into an index entry
+func (r *FileCertificateRepository) loadEntry(nit string, file string) *certificateEntry {
+	certificate, err := r.loadCertificate(nit, file)
+	if err != nil {
+		logs.Warn(fmt.Sprintf("Invalid certificate file %s: %v", r.relativePath(file), err))
+	}
+	return &certificateEntry{
+		file:        file,
+		certificate: certificate,
+		err:         err,
+		loadedAt:    time.Now(),
+	}
+}
+
+// indexedEntries returns the index entries of a NIT. Once indexed, a NIT missing
+// from the index has no entries, without reading the disk.
+func (r *FileCertificateRepository) indexedEntries(nit string) ([]*certificateEntry, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if !r.indexed {
+		return nil, false
+	}
+	return r.entries[nit], true
+}
+
+// isIndexed reports whether the directory has been indexed
+func (r *FileCertificateRepository) isIndexed() bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.indexed
+}
+
+// nitFromFile returns the NIT a certificate file belongs to
+func nitFromFile(file string) string {
+	base := filepath.Base(file)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
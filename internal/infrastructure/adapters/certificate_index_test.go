@@ -0,0 +1,110 @@
+package adapters
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chainedpixel/go-dte-signer/internal/infrastructure/cypher"
+)
+
+// checkNoChange fails if a NIT is received from the channel before the reloads of a burst would have settled
+func checkNoChange(t *testing.T, changes <-chan string) {
+	t.Helper()
+	select {
+	case nit := <-changes:
+		t.Errorf("extra change notified for NIT %s", nit)
+	case <-time.After(3 * reloadDelay):
+	}
+}
+
+// writeInChunks writes a file in several writes, as a slow copy would, producing a burst of events
+func writeInChunks(t *testing.T, path string, content []byte) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	for start := 0; start < len(content); start += len(content)/4 + 1 {
+		end := min(start+len(content)/4+1, len(content))
+		if _, err := file.Write(content[start:end]); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(reloadDelay / 10)
+	}
+}
+
+func TestFileCertificateRepositoryWatchReloadsOnceSettled(t *testing.T) {
+	basePath := t.TempDir()
+	repository := NewFileCertificateRepository(basePath, cypher.NewKeyProcessor(), nil, nil)
+	ctx := context.Background()
+	const nit = "06140101780010"
+	const otherNIT = "06142803901121"
+
+	changes := make(chan string, 10)
+	repository.OnChange(func(nit string) { changes <- nit })
+	if err := repository.Watch(ctx); err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	t.Cleanup(func() { repository.Close() })
+	if report := repository.Report(); !report.Watching || len(report.Loaded) != 0 {
+		t.Fatalf("Report() = %+v, want an empty watched directory", report)
+	}
+
+	// 1: A certificate written in a burst of events is reloaded once, after the writes settle
+	content, _ := haciendaCertificate(t, nit, nit+"-id", true)
+	writeInChunks(t, filepath.Join(basePath, nit+".crt"), content)
+	waitForChange(t, changes, nit)
+	checkNoChange(t, changes)
+	if certificate, err := repository.GetByNIT(ctx, nit); err != nil || certificate.ID != nit+"-id" {
+		t.Fatalf("GetByNIT() = %v, %v, want the written certificate", certificate, err)
+	}
+
+	// 2: A broken replacement is reported as invalid
+	writeInChunks(t, filepath.Join(basePath, nit+".crt"), []byte("<CertificadoMH>"))
+	waitForChange(t, changes, nit)
+	if report := repository.Report(); len(report.Invalid) != 1 || report.Invalid[0].File != nit+".crt" {
+		t.Errorf("Report() = %+v, want the broken file reported", report)
+	}
+	if _, err := repository.GetByNIT(ctx, nit); err == nil {
+		t.Error("GetByNIT() of a broken certificate succeeded")
+	}
+
+	// 3: A removed certificate leaves the index
+	if err := os.Remove(filepath.Join(basePath, nit+".crt")); err != nil {
+		t.Fatal(err)
+	}
+	waitForChange(t, changes, nit)
+	if report := repository.Report(); len(report.Loaded) != 0 || len(report.Invalid) != 0 {
+		t.Errorf("Report() = %+v, want an empty index", report)
+	}
+
+	// 4: The certificates of a new NIT directory are loaded, and the directory is watched
+	directory := filepath.Join(basePath, otherNIT)
+	if err := os.Mkdir(directory, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	first, _ := haciendaCertificate(t, otherNIT, "first", true)
+	writeInChunks(t, filepath.Join(directory, "first.crt"), first)
+	waitForChange(t, changes, otherNIT)
+	checkNoChange(t, changes)
+
+	second, _ := haciendaCertificate(t, otherNIT, "second", true)
+	writeInChunks(t, filepath.Join(directory, "second.crt"), second)
+	waitForChange(t, changes, otherNIT)
+	certificates, err := repository.ListByNIT(ctx, otherNIT)
+	if err != nil || len(certificates) != 2 {
+		t.Errorf("ListByNIT() = %d certificates, %v, want both files of the directory", len(certificates), err)
+	}
+
+	// 5: Closing stops watching
+	if err := repository.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if repository.Report().Watching {
+		t.Error("Report() still watching after Close()")
+	}
+}
@@ -0,0 +1,146 @@
+package adapters
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/pkg/logs"
+)
+
+// manifestFile is the name of the manifest of a NIT directory
+const manifestFile = "manifest.json"
+
+// certificateManifest describes the certificates of a NIT directory
+type certificateManifest struct {
+	Certificates []manifestCertificate `json:"certificates"`
+}
+
+// manifestCertificate sets the validity window, priority and expiry date of a certificate file.
+// The file may also be the single <nit>.crt file of the NIT. Unset dates keep the ones of the certificate, if any.
+type manifestCertificate struct {
+	File      string     `json:"file"`
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+	NotAfter  *time.Time `json:"notAfter,omitempty"`
+	Priority  int        `json:"priority,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// readManifest reads the manifest of a NIT directory, returning nil when there is none
+func (r *FileCertificateRepository) readManifest(nit string) (*certificateManifest, error) {
+	content, err := os.ReadFile(filepath.Join(r.certificateDirectory(nit), manifestFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+
+	var manifest certificateManifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeInvalid)
+	}
+
+	// Entries name a file of the NIT directory, or its single file, by base name
+	for _, certificate := range manifest.Certificates {
+		if certificate.File == "" || filepath.Base(certificate.File) != certificate.File || !isCertificateFile(certificate.File) {
+			return nil, domainErrors.NewDomainError(fmt.Sprintf("invalid manifest file entry %q", certificate.File), domainErrors.CodeInvalid)
+		}
+		if certificate.NotBefore != nil && certificate.NotAfter != nil && !certificate.NotAfter.After(*certificate.NotBefore) {
+			return nil, domainErrors.NewDomainError(fmt.Sprintf("invalid validity window for %s", certificate.File), domainErrors.CodeInvalid)
+		}
+	}
+
+	return &manifest, nil
+}
+
+// loadNIT loads every certificate file of a NIT into index entries. An invalid manifest makes the
+// whole NIT unusable, since its priorities and windows decide which certificate signs.
+func (r *FileCertificateRepository) loadNIT(nit string) []*certificateEntry {
+	manifestPath := filepath.Join(r.certificateDirectory(nit), manifestFile)
+
+	// 1: Read the manifest, if any
+	manifest, err := r.readManifest(nit)
+	if err != nil {
+		logs.Warn(fmt.Sprintf("Invalid certificate manifest %s: %v", r.relativePath(manifestPath), err))
+		return []*certificateEntry{{file: manifestPath, err: err, loadedAt: time.Now()}}
+	}
+
+	// 2: Load the certificate files
+	files, err := r.certificateFiles(nit)
+	if err != nil {
+		logs.Warn(fmt.Sprintf("Failed to list certificates of NIT %s: %v", nit, err))
+		return []*certificateEntry{{
+			file:     r.certificateDirectory(nit),
+			err:      domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued),
+			loadedAt: time.Now(),
+		}}
+	}
+
+	entries := make([]*certificateEntry, 0, len(files))
+	byFile := make(map[string]*certificateEntry, len(files))
+	for _, file := range files {
+		entry := r.loadEntry(nit, file)
+		entries = append(entries, entry)
+		// Files of the NIT directory come last, so they take precedence over a single file of the same name
+		byFile[filepath.Base(file)] = entry
+	}
+	if manifest == nil {
+		return entries
+	}
+
+	// 3: Apply the validity windows, priorities and expiry dates of the manifest
+	for _, described := range manifest.Certificates {
+		entry, ok := byFile[described.File]
+		if !ok {
+			file := filepath.Join(r.certificateDirectory(nit), described.File)
+			logs.Warn("Certificate listed in the manifest does not exist:", r.relativePath(file))
+			entries = append(entries, &certificateEntry{
+				file:     file,
+				err:      domainErrors.NewDomainError("file_not_found", domainErrors.CodeFileNotFound),
+				loadedAt: time.Now(),
+			})
+			continue
+		}
+		if entry.err != nil {
+			continue
+		}
+
+		if described.NotBefore != nil {
+			entry.certificate.NotBefore = *described.NotBefore
+		}
+		if described.NotAfter != nil {
+			entry.certificate.NotAfter = *described.NotAfter
+		}
+		if described.ExpiresAt != nil {
+			entry.certificate.ExpiresAt = *described.ExpiresAt
+		}
+		entry.certificate.Priority = described.Priority
+	}
+
+	return entries
+}
+
+// writeManifest stores the manifest of a NIT, creating its directory when needed
+func (r *FileCertificateRepository) writeManifest(nit string, manifest *certificateManifest) error {
+	if err := os.MkdirAll(r.certificateDirectory(nit), 0700); err != nil {
+		return domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+	return r.writeFile(nit, filepath.Join(r.certificateDirectory(nit), manifestFile), append(content, '\n'))
+}
+
+// relativePath returns the path of a file relative to the certificates directory
+func (r *FileCertificateRepository) relativePath(file string) string {
+	if relative, err := filepath.Rel(r.basePath, file); err == nil {
+		return filepath.ToSlash(relative)
+	}
+	return filepath.Base(file)
+}
@@ -0,0 +1,145 @@
+package adapters
+
+import (
+	"encoding/hex"
+	"encoding/xml"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/internal/infrastructure/cypher"
+	"github.com/chainedpixel/go-dte-signer/pkg/logs"
+)
+
+// certificateParser decodes the certificate formats shared by the certificate repositories
+type certificateParser struct {
+	keyProcessor *cypher.KeyProcessor
+}
+
+// parseHacienda parses the content of a Hacienda XML certificate file, decodes its keys and checks they belong together
+func (p *certificateParser) parseHacienda(content []byte) (*models.Certificate, error) {
+	// Parse the XML content
+	var certificate models.Certificate
+	if err := xml.Unmarshal(content, &certificate); err != nil {
+		logs.Error("Failed to unmarshal XML:", err)
+		return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+
+	// Check if the certificate has a private key. Certificates whose key is held outside the
+	// process, such as on a hardware token, only carry their public key.
+	if !certificate.HasPrivateKey() && !certificate.HasPublicKey() {
+		logs.Error("Certificate does not have a private key")
+		return nil, domainErrors.NewDomainError("invalid", domainErrors.CodeInvalid)
+	}
+
+	if certificate.HasPrivateKey() {
+		// Decode the private key
+		decodedBytes, err := certificate.DecodePrivateKey()
+		if err != nil {
+			logs.Error("Failed to decode private key:", err)
+			return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeJSONToStrConversion)
+		}
+
+		// Parse the private key
+		certWithKey, err := p.keyProcessor.BytesToPrivateKey(decodedBytes)
+		if err != nil {
+			logs.Error("Failed to parse private key:", err)
+			return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeNoPublicKey)
+		}
+
+		// Update the certificate with the decoded private key
+		certificate.DecodedPrivateKey = certWithKey.DecodedPrivateKey
+	}
+
+	// Decode the public key and check it belongs to the private key
+	if certificate.HasPublicKey() {
+		publicBytes, err := certificate.DecodePublicKey()
+		if err != nil {
+			logs.Error("Failed to decode public key:", err)
+			return nil, domainErrors.NewDomainError("no_public_key", domainErrors.CodeNoPublicKey)
+		}
+
+		publicKey, err := p.keyProcessor.BytesToPublicKey(publicBytes)
+		if err != nil {
+			logs.Error("Failed to parse public key:", err)
+			return nil, domainErrors.NewDomainError("no_public_key", domainErrors.CodeNoPublicKey)
+		}
+
+		if certificate.DecodedPrivateKey != nil && !p.keyProcessor.KeysMatch(certificate.DecodedPrivateKey, publicKey) {
+			logs.Error("Public key does not match the private key")
+			return nil, domainErrors.NewDomainError("public_key_mismatch", domainErrors.CodeNoPublicKey)
+		}
+
+		certificate.DecodedPublicKey = publicKey
+	}
+
+	return &certificate, nil
+}
+
+// parseKey imports the PKCS#12 or PEM key of a NIT protected with the given password
+func (p *certificateParser) parseKey(nit string, format string, content []byte, password string) (*models.Certificate, error) {
+	var bundle *cypher.KeyBundle
+	var err error
+	if format == formatPKCS12 {
+		bundle, err = p.keyProcessor.PKCS12ToKeys(content, password)
+	} else {
+		bundle, err = p.keyProcessor.PEMToKeys(content, password)
+	}
+	if err != nil {
+		logs.Error("Failed to import key:", err)
+		return nil, err
+	}
+
+	return p.importedCertificate(nit, bundle, password)
+}
+
+// importedCertificate builds the certificate of a key imported from a PKCS#12 or PEM file
+func (p *certificateParser) importedCertificate(nit string, bundle *cypher.KeyBundle, password string) (*models.Certificate, error) {
+	// Check the certificate belongs to the private key
+	publicKey := bundle.PublicKey()
+	if !p.keyProcessor.KeysMatch(bundle.PrivateKey, publicKey) {
+		logs.Error("Public key does not match the private key")
+		return nil, domainErrors.NewDomainError("public_key_mismatch", domainErrors.CodeNoPublicKey)
+	}
+
+	// The password hash follows the Hacienda format, so passwords are verified the same way
+	hashedPassword, err := p.keyProcessor.HashPassword(password)
+	if err != nil {
+		return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+
+	certificate := &models.Certificate{
+		ID:     importedCertificateID(nit, bundle),
+		Active: true,
+		NIT:    nit,
+		PrivateKey: models.Key{
+			Algorithm: cypher.KeyFamily(publicKey),
+			Password:  hashedPassword,
+		},
+		PublicKey: models.Key{
+			Algorithm: cypher.KeyFamily(publicKey),
+			Password:  hashedPassword,
+		},
+		DecodedPrivateKey: bundle.PrivateKey,
+		DecodedPublicKey:  publicKey,
+	}
+
+	// The key material is valid as long as its X.509 certificate
+	if bundle.Certificate != nil {
+		certificate.IssuedAt = bundle.Certificate.NotBefore
+		certificate.ExpiresAt = bundle.Certificate.NotAfter
+	}
+	return certificate, nil
+}
+
+// importedCertificateID identifies an imported key by the serial number of its certificate,
+// or by the fingerprint of its public key when the file has no certificate
+func importedCertificateID(nit string, bundle *cypher.KeyBundle) string {
+	if bundle.Certificate != nil {
+		return nit + "-" + hex.EncodeToString(bundle.Certificate.SerialNumber.Bytes())
+	}
+	fingerprint, err := cypher.PublicKeyFingerprint(bundle.PublicKey())
+	if err != nil {
+		return nit
+	}
+	return nit + "-" + fingerprint[:16]
+}
@@ -2,83 +2,131 @@ package adapters
 
 import (
 	"context"
-	"encoding/xml"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
 
 	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
 	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/ports"
 	"github.com/chainedpixel/go-dte-signer/internal/infrastructure/cypher"
 	"github.com/chainedpixel/go-dte-signer/pkg/logs"
 )
 
-// FileCertificateRepository implements a file-based certificate repository
+// FileCertificateRepository implements a file-based certificate repository. Certificates are
+// read from disk on every request unless the directory has been indexed with Index.
+// Besides the Hacienda XML format, it imports PKCS#12 and PEM keys whose passwords are configured per NIT.
+// A NIT has either a single <nit>.crt file or a <nit>/ directory holding several certificates,
+// optionally described by a manifest with their validity windows and priorities.
+// Certificate files may be encrypted at rest, in which case they are decrypted when loaded.
 type FileCertificateRepository struct {
 	basePath     string
 	keyProcessor *cypher.KeyProcessor
+	parser       *certificateParser
+	passwords    map[string]string
+	encryption   ports.KeyEncryptionService
+
+	mutex     sync.RWMutex
+	indexed   bool
+	entries   map[string][]*certificateEntry
+	listeners []func(nit string)
+	watcher   *fsnotify.Watcher
+	pending   map[string]*time.Timer
 }
 
-// NewFileCertificateRepository creates a new file-based certificate repository
-func NewFileCertificateRepository(basePath string, keyProcessor *cypher.KeyProcessor) *FileCertificateRepository {
+// NewFileCertificateRepository creates a new file-based certificate repository. The passwords
+// unlock the PKCS#12 and PEM keys of each NIT and are the ones expected on signing requests.
+// When encryption is set, stored certificates are encrypted with it; otherwise it may be nil.
+func NewFileCertificateRepository(basePath string, keyProcessor *cypher.KeyProcessor, passwords map[string]string, encryption ports.KeyEncryptionService) *FileCertificateRepository {
 	return &FileCertificateRepository{
 		basePath:     basePath,
 		keyProcessor: keyProcessor,
+		parser:       &certificateParser{keyProcessor: keyProcessor},
+		passwords:    passwords,
+		encryption:   encryption,
+		entries:      make(map[string][]*certificateEntry),
+		pending:      make(map[string]*time.Timer),
 	}
 }
 
-// GetByNIT retrieves a certificate by NIT
+// GetByNIT retrieves the preferred active certificate of a NIT that is valid now
 func (r *FileCertificateRepository) GetByNIT(ctx context.Context, nit string) (*models.Certificate, error) {
-	// Construct the file path
-	filePath := filepath.Join(r.basePath, nit+".crt")
+	certificates, err := r.activeCertificates(nit)
+	if err != nil {
+		return nil, err
+	}
+	return currentCertificate(nit, certificates)
+}
 
-	// Read the certificate file
-	content, err := os.ReadFile(filePath)
+// GetByID retrieves a specific certificate of a NIT, which must be active and valid now
+func (r *FileCertificateRepository) GetByID(ctx context.Context, nit string, id string) (*models.Certificate, error) {
+	certificates, err := r.activeCertificates(nit)
 	if err != nil {
-		if os.IsNotExist(err) {
-			logs.Error("Certificate does not exist")
-			return nil, domainErrors.NewDomainError(filePath, domainErrors.CodeFileNotFound)
-		}
-		logs.Error("Failed to read certificate:", err)
-		return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+		return nil, err
 	}
+	return pinnedCertificate(nit, id, certificates)
+}
 
-	// Parse the XML content
-	var certificate models.Certificate
-	if err = xml.Unmarshal(content, &certificate); err != nil {
-		logs.Error("Failed to unmarshal XML:", err)
-		return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+// ListByNIT retrieves every active certificate of a NIT regardless of its validity window.
+// The certificates valid now come first, each group in preference order.
+func (r *FileCertificateRepository) ListByNIT(ctx context.Context, nit string) ([]*models.Certificate, error) {
+	certificates, err := r.activeCertificates(nit)
+	if err != nil {
+		return nil, err
 	}
+	return verificationOrder(certificates), nil
+}
 
-	// Check if the certificate is active
-	if !certificate.IsActive() {
-		logs.Error("Certificate is not active")
-		return nil, domainErrors.NewDomainError("cert_not_found", domainErrors.CodeCertNotFound)
+// activeCertificates returns the active certificates of a NIT, taken from the index when available
+func (r *FileCertificateRepository) activeCertificates(nit string) ([]*models.Certificate, error) {
+	// The NIT names the certificate files, so anything else must not reach a path
+	if !models.IsValidNIT(nit) {
+		return nil, domainErrors.NewDomainError("invalid_nit", domainErrors.CodeInvalid)
 	}
 
-	// Check if the certificate has a private key
-	if !certificate.HasPrivateKey() {
-		logs.Error("Certificate does not have a private key")
-		return nil, domainErrors.NewDomainError("invalid", domainErrors.CodeInvalid)
+	entries, ok := r.indexedEntries(nit)
+	if !ok {
+		entries = r.loadNIT(nit)
 	}
+	if len(entries) == 0 {
+		logs.Error("Certificate does not exist:", r.relativePath(r.certificatePath(nit)))
+		return nil, domainErrors.NewDomainError("file_not_found", domainErrors.CodeFileNotFound)
+	}
+	return activeCertificates(entries)
+}
+
+// certificatePath returns the path of the single certificate file of a NIT
+func (r *FileCertificateRepository) certificatePath(nit string) string {
+	return filepath.Join(r.basePath, nit+".crt")
+}
 
-	// Decode the private key
-	decodedBytes, err := certificate.DecodePrivateKey()
+// loadCertificate reads a certificate file of a NIT, decodes its keys and checks they belong together
+func (r *FileCertificateRepository) loadCertificate(nit string, filePath string) (*models.Certificate, error) {
+	// Read the certificate file
+	content, err := r.readCertificate(filePath)
 	if err != nil {
-		logs.Error("Failed to decode private key:", err)
-		return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeJSONToStrConversion)
+		return nil, err
 	}
 
-	// Parse the private key
-	certWithKey, err := r.keyProcessor.BytesToPrivateKey(decodedBytes)
+	return r.parseCertificateFile(nit, filePath, content)
+}
+
+// readCertificate reads a certificate file, decrypting it when it is encrypted at rest
+func (r *FileCertificateRepository) readCertificate(filePath string) ([]byte, error) {
+	content, err := os.ReadFile(filePath)
 	if err != nil {
-		logs.Error("Failed to parse private key:", err)
-		return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeNoPublicKey)
+		if os.IsNotExist(err) {
+			logs.Error("Certificate does not exist:", r.relativePath(filePath))
+			return nil, domainErrors.NewDomainError("file_not_found", domainErrors.CodeFileNotFound)
+		}
+		logs.Error("Failed to read certificate:", err)
+		return nil, domainErrors.NewDomainError("uncatalogued_error", domainErrors.CodeUncatalogued)
 	}
 
-	// Update the certificate with the decoded private key
-	certificate.DecodedPrivateKey = certWithKey.DecodedPrivateKey
-
-	return &certificate, nil
+	return openCertificate(r.encryption, content)
 }
 
 // VerifyPassword checks if the password is valid for the certificate
@@ -92,3 +140,15 @@ func (r *FileCertificateRepository) VerifyPassword(ctx context.Context, certific
 
 	return valid, nil
 }
+
+// VerifyPublicPassword checks if the password is valid for the certificate public key
+func (r *FileCertificateRepository) VerifyPublicPassword(ctx context.Context, certificate *models.Certificate, password string) (bool, error) {
+	// Verify the password
+	valid, err := r.keyProcessor.VerifyPassword(password, certificate.PublicKey.Password)
+	if err != nil {
+		logs.Error("Failed to verify public key password:", err)
+		return false, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+
+	return valid, nil
+}
@@ -0,0 +1,104 @@
+package adapters
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/infrastructure/cypher"
+)
+
+func TestFileCertificateRepositoryRejectsInvalidNITs(t *testing.T) {
+	root := t.TempDir()
+	basePath := filepath.Join(root, "certs")
+	if err := os.Mkdir(basePath, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	// A file outside the certificates directory that a traversing NIT would reach
+	if err := os.WriteFile(filepath.Join(root, "outside.crt"), []byte("<CertificadoMH/>"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	repository := NewFileCertificateRepository(basePath, cypher.NewKeyProcessor(), nil, nil)
+	ctx := context.Background()
+
+	for _, nit := range []string{"../outside", "..", "/etc/passwd", "0614010178001/..", "06140101780010/../x", ""} {
+		calls := map[string]error{}
+		_, calls["GetByNIT"] = repository.GetByNIT(ctx, nit)
+		_, calls["GetByID"] = repository.GetByID(ctx, nit, "id")
+		_, calls["ListByNIT"] = repository.ListByNIT(ctx, nit)
+		_, calls["SetActive"] = repository.SetActive(ctx, nit, "", false)
+		calls["Delete"] = repository.Delete(ctx, nit, "")
+		for name, err := range calls {
+			if domainErr, ok := err.(domainErrors.DomainError); !ok || domainErr.Message != "invalid_nit" {
+				t.Errorf("%s(%q) error = %v, want invalid_nit", name, nit, err)
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "outside.crt")); err != nil {
+		t.Errorf("file outside the certificates directory was touched: %v", err)
+	}
+}
+
+func TestFileCertificateRepositoryMissingCertificateHidesPath(t *testing.T) {
+	basePath := t.TempDir()
+	repository := NewFileCertificateRepository(basePath, cypher.NewKeyProcessor(), nil, nil)
+
+	_, err := repository.GetByNIT(context.Background(), "06140101780010")
+	domainErr, ok := err.(domainErrors.DomainError)
+	if !ok || domainErr.Code != domainErrors.CodeFileNotFound || domainErr.Message != "file_not_found" {
+		t.Fatalf("GetByNIT() error = %v, want %s: file_not_found", err, domainErrors.CodeFileNotFound)
+	}
+	if strings.Contains(err.Error(), basePath) || strings.Contains(err.Error(), ".crt") {
+		t.Errorf("GetByNIT() error %q reveals the certificate path", err)
+	}
+}
+
+func TestFileCertificateRepositoryChecksThePublicKey(t *testing.T) {
+	const nit = "06140101780010"
+	content, _ := haciendaCertificate(t, nit, nit+"-id", true)
+	other, _ := haciendaCertificate(t, nit, nit+"-other", true)
+	publicKey := regexp.MustCompile(`<publicKey>.*</publicKey>`)
+	mismatched := publicKey.ReplaceAll(content, publicKey.Find(other))
+
+	tests := []struct {
+		name           string
+		content        []byte
+		publicPassword string
+		wantValid      bool
+		wantMessage    string
+	}{
+		{name: "matching key and password", content: content, publicPassword: testCertificatePassword, wantValid: true},
+		{name: "matching key and wrong password", content: content, publicPassword: "wrong"},
+		{name: "key of another certificate", content: mismatched, wantMessage: "public_key_mismatch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			basePath := t.TempDir()
+			if err := os.WriteFile(filepath.Join(basePath, nit+".crt"), tt.content, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			repository := NewFileCertificateRepository(basePath, cypher.NewKeyProcessor(), nil, nil)
+			ctx := context.Background()
+
+			certificate, err := repository.GetByNIT(ctx, nit)
+			if tt.wantMessage != "" {
+				domainErr, ok := err.(domainErrors.DomainError)
+				if !ok || domainErr.Code != domainErrors.CodeNoPublicKey || domainErr.Message != tt.wantMessage {
+					t.Fatalf("GetByNIT() error = %v, want %s: %s", err, domainErrors.CodeNoPublicKey, tt.wantMessage)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetByNIT() error = %v", err)
+			}
+			valid, err := repository.VerifyPublicPassword(ctx, certificate, tt.publicPassword)
+			if err != nil || valid != tt.wantValid {
+				t.Errorf("VerifyPublicPassword() = %v, %v, want %v", valid, err, tt.wantValid)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package adapters
+
+import (
+	"sort"
+	"time"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/pkg/logs"
+)
+
+// activeCertificates returns the active certificates among the loaded entries of a NIT. Invalid
+// entries only make the request fail when the NIT has no other certificate.
+func activeCertificates(entries []*certificateEntry) ([]*models.Certificate, error) {
+	var certificates []*models.Certificate
+	var loadErr error
+	for _, entry := range entries {
+		if entry.err != nil {
+			if loadErr == nil {
+				loadErr = entry.err
+			}
+			continue
+		}
+		if entry.certificate.IsActive() {
+			certificates = append(certificates, entry.certificate)
+		}
+	}
+
+	if len(certificates) == 0 {
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		logs.Error("Certificate is not active")
+		return nil, domainErrors.NewDomainError("cert_not_found", domainErrors.CodeCertNotFound)
+	}
+
+	return certificates, nil
+}
+
+// currentCertificate selects the certificate of a NIT whose validity window covers the current time
+func currentCertificate(nit string, certificates []*models.Certificate) (*models.Certificate, error) {
+	certificate := models.SelectCertificate(certificates, time.Now())
+	if certificate == nil {
+		logs.Error("No certificate is valid now for NIT:", nit)
+		return nil, domainErrors.NewDomainError("certificate_not_valid", domainErrors.CodeCertNotFound)
+	}
+	return certificate, nil
+}
+
+// pinnedCertificate returns the certificate of a NIT with the given ID, which must be valid now
+func pinnedCertificate(nit string, id string, certificates []*models.Certificate) (*models.Certificate, error) {
+	for _, certificate := range certificates {
+		if certificate.ID != id {
+			continue
+		}
+		if !certificate.IsValidAt(time.Now()) {
+			logs.Error("Certificate is not valid now:", id)
+			return nil, domainErrors.NewDomainError("certificate_not_valid", domainErrors.CodeCertNotFound)
+		}
+		return certificate, nil
+	}
+
+	logs.Error("Certificate not found for NIT:", nit, "ID:", id)
+	return nil, domainErrors.NewDomainError("certificate_id_not_found", domainErrors.CodeCertNotFound)
+}
+
+// verificationOrder sorts the certificates of a NIT to verify a signature: the ones valid now
+// come first, each group in preference order
+func verificationOrder(certificates []*models.Certificate) []*models.Certificate {
+	now := time.Now()
+	models.SortCertificates(certificates)
+	sort.SliceStable(certificates, func(i, j int) bool {
+		return certificates[i].IsValidAt(now) && !certificates[j].IsValidAt(now)
+	})
+	return certificates
+}
+
+// selectedCertificate returns the certificate GetByNIT currently selects among the entries of a NIT
+func selectedCertificate(entries []*certificateEntry) *models.Certificate {
+	certificates := make([]*models.Certificate, 0, len(entries))
+	for _, entry := range entries {
+		if entry.err == nil {
+			certificates = append(certificates, entry.certificate)
+		}
+	}
+	return models.SelectCertificate(certificates, time.Now())
+}
@@ -0,0 +1,369 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/internal/infrastructure/cypher"
+	"github.com/chainedpixel/go-dte-signer/pkg/logs"
+)
+
+// activePattern matches the activo element of a certificate file
+var activePattern = regexp.MustCompile(`<activo>\s*(true|false)\s*</activo>`)
+
+// storedIDPattern matches the certificate IDs usable as file names of a NIT directory
+var storedIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+// List returns a summary of every certificate file of the directory
+func (r *FileCertificateRepository) List(ctx context.Context) ([]models.CertificateSummary, error) {
+	// Use the index when available, otherwise read every file
+	entries := make(map[string][]*certificateEntry)
+	if r.isIndexed() {
+		r.mutex.RLock()
+		for nit, nitEntries := range r.entries {
+			entries[nit] = nitEntries
+		}
+		r.mutex.RUnlock()
+	} else {
+		nits, err := r.certificateNITs()
+		if err != nil {
+			return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+		}
+		for _, nit := range nits {
+			entries[nit] = r.loadNIT(nit)
+		}
+	}
+
+	summaries := make([]models.CertificateSummary, 0, len(entries))
+	for nit, nitEntries := range entries {
+		selected := selectedCertificate(nitEntries)
+		for _, entry := range nitEntries {
+			summaries = append(summaries, r.summarize(nit, entry, selected))
+		}
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].NIT != summaries[j].NIT {
+			return summaries[i].NIT < summaries[j].NIT
+		}
+		return summaries[i].File < summaries[j].File
+	})
+	return summaries, nil
+}
+
+// Save validates a certificate file and stores it under the NIT it belongs to. NITs with a
+// certificate directory get a new <nit>/<id>.crt file, the rest keep their single <nit>.crt file.
+func (r *FileCertificateRepository) Save(ctx context.Context, content []byte, replace bool) (*models.CertificateSummary, error) {
+	// 1: Validate the certificate before touching the directory
+	certificate, err := r.parser.parseHacienda(content)
+	if err != nil {
+		return nil, err
+	}
+	if !models.IsValidNIT(certificate.NIT) {
+		return nil, domainErrors.NewDomainError("invalid_nit", domainErrors.CodeInvalid)
+	}
+
+	// 2: Refuse to overwrite an existing certificate, in any format, unless requested
+	var filePath, existing string
+	var exists bool
+	if r.hasCertificateDirectory(certificate.NIT) {
+		filePath = filepath.Join(r.certificateDirectory(certificate.NIT), storedFileName(certificate)+".crt")
+		_, err := os.Stat(filePath)
+		existing, exists = filePath, err == nil
+	} else {
+		filePath = r.certificatePath(certificate.NIT)
+		existing, exists = r.findCertificateFile(certificate.NIT)
+	}
+	if exists && !replace {
+		return nil, domainErrors.NewDomainError("certificate_exists", domainErrors.CodeCertificateExists)
+	}
+
+	// 3: Write the file, dropping a replaced certificate of another format
+	if exists && existing != filePath {
+		if err := os.Remove(existing); err != nil {
+			return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+		}
+	}
+	sealed, err := sealCertificate(r.encryption, content)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.writeFile(certificate.NIT, filePath, sealed); err != nil {
+		return nil, err
+	}
+	logs.Info("Certificate stored for NIT:", certificate.NIT)
+
+	summary := r.summarize(certificate.NIT, &certificateEntry{file: filePath, certificate: certificate}, nil)
+	return &summary, nil
+}
+
+// SetActive activates or deactivates a certificate of a NIT by rewriting its activo element
+func (r *FileCertificateRepository) SetActive(ctx context.Context, nit string, id string, active bool) (*models.CertificateSummary, error) {
+	// 1: Read the certificate file
+	entry, err := r.targetEntry(nit, id)
+	if err != nil {
+		return nil, err
+	}
+	content, err := r.readCertificate(entry.file)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2: Replace the activo element, keeping the rest of the file untouched
+	if !activePattern.Match(content) {
+		return nil, domainErrors.NewDomainError("invalid", domainErrors.CodeInvalid)
+	}
+	value := []byte("<activo>false</activo>")
+	if active {
+		value = []byte("<activo>true</activo>")
+	}
+	updated := activePattern.ReplaceAllLiteral(content, value)
+
+	// 3: Write the file when it changed
+	if !bytes.Equal(content, updated) {
+		sealed, err := sealCertificate(r.encryption, updated)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.writeFile(nit, entry.file, sealed); err != nil {
+			return nil, err
+		}
+		logs.Info("Certificate active flag updated for NIT:", nit)
+	}
+
+	certificate, err := r.parser.parseHacienda(updated)
+	if err == nil && entry.certificate != nil {
+		// Keep the validity window, priority and expiry date given by the manifest
+		certificate.NotBefore = entry.certificate.NotBefore
+		certificate.NotAfter = entry.certificate.NotAfter
+		certificate.Priority = entry.certificate.Priority
+		certificate.ExpiresAt = entry.certificate.ExpiresAt
+	}
+	summary := r.summarize(nit, &certificateEntry{file: entry.file, certificate: certificate, err: err}, nil)
+	return &summary, nil
+}
+
+// SetExpiry sets or clears the expiry date of a certificate of a NIT in the manifest of its directory
+func (r *FileCertificateRepository) SetExpiry(ctx context.Context, nit string, id string, expiresAt *time.Time) (*models.CertificateSummary, error) {
+	// 1: Find the certificate file, an invalid manifest must be fixed by hand
+	entry, err := r.targetEntry(nit, id)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Base(entry.file) == manifestFile {
+		return nil, entry.err
+	}
+
+	// 2: Update the manifest entry of the file
+	manifest, err := r.readManifest(nit)
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		manifest = &certificateManifest{}
+	}
+	name := filepath.Base(entry.file)
+	found := false
+	for i := range manifest.Certificates {
+		if manifest.Certificates[i].File == name {
+			manifest.Certificates[i].ExpiresAt = expiresAt
+			found = true
+		}
+	}
+	if !found {
+		manifest.Certificates = append(manifest.Certificates, manifestCertificate{File: name, ExpiresAt: expiresAt})
+	}
+
+	// 3: Write the manifest, which reloads the NIT
+	if err := r.writeManifest(nit, manifest); err != nil {
+		return nil, err
+	}
+	logs.Info("Certificate expiry updated for NIT:", nit, name)
+
+	// 4: Describe the reloaded certificate
+	entries, ok := r.indexedEntries(nit)
+	if !ok {
+		entries = r.loadNIT(nit)
+	}
+	for _, reloaded := range entries {
+		if reloaded.file == entry.file {
+			summary := r.summarize(nit, reloaded, selectedCertificate(entries))
+			return &summary, nil
+		}
+	}
+	return nil, domainErrors.NewDomainError("file_not_found", domainErrors.CodeFileNotFound)
+}
+
+// Delete removes a certificate file of a NIT
+func (r *FileCertificateRepository) Delete(ctx context.Context, nit string, id string) error {
+	entry, err := r.targetEntry(nit, id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(entry.file); err != nil {
+		if os.IsNotExist(err) {
+			return domainErrors.NewDomainError("file_not_found", domainErrors.CodeFileNotFound)
+		}
+		return domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+	logs.Info("Certificate deleted for NIT:", nit, r.relativePath(entry.file))
+
+	r.reload(nit)
+	return nil
+}
+
+// Reseal encrypts every plaintext certificate file and wraps the data key of the encrypted ones
+// with the active master key, returning the number of files rewritten
+func (r *FileCertificateRepository) Reseal(ctx context.Context) (int, error) {
+	if r.encryption == nil {
+		return 0, fmt.Errorf("certificate encryption is not configured")
+	}
+
+	nits, err := r.certificateNITs()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list certificates directory: %w", err)
+	}
+
+	resealed := 0
+	for _, nit := range nits {
+		files, err := r.certificateFiles(nit)
+		if err != nil {
+			return resealed, fmt.Errorf("failed to list certificates of NIT %s: %w", nit, err)
+		}
+		for _, file := range files {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return resealed, err
+			}
+			sealed, changed, err := resealCertificate(ctx, r.encryption, content)
+			if err != nil {
+				return resealed, fmt.Errorf("failed to encrypt %s: %w", r.relativePath(file), err)
+			}
+			if !changed {
+				continue
+			}
+			if err := r.writeFile(nit, file, sealed); err != nil {
+				return resealed, fmt.Errorf("failed to write %s: %w", r.relativePath(file), err)
+			}
+			resealed++
+		}
+	}
+
+	return resealed, nil
+}
+
+// targetEntry returns the certificate file of a NIT targeted by an administration request.
+// The ID may be omitted when the NIT has a single certificate file.
+func (r *FileCertificateRepository) targetEntry(nit string, id string) (*certificateEntry, error) {
+	if !models.IsValidNIT(nit) {
+		return nil, domainErrors.NewDomainError("invalid_nit", domainErrors.CodeInvalid)
+	}
+
+	entries, ok := r.indexedEntries(nit)
+	if !ok {
+		entries = r.loadNIT(nit)
+	}
+	if len(entries) == 0 {
+		return nil, domainErrors.NewDomainError("file_not_found", domainErrors.CodeFileNotFound)
+	}
+
+	if id == "" {
+		if len(entries) > 1 {
+			return nil, domainErrors.NewDomainError("certificate_id_required", domainErrors.CodeRequiredData)
+		}
+		return entries[0], nil
+	}
+	for _, entry := range entries {
+		if entry.certificate != nil && entry.certificate.ID == id {
+			return entry, nil
+		}
+	}
+	return nil, domainErrors.NewDomainError("certificate_id_not_found", domainErrors.CodeCertNotFound)
+}
+
+// storedFileName returns the file name, without extension, of a certificate stored in a NIT directory
+func storedFileName(certificate *models.Certificate) string {
+	if storedIDPattern.MatchString(certificate.ID) {
+		return certificate.ID
+	}
+	if fingerprint, err := cypher.PublicKeyFingerprint(certificate.VerificationKey()); err == nil {
+		return fingerprint[:16]
+	}
+	return certificate.NIT
+}
+
+// writeFile atomically replaces a certificate file of a NIT and reloads it
+func (r *FileCertificateRepository) writeFile(nit string, filePath string, content []byte) error {
+	// The temporary file does not use the certificate extension, so the watcher ignores it
+	temp, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(content); err != nil {
+		temp.Close()
+		return domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+	// Certificate files hold private keys, only the service may read them
+	if err := temp.Chmod(0600); err != nil {
+		temp.Close()
+		return domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+	if err := temp.Close(); err != nil {
+		return domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+	if err := os.Rename(temp.Name(), filePath); err != nil {
+		return domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+
+	r.reload(nit)
+	return nil
+}
+
+// summarize describes a certificate file, or the error that prevented loading it
+func (r *FileCertificateRepository) summarize(nit string, entry *certificateEntry, selected *models.Certificate) models.CertificateSummary {
+	return describeCertificate(nit, r.relativePath(entry.file), entry.certificate, entry.err, selected)
+}
+
+// describeCertificate summarizes a stored certificate, or the error that prevented loading it
+func describeCertificate(nit string, file string, certificate *models.Certificate, err error, selected *models.Certificate) models.CertificateSummary {
+	summary := models.CertificateSummary{NIT: nit, File: file}
+	if err != nil {
+		summary.Error = err.Error()
+		return summary
+	}
+
+	summary.ID = certificate.ID
+	summary.Active = certificate.IsActive()
+	summary.Priority = certificate.Priority
+	summary.Selected = certificate == selected
+	summary.Valid = true
+	if !certificate.NotBefore.IsZero() {
+		summary.NotBefore = &certificate.NotBefore
+	}
+	if !certificate.NotAfter.IsZero() {
+		summary.NotAfter = &certificate.NotAfter
+	}
+	if !certificate.IssuedAt.IsZero() {
+		summary.IssuedAt = &certificate.IssuedAt
+	}
+	if !certificate.ExpiresAt.IsZero() {
+		summary.ExpiresAt = &certificate.ExpiresAt
+		summary.Expired = certificate.IsExpiredAt(time.Now())
+	}
+
+	publicKey := certificate.VerificationKey()
+	summary.KeyType = cypher.DescribeKey(publicKey)
+	if fingerprint, err := cypher.PublicKeyFingerprint(publicKey); err == nil {
+		summary.Fingerprint = fingerprint
+	}
+	return summary
+}
@@ -0,0 +1,96 @@
+package adapters
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chainedpixel/go-dte-signer/internal/infrastructure/cypher"
+)
+
+// testCertificatePassword is the private and public key password of the test certificates
+const testCertificatePassword = "secret"
+
+// haciendaCertificate returns a Hacienda XML certificate of a NIT with a new ECDSA key, along with the key
+func haciendaCertificate(t *testing.T, nit string, id string, active bool) ([]byte, crypto.Signer) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha512.Sum512([]byte(testCertificatePassword))
+	password := hex.EncodeToString(digest[:])
+
+	content := fmt.Sprintf(`<CertificadoMH><_id>%s</_id><nit>%s</nit><activo>%t</activo>`+
+		`<privateKey><algorithm>EC</algorithm><clave>%s</clave><encodied>%s</encodied><format>PKCS#8</format><keyType>PRIVATE</keyType></privateKey>`+
+		`<publicKey><algorithm>EC</algorithm><clave>%s</clave><encodied>%s</encodied><format>X.509</format><keyType>PUBLIC</keyType></publicKey>`+
+		`</CertificadoMH>`,
+		id, nit, active, password, base64.StdEncoding.EncodeToString(privateDER), password, base64.StdEncoding.EncodeToString(publicDER))
+	return []byte(content), key
+}
+
+// checkMode fails when the permissions of a file differ from the expected ones
+func checkMode(t *testing.T, path string, want os.FileMode) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); got != want {
+		t.Errorf("mode of %s = %o, want %o", filepath.Base(path), got, want)
+	}
+}
+
+func TestFileCertificateRepositoryKeepsFilesPrivate(t *testing.T) {
+	basePath := t.TempDir()
+	repository := NewFileCertificateRepository(basePath, cypher.NewKeyProcessor(), nil, nil)
+	ctx := context.Background()
+	const nit = "06140101780010"
+
+	// 1: An uploaded certificate is only readable by the service
+	content, _ := haciendaCertificate(t, nit, nit+"-id", true)
+	if _, err := repository.Save(ctx, content, false); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	checkMode(t, filepath.Join(basePath, nit+".crt"), 0o600)
+
+	// 2: Rewriting it keeps it private
+	if _, err := repository.SetActive(ctx, nit, "", false); err != nil {
+		t.Fatalf("SetActive() error = %v", err)
+	}
+	checkMode(t, filepath.Join(basePath, nit+".crt"), 0o600)
+
+	// 3: The directory and manifest of a NIT are private as well
+	expiresAt := time.Now().Add(24 * time.Hour)
+	if _, err := repository.SetExpiry(ctx, nit, "", &expiresAt); err != nil {
+		t.Fatalf("SetExpiry() error = %v", err)
+	}
+	checkMode(t, filepath.Join(basePath, nit), 0o700)
+	checkMode(t, filepath.Join(basePath, nit, manifestFile), 0o600)
+
+	// 4: A certificate added to the directory of the NIT too
+	other, _ := haciendaCertificate(t, nit, nit+"-other", true)
+	if _, err := repository.Save(ctx, other, false); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	checkMode(t, filepath.Join(basePath, nit, nit+"-other.crt"), 0o600)
+}
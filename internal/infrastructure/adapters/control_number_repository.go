@@ -0,0 +1,153 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+)
+
+// FileControlNumberRepository implements a control number repository backed by a JSON file. Every
+// change is written to a temporary file, synced and renamed over the previous one before the
+// correlative is returned, so a correlative is never handed out twice, even after a crash. The file
+// belongs to a single instance; replicas share their correlatives through the SQLite repository.
+type FileControlNumberRepository struct {
+	path string
+
+	mutex     sync.Mutex
+	sequences map[string]int64
+}
+
+// controlNumberFile is the content of the correlatives file
+type controlNumberFile struct {
+	// Sequences holds the last correlative of every series, by "<nit>/<tipoDte>/<establecimiento>/<puntoVenta>"
+	Sequences map[string]int64 `json:"sequences"`
+}
+
+// NewFileControlNumberRepository opens the correlatives file at the given path, which is created on the first allocation
+func NewFileControlNumberRepository(path string) (*FileControlNumberRepository, error) {
+	repository := &FileControlNumberRepository{path: path, sequences: make(map[string]int64)}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return repository, nil
+		}
+		return nil, fmt.Errorf("failed to read control numbers file: %w", err)
+	}
+	var file controlNumberFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("invalid control numbers file %s: %w", path, err)
+	}
+	if file.Sequences != nil {
+		repository.sequences = file.Sequences
+	}
+
+	return repository, nil
+}
+
+// Next increments the correlative of a series, starting at 1, and returns it
+func (r *FileControlNumberRepository) Next(ctx context.Context, series models.ControlNumberSeries) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := series.Key()
+	current := r.sequences[key]
+	if current >= models.MaxControlNumberSequence {
+		return 0, domainErrors.NewDomainError("numero_control_exhausted", domainErrors.CodeControlNumber)
+	}
+	if err := r.store(key, current+1); err != nil {
+		return 0, err
+	}
+	return current + 1, nil
+}
+
+// Current returns the last correlative allocated from a series, 0 when none was
+func (r *FileControlNumberRepository) Current(ctx context.Context, series models.ControlNumberSeries) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.sequences[series.Key()], nil
+}
+
+// Advance raises the correlative of a series to at least the given one, never lowering it, and returns it
+func (r *FileControlNumberRepository) Advance(ctx context.Context, series models.ControlNumberSeries, sequence int64) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := series.Key()
+	current := r.sequences[key]
+	if sequence <= current {
+		return current, nil
+	}
+	if err := r.store(key, sequence); err != nil {
+		return 0, err
+	}
+	return sequence, nil
+}
+
+// Release returns the given correlative to its series while it is still the last one allocated, and reports whether it did
+func (r *FileControlNumberRepository) Release(ctx context.Context, series models.ControlNumberSeries, sequence int64) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := series.Key()
+	if sequence < 1 || r.sequences[key] != sequence {
+		return false, nil
+	}
+	if err := r.store(key, sequence-1); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// store sets the correlative of a series and writes the file, leaving both unchanged when the write fails
+func (r *FileControlNumberRepository) store(key string, sequence int64) error {
+	previous, existed := r.sequences[key]
+	r.sequences[key] = sequence
+	if err := r.write(); err != nil {
+		if existed {
+			r.sequences[key] = previous
+		} else {
+			delete(r.sequences, key)
+		}
+		return domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+	return nil
+}
+
+// write atomically replaces the file with the current correlatives
+func (r *FileControlNumberRepository) write() error {
+	content, err := json.MarshalIndent(controlNumberFile{Sequences: r.sequences}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(r.path), ".control-numbers-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(append(content, '\n')); err != nil {
+		temp.Close()
+		return err
+	}
+	// The correlative must be on disk before it is handed out
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), r.path)
+}
@@ -0,0 +1,140 @@
+package adapters
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/ports"
+)
+
+var testSeries = models.ControlNumberSeries{NIT: "06140101780010", TipoDte: "01", Establecimiento: "M001", PuntoVenta: "P001"}
+
+// allocateConcurrently takes count correlatives of the test series spread over the repositories, failing on any duplicate
+func allocateConcurrently(t *testing.T, count int, repositories ...ports.ControlNumberRepository) {
+	t.Helper()
+	sequences := make(chan int64, count)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(repository ports.ControlNumberRepository) {
+			defer wg.Done()
+			sequence, err := repository.Next(context.Background(), testSeries)
+			if err != nil {
+				t.Errorf("Next() error = %v", err)
+				return
+			}
+			sequences <- sequence
+		}(repositories[i%len(repositories)])
+	}
+	wg.Wait()
+	close(sequences)
+
+	seen := make(map[int64]bool, count)
+	for sequence := range sequences {
+		if seen[sequence] {
+			t.Errorf("correlative %d allocated twice", sequence)
+		}
+		if sequence < 1 || sequence > int64(count) {
+			t.Errorf("correlative %d outside 1..%d", sequence, count)
+		}
+		seen[sequence] = true
+	}
+	if len(seen) != count {
+		t.Errorf("allocated %d correlatives, want %d", len(seen), count)
+	}
+}
+
+// checkAdvance checks that a repository at the given correlative never lowers it and fails once the series is exhausted
+func checkAdvance(t *testing.T, repository ports.ControlNumberRepository, current int64) {
+	t.Helper()
+	ctx := context.Background()
+	if got, err := repository.Advance(ctx, testSeries, current-1); err != nil || got != current {
+		t.Errorf("Advance(%d) = %d, %v, want the correlative kept at %d", current-1, got, err, current)
+	}
+	if got, err := repository.Advance(ctx, testSeries, models.MaxControlNumberSequence); err != nil || got != models.MaxControlNumberSequence {
+		t.Errorf("Advance(max) = %d, %v", got, err)
+	}
+	_, err := repository.Next(ctx, testSeries)
+	if domainErr, ok := err.(domainErrors.DomainError); !ok || domainErr.Message != "numero_control_exhausted" {
+		t.Errorf("Next() of an exhausted series error = %v, want numero_control_exhausted", err)
+	}
+	other := testSeries
+	other.PuntoVenta = "P002"
+	if got, err := repository.Current(ctx, other); err != nil || got != 0 {
+		t.Errorf("Current() of another series = %d, %v, want 0", got, err)
+	}
+}
+
+// checkRelease checks that a repository at the given correlative only takes back its last one, which it then allocates again
+func checkRelease(t *testing.T, repository ports.ControlNumberRepository, current int64) {
+	t.Helper()
+	ctx := context.Background()
+	if released, err := repository.Release(ctx, testSeries, current-1); err != nil || released {
+		t.Errorf("Release(%d) = %v, %v, want an earlier correlative kept", current-1, released, err)
+	}
+	if released, err := repository.Release(ctx, testSeries, current); err != nil || !released {
+		t.Fatalf("Release(%d) = %v, %v, want the last correlative released", current, released, err)
+	}
+	if got, err := repository.Current(ctx, testSeries); err != nil || got != current-1 {
+		t.Errorf("Current() after the release = %d, %v, want %d", got, err, current-1)
+	}
+	if got, err := repository.Next(ctx, testSeries); err != nil || got != current {
+		t.Errorf("Next() after the release = %d, %v, want %d again", got, err, current)
+	}
+	other := testSeries
+	other.PuntoVenta = "P002"
+	if released, err := repository.Release(ctx, other, 1); err != nil || released {
+		t.Errorf("Release() of an unused series = %v, %v, want nothing released", released, err)
+	}
+}
+
+func TestFileControlNumberRepositoryConcurrentAllocation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control-numbers.json")
+	repository, err := NewFileControlNumberRepository(path)
+	if err != nil {
+		t.Fatalf("NewFileControlNumberRepository() error = %v", err)
+	}
+
+	allocateConcurrently(t, 200, repository)
+
+	// The correlatives survive a restart
+	reopened, err := NewFileControlNumberRepository(path)
+	if err != nil {
+		t.Fatalf("NewFileControlNumberRepository() error = %v", err)
+	}
+	if got, err := reopened.Current(context.Background(), testSeries); err != nil || got != 200 {
+		t.Fatalf("Current() after reopening = %d, %v, want 200", got, err)
+	}
+	checkRelease(t, reopened, 200)
+	checkAdvance(t, reopened, 200)
+}
+
+func TestSQLiteControlNumberRepositoryConcurrentAllocation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signer.db")
+	ctx := context.Background()
+
+	// Two repositories on the same file stand for two replicas
+	first, err := NewSQLiteControlNumberRepository(ctx, path, 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewSQLiteControlNumberRepository() error = %v", err)
+	}
+	defer first.Close()
+	second, err := NewSQLiteControlNumberRepository(ctx, path, 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewSQLiteControlNumberRepository() error = %v", err)
+	}
+	defer second.Close()
+
+	allocateConcurrently(t, 200, first, second)
+
+	if got, err := second.Current(ctx, testSeries); err != nil || got != 200 {
+		t.Fatalf("Current() = %d, %v, want 200", got, err)
+	}
+	checkRelease(t, second, 200)
+	checkAdvance(t, first, 200)
+}
@@ -0,0 +1,150 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+)
+
+// dteSchemaFiles holds the JSON schemas of the DTE types, named after the Hacienda schema files
+//
+//go:embed schemas/*.json
+var dteSchemaFiles embed.FS
+
+// dteSchemas maps every supported tipoDte and version, as "<tipoDte>/<version>", to its schema file
+var dteSchemas = map[string]string{
+	"01/1": "fe-fc-v1.json",
+	"03/3": "fe-ccf-v3.json",
+	"04/3": "fe-nr-v3.json",
+	"05/3": "fe-nc-v3.json",
+	"06/3": "fe-nd-v3.json",
+	"07/1": "fe-cr-v1.json",
+	"08/1": "fe-cl-v1.json",
+	"09/1": "fe-dcl-v1.json",
+	"11/1": "fe-fex-v1.json",
+	"14/1": "fe-fse-v1.json",
+	"15/1": "fe-cd-v1.json",
+}
+
+// maxSchemaViolations bounds the violations reported for a single document
+const maxSchemaViolations = 50
+
+// DTESchemaValidator validates documents against the JSON schema of their DTE type and version
+type DTESchemaValidator struct {
+	schemas map[string]*jsonschema.Schema
+}
+
+// NewDTESchemaValidator creates a new schema validator, compiling the embedded schemas
+func NewDTESchemaValidator() (*DTESchemaValidator, error) {
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft7
+
+	schemas := make(map[string]*jsonschema.Schema, len(dteSchemas))
+	for key, file := range dteSchemas {
+		content, err := dteSchemaFiles.ReadFile("schemas/" + file)
+		if err != nil {
+			return nil, err
+		}
+		if err := compiler.AddResource(file, bytes.NewReader(content)); err != nil {
+			return nil, fmt.Errorf("invalid schema %s: %w", file, err)
+		}
+		schema, err := compiler.Compile(file)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema %s: %w", file, err)
+		}
+		schemas[key] = schema
+	}
+
+	return &DTESchemaValidator{schemas: schemas}, nil
+}
+
+// Validate checks a document against the schema of its identificacion.tipoDte and identificacion.version
+func (v *DTESchemaValidator) Validate(ctx context.Context, document []byte) error {
+	// 1: Decode the document, keeping numbers exact for the decimal checks
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	var instance interface{}
+	if err := decoder.Decode(&instance); err != nil {
+		return domainErrors.NewDomainError("invalid_document_json", domainErrors.CodeStrToJSONConversion)
+	}
+
+	// 2: Find the schema of the DTE type and version
+	tipoDte, version := dteType(instance)
+	schema, ok := v.schemas[tipoDte+"/"+version]
+	if !ok {
+		return domainErrors.NewDocumentInvalidError("dte_type_unsupported", []string{
+			fmt.Sprintf("$.identificacion: tipoDte %q version %q has no schema", tipoDte, version),
+		})
+	}
+
+	// 3: Validate the document, listing every violation by its JSON path
+	err := schema.Validate(instance)
+	if err == nil {
+		return nil
+	}
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+	return domainErrors.NewDocumentInvalidError("dte_schema_invalid", schemaViolations(validationErr))
+}
+
+// dteType returns the tipoDte and version declared in the identificacion of a document
+func dteType(instance interface{}) (string, string) {
+	document, _ := instance.(map[string]interface{})
+	identificacion, _ := document["identificacion"].(map[string]interface{})
+	tipoDte, _ := identificacion["tipoDte"].(string)
+	var version string
+	if number, ok := identificacion["version"].(json.Number); ok {
+		version = number.String()
+	}
+	return tipoDte, version
+}
+
+// schemaViolations lists the innermost errors of a validation error as "<JSON path>: <message>"
+func schemaViolations(err *jsonschema.ValidationError) []string {
+	var violations []string
+	var collect func(*jsonschema.ValidationError)
+	collect = func(err *jsonschema.ValidationError) {
+		if len(err.Causes) == 0 {
+			violations = append(violations, jsonPath(err.InstanceLocation)+": "+err.Message)
+			return
+		}
+		for _, cause := range err.Causes {
+			collect(cause)
+		}
+	}
+	collect(err)
+
+	sort.Strings(violations)
+	if len(violations) > maxSchemaViolations {
+		violations = append(violations[:maxSchemaViolations], fmt.Sprintf("%d more violation(s)", len(violations)-maxSchemaViolations))
+	}
+	return violations
+}
+
+// jsonPath converts a JSON pointer to a JSON path, e.g. /cuerpoDocumento/0/precioUni to $.cuerpoDocumento[0].precioUni
+func jsonPath(pointer string) string {
+	path := "$"
+	if pointer == "" {
+		return path
+	}
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		if _, err := strconv.Atoi(token); err == nil {
+			path += "[" + token + "]"
+		} else {
+			path += "." + token
+		}
+	}
+	return path
+}
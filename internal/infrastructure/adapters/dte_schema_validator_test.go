@@ -0,0 +1,217 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+)
+
+// testFactura is a Factura that complies with the fe-fc-v1 schema
+const testFactura = `{
+  "identificacion": {"version": 1, "ambiente": "00", "tipoDte": "01", "numeroControl": "DTE-01-M001P001-000000000000001",
+    "codigoGeneracion": "6F1E3B2A-4C5D-4E6F-8A9B-0C1D2E3F4A5B", "tipoModelo": 1, "tipoOperacion": 1, "tipoContingencia": null,
+    "motivoContin": null, "fecEmi": "2026-10-16", "horEmi": "10:15:00", "tipoMoneda": "USD"},
+  "documentoRelacionado": null,
+  "emisor": {"nit": "06140101780010", "nrc": "1234567", "nombre": "EMPRESA DE PRUEBA S.A. DE C.V.", "codActividad": "62010",
+    "descActividad": "Programación informática", "nombreComercial": "PRUEBA", "tipoEstablecimiento": "01",
+    "direccion": {"departamento": "06", "municipio": "14", "complemento": "Col. Escalón, San Salvador"}, "telefono": "22223333",
+    "correo": "facturacion@prueba.com.sv", "codEstableMH": "M001", "codEstable": "M001", "codPuntoVentaMH": "P001", "codPuntoVenta": "P001"},
+  "receptor": {"tipoDocumento": "13", "numDocumento": "01234567-8", "nrc": null, "nombre": "CLIENTE FINAL", "codActividad": null,
+    "descActividad": null, "direccion": null, "telefono": null, "correo": "cliente@correo.com"},
+  "otrosDocumentos": null,
+  "ventaTercero": null,
+  "cuerpoDocumento": [{"numItem": 1, "tipoItem": 2, "numeroDocumento": null, "cantidad": 2, "codigo": "SERV-01", "codTributo": null,
+    "uniMedida": 59, "descripcion": "Servicio de soporte", "precioUni": 56.5, "montoDescu": 0, "ventaNoSuj": 0, "ventaExenta": 0,
+    "ventaGravada": 113, "tributos": null, "psv": 0, "noGravado": 0, "ivaItem": 13}],
+  "resumen": {"totalNoSuj": 0, "totalExenta": 0, "totalGravada": 113, "subTotalVentas": 113, "descuNoSuj": 0, "descuExenta": 0,
+    "descuGravada": 0, "porcentajeDescuento": 0, "totalDescu": 0, "tributos": null, "subTotal": 113, "ivaRete1": 0, "reteRenta": 0,
+    "montoTotalOperacion": 113, "totalNoGravado": 0, "totalPagar": 113, "totalLetras": "CIENTO TRECE 00/100 USD", "totalIva": 13,
+    "saldoFavor": 0, "condicionOperacion": 1,
+    "pagos": [{"codigo": "01", "montoPago": 113, "referencia": null, "plazo": null, "periodo": null}], "numPagoElectronico": null},
+  "extension": null,
+  "apendice": null
+}`
+
+// modifiedFactura returns the test Factura after applying a change to its decoded form
+func modifiedFactura(t *testing.T, modify func(document map[string]interface{})) []byte {
+	t.Helper()
+	var document map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(testFactura))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		t.Fatal(err)
+	}
+	modify(document)
+	content, err := json.Marshal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+// member returns an object member of the decoded document
+func member(document map[string]interface{}, name string) map[string]interface{} {
+	return document[name].(map[string]interface{})
+}
+
+func TestNewDTESchemaValidatorCompilesEverySchema(t *testing.T) {
+	validator, err := NewDTESchemaValidator()
+	if err != nil {
+		t.Fatalf("NewDTESchemaValidator() error = %v", err)
+	}
+	if len(validator.schemas) != len(dteSchemas) {
+		t.Errorf("compiled %d schemas, want %d", len(validator.schemas), len(dteSchemas))
+	}
+	entries, err := dteSchemaFiles.ReadDir("schemas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(dteSchemas) {
+		t.Errorf("%d embedded schema files, but %d registered in dteSchemas", len(entries), len(dteSchemas))
+	}
+}
+
+func TestDTESchemaValidator(t *testing.T) {
+	validator, err := NewDTESchemaValidator()
+	if err != nil {
+		t.Fatalf("NewDTESchemaValidator() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		document []byte
+		wantCode string
+		wantMsg  string
+		// want lists the start of every expected violation
+		want []string
+	}{
+		{name: "valid factura", document: []byte(testFactura)},
+		{
+			name: "decimals compared exactly",
+			document: modifiedFactura(t, func(document map[string]interface{}) {
+				item := document["cuerpoDocumento"].([]interface{})[0].(map[string]interface{})
+				item["precioUni"] = json.Number("56.50000001")
+			}),
+		},
+		{
+			name: "too many decimals",
+			document: modifiedFactura(t, func(document map[string]interface{}) {
+				member(document, "resumen")["totalPagar"] = json.Number("113.001")
+			}),
+			wantCode: domainErrors.CodeDocumentInvalid, wantMsg: "dte_schema_invalid",
+			want: []string{"$.resumen.totalPagar: "},
+		},
+		{
+			name: "missing required member",
+			document: modifiedFactura(t, func(document map[string]interface{}) {
+				delete(member(document, "resumen"), "totalIva")
+			}),
+			wantCode: domainErrors.CodeDocumentInvalid, wantMsg: "dte_schema_invalid",
+			want: []string{"$.resumen: missing properties: 'totalIva'"},
+		},
+		{
+			name: "violations sorted by path, inside arrays",
+			document: modifiedFactura(t, func(document map[string]interface{}) {
+				member(document, "identificacion")["codigoGeneracion"] = "6f1e3b2a-4c5d-4e6f-8a9b-0c1d2e3f4a5b"
+				item := document["cuerpoDocumento"].([]interface{})[0].(map[string]interface{})
+				item["cantidad"] = json.Number("-1")
+			}),
+			wantCode: domainErrors.CodeDocumentInvalid, wantMsg: "dte_schema_invalid",
+			want: []string{"$.cuerpoDocumento[0].cantidad: ", "$.identificacion.codigoGeneracion: "},
+		},
+		{
+			name: "unsupported type",
+			document: modifiedFactura(t, func(document map[string]interface{}) {
+				member(document, "identificacion")["tipoDte"] = "02"
+			}),
+			wantCode: domainErrors.CodeDocumentInvalid, wantMsg: "dte_type_unsupported",
+			want: []string{`$.identificacion: tipoDte "02" version "1" has no schema`},
+		},
+		{
+			name: "unsupported version",
+			document: modifiedFactura(t, func(document map[string]interface{}) {
+				member(document, "identificacion")["version"] = json.Number("3")
+			}),
+			wantCode: domainErrors.CodeDocumentInvalid, wantMsg: "dte_type_unsupported",
+			want: []string{`$.identificacion: tipoDte "01" version "3" has no schema`},
+		},
+		{
+			name:     "missing identificacion",
+			document: []byte(`{"resumen": {}}`),
+			wantCode: domainErrors.CodeDocumentInvalid, wantMsg: "dte_type_unsupported",
+			want: []string{`$.identificacion: tipoDte "" version "" has no schema`},
+		},
+		{
+			name:     "malformed JSON",
+			document: []byte(`{"identificacion": `),
+			wantCode: domainErrors.CodeStrToJSONConversion, wantMsg: "invalid_document_json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.Validate(context.Background(), tt.document)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			domainErr, ok := err.(domainErrors.DomainError)
+			if !ok || domainErr.Code != tt.wantCode || domainErr.Message != tt.wantMsg {
+				t.Fatalf("Validate() error = %v, want %s: %s", err, tt.wantCode, tt.wantMsg)
+			}
+			if len(domainErr.Details) != len(tt.want) {
+				t.Fatalf("Validate() details = %q, want %d violation(s)", domainErr.Details, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(domainErr.Details[i], want) {
+					t.Errorf("violation %d = %q, want %q", i, domainErr.Details[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestDTESchemaValidatorBoundsViolations(t *testing.T) {
+	validator, err := NewDTESchemaValidator()
+	if err != nil {
+		t.Fatalf("NewDTESchemaValidator() error = %v", err)
+	}
+	document := modifiedFactura(t, func(document map[string]interface{}) {
+		lines := make([]interface{}, 60)
+		for i := range lines {
+			lines[i] = map[string]interface{}{"numItem": json.Number("0")}
+		}
+		document["cuerpoDocumento"] = lines
+	})
+
+	err = validator.Validate(context.Background(), document)
+	domainErr, ok := err.(domainErrors.DomainError)
+	if !ok || domainErr.Message != "dte_schema_invalid" {
+		t.Fatalf("Validate() error = %v, want dte_schema_invalid", err)
+	}
+	if len(domainErr.Details) != maxSchemaViolations+1 {
+		t.Fatalf("Validate() reported %d details, want %d", len(domainErr.Details), maxSchemaViolations+1)
+	}
+	if last := domainErr.Details[maxSchemaViolations]; !strings.HasSuffix(last, "more violation(s)") {
+		t.Errorf("last detail = %q, want the count of the omitted violations", last)
+	}
+}
+
+func TestJSONPath(t *testing.T) {
+	tests := map[string]string{
+		"":                             "$",
+		"/resumen":                     "$.resumen",
+		"/cuerpoDocumento/0/precioUni": "$.cuerpoDocumento[0].precioUni",
+		"/resumen/pagos/12/codigo":     "$.resumen.pagos[12].codigo",
+		"/a~1b/c~0d":                   "$.a/b.c~d",
+	}
+	for pointer, want := range tests {
+		if got := jsonPath(pointer); got != want {
+			t.Errorf("jsonPath(%q) = %q, want %q", pointer, got, want)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+-- Certificates of every NIT, stored as uploaded: Hacienda XML, PKCS#12 or PEM
+CREATE TABLE certificates (
+    nit        TEXT    NOT NULL,
+    id         TEXT    NOT NULL,
+    format     TEXT    NOT NULL,
+    content    BLOB    NOT NULL,
+    active     INTEGER NOT NULL DEFAULT 1,
+    not_before TEXT,
+    not_after  TEXT,
+    priority   INTEGER NOT NULL DEFAULT 0,
+    expires_at TEXT,
+    created_at TEXT    NOT NULL,
+    updated_at TEXT    NOT NULL,
+    PRIMARY KEY (nit, id)
+);
@@ -0,0 +1,10 @@
+-- Last numeroControl correlative of every series of documents
+CREATE TABLE control_numbers (
+    nit             TEXT    NOT NULL,
+    tipo_dte        TEXT    NOT NULL,
+    establecimiento TEXT    NOT NULL,
+    punto_venta     TEXT    NOT NULL,
+    sequence        INTEGER NOT NULL,
+    updated_at      TEXT    NOT NULL,
+    PRIMARY KEY (nit, tipo_dte, establecimiento, punto_venta)
+);
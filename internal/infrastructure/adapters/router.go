@@ -1,6 +1,7 @@
 package adapters
 
 import (
+	"fmt"
 	"github.com/gorilla/mux"
 	"net/http"
 	"time"
@@ -58,7 +59,7 @@ func loggingMiddleware(next http.Handler) http.Handler {
 
 		// Log after processing
 		duration := time.Since(start)
-		logs.Info("[%s] %s %s %d %s", r.Method, r.RequestURI, r.RemoteAddr, wrapper.status, duration)
+		logs.Info(fmt.Sprintf("[%s] %s %s %d %s", r.Method, r.RequestURI, r.RemoteAddr, wrapper.status, duration))
 	})
 }
 
@@ -67,7 +68,7 @@ func recoveryMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				logs.Error("PANIC: %v", err)
+				logs.Error(fmt.Sprintf("PANIC: %v", err))
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			}
 		}()
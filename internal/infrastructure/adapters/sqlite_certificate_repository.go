@@ -0,0 +1,721 @@
+package adapters
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/ports"
+	"github.com/chainedpixel/go-dte-signer/internal/infrastructure/cypher"
+	"github.com/chainedpixel/go-dte-signer/pkg/logs"
+)
+
+// certificateColumns lists the columns read for every certificate row
+const certificateColumns = `nit, id, format, content, active, not_before, not_after, priority, expires_at`
+
+// SQLiteCertificateRepository implements a certificate repository backed by an embedded SQLite
+// database. Certificates are stored as uploaded, along with their activation state, validity
+// window, priority and expiry date, so several replicas can share a single database file.
+// Their content may be encrypted at rest, in which case it is decrypted when read.
+type SQLiteCertificateRepository struct {
+	db           *sql.DB
+	path         string
+	version      int
+	keyProcessor *cypher.KeyProcessor
+	parser       *certificateParser
+	passwords    map[string]string
+	encryption   ports.KeyEncryptionService
+
+	mutex     sync.RWMutex
+	listeners []func(nit string)
+
+	// versions holds the update times of the certificates of each NIT seen by the last poll
+	versions map[string]string
+	stop     context.CancelFunc
+	stopped  chan struct{}
+}
+
+// certificateRow is a row of the certificates table
+type certificateRow struct {
+	nit       string
+	id        string
+	format    string
+	content   []byte
+	active    bool
+	notBefore sql.NullString
+	notAfter  sql.NullString
+	priority  int
+	expiresAt sql.NullString
+}
+
+// NewSQLiteCertificateRepository opens, creating it when needed, the SQLite database at the given
+// path and applies its pending migrations. The passwords unlock the PKCS#12 and PEM keys of each NIT.
+// When encryption is set, stored certificates are encrypted with it; otherwise it may be nil.
+func NewSQLiteCertificateRepository(ctx context.Context, path string, busyTimeout time.Duration, keyProcessor *cypher.KeyProcessor, passwords map[string]string, encryption ports.KeyEncryptionService) (*SQLiteCertificateRepository, error) {
+	db, version, err := openSQLite(ctx, path, busyTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open certificates database: %w", err)
+	}
+
+	logs.Info(fmt.Sprintf("Certificates database ready: %s (schema version %d)", path, version))
+	return &SQLiteCertificateRepository{
+		db:           db,
+		path:         path,
+		version:      version,
+		keyProcessor: keyProcessor,
+		parser:       &certificateParser{keyProcessor: keyProcessor},
+		passwords:    passwords,
+		encryption:   encryption,
+	}, nil
+}
+
+// GetByNIT retrieves the preferred active certificate of a NIT that is valid now
+func (r *SQLiteCertificateRepository) GetByNIT(ctx context.Context, nit string) (*models.Certificate, error) {
+	certificates, err := r.activeCertificates(ctx, nit)
+	if err != nil {
+		return nil, err
+	}
+	return currentCertificate(nit, certificates)
+}
+
+// GetByID retrieves a specific certificate of a NIT, which must be active and valid now
+func (r *SQLiteCertificateRepository) GetByID(ctx context.Context, nit string, id string) (*models.Certificate, error) {
+	certificates, err := r.activeCertificates(ctx, nit)
+	if err != nil {
+		return nil, err
+	}
+	return pinnedCertificate(nit, id, certificates)
+}
+
+// ListByNIT retrieves every active certificate of a NIT regardless of its validity window.
+// The certificates valid now come first, each group in preference order.
+func (r *SQLiteCertificateRepository) ListByNIT(ctx context.Context, nit string) ([]*models.Certificate, error) {
+	certificates, err := r.activeCertificates(ctx, nit)
+	if err != nil {
+		return nil, err
+	}
+	return verificationOrder(certificates), nil
+}
+
+// VerifyPassword checks if the password is valid for the certificate
+func (r *SQLiteCertificateRepository) VerifyPassword(ctx context.Context, certificate *models.Certificate, password string) (bool, error) {
+	valid, err := r.keyProcessor.VerifyPassword(password, certificate.PrivateKey.Password)
+	if err != nil {
+		logs.Error("Failed to verify password:", err)
+		return false, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+
+	return valid, nil
+}
+
+// VerifyPublicPassword checks if the password is valid for the certificate public key
+func (r *SQLiteCertificateRepository) VerifyPublicPassword(ctx context.Context, certificate *models.Certificate, password string) (bool, error) {
+	valid, err := r.keyProcessor.VerifyPassword(password, certificate.PublicKey.Password)
+	if err != nil {
+		logs.Error("Failed to verify public key password:", err)
+		return false, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+
+	return valid, nil
+}
+
+// List returns a summary of every stored certificate
+func (r *SQLiteCertificateRepository) List(ctx context.Context) ([]models.CertificateSummary, error) {
+	rows, err := r.queryRows(ctx, `SELECT `+certificateColumns+` FROM certificates ORDER BY nit, id`)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]models.CertificateSummary, 0, len(rows))
+	for start := 0; start < len(rows); {
+		// Rows are grouped by NIT, the selected certificate is computed per group
+		end := start
+		for end < len(rows) && rows[end].nit == rows[start].nit {
+			end++
+		}
+		entries := r.entries(rows[start:end])
+		selected := selectedCertificate(entries)
+		for i, entry := range entries {
+			summary := describeCertificate(rows[start+i].nit, "", entry.certificate, entry.err, selected)
+			summary.ID = rows[start+i].id
+			summaries = append(summaries, summary)
+		}
+		start = end
+	}
+	return summaries, nil
+}
+
+// Save validates a Hacienda certificate file and stores it under the NIT and ID it carries.
+// A NIT may hold several certificates; an existing ID is only overwritten when replace is set.
+func (r *SQLiteCertificateRepository) Save(ctx context.Context, content []byte, replace bool) (*models.CertificateSummary, error) {
+	// 1: Validate the certificate before touching the database
+	certificate, err := r.parser.parseHacienda(content)
+	if err != nil {
+		return nil, err
+	}
+	if !models.IsValidNIT(certificate.NIT) {
+		return nil, domainErrors.NewDomainError("invalid_nit", domainErrors.CodeInvalid)
+	}
+
+	// 2: Insert the certificate, or replace it when requested
+	sealed, err := sealCertificate(r.encryption, content)
+	if err != nil {
+		return nil, err
+	}
+	err = r.withTx(ctx, func(tx *sql.Tx) error {
+		var count int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM certificates WHERE nit = ? AND id = ?`,
+			certificate.NIT, certificate.ID).Scan(&count); err != nil {
+			return err
+		}
+
+		now := formatTime(time.Now())
+		if count == 0 {
+			_, err := tx.ExecContext(ctx, `INSERT INTO certificates (nit, id, format, content, active, created_at, updated_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?)`, certificate.NIT, certificate.ID, formatHacienda, sealed, certificate.IsActive(), now, now)
+			return err
+		}
+		if !replace {
+			return domainErrors.NewDomainError("certificate_exists", domainErrors.CodeCertificateExists)
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE certificates SET format = ?, content = ?, active = ?, updated_at = ? WHERE nit = ? AND id = ?`,
+			formatHacienda, sealed, certificate.IsActive(), now, certificate.NIT, certificate.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	logs.Info("Certificate stored for NIT:", certificate.NIT)
+	r.notify(certificate.NIT)
+
+	return r.summary(ctx, certificate.NIT, certificate.ID)
+}
+
+// SetActive activates or deactivates a certificate of a NIT
+func (r *SQLiteCertificateRepository) SetActive(ctx context.Context, nit string, id string, active bool) (*models.CertificateSummary, error) {
+	id, err := r.update(ctx, nit, id, `UPDATE certificates SET active = ?, updated_at = ? WHERE nit = ? AND id = ?`, active)
+	if err != nil {
+		return nil, err
+	}
+	logs.Info("Certificate active flag updated for NIT:", nit)
+
+	return r.summary(ctx, nit, id)
+}
+
+// SetExpiry sets or, when nil, clears the expiry date of a certificate of a NIT
+func (r *SQLiteCertificateRepository) SetExpiry(ctx context.Context, nit string, id string, expiresAt *time.Time) (*models.CertificateSummary, error) {
+	var value sql.NullString
+	if expiresAt != nil {
+		value = formatTime(*expiresAt)
+	}
+	id, err := r.update(ctx, nit, id, `UPDATE certificates SET expires_at = ?, updated_at = ? WHERE nit = ? AND id = ?`, value)
+	if err != nil {
+		return nil, err
+	}
+	logs.Info("Certificate expiry updated for NIT:", nit, id)
+
+	return r.summary(ctx, nit, id)
+}
+
+// Delete removes a certificate of a NIT
+func (r *SQLiteCertificateRepository) Delete(ctx context.Context, nit string, id string) error {
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		target, err := targetID(ctx, tx, nit, id)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM certificates WHERE nit = ? AND id = ?`, nit, target)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	logs.Info("Certificate deleted for NIT:", nit)
+	r.notify(nit)
+	return nil
+}
+
+// Import copies the certificates of a certificates directory into the database, along with the
+// metadata of their manifests. Certificates already stored are left untouched.
+func (r *SQLiteCertificateRepository) Import(ctx context.Context, source *FileCertificateRepository) (int, error) {
+	nits, err := source.certificateNITs()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list certificates directory: %w", err)
+	}
+
+	imported := 0
+	for _, nit := range nits {
+		for _, entry := range source.loadNIT(nit) {
+			if entry.err != nil {
+				logs.Warn("Skipping invalid certificate file:", source.relativePath(entry.file))
+				continue
+			}
+			stored, err := r.importEntry(ctx, source, nit, entry)
+			if err != nil {
+				return imported, fmt.Errorf("failed to import %s: %w", source.relativePath(entry.file), err)
+			}
+			if stored {
+				imported++
+			}
+		}
+	}
+
+	logs.Info(fmt.Sprintf("Imported %d certificate(s) from %s", imported, source.basePath))
+	return imported, nil
+}
+
+// Reseal encrypts every plaintext certificate and wraps the data key of the encrypted ones with
+// the active master key, returning the number of certificates rewritten
+func (r *SQLiteCertificateRepository) Reseal(ctx context.Context) (int, error) {
+	if r.encryption == nil {
+		return 0, fmt.Errorf("certificate encryption is not configured")
+	}
+
+	resealed := 0
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		rows, err := queryCertificateRows(ctx, tx, `SELECT `+certificateColumns+` FROM certificates ORDER BY nit, id`)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			sealed, changed, err := resealCertificate(ctx, r.encryption, row.content)
+			if err != nil {
+				return fmt.Errorf("failed to encrypt certificate %s of NIT %s: %w", row.id, row.nit, err)
+			}
+			if !changed {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, `UPDATE certificates SET content = ?, updated_at = ? WHERE nit = ? AND id = ?`,
+				sealed, formatTime(time.Now()), row.nit, row.id); err != nil {
+				return err
+			}
+			resealed++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return resealed, nil
+}
+
+// OnChange registers a listener called with the NIT of every certificate changed through this repository
+// and, once Watch is called, of the certificates changed by other replicas sharing the database
+func (r *SQLiteCertificateRepository) OnChange(listener func(nit string)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.listeners = append(r.listeners, listener)
+}
+
+// Watch polls the database every interval until Close is called, notifying the NIT of the certificates
+// added, changed or removed since the previous poll, including the changes made by other replicas.
+// Changes made through this repository are notified right away and, again, by the next poll.
+func (r *SQLiteCertificateRepository) Watch(ctx context.Context, interval time.Duration) error {
+	versions, err := r.certificateVersions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read certificate versions: %w", err)
+	}
+
+	pollCtx, stop := context.WithCancel(context.Background())
+	r.mutex.Lock()
+	if r.stop != nil {
+		r.mutex.Unlock()
+		stop()
+		return fmt.Errorf("certificates database is already watched")
+	}
+	r.versions = versions
+	r.stop = stop
+	r.stopped = make(chan struct{})
+	r.mutex.Unlock()
+
+	go r.poll(pollCtx, interval, r.stopped)
+	logs.Info(fmt.Sprintf("Polling certificates database every %s", interval))
+	return nil
+}
+
+// Close stops polling and closes the database
+func (r *SQLiteCertificateRepository) Close() error {
+	r.mutex.Lock()
+	stop, stopped := r.stop, r.stopped
+	r.stop = nil
+	r.mutex.Unlock()
+
+	if stop != nil {
+		stop()
+		<-stopped
+	}
+	return r.db.Close()
+}
+
+// HealthName returns the key under which the database is reported in the health check
+func (r *SQLiteCertificateRepository) HealthName() string {
+	return "certificateDatabase"
+}
+
+// HealthDetails returns the state of the database for the health check
+func (r *SQLiteCertificateRepository) HealthDetails(ctx context.Context) interface{} {
+	details := map[string]interface{}{
+		"driver":        "sqlite",
+		"path":          r.path,
+		"schemaVersion": r.version,
+	}
+
+	var certificates, nits int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*), COUNT(DISTINCT nit) FROM certificates`).Scan(&certificates, &nits)
+	if err != nil {
+		details["error"] = err.Error()
+		return details
+	}
+	details["certificates"] = certificates
+	details["nits"] = nits
+	return details
+}
+
+// activeCertificates returns the active certificates of a NIT
+func (r *SQLiteCertificateRepository) activeCertificates(ctx context.Context, nit string) ([]*models.Certificate, error) {
+	rows, err := r.queryRows(ctx, `SELECT `+certificateColumns+` FROM certificates WHERE nit = ?`, nit)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		logs.Error("Certificate does not exist for NIT:", nit)
+		return nil, domainErrors.NewDomainError("cert_not_found", domainErrors.CodeCertNotFound)
+	}
+	return activeCertificates(r.entries(rows))
+}
+
+// entries decodes certificate rows into entries, keeping the error of the ones that cannot be decoded
+func (r *SQLiteCertificateRepository) entries(rows []certificateRow) []*certificateEntry {
+	entries := make([]*certificateEntry, 0, len(rows))
+	for _, row := range rows {
+		certificate, err := r.decode(row)
+		if err != nil {
+			logs.Warn(fmt.Sprintf("Invalid stored certificate %s of NIT %s: %v", row.id, row.nit, err))
+		}
+		entries = append(entries, &certificateEntry{file: row.id, certificate: certificate, err: err, loadedAt: time.Now()})
+	}
+	return entries
+}
+
+// decode parses the content of a certificate row and applies its metadata
+func (r *SQLiteCertificateRepository) decode(row certificateRow) (*models.Certificate, error) {
+	content, err := openCertificate(r.encryption, row.content)
+	if err != nil {
+		return nil, err
+	}
+
+	var certificate *models.Certificate
+	if row.format == formatHacienda {
+		certificate, err = r.parser.parseHacienda(content)
+	} else {
+		password, ok := r.keyPassword(row.nit, row.id)
+		if !ok {
+			return nil, domainErrors.NewDomainError("key_password_missing", domainErrors.CodeInvalid)
+		}
+		certificate, err = r.parser.parseKey(row.nit, row.format, content, password)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The columns prevail over the content of the certificate
+	certificate.ID = row.id
+	certificate.NIT = row.nit
+	certificate.Active = row.active
+	certificate.NotBefore = parseTime(row.notBefore)
+	certificate.NotAfter = parseTime(row.notAfter)
+	certificate.Priority = row.priority
+	if row.expiresAt.Valid {
+		certificate.ExpiresAt = parseTime(row.expiresAt)
+	}
+	return certificate, nil
+}
+
+// keyPassword returns the password of a stored key: the one configured for the certificate
+// (<nit>/<id>) or, failing that, the one of the NIT
+func (r *SQLiteCertificateRepository) keyPassword(nit string, id string) (string, bool) {
+	if password, ok := r.passwords[strings.ToLower(nit+"/"+id)]; ok {
+		return password, true
+	}
+	password, ok := r.passwords[nit]
+	return password, ok
+}
+
+// importEntry stores a certificate loaded from a certificates directory unless it already exists
+func (r *SQLiteCertificateRepository) importEntry(ctx context.Context, source *FileCertificateRepository, nit string, entry *certificateEntry) (bool, error) {
+	content, err := source.readCertificate(entry.file)
+	if err != nil {
+		return false, err
+	}
+	sealed, err := sealCertificate(r.encryption, content)
+	if err != nil {
+		return false, err
+	}
+
+	// Stored keys find their password under their ID instead of their file name
+	certificate := entry.certificate
+	format := detectCertificateFormat(entry.file, content)
+	if format != formatHacienda {
+		if _, ok := r.keyPassword(nit, certificate.ID); !ok {
+			logs.Warn(fmt.Sprintf("Configure the password of %s under the key %s/%s", filepath.Base(entry.file), nit, certificate.ID))
+		}
+	}
+
+	now := formatTime(time.Now())
+	result, err := r.db.ExecContext(ctx, `INSERT OR IGNORE INTO certificates
+		(nit, id, format, content, active, not_before, not_after, priority, expires_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		nit, certificate.ID, format, sealed, certificate.IsActive(), formatTime(certificate.NotBefore),
+		formatTime(certificate.NotAfter), certificate.Priority, formatTime(certificate.ExpiresAt), now, now)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// update changes a certificate of a NIT, targeted by its ID or as its only certificate, and returns its ID
+func (r *SQLiteCertificateRepository) update(ctx context.Context, nit string, id string, statement string, value interface{}) (string, error) {
+	var target string
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		target, err = targetID(ctx, tx, nit, id)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, statement, value, formatTime(time.Now()), nit, target)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	r.notify(nit)
+	return target, nil
+}
+
+// summary describes a stored certificate
+func (r *SQLiteCertificateRepository) summary(ctx context.Context, nit string, id string) (*models.CertificateSummary, error) {
+	rows, err := r.queryRows(ctx, `SELECT `+certificateColumns+` FROM certificates WHERE nit = ?`, nit)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := r.entries(rows)
+	selected := selectedCertificate(entries)
+	for i, row := range rows {
+		if row.id == id {
+			summary := describeCertificate(nit, "", entries[i].certificate, entries[i].err, selected)
+			summary.ID = id
+			return &summary, nil
+		}
+	}
+	return nil, domainErrors.NewDomainError("certificate_id_not_found", domainErrors.CodeCertNotFound)
+}
+
+// queryRows reads the certificate rows returned by a query
+func (r *SQLiteCertificateRepository) queryRows(ctx context.Context, query string, args ...interface{}) ([]certificateRow, error) {
+	return queryCertificateRows(ctx, r.db, query, args...)
+}
+
+// queryer runs queries on the database or within a transaction
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// queryCertificateRows reads the certificate rows returned by a query
+func queryCertificateRows(ctx context.Context, q queryer, query string, args ...interface{}) ([]certificateRow, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		logs.Error("Failed to query certificates:", err)
+		return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+	defer rows.Close()
+
+	var result []certificateRow
+	for rows.Next() {
+		var row certificateRow
+		if err := rows.Scan(&row.nit, &row.id, &row.format, &row.content, &row.active,
+			&row.notBefore, &row.notAfter, &row.priority, &row.expiresAt); err != nil {
+			return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+	return result, nil
+}
+
+// withTx runs a function in a transaction, committing it when the function succeeds. Domain
+// errors are returned as they are, the rest are reported as uncatalogued.
+func (r *SQLiteCertificateRepository) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		if _, ok := err.(domainErrors.DomainError); ok {
+			return err
+		}
+		logs.Error("Failed to update certificates:", err)
+		return domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+	if err := tx.Commit(); err != nil {
+		return domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+	return nil
+}
+
+// poll notifies the certificates changed in the database on every tick until the context ends
+func (r *SQLiteCertificateRepository) poll(ctx context.Context, interval time.Duration, stopped chan struct{}) {
+	defer close(stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// 1: Read the current versions, retrying on the next tick when the database is busy
+		versions, err := r.certificateVersions(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				logs.Warn(fmt.Sprintf("Failed to poll certificates database: %v", err))
+			}
+			continue
+		}
+
+		// 2: Notify every NIT whose certificates differ from the previous poll
+		r.mutex.Lock()
+		previous := r.versions
+		r.versions = versions
+		r.mutex.Unlock()
+		for _, nit := range changedNITs(previous, versions) {
+			logs.Info(fmt.Sprintf("Certificates of NIT %s changed in the database", nit))
+			r.notify(nit)
+		}
+	}
+}
+
+// certificateVersions returns, for each NIT, the IDs and update times of its certificates. An update
+// time is compared for equality only, so the clocks of the replicas writing it need not agree.
+func (r *SQLiteCertificateRepository) certificateVersions(ctx context.Context) (map[string]string, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT nit, id, updated_at FROM certificates ORDER BY nit, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make(map[string]string)
+	for rows.Next() {
+		var nit, id, updatedAt string
+		if err := rows.Scan(&nit, &id, &updatedAt); err != nil {
+			return nil, err
+		}
+		versions[nit] += id + "@" + updatedAt + ";"
+	}
+	return versions, rows.Err()
+}
+
+// changedNITs returns the NITs whose certificate versions differ between two polls, sorted
+func changedNITs(previous map[string]string, current map[string]string) []string {
+	var changed []string
+	for nit, version := range current {
+		if previous[nit] != version {
+			changed = append(changed, nit)
+		}
+	}
+	for nit := range previous {
+		if _, ok := current[nit]; !ok {
+			changed = append(changed, nit)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// notify calls the change listeners with the NIT of a changed certificate
+func (r *SQLiteCertificateRepository) notify(nit string) {
+	r.mutex.RLock()
+	listeners := append([]func(nit string){}, r.listeners...)
+	r.mutex.RUnlock()
+
+	for _, listener := range listeners {
+		listener(nit)
+	}
+}
+
+// targetID returns the ID of the certificate of a NIT targeted by an administration request.
+// The ID may be omitted when the NIT has a single certificate.
+func targetID(ctx context.Context, tx *sql.Tx, nit string, id string) (string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM certificates WHERE nit = ?`, nit)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var stored string
+		if err := rows.Scan(&stored); err != nil {
+			return "", err
+		}
+		ids = append(ids, stored)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	switch {
+	case len(ids) == 0:
+		return "", domainErrors.NewDomainError("cert_not_found", domainErrors.CodeCertNotFound)
+	case id == "" && len(ids) > 1:
+		return "", domainErrors.NewDomainError("certificate_id_required", domainErrors.CodeRequiredData)
+	case id == "":
+		return ids[0], nil
+	}
+	for _, stored := range ids {
+		if stored == id {
+			return id, nil
+		}
+	}
+	return "", domainErrors.NewDomainError("certificate_id_not_found", domainErrors.CodeCertNotFound)
+}
+
+// formatTime stores a time as RFC 3339 text in UTC, or NULL when it is zero
+func formatTime(t time.Time) sql.NullString {
+	if t.IsZero() {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.UTC().Format(time.RFC3339Nano), Valid: true}
+}
+
+// parseTime reads a time stored by formatTime, returning the zero time for NULL
+func parseTime(value sql.NullString) time.Time {
+	if !value.Valid {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, value.String)
+	if err != nil {
+		logs.Warn("Invalid stored time:", value.String)
+		return time.Time{}
+	}
+	return t
+}
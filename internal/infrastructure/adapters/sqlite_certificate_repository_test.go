@@ -0,0 +1,263 @@
+package adapters
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/internal/infrastructure/cypher"
+)
+
+// newTestSQLiteCertificateRepository opens a certificate repository on a database, closing it with the test
+func newTestSQLiteCertificateRepository(t *testing.T, path string) *SQLiteCertificateRepository {
+	t.Helper()
+	repository, err := NewSQLiteCertificateRepository(context.Background(), path, 5*time.Second, cypher.NewKeyProcessor(), nil, nil)
+	if err != nil {
+		t.Fatalf("NewSQLiteCertificateRepository() error = %v", err)
+	}
+	t.Cleanup(func() { repository.Close() })
+	return repository
+}
+
+// waitForChange fails unless a NIT is received from the channel before the timeout
+func waitForChange(t *testing.T, changes <-chan string, want string) {
+	t.Helper()
+	select {
+	case nit := <-changes:
+		if nit != want {
+			t.Errorf("change notified for NIT %s, want %s", nit, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("change of NIT %s not notified", want)
+	}
+}
+
+func TestSQLiteCertificateRepositoryNotifiesChangesOfOtherReplicas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "certificates.db")
+	writer := newTestSQLiteCertificateRepository(t, path)
+	watcher := newTestSQLiteCertificateRepository(t, path)
+	ctx := context.Background()
+	const nit = "06140101780010"
+
+	changes := make(chan string, 10)
+	watcher.OnChange(func(nit string) { changes <- nit })
+	if err := watcher.Watch(ctx, 10*time.Millisecond); err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	// 1: A certificate added by another replica
+	content, _ := haciendaCertificate(t, nit, nit+"-id", true)
+	if _, err := writer.Save(ctx, content, false); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	waitForChange(t, changes, nit)
+
+	// 2: A certificate changed by another replica
+	if _, err := writer.SetActive(ctx, nit, "", false); err != nil {
+		t.Fatalf("SetActive() error = %v", err)
+	}
+	waitForChange(t, changes, nit)
+
+	// 3: A certificate removed by another replica
+	if err := writer.Delete(ctx, nit, ""); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	waitForChange(t, changes, nit)
+
+	// 4: Nothing else is notified while the database does not change
+	select {
+	case nit := <-changes:
+		t.Errorf("change notified for NIT %s without changes", nit)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// 5: Closing stops the polling
+	if err := watcher.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+}
+
+func TestSQLiteCertificateRepositoryStoresCertificates(t *testing.T) {
+	repository := newTestSQLiteCertificateRepository(t, filepath.Join(t.TempDir(), "certificates.db"))
+	ctx := context.Background()
+	const nit = "06140101780010"
+	content, key := haciendaCertificate(t, nit, "first", true)
+
+	// 1: A stored certificate signs for its NIT
+	if _, err := repository.Save(ctx, content, false); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	certificate, err := repository.GetByNIT(ctx, nit)
+	if err != nil || certificate.ID != "first" || !key.Public().(*ecdsa.PublicKey).Equal(certificate.DecodedPrivateKey.Public()) {
+		t.Fatalf("GetByNIT() = %v, %v, want the stored certificate", certificate, err)
+	}
+	if valid, err := repository.VerifyPassword(ctx, certificate, testCertificatePassword); err != nil || !valid {
+		t.Errorf("VerifyPassword() = %v, %v, want true", valid, err)
+	}
+
+	// 2: It is only overwritten when requested
+	_, err = repository.Save(ctx, content, false)
+	if domainErr, ok := err.(domainErrors.DomainError); !ok || domainErr.Code != domainErrors.CodeCertificateExists {
+		t.Errorf("Save() of an existing certificate error = %v, want %s", err, domainErrors.CodeCertificateExists)
+	}
+	if _, err := repository.Save(ctx, content, true); err != nil {
+		t.Errorf("Save() replacing the certificate error = %v", err)
+	}
+
+	// 3: The ID is required to change one of several certificates
+	second, _ := haciendaCertificate(t, nit, "second", true)
+	if _, err := repository.Save(ctx, second, false); err != nil {
+		t.Fatalf("Save() of a second certificate error = %v", err)
+	}
+	if certificates, err := repository.ListByNIT(ctx, nit); err != nil || len(certificates) != 2 {
+		t.Errorf("ListByNIT() = %d certificates, %v, want 2", len(certificates), err)
+	}
+	_, err = repository.SetActive(ctx, nit, "", false)
+	if domainErr, ok := err.(domainErrors.DomainError); !ok || domainErr.Code != domainErrors.CodeRequiredData {
+		t.Errorf("SetActive() without ID error = %v, want %s", err, domainErrors.CodeRequiredData)
+	}
+	_, err = repository.SetActive(ctx, nit, "missing", false)
+	if domainErr, ok := err.(domainErrors.DomainError); !ok || domainErr.Code != domainErrors.CodeCertNotFound {
+		t.Errorf("SetActive() of an unknown ID error = %v, want %s", err, domainErrors.CodeCertNotFound)
+	}
+
+	// 4: Deactivated certificates do not sign, and their metadata is kept
+	if summary, err := repository.SetActive(ctx, nit, "first", false); err != nil || summary.Active {
+		t.Fatalf("SetActive() = %+v, %v, want an inactive certificate", summary, err)
+	}
+	if certificate, err := repository.GetByNIT(ctx, nit); err != nil || certificate.ID != "second" {
+		t.Errorf("GetByNIT() = %v, %v, want the active certificate", certificate, err)
+	}
+	expiresAt := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	summary, err := repository.SetExpiry(ctx, nit, "second", &expiresAt)
+	if err != nil || summary.ExpiresAt == nil || !summary.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("SetExpiry() = %+v, %v, want the expiry date", summary, err)
+	}
+	if summary, err := repository.SetExpiry(ctx, nit, "second", nil); err != nil || summary.ExpiresAt != nil {
+		t.Errorf("SetExpiry(nil) = %+v, %v, want the expiry date cleared", summary, err)
+	}
+	summaries, err := repository.List(ctx)
+	if err != nil || len(summaries) != 2 || summaries[0].ID != "first" || summaries[0].Active || summaries[1].ID != "second" {
+		t.Errorf("List() = %+v, %v, want both certificates", summaries, err)
+	}
+
+	// 5: Deleted certificates are gone
+	if err := repository.Delete(ctx, nit, "second"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := repository.Delete(ctx, nit, ""); err != nil {
+		t.Fatalf("Delete() of the only certificate error = %v", err)
+	}
+	_, err = repository.GetByNIT(ctx, nit)
+	if domainErr, ok := err.(domainErrors.DomainError); !ok || domainErr.Code != domainErrors.CodeCertNotFound {
+		t.Errorf("GetByNIT() after Delete() error = %v, want %s", err, domainErrors.CodeCertNotFound)
+	}
+}
+
+func TestSQLiteCertificateRepositoryImportsCertificatesDirectory(t *testing.T) {
+	const nit = "06140101780010"
+	const otherNIT = "06142803901121"
+	const keyNIT = "06140202780020"
+	basePath := t.TempDir()
+	ctx := context.Background()
+
+	// 1: A single certificate file, a NIT directory with a manifest, a PKCS#12 key and an invalid file
+	single, _ := haciendaCertificate(t, nit, "single", true)
+	if err := os.WriteFile(filepath.Join(basePath, nit+".crt"), single, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(basePath, otherNIT), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"current", "next"} {
+		content, _ := haciendaCertificate(t, otherNIT, id, true)
+		if err := os.WriteFile(filepath.Join(basePath, otherNIT, id+".crt"), content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expiresAt := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+	manifest := fmt.Sprintf(`{"certificates": [{"file": "next.crt", "priority": 5, "expiresAt": %q}]}`, expiresAt.Format(time.RFC3339))
+	if err := os.WriteFile(filepath.Join(basePath, otherNIT, manifestFile), []byte(manifest), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p12, _, _, _ := keyFiles(t, 42, "key-secret")
+	if err := os.WriteFile(filepath.Join(basePath, keyNIT+".p12"), p12, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(basePath, "06140303780030.crt"), []byte("<CertificadoMH>"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	passwords := map[string]string{keyNIT: "key-secret"}
+	source := NewFileCertificateRepository(basePath, cypher.NewKeyProcessor(), passwords, nil)
+
+	// 2: Every valid certificate is imported once
+	repository, err := NewSQLiteCertificateRepository(ctx, filepath.Join(t.TempDir(), "certificates.db"), 5*time.Second, cypher.NewKeyProcessor(), passwords, nil)
+	if err != nil {
+		t.Fatalf("NewSQLiteCertificateRepository() error = %v", err)
+	}
+	t.Cleanup(func() { repository.Close() })
+	if imported, err := repository.Import(ctx, source); err != nil || imported != 4 {
+		t.Fatalf("Import() = %d, %v, want 4 certificates", imported, err)
+	}
+	if imported, err := repository.Import(ctx, source); err != nil || imported != 0 {
+		t.Errorf("Import() again = %d, %v, want the stored certificates left untouched", imported, err)
+	}
+
+	// 3: The imported certificates keep their manifest metadata and key passwords
+	summaries, err := repository.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	byID := make(map[string]models.CertificateSummary, len(summaries))
+	for _, summary := range summaries {
+		byID[summary.ID] = summary
+	}
+	next := byID["next"]
+	if next.NIT != otherNIT || next.Priority != 5 || next.ExpiresAt == nil || !next.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("imported certificate = %+v, want the priority and expiry of its manifest", next)
+	}
+	if summary := byID[keyNIT+"-2a"]; !summary.Valid {
+		t.Errorf("imported key = %+v, want it readable with the password of its NIT", summary)
+	}
+	for _, nit := range []string{nit, otherNIT, keyNIT} {
+		if _, err := repository.GetByNIT(ctx, nit); err != nil {
+			t.Errorf("GetByNIT(%s) after Import() error = %v", nit, err)
+		}
+	}
+}
+
+func TestSQLiteCertificateRepositoryMigratesOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "certificates.db")
+	ctx := context.Background()
+	files, err := fs.Glob(sqliteMigrations, "migrations/*.sql")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("migrations = %v, %v", files, err)
+	}
+
+	// Opening the database again, as another replica would, applies nothing
+	for i := 0; i < 2; i++ {
+		repository := newTestSQLiteCertificateRepository(t, path)
+		details := repository.HealthDetails(ctx).(map[string]interface{})
+		if details["schemaVersion"] != len(files) {
+			t.Errorf("schema version = %v, want %d", details["schemaVersion"], len(files))
+		}
+
+		var applied int
+		if err := repository.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations`).Scan(&applied); err != nil {
+			t.Fatal(err)
+		}
+		if applied != len(files) {
+			t.Errorf("applied %d migrations, want %d", applied, len(files))
+		}
+		if err := repository.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package adapters
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/pkg/logs"
+)
+
+// SQLiteControlNumberRepository implements a control number repository backed by the SQLite
+// database. Every allocation is a single statement, so replicas sharing the database file never
+// receive the same correlative.
+type SQLiteControlNumberRepository struct {
+	db   *sql.DB
+	path string
+}
+
+// NewSQLiteControlNumberRepository opens, creating it when needed, the SQLite database at the given
+// path and applies its pending migrations
+func NewSQLiteControlNumberRepository(ctx context.Context, path string, busyTimeout time.Duration) (*SQLiteControlNumberRepository, error) {
+	db, _, err := openSQLite(ctx, path, busyTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open control numbers database: %w", err)
+	}
+
+	logs.Info("Control numbers database ready:", path)
+	return &SQLiteControlNumberRepository{db: db, path: path}, nil
+}
+
+// Next increments the correlative of a series, starting at 1, and returns it
+func (r *SQLiteControlNumberRepository) Next(ctx context.Context, series models.ControlNumberSeries) (int64, error) {
+	// The update is skipped once the series is exhausted, returning no row
+	var sequence int64
+	err := r.db.QueryRowContext(ctx, `INSERT INTO control_numbers (nit, tipo_dte, establecimiento, punto_venta, sequence, updated_at)
+		VALUES (?, ?, ?, ?, 1, ?)
+		ON CONFLICT (nit, tipo_dte, establecimiento, punto_venta) DO UPDATE
+		SET sequence = control_numbers.sequence + 1, updated_at = excluded.updated_at
+		WHERE control_numbers.sequence < ?
+		RETURNING sequence`,
+		series.NIT, series.TipoDte, series.Establecimiento, series.PuntoVenta, formatTime(time.Now()), models.MaxControlNumberSequence,
+	).Scan(&sequence)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, domainErrors.NewDomainError("numero_control_exhausted", domainErrors.CodeControlNumber)
+	}
+	if err != nil {
+		logs.Error("Failed to allocate control number:", err)
+		return 0, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+	return sequence, nil
+}
+
+// Current returns the last correlative allocated from a series, 0 when none was
+func (r *SQLiteControlNumberRepository) Current(ctx context.Context, series models.ControlNumberSeries) (int64, error) {
+	var sequence int64
+	err := r.db.QueryRowContext(ctx, `SELECT sequence FROM control_numbers
+		WHERE nit = ? AND tipo_dte = ? AND establecimiento = ? AND punto_venta = ?`,
+		series.NIT, series.TipoDte, series.Establecimiento, series.PuntoVenta,
+	).Scan(&sequence)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+	return sequence, nil
+}
+
+// Advance raises the correlative of a series to at least the given one, never lowering it, and returns it
+func (r *SQLiteControlNumberRepository) Advance(ctx context.Context, series models.ControlNumberSeries, sequence int64) (int64, error) {
+	var current int64
+	err := r.db.QueryRowContext(ctx, `INSERT INTO control_numbers (nit, tipo_dte, establecimiento, punto_venta, sequence, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (nit, tipo_dte, establecimiento, punto_venta) DO UPDATE
+		SET sequence = MAX(control_numbers.sequence, excluded.sequence), updated_at = excluded.updated_at
+		RETURNING sequence`,
+		series.NIT, series.TipoDte, series.Establecimiento, series.PuntoVenta, sequence, formatTime(time.Now()),
+	).Scan(&current)
+	if err != nil {
+		logs.Error("Failed to advance control number:", err)
+		return 0, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+	return current, nil
+}
+
+// Release returns the given correlative to its series while it is still the last one allocated, and reports whether it did
+func (r *SQLiteControlNumberRepository) Release(ctx context.Context, series models.ControlNumberSeries, sequence int64) (bool, error) {
+	// The update only matches while no replica allocated or advanced past the correlative
+	result, err := r.db.ExecContext(ctx, `UPDATE control_numbers SET sequence = sequence - 1, updated_at = ?
+		WHERE nit = ? AND tipo_dte = ? AND establecimiento = ? AND punto_venta = ? AND sequence = ? AND sequence > 0`,
+		formatTime(time.Now()), series.NIT, series.TipoDte, series.Establecimiento, series.PuntoVenta, sequence,
+	)
+	if err != nil {
+		logs.Error(fmt.Sprintf("Failed to release control number %d: %v", sequence, err))
+		return false, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+	released, err := result.RowsAffected()
+	if err != nil {
+		return false, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+	return released == 1, nil
+}
+
+// Close closes the database
+func (r *SQLiteControlNumberRepository) Close() error {
+	return r.db.Close()
+}
@@ -0,0 +1,118 @@
+package adapters
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chainedpixel/go-dte-signer/pkg/logs"
+)
+
+// sqliteMigrations holds the schema migrations of the SQLite repository, applied in file name order
+//
+//go:embed migrations/*.sql
+var sqliteMigrations embed.FS
+
+// openSQLite opens, creating it when needed, the SQLite database at the given path and applies its
+// pending migrations, returning the database and its schema version
+func openSQLite(ctx context.Context, path string, busyTimeout time.Duration) (*sql.DB, int, error) {
+	// Writers wait for each other instead of failing, and take the write lock when the transaction begins
+	query := url.Values{}
+	query.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()))
+	query.Add("_txlock", "immediate")
+	db, err := sql.Open("sqlite", "file:"+path+"?"+query.Encode())
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, 0, err
+	}
+
+	version, err := migrateSQLite(ctx, db)
+	if err != nil {
+		db.Close()
+		return nil, 0, err
+	}
+	return db, version, nil
+}
+
+// migrateSQLite applies the pending schema migrations and returns the resulting schema version.
+// Each migration runs in its own transaction, so replicas starting together apply it only once.
+func migrateSQLite(ctx context.Context, db *sql.DB) (int, error) {
+	// 1: Ensure the migrations table exists
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
+		return 0, fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	// 2: List the migrations in version order
+	files, err := fs.Glob(sqliteMigrations, "migrations/*.sql")
+	if err != nil {
+		return 0, err
+	}
+	sort.Strings(files)
+
+	// 3: Apply the ones not applied yet
+	version := 0
+	for _, file := range files {
+		name := strings.TrimPrefix(file, "migrations/")
+		number, err := strconv.Atoi(strings.SplitN(name, "_", 2)[0])
+		if err != nil {
+			return 0, fmt.Errorf("invalid migration name %s", name)
+		}
+
+		applied, err := applyMigration(ctx, db, number, file)
+		if err != nil {
+			return 0, fmt.Errorf("failed to apply migration %s: %w", name, err)
+		}
+		if applied {
+			logs.Info("Applied database migration:", name)
+		}
+		version = number
+	}
+
+	return version, nil
+}
+
+// applyMigration runs a migration unless it was already applied, reporting whether it ran
+func applyMigration(ctx context.Context, db *sql.DB, version int, file string) (bool, error) {
+	statements, err := sqliteMigrations.ReadFile(file)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Check again within the transaction, another replica may have applied it meanwhile
+	var count int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations WHERE version = ?`, version).Scan(&count); err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, string(statements)); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+		version, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
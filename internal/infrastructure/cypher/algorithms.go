@@ -0,0 +1,183 @@
+package cypher
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"fmt"
+	"strings"
+
+	"github.com/go-jose/go-jose/v3"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+)
+
+// Key families understood by the algorithm registry
+const (
+	KeyFamilyRSA     = "rsa"
+	KeyFamilyEC      = "ec"
+	KeyFamilyEd25519 = "ed25519"
+)
+
+// supportedAlgorithms maps every JWS algorithm accepted by the service to its key family
+var supportedAlgorithms = map[jose.SignatureAlgorithm]string{
+	jose.RS256: KeyFamilyRSA,
+	jose.RS384: KeyFamilyRSA,
+	jose.RS512: KeyFamilyRSA,
+	jose.PS256: KeyFamilyRSA,
+	jose.PS384: KeyFamilyRSA,
+	jose.PS512: KeyFamilyRSA,
+	jose.ES256: KeyFamilyEC,
+	jose.ES384: KeyFamilyEC,
+	jose.ES512: KeyFamilyEC,
+	jose.EdDSA: KeyFamilyEd25519,
+}
+
+// curveAlgorithms maps each supported elliptic curve to the only ECDSA algorithm valid for it
+var curveAlgorithms = map[string]jose.SignatureAlgorithm{
+	elliptic.P256().Params().Name: jose.ES256,
+	elliptic.P384().Params().Name: jose.ES384,
+	elliptic.P521().Params().Name: jose.ES512,
+}
+
+// AlgorithmRegistry resolves the JWS signature algorithm used for each certificate
+type AlgorithmRegistry struct {
+	defaults  map[string]jose.SignatureAlgorithm
+	overrides map[string]jose.SignatureAlgorithm
+}
+
+// NewAlgorithmRegistry creates a registry from the per key family defaults and per NIT overrides.
+// Empty values fall back to RS512 for RSA keys, the curve algorithm for EC keys and EdDSA for Ed25519 keys.
+func NewAlgorithmRegistry(defaults map[string]string, overrides map[string]string) (*AlgorithmRegistry, error) {
+	registry := &AlgorithmRegistry{
+		defaults: map[string]jose.SignatureAlgorithm{
+			KeyFamilyRSA:     jose.RS512,
+			KeyFamilyEd25519: jose.EdDSA,
+		},
+		overrides: make(map[string]jose.SignatureAlgorithm),
+	}
+
+	for family, name := range defaults {
+		family = strings.ToLower(family)
+		if name == "" {
+			continue
+		}
+		alg, err := ParseAlgorithm(name)
+		if err != nil {
+			return nil, err
+		}
+		if supportedAlgorithms[alg] != family {
+			return nil, fmt.Errorf("algorithm %s cannot be used with %s keys", alg, family)
+		}
+		registry.defaults[family] = alg
+	}
+
+	for nit, name := range overrides {
+		alg, err := ParseAlgorithm(name)
+		if err != nil {
+			return nil, err
+		}
+		registry.overrides[nit] = alg
+	}
+
+	return registry, nil
+}
+
+// DefaultAlgorithmRegistry creates a registry that signs RSA keys with RS512, as required by Hacienda
+func DefaultAlgorithmRegistry() *AlgorithmRegistry {
+	registry, _ := NewAlgorithmRegistry(nil, nil)
+	return registry
+}
+
+// ParseAlgorithm converts an algorithm name into a supported JWS signature algorithm
+func ParseAlgorithm(name string) (jose.SignatureAlgorithm, error) {
+	for alg := range supportedAlgorithms {
+		if strings.EqualFold(string(alg), name) {
+			return alg, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported signature algorithm: %s", name)
+}
+
+// Resolve returns the signature algorithm to use with the certificate.
+// A NIT override takes precedence, then a JWS algorithm named in the private key
+// algorithm field, and finally the default configured for the key family.
+func (r *AlgorithmRegistry) Resolve(certificate *models.Certificate) (jose.SignatureAlgorithm, error) {
+	if certificate.DecodedPrivateKey == nil {
+		return "", domainErrors.NewDomainError("private key not available", domainErrors.CodeInvalid)
+	}
+	publicKey := certificate.DecodedPrivateKey.Public()
+
+	alg, ok := r.overrides[certificate.NIT]
+	if !ok {
+		alg, ok = r.fromKeyField(certificate.PrivateKey)
+	}
+	if !ok {
+		return r.ForKey(publicKey)
+	}
+
+	if !r.Compatible(alg, publicKey) {
+		return "", domainErrors.NewDomainError("unsupported_algorithm", domainErrors.CodeInvalid)
+	}
+
+	return alg, nil
+}
+
+// ForKey returns the default signature algorithm for the family of the given public key
+func (r *AlgorithmRegistry) ForKey(publicKey crypto.PublicKey) (jose.SignatureAlgorithm, error) {
+	family := KeyFamily(publicKey)
+	if family == "" {
+		return "", domainErrors.NewDomainError("unsupported_algorithm", domainErrors.CodeInvalid)
+	}
+
+	alg, ok := r.defaults[family]
+	if !ok && family == KeyFamilyEC {
+		alg, ok = curveAlgorithms[publicKey.(*ecdsa.PublicKey).Curve.Params().Name]
+	}
+	if !ok || !r.Compatible(alg, publicKey) {
+		return "", domainErrors.NewDomainError("unsupported_algorithm", domainErrors.CodeInvalid)
+	}
+
+	return alg, nil
+}
+
+// Compatible checks whether the algorithm can be used with the given public key
+func (r *AlgorithmRegistry) Compatible(alg jose.SignatureAlgorithm, publicKey crypto.PublicKey) bool {
+	family, ok := supportedAlgorithms[alg]
+	if !ok || family != KeyFamily(publicKey) {
+		return false
+	}
+
+	// ECDSA algorithms are bound to a single curve
+	if ecKey, isEC := publicKey.(*ecdsa.PublicKey); isEC {
+		return curveAlgorithms[ecKey.Curve.Params().Name] == alg
+	}
+
+	return true
+}
+
+// fromKeyField returns the algorithm named in the certificate key, if it is a JWS algorithm
+func (r *AlgorithmRegistry) fromKeyField(key models.Key) (jose.SignatureAlgorithm, bool) {
+	alg, err := ParseAlgorithm(key.Algorithm)
+	if err != nil {
+		return "", false
+	}
+	return alg, true
+}
+
+// KeyFamily returns the key family of a public key, or an empty string if it is not supported
+func KeyFamily(publicKey crypto.PublicKey) string {
+	switch publicKey.(type) {
+	case *rsa.PublicKey:
+		return KeyFamilyRSA
+	case *ecdsa.PublicKey:
+		return KeyFamilyEC
+	case ed25519.PublicKey:
+		return KeyFamilyEd25519
+	default:
+		return ""
+	}
+}
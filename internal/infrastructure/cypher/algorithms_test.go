@@ -0,0 +1,112 @@
+package cypher
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/go-jose/go-jose/v3"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+)
+
+func TestNewAlgorithmRegistryRejectsInvalidConfiguration(t *testing.T) {
+	tests := []struct {
+		name      string
+		defaults  map[string]string
+		overrides map[string]string
+		wantErr   bool
+	}{
+		{name: "no configuration"},
+		{name: "defaults of every family", defaults: map[string]string{"RSA": "ps256", "ec": "ES384", "ed25519": "EdDSA"}},
+		{name: "empty default", defaults: map[string]string{"rsa": ""}},
+		{name: "unknown algorithm", defaults: map[string]string{"rsa": "HS256"}, wantErr: true},
+		{name: "algorithm of another family", defaults: map[string]string{"rsa": "ES256"}, wantErr: true},
+		{name: "unknown family", defaults: map[string]string{"dsa": "RS256"}, wantErr: true},
+		{name: "unknown override", overrides: map[string]string{"06140101780010": "none"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAlgorithmRegistry(tt.defaults, tt.overrides)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewAlgorithmRegistry() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAlgorithmRegistryResolve(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configured, err := NewAlgorithmRegistry(map[string]string{"rsa": "PS512"}, map[string]string{
+		"06140101780010": "RS256",
+		"06142803901121": "ES256",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		registry     *AlgorithmRegistry
+		nit          string
+		key          crypto.Signer
+		keyAlgorithm string
+		want         jose.SignatureAlgorithm
+		wantErr      bool
+	}{
+		{name: "RSA key signs with RS512 by default", registry: DefaultAlgorithmRegistry(), key: rsaKey, keyAlgorithm: "RSA", want: jose.RS512},
+		{name: "EC key signs with the algorithm of its curve", registry: DefaultAlgorithmRegistry(), key: p384Key, want: jose.ES384},
+		{name: "Ed25519 key signs with EdDSA", registry: DefaultAlgorithmRegistry(), key: edKey, want: jose.EdDSA},
+		{name: "algorithm named by the key", registry: DefaultAlgorithmRegistry(), key: rsaKey, keyAlgorithm: "PS384", want: jose.PS384},
+		{name: "algorithm named by the key for another family", registry: DefaultAlgorithmRegistry(), key: rsaKey, keyAlgorithm: "ES256", wantErr: true},
+		{name: "configured family default", registry: configured, key: rsaKey, want: jose.PS512},
+		{name: "NIT override", registry: configured, nit: "06140101780010", key: rsaKey, keyAlgorithm: "PS384", want: jose.RS256},
+		{name: "NIT override for another curve", registry: configured, nit: "06142803901121", key: p384Key, wantErr: true},
+		{name: "NIT override for its curve", registry: configured, nit: "06142803901121", key: p256Key, want: jose.ES256},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certificate := &models.Certificate{
+				NIT:               tt.nit,
+				PrivateKey:        models.Key{Algorithm: tt.keyAlgorithm},
+				DecodedPrivateKey: tt.key,
+			}
+			got, err := tt.registry.Resolve(certificate)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Resolve() = %s, want an error", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Resolve() = %s, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlgorithmRegistryResolveWithoutPrivateKey(t *testing.T) {
+	if _, err := DefaultAlgorithmRegistry().Resolve(&models.Certificate{}); err == nil {
+		t.Error("Resolve() of a certificate without private key succeeded")
+	}
+}
@@ -0,0 +1,201 @@
+package cypher
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// envelopeVersion identifies the envelopes produced by LocalKeyEncryptionService
+const envelopeVersion = "dte-signer/v1"
+
+// dataKeySize is the size of the AES-256 data keys
+const dataKeySize = 32
+
+// envelope is an encrypted content along with its wrapped data key. The wrapped key and the
+// ciphertext carry their GCM nonce as prefix.
+type envelope struct {
+	Version    string `json:"envelope"`
+	KeyID      string `json:"keyId"`
+	WrappedKey []byte `json:"wrappedKey"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// LocalKeyEncryptionService implements envelope encryption with master keys held in memory,
+// loaded from a keyring file or the environment. Contents are sealed with AES-256-GCM data keys,
+// themselves sealed with the active master key.
+type LocalKeyEncryptionService struct {
+	keys   map[string][]byte
+	active string
+}
+
+// NewLocalKeyEncryptionService creates a new key encryption service from a keyring
+func NewLocalKeyEncryptionService(keyring *Keyring) (*LocalKeyEncryptionService, error) {
+	keys, err := keyring.decode()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := keys[keyring.Active]; !ok {
+		return nil, fmt.Errorf("active master key %q not found in keyring", keyring.Active)
+	}
+
+	return &LocalKeyEncryptionService{keys: keys, active: keyring.Active}, nil
+}
+
+// ActiveKeyID returns the ID of the master key wrapping new data keys
+func (s *LocalKeyEncryptionService) ActiveKeyID() string {
+	return s.active
+}
+
+// Encrypt seals a content with a new data key wrapped by the active master key
+func (s *LocalKeyEncryptionService) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	// 1: Generate the data key and seal the content with it
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	ciphertext, err := seal(dataKey, plaintext, []byte(envelopeVersion))
+	if err != nil {
+		return nil, err
+	}
+
+	// 2: Wrap the data key with the active master key
+	wrappedKey, err := seal(s.keys[s.active], dataKey, []byte(s.active))
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(envelope{Version: envelopeVersion, KeyID: s.active, WrappedKey: wrappedKey, Ciphertext: ciphertext})
+}
+
+// Decrypt opens an envelope sealed with any of the known master keys
+func (s *LocalKeyEncryptionService) Decrypt(ctx context.Context, content []byte) ([]byte, error) {
+	sealed, dataKey, err := s.unwrap(content)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := open(dataKey, sealed.Ciphertext, []byte(envelopeVersion))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt content: %w", err)
+	}
+	return plaintext, nil
+}
+
+// Rewrap wraps the data key of an envelope with the active master key, leaving its content untouched
+func (s *LocalKeyEncryptionService) Rewrap(ctx context.Context, content []byte) ([]byte, error) {
+	sealed, dataKey, err := s.unwrap(content)
+	if err != nil {
+		return nil, err
+	}
+	if sealed.KeyID == s.active {
+		return content, nil
+	}
+
+	wrappedKey, err := seal(s.keys[s.active], dataKey, []byte(s.active))
+	if err != nil {
+		return nil, err
+	}
+	sealed.KeyID = s.active
+	sealed.WrappedKey = wrappedKey
+	return json.Marshal(sealed)
+}
+
+// IsEncrypted reports whether a content is an envelope
+func (s *LocalKeyEncryptionService) IsEncrypted(content []byte) bool {
+	return IsEnvelope(content)
+}
+
+// EnvelopeKeyID returns the ID of the master key wrapping the data key of an envelope
+func EnvelopeKeyID(content []byte) (string, error) {
+	sealed, err := decodeEnvelope(content)
+	if err != nil {
+		return "", err
+	}
+	return sealed.KeyID, nil
+}
+
+// IsEnvelope reports whether a content is an envelope, which no certificate format starts like
+func IsEnvelope(content []byte) bool {
+	if !bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		return false
+	}
+	_, err := decodeEnvelope(content)
+	return err == nil
+}
+
+// unwrap decodes an envelope and unwraps its data key
+func (s *LocalKeyEncryptionService) unwrap(content []byte) (*envelope, []byte, error) {
+	sealed, err := decodeEnvelope(content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	masterKey, ok := s.keys[sealed.KeyID]
+	if !ok {
+		return nil, nil, fmt.Errorf("master key %q not found in keyring", sealed.KeyID)
+	}
+	dataKey, err := open(masterKey, sealed.WrappedKey, []byte(sealed.KeyID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unwrap data key with master key %q: %w", sealed.KeyID, err)
+	}
+	return sealed, dataKey, nil
+}
+
+// decodeEnvelope decodes an envelope, checking its version
+func decodeEnvelope(content []byte) (*envelope, error) {
+	var sealed envelope
+	if err := json.Unmarshal(content, &sealed); err != nil {
+		return nil, fmt.Errorf("invalid envelope: %w", err)
+	}
+	if sealed.Version != envelopeVersion {
+		return nil, errors.New("invalid envelope: unsupported version")
+	}
+	if sealed.KeyID == "" || len(sealed.WrappedKey) == 0 || len(sealed.Ciphertext) == 0 {
+		return nil, errors.New("invalid envelope: missing fields")
+	}
+	return &sealed, nil
+}
+
+// seal encrypts a content with AES-GCM, prefixing the random nonce
+func seal(key []byte, plaintext []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts a content sealed by seal
+func open(key []byte, sealed []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+// newGCM creates an AES-GCM cipher for a key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
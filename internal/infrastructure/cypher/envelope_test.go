@@ -0,0 +1,218 @@
+package cypher
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestKeyring returns a keyring with new master keys, the last one being the active one
+func newTestKeyring(t *testing.T, ids ...string) *Keyring {
+	t.Helper()
+	keyring := &Keyring{}
+	for _, id := range ids {
+		if err := keyring.Generate(id); err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+	}
+	return keyring
+}
+
+func newTestKeyEncryptionService(t *testing.T, keyring *Keyring) *LocalKeyEncryptionService {
+	t.Helper()
+	service, err := NewLocalKeyEncryptionService(keyring)
+	if err != nil {
+		t.Fatalf("NewLocalKeyEncryptionService() error = %v", err)
+	}
+	return service
+}
+
+func TestLocalKeyEncryptionServiceRoundTrip(t *testing.T) {
+	service := newTestKeyEncryptionService(t, newTestKeyring(t, "2026-01"))
+	ctx := context.Background()
+	plaintext := []byte("<CertificadoMH><nit>06140101780010</nit></CertificadoMH>")
+
+	sealed, err := service.Encrypt(ctx, plaintext)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if bytes.Contains(sealed, plaintext) || !service.IsEncrypted(sealed) || service.IsEncrypted(plaintext) {
+		t.Fatalf("Encrypt() = %s, want an envelope without the plaintext", sealed)
+	}
+	if keyID, err := EnvelopeKeyID(sealed); err != nil || keyID != "2026-01" {
+		t.Errorf("EnvelopeKeyID() = %q, %v, want the active key", keyID, err)
+	}
+
+	opened, err := service.Decrypt(ctx, sealed)
+	if err != nil || !bytes.Equal(opened, plaintext) {
+		t.Fatalf("Decrypt() = %q, %v, want the plaintext", opened, err)
+	}
+
+	// Every content gets its own data key and nonces
+	again, err := service.Encrypt(ctx, plaintext)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if bytes.Equal(again, sealed) {
+		t.Error("Encrypt() of the same content produced the same envelope")
+	}
+}
+
+func TestLocalKeyEncryptionServiceRejectsTamperedEnvelopes(t *testing.T) {
+	service := newTestKeyEncryptionService(t, newTestKeyring(t, "old", "current"))
+	sealed, err := service.Encrypt(context.Background(), []byte("certificado"))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		tamper  func(e *envelope)
+		wantErr string
+	}{
+		{
+			name:    "flipped ciphertext byte",
+			tamper:  func(e *envelope) { e.Ciphertext[len(e.Ciphertext)-1] ^= 1 },
+			wantErr: "failed to decrypt content",
+		},
+		{
+			name:    "flipped wrapped key byte",
+			tamper:  func(e *envelope) { e.WrappedKey[len(e.WrappedKey)-1] ^= 1 },
+			wantErr: "failed to unwrap data key",
+		},
+		{
+			// The key ID is the additional data of the wrapped key, so another known key cannot open it
+			name:    "key ID of another master key",
+			tamper:  func(e *envelope) { e.KeyID = "old" },
+			wantErr: `failed to unwrap data key with master key "old"`,
+		},
+		{
+			name:    "unknown key ID",
+			tamper:  func(e *envelope) { e.KeyID = "missing" },
+			wantErr: `master key "missing" not found`,
+		},
+		{
+			name:    "truncated ciphertext",
+			tamper:  func(e *envelope) { e.Ciphertext = e.Ciphertext[:4] },
+			wantErr: "ciphertext too short",
+		},
+		{
+			name:    "unsupported version",
+			tamper:  func(e *envelope) { e.Version = "dte-signer/v0" },
+			wantErr: "unsupported version",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered, err := decodeEnvelope(sealed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.tamper(tampered)
+			content, err := json.Marshal(tampered)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := service.Decrypt(context.Background(), content); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Decrypt() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLocalKeyEncryptionServiceRotation(t *testing.T) {
+	ctx := context.Background()
+	keyring := newTestKeyring(t, "2025")
+	sealed, err := newTestKeyEncryptionService(t, keyring).Encrypt(ctx, []byte("certificado"))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	// 1: After the rotation the previous key still opens its envelopes
+	if err := keyring.Generate("2026"); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	rotated := newTestKeyEncryptionService(t, keyring)
+	if rotated.ActiveKeyID() != "2026" {
+		t.Fatalf("ActiveKeyID() = %s, want the new key", rotated.ActiveKeyID())
+	}
+	if opened, err := rotated.Decrypt(ctx, sealed); err != nil || string(opened) != "certificado" {
+		t.Fatalf("Decrypt() of an envelope of the previous key = %q, %v", opened, err)
+	}
+
+	// 2: Rewrapping moves the data key to the new key, leaving the ciphertext untouched
+	rewrapped, err := rotated.Rewrap(ctx, sealed)
+	if err != nil {
+		t.Fatalf("Rewrap() error = %v", err)
+	}
+	before, _ := decodeEnvelope(sealed)
+	after, _ := decodeEnvelope(rewrapped)
+	if after.KeyID != "2026" || !bytes.Equal(after.Ciphertext, before.Ciphertext) {
+		t.Errorf("Rewrap() = key %s, ciphertext changed %t; want key 2026 and the same ciphertext", after.KeyID, !bytes.Equal(after.Ciphertext, before.Ciphertext))
+	}
+	if again, err := rotated.Rewrap(ctx, rewrapped); err != nil || !bytes.Equal(again, rewrapped) {
+		t.Errorf("Rewrap() of an envelope of the active key changed it: %v", err)
+	}
+
+	// 3: Once rewrapped, the previous key is no longer needed
+	current := newTestKeyEncryptionService(t, &Keyring{Active: "2026", Keys: map[string]string{"2026": keyring.Keys["2026"]}})
+	if opened, err := current.Decrypt(ctx, rewrapped); err != nil || string(opened) != "certificado" {
+		t.Errorf("Decrypt() without the previous key = %q, %v", opened, err)
+	}
+	if _, err := current.Decrypt(ctx, sealed); err == nil {
+		t.Error("Decrypt() of an envelope of a removed key succeeded")
+	}
+}
+
+func TestLoadKeyring(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	keyring := newTestKeyring(t, "file")
+	if err := keyring.Write(path); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("keyring file mode = %v, %v, want 0600", info.Mode().Perm(), err)
+	}
+	environment := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, masterKeySize))
+
+	tests := []struct {
+		name       string
+		path       string
+		masterKey  string
+		keyID      string
+		wantActive string
+		wantKeys   int
+		wantErr    bool
+	}{
+		{name: "keyring file", path: path, wantActive: "file", wantKeys: 1},
+		{name: "master key of the environment", masterKey: environment, keyID: "env", wantActive: "env", wantKeys: 1},
+		{name: "keyring file with an additional key", path: path, masterKey: environment, keyID: "env", wantActive: "file", wantKeys: 2},
+		{name: "master key differing from the keyring file", path: path, masterKey: environment, keyID: "file", wantErr: true},
+		{name: "master key without ID", masterKey: environment, wantErr: true},
+		{name: "master key of the wrong size", masterKey: base64.StdEncoding.EncodeToString([]byte("short")), keyID: "env", wantErr: true},
+		{name: "no master key", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loaded, err := LoadKeyring(tt.path, tt.masterKey, tt.keyID)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("LoadKeyring() = %+v, want an error", loaded)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadKeyring() error = %v", err)
+			}
+			if loaded.Active != tt.wantActive || len(loaded.Keys) != tt.wantKeys {
+				t.Errorf("LoadKeyring() = active %s with %d keys, want %s with %d", loaded.Active, len(loaded.Keys), tt.wantActive, tt.wantKeys)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package cypher
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/ports"
+	"github.com/chainedpixel/go-dte-signer/pkg/logs"
+)
+
+// KeyStore provides signing handles for keys held outside the process
+type KeyStore interface {
+	// Signer returns the signing handle of a key by its label
+	Signer(label string) (crypto.Signer, error)
+}
+
+// ExternalKeySigner signs documents with keys held outside the process, such as on a hardware
+// token. Certificates mapped to a key label sign through its handle, the rest with their own key.
+type ExternalKeySigner struct {
+	signer       ports.DocumentSigner
+	keys         KeyStore
+	labels       map[string]string
+	keyProcessor *KeyProcessor
+
+	// wrappers keeps one wrapper per key and NIT, so the signers built for it can be cached
+	mutex    sync.Mutex
+	wrappers map[string]*externalKey
+}
+
+// NewExternalKeySigner creates a new document signer using the keys of a key store. The labels
+// map a NIT, or a single certificate as "<nit>/<_id>", to the label of its key in the store.
+func NewExternalKeySigner(signer ports.DocumentSigner, keys KeyStore, labels map[string]string, keyProcessor *KeyProcessor) *ExternalKeySigner {
+	// Configuration keys are case insensitive
+	normalized := make(map[string]string, len(labels))
+	for key, label := range labels {
+		normalized[strings.ToLower(key)] = label
+	}
+
+	return &ExternalKeySigner{
+		signer:       signer,
+		keys:         keys,
+		labels:       normalized,
+		keyProcessor: keyProcessor,
+		wrappers:     make(map[string]*externalKey),
+	}
+}
+
+// Sign signs a document with the provided certificate
+func (s *ExternalKeySigner) Sign(ctx context.Context, certificate *models.Certificate, documentData interface{}, options models.SigningOptions) (*models.SignedDocument, error) {
+	certificate, err := s.withExternalKey(certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.signer.Sign(ctx, certificate, documentData, options)
+}
+
+// SignMulti signs a document with several certificates, producing one signature per certificate
+func (s *ExternalKeySigner) SignMulti(ctx context.Context, certificates []*models.Certificate, documentData interface{}, options models.SigningOptions) (*models.SignedDocument, error) {
+	resolved := make([]*models.Certificate, 0, len(certificates))
+	for _, certificate := range certificates {
+		certificate, err := s.withExternalKey(certificate)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, certificate)
+	}
+
+	return s.signer.SignMulti(ctx, resolved, documentData, options)
+}
+
+// label returns the key label of a certificate, preferring the one configured for the certificate over the one of its NIT
+func (s *ExternalKeySigner) label(certificate *models.Certificate) (string, bool) {
+	if label, ok := s.labels[strings.ToLower(certificate.NIT+"/"+certificate.ID)]; ok {
+		return label, true
+	}
+	label, ok := s.labels[certificate.NIT]
+	return label, ok
+}
+
+// withExternalKey returns a copy of the certificate signing through the handle of its external key,
+// or the certificate itself when it has none
+func (s *ExternalKeySigner) withExternalKey(certificate *models.Certificate) (*models.Certificate, error) {
+	label, ok := s.label(certificate)
+	if !ok {
+		return certificate, nil
+	}
+
+	// 1: Get the handle of the key
+	handle, err := s.keys.Signer(label)
+	if err != nil {
+		logs.Error(fmt.Sprintf("Signing key %s unavailable for NIT %s: %v", label, certificate.NIT, err))
+		return nil, domainErrors.NewDomainError("signing_key_unavailable", domainErrors.CodeKeyUnavailable)
+	}
+
+	// 2: Check the key belongs to the certificate
+	if publicKey := certificate.VerificationKey(); publicKey != nil && !s.keyProcessor.KeysMatch(handle, publicKey) {
+		logs.Error(fmt.Sprintf("Signing key %s does not match the certificate of NIT %s", label, certificate.NIT))
+		return nil, domainErrors.NewDomainError("public_key_mismatch", domainErrors.CodeNoPublicKey)
+	}
+
+	external := *certificate
+	external.DecodedPrivateKey = s.wrapper(label, certificate.NIT, handle)
+	return &external, nil
+}
+
+// wrapper returns the wrapper of the handle of a key for a NIT, reusing it while the key store returns the same handle
+func (s *ExternalKeySigner) wrapper(label string, nit string, handle crypto.Signer) *externalKey {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := label + "|" + nit
+	if wrapper, ok := s.wrappers[key]; ok && wrapper.Signer == handle {
+		return wrapper
+	}
+	wrapper := &externalKey{Signer: handle, label: label, nit: nit}
+	s.wrappers[key] = wrapper
+	return wrapper
+}
+
+// externalKey is the handle of an external key, reporting its signing failures as an unavailable key
+type externalKey struct {
+	crypto.Signer
+	label string
+	nit   string
+}
+
+// Sign signs a digest with the external key
+func (k *externalKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	signature, err := k.Signer.Sign(rand, digest, opts)
+	if err != nil {
+		logs.Error(fmt.Sprintf("Signing key %s failed for NIT %s: %v", k.label, k.nit, err))
+		return nil, domainErrors.NewDomainError("signing_key_unavailable", domainErrors.CodeKeyUnavailable)
+	}
+	return signature, nil
+}
+
+// contextSigner is implemented by the handles of keys held by a remote service, whose requests follow the context of the signing request
+type contextSigner interface {
+	crypto.Signer
+
+	// SignContext signs a digest, abandoning the request once the context ends
+	SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error)
+}
+
+// requestKey binds an external key whose handle follows the context of the request to that context,
+// or returns nil for any other key
+func requestKey(ctx context.Context, key crypto.Signer) crypto.Signer {
+	external, ok := key.(*externalKey)
+	if !ok {
+		return nil
+	}
+	handle, ok := external.Signer.(contextSigner)
+	if !ok {
+		return nil
+	}
+	return &externalKey{Signer: &boundSigner{handle: handle, ctx: ctx}, label: external.label, nit: external.nit}
+}
+
+// boundSigner signs through a handle with the context of a request
+type boundSigner struct {
+	handle contextSigner
+	ctx    context.Context
+}
+
+// Public returns the public key of the handle
+func (s *boundSigner) Public() crypto.PublicKey {
+	return s.handle.Public()
+}
+
+// Sign signs a digest through the handle with the context of the request
+func (s *boundSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.handle.SignContext(s.ctx, digest, opts)
+}
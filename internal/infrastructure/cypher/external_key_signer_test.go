@@ -0,0 +1,141 @@
+package cypher
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+)
+
+// tokenKey stands for the handle of a key held on a token, which signs without a context
+type tokenKey struct {
+	key *ecdsa.PrivateKey
+}
+
+func (k *tokenKey) Public() crypto.PublicKey {
+	return k.key.Public()
+}
+
+func (k *tokenKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return k.key.Sign(rand, digest, opts)
+}
+
+// tokenKeyStore keeps one handle per label, as the PKCS#11 key store does
+type tokenKeyStore map[string]crypto.Signer
+
+func (s tokenKeyStore) Signer(label string) (crypto.Signer, error) {
+	signer, ok := s[label]
+	if !ok {
+		return nil, errors.New("key not found")
+	}
+	return signer, nil
+}
+
+// removedKey stands for the handle of a key removed from its token after it was found
+type removedKey struct {
+	crypto.Signer
+}
+
+func (k removedKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return nil, errors.New("object handle invalid")
+}
+
+func TestExternalKeySignerCachesTokenSigners(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := NewSignerCache(time.Minute, 10)
+	signer := NewExternalKeySigner(NewJWSSigner(DefaultAlgorithmRegistry(), HeaderPolicy{}, cache),
+		tokenKeyStore{"firma": &tokenKey{key: key}}, map[string]string{"06140101780010": "firma"}, NewKeyProcessor())
+	certificate := &models.Certificate{ID: "cert", NIT: "06140101780010", Active: true, DecodedPublicKey: key.Public()}
+
+	for i := 0; i < 5; i++ {
+		signed, err := signer.Sign(context.Background(), certificate, []byte(`{"a":1}`), models.SigningOptions{})
+		if err != nil {
+			t.Fatalf("Sign() error = %v", err)
+		}
+		object, err := jose.ParseSigned(signed.Serialized)
+		if err != nil {
+			t.Fatalf("ParseSigned() error = %v", err)
+		}
+		if _, err := object.Verify(key.Public()); err != nil {
+			t.Errorf("Verify() error = %v", err)
+		}
+	}
+
+	stats := cache.Stats()
+	if stats.Entries != 1 || stats.Hits != 4 {
+		t.Errorf("signer cache has %d entries and %d hits, want 1 entry and 4 hits", stats.Entries, stats.Hits)
+	}
+}
+
+func TestExternalKeySignerResolvesTheKeyOfACertificate(t *testing.T) {
+	const nit = "06140101780010"
+	keys := make(map[string]*ecdsa.PrivateKey)
+	for _, label := range []string{"nit", "certificate", "own", "removed"} {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[label] = key
+	}
+	store := tokenKeyStore{
+		"nit":         &tokenKey{key: keys["nit"]},
+		"certificate": &tokenKey{key: keys["certificate"]},
+		"removed":     removedKey{keys["removed"]},
+	}
+	labels := map[string]string{
+		nit:              "nit",
+		nit + "/Renewed": "certificate",
+		nit + "/removed": "removed",
+		nit + "/missing": "missing",
+		"06142803901121": "nit",
+	}
+	signer := NewExternalKeySigner(NewJWSSigner(DefaultAlgorithmRegistry(), HeaderPolicy{}, nil), store, labels, NewKeyProcessor())
+
+	tests := []struct {
+		name        string
+		certificate *models.Certificate
+		wantKey     *ecdsa.PrivateKey
+		wantCode    string
+	}{
+		{"key of the NIT", &models.Certificate{ID: "current", NIT: nit, DecodedPublicKey: keys["nit"].Public()}, keys["nit"], ""},
+		{"key of the certificate, case insensitively", &models.Certificate{ID: "renewed", NIT: nit, DecodedPublicKey: keys["certificate"].Public()}, keys["certificate"], ""},
+		{"own key of an unmapped NIT", &models.Certificate{ID: "own", NIT: "06140202780020", DecodedPrivateKey: keys["own"]}, keys["own"], ""},
+		{"key of another certificate", &models.Certificate{ID: "other", NIT: "06142803901121", DecodedPublicKey: keys["own"].Public()}, nil, domainErrors.CodeNoPublicKey},
+		{"key missing from the store", &models.Certificate{ID: "missing", NIT: nit, DecodedPublicKey: keys["nit"].Public()}, nil, domainErrors.CodeKeyUnavailable},
+		{"key failing to sign", &models.Certificate{ID: "removed", NIT: nit, DecodedPublicKey: keys["removed"].Public()}, nil, domainErrors.CodeKeyUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := signer.Sign(context.Background(), tt.certificate, []byte(`{"a":1}`), models.SigningOptions{})
+			if tt.wantCode != "" {
+				if domainErr, ok := err.(domainErrors.DomainError); !ok || domainErr.Code != tt.wantCode {
+					t.Fatalf("Sign() error = %v, want %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+			object, err := jose.ParseSigned(signed.Serialized)
+			if err != nil {
+				t.Fatalf("ParseSigned() error = %v", err)
+			}
+			if _, err := object.Verify(tt.wantKey.Public()); err != nil {
+				t.Errorf("Verify() error = %v, want the document signed with the expected key", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package cypher
+
+import (
+	"crypto"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+)
+
+// thumbprintHeader is the private header carrying the RFC 7638 JWK thumbprint of the verification key.
+// It is not "x5t#S256", which RFC 7515 defines as the digest of an X.509 certificate the signer does not have.
+const thumbprintHeader = "jkt"
+
+// reservedHeaders are protected header parameters controlled by the signer itself. The parameters
+// locating the verification key are among them, so a request cannot point verifiers to another key.
+var reservedHeaders = map[string]bool{
+	"alg":      true,
+	"b64":      true,
+	"crit":     true,
+	"nonce":    true,
+	"jku":      true,
+	"jwk":      true,
+	"kid":      true,
+	"x5u":      true,
+	"x5c":      true,
+	"x5t":      true,
+	"x5t#s256": true,
+	"jkt":      true,
+}
+
+// HeaderPolicy describes the protected headers emitted with every signature
+type HeaderPolicy struct {
+	// IncludeKeyID emits the certificate _id as "kid"
+	IncludeKeyID bool
+	// Type is emitted as "typ" when not empty
+	Type string
+	// IncludeThumbprint emits the RFC 7638 SHA-256 JWK thumbprint of the public key as "jkt"
+	IncludeThumbprint bool
+	// IncludeTimestamp emits the signing time as "iat" (seconds since epoch)
+	IncludeTimestamp bool
+	// Headers are extra headers added to every signature
+	Headers map[string]interface{}
+	// NITHeaders are extra headers added to the signatures of a given NIT
+	NITHeaders map[string]map[string]interface{}
+}
+
+// Validate checks that the policy does not try to override reserved headers
+func (p HeaderPolicy) Validate() error {
+	if err := checkReservedHeaders(p.Headers); err != nil {
+		return err
+	}
+	for nit, headers := range p.NITHeaders {
+		if err := checkReservedHeaders(headers); err != nil {
+			return fmt.Errorf("NIT %s: %w", nit, err)
+		}
+	}
+	return nil
+}
+
+// signerOptions builds the go-jose signer options for a certificate and request
+func (p HeaderPolicy) signerOptions(certificate *models.Certificate, options models.SigningOptions, signedAt time.Time) (*jose.SignerOptions, error) {
+	if err := checkReservedHeaders(options.Headers); err != nil {
+		return nil, domainErrors.NewDomainError("reserved_header", domainErrors.CodeInvalid)
+	}
+
+	signerOptions := &jose.SignerOptions{}
+
+	// Extra headers, from the most generic to the most specific
+	for k, v := range p.Headers {
+		signerOptions.WithHeader(jose.HeaderKey(k), v)
+	}
+	for k, v := range p.NITHeaders[certificate.NIT] {
+		signerOptions.WithHeader(jose.HeaderKey(k), v)
+	}
+	for k, v := range options.Headers {
+		signerOptions.WithHeader(jose.HeaderKey(k), v)
+	}
+
+	// Headers derived from the certificate take precedence over extra headers
+	if p.IncludeKeyID && certificate.ID != "" {
+		signerOptions.WithHeader("kid", certificate.ID)
+	}
+	if p.Type != "" {
+		signerOptions.WithType(jose.ContentType(p.Type))
+	}
+	if p.IncludeThumbprint {
+		thumbprint, err := JWKThumbprint(certificate.VerificationKey())
+		if err != nil {
+			return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeNoPublicKey)
+		}
+		signerOptions.WithHeader(thumbprintHeader, thumbprint)
+	}
+	if p.IncludeTimestamp {
+		signerOptions.WithHeader("iat", signedAt.Unix())
+	}
+
+	return signerOptions, nil
+}
+
+// JWKThumbprint returns the base64url RFC 7638 SHA-256 thumbprint of the JWK of a public key
+func JWKThumbprint(publicKey crypto.PublicKey) (string, error) {
+	digest, err := (&jose.JSONWebKey{Key: publicKey}).Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(digest), nil
+}
+
+// checkReservedHeaders returns an error if any header name is reserved
+func checkReservedHeaders(headers map[string]interface{}) error {
+	for k := range headers {
+		if reservedHeaders[strings.ToLower(k)] {
+			return fmt.Errorf("header %q is reserved", k)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package cypher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+)
+
+func TestHeaderPolicyRejectsReservedHeaders(t *testing.T) {
+	certificate := newTestCertificate(t, "06140101780010", "06140101780010-id")
+	reserved := []string{"alg", "b64", "crit", "nonce", "jku", "jwk", "kid", "x5u", "x5c", "x5t", "x5t#S256", "KID", "X5U"}
+
+	for _, name := range reserved {
+		headers := map[string]interface{}{name: "https://attacker.example/keys"}
+
+		_, err := HeaderPolicy{}.signerOptions(certificate, models.SigningOptions{Headers: headers}, time.Now())
+		if domainErr, ok := err.(domainErrors.DomainError); !ok || domainErr.Message != "reserved_header" {
+			t.Errorf("request header %q error = %v, want reserved_header", name, err)
+		}
+		if err := (HeaderPolicy{Headers: headers}).Validate(); err == nil {
+			t.Errorf("configured header %q accepted, want it reserved", name)
+		}
+		if err := (HeaderPolicy{NITHeaders: map[string]map[string]interface{}{"06140101780010": headers}}).Validate(); err == nil {
+			t.Errorf("configured NIT header %q accepted, want it reserved", name)
+		}
+	}
+
+	headers := map[string]interface{}{"ref": "lote-42", "x5t-custom": true}
+	if _, err := (HeaderPolicy{}).signerOptions(certificate, models.SigningOptions{Headers: headers}, time.Now()); err != nil {
+		t.Errorf("signerOptions() of unreserved headers error = %v", err)
+	}
+}
+
+func TestJWKThumbprint(t *testing.T) {
+	// RFC 7638, section 3.1
+	var key jose.JSONWebKey
+	err := key.UnmarshalJSON([]byte(`{"kty":"RSA","e":"AQAB","alg":"RS256","kid":"2011-04-29",
+		"n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumbprint, err := JWKThumbprint(key.Key)
+	if err != nil {
+		t.Fatalf("JWKThumbprint() error = %v", err)
+	}
+	if thumbprint != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+		t.Errorf("JWKThumbprint() = %s", thumbprint)
+	}
+}
+
+func TestHeaderPolicyEmitsJWKThumbprint(t *testing.T) {
+	certificate := newTestCertificate(t, "06140101780010", "06140101780010-id")
+	signer := NewJWSSigner(DefaultAlgorithmRegistry(), HeaderPolicy{IncludeThumbprint: true}, nil)
+	signed, err := signer.Sign(context.Background(), certificate, []byte(`{"a":1}`), models.SigningOptions{})
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	object, err := jose.ParseSigned(signed.Serialized)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	headers := object.Signatures[0].Protected.ExtraHeaders
+	want, err := JWKThumbprint(certificate.VerificationKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers["jkt"] != want {
+		t.Errorf("jkt = %v, want %s", headers["jkt"], want)
+	}
+	if _, ok := headers["x5t#S256"]; ok {
+		t.Error("x5t#S256 emitted without an X.509 certificate")
+	}
+}
+
+func TestHeaderPolicyEmitsConfiguredHeaders(t *testing.T) {
+	certificate := newTestCertificate(t, "06140101780010", "06140101780010-id")
+	policy := HeaderPolicy{
+		IncludeKeyID:     true,
+		Type:             "JOSE",
+		IncludeTimestamp: true,
+		Headers:          map[string]interface{}{"env": "prod", "ref": "generic", "src": "generic"},
+		NITHeaders: map[string]map[string]interface{}{
+			"06140101780010": {"ref": "nit", "src": "nit"},
+			"06142803901121": {"ref": "other"},
+		},
+	}
+	signer := NewJWSSigner(DefaultAlgorithmRegistry(), policy, nil)
+	before := time.Now().Unix()
+	signed, err := signer.Sign(context.Background(), certificate, []byte(`{"a":1}`), models.SigningOptions{Headers: map[string]interface{}{"ref": "request"}})
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	object, err := jose.ParseSigned(signed.Serialized)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := object.Signatures[0].Protected
+	if header.KeyID != "06140101780010-id" {
+		t.Errorf("kid = %q, want the certificate _id", header.KeyID)
+	}
+	if typ := header.ExtraHeaders[jose.HeaderType]; typ != "JOSE" {
+		t.Errorf("typ = %v, want JOSE", typ)
+	}
+	// The request overrides the NIT headers, which override the generic ones
+	want := map[string]string{"env": "prod", "src": "nit", "ref": "request"}
+	for name, value := range want {
+		if got := header.ExtraHeaders[jose.HeaderKey(name)]; got != value {
+			t.Errorf("%s = %v, want %s", name, got, value)
+		}
+	}
+	iat, ok := header.ExtraHeaders["iat"].(float64)
+	if !ok || int64(iat) < before || int64(iat) > time.Now().Unix() {
+		t.Errorf("iat = %v, want the signing time", header.ExtraHeaders["iat"])
+	}
+}
+
+func TestHeaderPolicyEmitsNoOptionalHeadersByDefault(t *testing.T) {
+	certificate := newTestCertificate(t, "06140101780010", "06140101780010-id")
+	signed, err := NewJWSSigner(DefaultAlgorithmRegistry(), HeaderPolicy{}, nil).Sign(context.Background(), certificate, []byte(`{"a":1}`), models.SigningOptions{})
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	object, err := jose.ParseSigned(signed.Serialized)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := object.Signatures[0].Protected
+	if header.KeyID != "" || len(header.ExtraHeaders) != 0 {
+		t.Errorf("protected header = kid %q, %v, want only alg", header.KeyID, header.ExtraHeaders)
+	}
+}
@@ -2,8 +2,13 @@ package cypher
 
 import (
 	"context"
-	"crypto/rsa"
+	"crypto"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/go-jose/go-jose/v3"
 
 	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
@@ -11,64 +16,244 @@ import (
 )
 
 // JWSSigner handles JWS signing operations
-type JWSSigner struct{}
+type JWSSigner struct {
+	algorithms *AlgorithmRegistry
+	headers    HeaderPolicy
+	signers    *SignerCache
+}
 
-// NewJWSSigner creates a new JWS signer
-func NewJWSSigner() *JWSSigner {
-	return &JWSSigner{}
+// NewJWSSigner creates a new JWS signer that resolves algorithms with the given registry
+// and emits the protected headers described by the header policy. The signer cache is
+// optional; when nil a new go-jose signer is built for every request.
+func NewJWSSigner(algorithms *AlgorithmRegistry, headers HeaderPolicy, signers *SignerCache) *JWSSigner {
+	return &JWSSigner{
+		algorithms: algorithms,
+		headers:    headers,
+		signers:    signers,
+	}
 }
 
 // Sign signs a document with the provided certificate
-func (s *JWSSigner) Sign(ctx context.Context, certificate *models.Certificate, documentData interface{}) (string, error) {
+func (s *JWSSigner) Sign(ctx context.Context, certificate *models.Certificate, documentData interface{}, options models.SigningOptions) (*models.SignedDocument, error) {
+	// Convert document data to bytes if necessary
+	payload, err := documentPayload(documentData)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sign the document
+	signedAt := time.Now()
+	object, err := s.signObject(ctx, certificate, payload, options, signedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Serialize the signature in the requested format
+	serialization := options.Serialization
+	if serialization == "" {
+		serialization = models.SerializationCompact
+	}
+	serialized, err := serializeJWS(object, serialization, options.Detached)
+	if err != nil {
+		return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+
+	return &models.SignedDocument{
+		Serialized:    serialized,
+		Serialization: serialization,
+		Detached:      options.Detached,
+		Unencoded:     options.Unencoded,
+		Payload:       payload,
+		SignedAt:      signedAt,
+		Signatures:    []models.SignatureDetails{signatureDetails(certificate, object)},
+	}, nil
+}
+
+// SignMulti signs a document with several certificates, producing a general JWS JSON
+// with one signature per certificate, each with its own protected headers
+func (s *JWSSigner) SignMulti(ctx context.Context, certificates []*models.Certificate, documentData interface{}, options models.SigningOptions) (*models.SignedDocument, error) {
+	// Only the general JSON serialization can carry several signatures
+	if options.Serialization != "" && options.Serialization != models.SerializationGeneral {
+		return nil, domainErrors.NewDomainError("invalid_signing_options", domainErrors.CodeInvalid)
+	}
+
+	// Convert document data to bytes if necessary
+	payload, err := documentPayload(documentData)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sign the document once per certificate and collect the signatures
+	signedAt := time.Now()
+	objects := make([]*jose.JSONWebSignature, 0, len(certificates))
+	details := make([]models.SignatureDetails, 0, len(certificates))
+	for _, certificate := range certificates {
+		object, err := s.signObject(ctx, certificate, payload, options, signedAt)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, object)
+		details = append(details, signatureDetails(certificate, object))
+	}
+
+	// Merge the signatures into a single general JWS JSON
+	serialized, err := mergeJWS(objects, options.Detached)
+	if err != nil {
+		return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+
+	return &models.SignedDocument{
+		Serialized:    serialized,
+		Serialization: models.SerializationGeneral,
+		Detached:      options.Detached,
+		Unencoded:     options.Unencoded,
+		Payload:       payload,
+		SignedAt:      signedAt,
+		Signatures:    details,
+	}, nil
+}
+
+// signObject signs the payload with a single certificate
+func (s *JWSSigner) signObject(ctx context.Context, certificate *models.Certificate, payload []byte, options models.SigningOptions, signedAt time.Time) (*jose.JSONWebSignature, error) {
 	// Ensure the private key is available
 	if certificate.DecodedPrivateKey == nil {
-		return "", domainErrors.NewDomainError("private key not available", domainErrors.CodeInvalid)
+		return nil, domainErrors.NewDomainError("private key not available", domainErrors.CodeInvalid)
 	}
 
-	// Convert document data to a string if necessary
-	var documentStr string
-	switch v := documentData.(type) {
-	case string:
-		documentStr = v
-	case []byte:
-		documentStr = string(v)
-	default:
-		jsonData, err := json.Marshal(documentData)
+	// Resolve the signature algorithm for the certificate
+	algorithm, err := s.algorithms.Resolve(certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get a signer with the resolved algorithm
+	signer, err := s.signerFor(ctx, certificate, algorithm, options, signedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sign the document
+	object, err := signer.Sign(payload)
+	if err != nil {
+		// External keys report their own failures
+		var domainErr domainErrors.DomainError
+		if errors.As(err, &domainErr) {
+			return nil, domainErr
+		}
+		return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+
+	return object, nil
+}
+
+// signerFor returns a go-jose signer for the certificate, reusing a cached one when the
+// protected headers do not depend on the request or on the signing time. Keys held by a remote
+// service sign with the context of the request, so only the options of their signers are reused.
+func (s *JWSSigner) signerFor(ctx context.Context, certificate *models.Certificate, algorithm jose.SignatureAlgorithm, options models.SigningOptions, signedAt time.Time) (jose.Signer, error) {
+	cacheable := s.signers != nil && !s.headers.IncludeTimestamp && len(options.Headers) == 0
+	key := signerCacheKey{
+		nit:         certificate.NIT,
+		fingerprint: fmt.Sprintf("%s|%s|%t|%p", certificate.ID, algorithm, options.Unencoded, certificate.DecodedPrivateKey),
+	}
+	privateKey := certificate.DecodedPrivateKey
+	bound := requestKey(ctx, privateKey)
+	if bound != nil {
+		privateKey = bound
+	}
+
+	var signerOptions *jose.SignerOptions
+	if cacheable {
+		if cached, ok := s.signers.get(key); ok {
+			if bound == nil && cached.signer != nil {
+				return cached.signer, nil
+			}
+			signerOptions = cached.options
+		}
+	}
+
+	// Build the protected headers
+	if signerOptions == nil {
+		built, err := s.headers.signerOptions(certificate, options, signedAt)
 		if err != nil {
-			return "", domainErrors.NewDomainError(err.Error(), domainErrors.CodeJSONToStrConversion)
+			return nil, err
+		}
+		if options.Unencoded {
+			built.WithBase64(false)
+		}
+		signerOptions = built
+		if cacheable && bound != nil {
+			s.signers.set(key, cachedSigner{options: signerOptions})
 		}
-		documentStr = string(jsonData)
 	}
 
-	// Create signer with RSA-SHA512 algorithm
+	// Create signer with the resolved algorithm
 	signer, err := jose.NewSigner(jose.SigningKey{
-		Algorithm: jose.RS512,
-		Key:       certificate.DecodedPrivateKey,
-	}, nil)
+		Algorithm: algorithm,
+		Key:       signingKey(privateKey),
+	}, signerOptions)
 	if err != nil {
-		return "", domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+		return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
 	}
 
-	// Sign the document
-	object, err := signer.Sign([]byte(documentStr))
-	if err != nil {
-		return "", domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	if cacheable && bound == nil {
+		s.signers.set(key, cachedSigner{signer: signer, options: signerOptions})
 	}
+	return signer, nil
+}
 
-	// Serialize the signature
-	serialized, err := object.CompactSerialize()
+// signatureDetails describes the signature produced by a certificate. go-jose only fills the
+// parsed protected header of signatures it parses, so it is decoded from the serialization.
+func signatureDetails(certificate *models.Certificate, object *jose.JSONWebSignature) models.SignatureDetails {
+	details := models.SignatureDetails{
+		NIT:           certificate.NIT,
+		CertificateID: certificate.ID,
+	}
+
+	var flattened struct {
+		Protected string `json:"protected"`
+	}
+	if err := json.Unmarshal([]byte(object.FullSerialize()), &flattened); err != nil {
+		return details
+	}
+	encoded, err := base64.RawURLEncoding.DecodeString(flattened.Protected)
 	if err != nil {
-		return "", domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+		return details
+	}
+	if err := json.Unmarshal(encoded, &details.Header); err != nil {
+		return details
 	}
 
-	return serialized, nil
+	details.Algorithm, _ = details.Header["alg"].(string)
+	details.KeyID, _ = details.Header["kid"].(string)
+	return details
+}
+
+// documentPayload converts document data to the bytes to sign
+func documentPayload(documentData interface{}) ([]byte, error) {
+	switch v := documentData.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	default:
+		jsonData, err := json.Marshal(documentData)
+		if err != nil {
+			return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeJSONToStrConversion)
+		}
+		return jsonData, nil
+	}
 }
 
-// SignWithPrivateKey signs data with a raw private key
-func (s *JWSSigner) SignWithPrivateKey(data string, privateKey *rsa.PrivateKey) (string, error) {
+// SignWithPrivateKey signs data with a raw private key using the default algorithm for its key family
+func (s *JWSSigner) SignWithPrivateKey(data string, privateKey crypto.Signer) (string, error) {
+	algorithm, err := s.algorithms.ForKey(privateKey.Public())
+	if err != nil {
+		return "", err
+	}
+
 	signer, err := jose.NewSigner(jose.SigningKey{
-		Algorithm: jose.RS512,
-		Key:       privateKey,
+		Algorithm: algorithm,
+		Key:       signingKey(privateKey),
 	}, nil)
 	if err != nil {
 		return "", err
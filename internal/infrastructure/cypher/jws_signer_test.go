@@ -0,0 +1,57 @@
+package cypher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+)
+
+func TestJWSSignerDescribesEverySignature(t *testing.T) {
+	ctx := context.Background()
+	first := newTestCertificate(t, "06140101780010", "first-id")
+	second := newTestCertificate(t, "06142803901121", "second-id")
+	signer := NewJWSSigner(DefaultAlgorithmRegistry(), HeaderPolicy{IncludeKeyID: true, Type: "JOSE"}, nil)
+	payload := []byte(`{"a":1}`)
+
+	before := time.Now()
+	signed, err := signer.SignMulti(ctx, []*models.Certificate{first, second}, payload, models.SigningOptions{})
+	if err != nil {
+		t.Fatalf("SignMulti() error = %v", err)
+	}
+	if signed.SignedAt.Before(before) || signed.SignedAt.After(time.Now()) {
+		t.Errorf("SignedAt = %s, want the signing time", signed.SignedAt)
+	}
+	if string(signed.Payload) != string(payload) {
+		t.Errorf("Payload = %s, want the signed bytes", signed.Payload)
+	}
+
+	// The details follow the order of the signatures in the JWS
+	if len(signed.Signatures) != 2 {
+		t.Fatalf("Signatures = %+v, want one per certificate", signed.Signatures)
+	}
+	for i, certificate := range []*models.Certificate{first, second} {
+		details := signed.Signatures[i]
+		if details.NIT != certificate.NIT || details.CertificateID != certificate.ID || details.KeyID != certificate.ID {
+			t.Errorf("signature %d = %+v, want the NIT and _id of %s", i, details, certificate.ID)
+		}
+		if details.Algorithm != "ES256" || details.Header["alg"] != "ES256" || details.Header["typ"] != "JOSE" {
+			t.Errorf("signature %d header = %s %v, want the protected header", i, details.Algorithm, details.Header)
+		}
+	}
+
+	// The details match what a verifier reads from the JWS
+	result, err := NewJWSVerifier(DefaultAlgorithmRegistry()).VerifyMulti(ctx, [][]*models.Certificate{{first}, {second}}, signed.Serialized, nil)
+	if err != nil {
+		t.Fatalf("VerifyMulti() error = %v", err)
+	}
+	if len(result.Signatures) != len(signed.Signatures) {
+		t.Fatalf("verified signatures = %+v, want %d", result.Signatures, len(signed.Signatures))
+	}
+	for i, details := range result.Signatures {
+		if details.Algorithm != signed.Signatures[i].Algorithm || details.KeyID != signed.Signatures[i].KeyID {
+			t.Errorf("verified signature %d = %+v, want %+v", i, details, signed.Signatures[i])
+		}
+	}
+}
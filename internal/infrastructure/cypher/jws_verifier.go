@@ -0,0 +1,157 @@
+package cypher
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-jose/go-jose/v3"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+)
+
+// JWSVerifier handles JWS verification operations
+type JWSVerifier struct {
+	algorithms *AlgorithmRegistry
+}
+
+// NewJWSVerifier creates a new JWS verifier that checks algorithms with the given registry
+func NewJWSVerifier(algorithms *AlgorithmRegistry) *JWSVerifier {
+	return &JWSVerifier{
+		algorithms: algorithms,
+	}
+}
+
+// Verify verifies a compact, flattened or general JWS with the provided certificate.
+// The detached payload is only used when the serialized JWS does not carry its payload.
+func (v *JWSVerifier) Verify(ctx context.Context, certificate *models.Certificate, serialized string, detachedPayload []byte) (*models.VerificationResult, error) {
+	// Ensure the key material is available
+	publicKey := certificate.VerificationKey()
+	if publicKey == nil {
+		return nil, domainErrors.NewDomainError("no_public_key", domainErrors.CodeNoPublicKey)
+	}
+
+	// Parse the serialized JWS
+	object, serialization, detached, err := parseJWS(serialized, detachedPayload)
+	if err != nil {
+		return nil, domainErrors.NewDomainError("malformed_jws", domainErrors.CodeInvalid)
+	}
+	if len(object.Signatures) != 1 {
+		// Multi-signer documents must be verified against all their signers
+		return nil, domainErrors.NewDomainError("signature_invalid", domainErrors.CodeSignatureInvalid)
+	}
+
+	// Reject algorithms that are not supported for the certificate key
+	header := object.Signatures[0].Protected
+	if !v.algorithms.Compatible(jose.SignatureAlgorithm(header.Algorithm), publicKey) {
+		return nil, domainErrors.NewDomainError("unsupported_algorithm", domainErrors.CodeInvalid)
+	}
+
+	// Verify the signature with the certificate public key
+	payload, err := object.Verify(publicKey)
+	if err != nil {
+		return nil, domainErrors.NewDomainError("signature_invalid", domainErrors.CodeSignatureInvalid)
+	}
+
+	return &models.VerificationResult{
+		Valid:         true,
+		NIT:           certificate.NIT,
+		Algorithm:     header.Algorithm,
+		KeyID:         header.KeyID,
+		Serialization: serialization,
+		Detached:      detached,
+		Header:        headerToMap(header),
+		Payload:       decodePayload(payload),
+	}, nil
+}
+
+// VerifyMulti verifies a JWS signed by several signers, each given as its candidate certificates.
+// Every signature must verify with a certificate of one signer and every signer must have signed the document.
+func (v *JWSVerifier) VerifyMulti(ctx context.Context, signers [][]*models.Certificate, serialized string, detachedPayload []byte) (*models.VerificationResult, error) {
+	// Parse the serialized JWS
+	object, serialization, detached, err := parseJWS(serialized, detachedPayload)
+	if err != nil {
+		return nil, domainErrors.NewDomainError("malformed_jws", domainErrors.CodeInvalid)
+	}
+	if len(object.Signatures) != len(signers) {
+		return nil, domainErrors.NewDomainError("signature_invalid", domainErrors.CodeSignatureInvalid)
+	}
+
+	// Match every signer with the signature one of its certificates produced
+	details := make([]models.SignatureDetails, len(object.Signatures))
+	matched := make([]bool, len(object.Signatures))
+	var payload []byte
+	for _, candidates := range signers {
+		index, certificate, verifiedPayload, err := v.matchSigner(object, candidates, matched)
+		if err != nil {
+			return nil, err
+		}
+
+		header := object.Signatures[index].Protected
+		matched[index] = true
+		payload = verifiedPayload
+		details[index] = models.SignatureDetails{
+			NIT:           certificate.NIT,
+			CertificateID: certificate.ID,
+			Algorithm:     header.Algorithm,
+			KeyID:         header.KeyID,
+			Header:        headerToMap(header),
+		}
+	}
+
+	first := details[0]
+	return &models.VerificationResult{
+		Valid:         true,
+		NIT:           first.NIT,
+		Algorithm:     first.Algorithm,
+		KeyID:         first.KeyID,
+		Serialization: serialization,
+		Detached:      detached,
+		Header:        first.Header,
+		Payload:       decodePayload(payload),
+		Signatures:    details,
+	}, nil
+}
+
+// matchSigner returns the first unmatched signature produced by one of the candidate certificates of a
+// signer, trying them in preference order. The error of the preferred certificate is returned when none matches.
+func (v *JWSVerifier) matchSigner(object *jose.JSONWebSignature, candidates []*models.Certificate, matched []bool) (int, *models.Certificate, []byte, error) {
+	firstErr := domainErrors.NewDomainError("signature_invalid", domainErrors.CodeSignatureInvalid)
+	for i, certificate := range candidates {
+		err := domainErrors.NewDomainError("signature_invalid", domainErrors.CodeSignatureInvalid)
+		publicKey := certificate.VerificationKey()
+		if publicKey == nil {
+			err = domainErrors.NewDomainError("no_public_key", domainErrors.CodeNoPublicKey)
+		} else if index, signature, verifiedPayload, verifyErr := object.VerifyMulti(publicKey); verifyErr == nil && !matched[index] {
+			if v.algorithms.Compatible(jose.SignatureAlgorithm(signature.Protected.Algorithm), publicKey) {
+				return index, certificate, verifiedPayload, nil
+			}
+			err = domainErrors.NewDomainError("unsupported_algorithm", domainErrors.CodeInvalid)
+		}
+		if i == 0 {
+			firstErr = err
+		}
+	}
+	return 0, nil, nil, firstErr
+}
+
+// headerToMap flattens a JOSE header into a generic map
+func headerToMap(header jose.Header) map[string]interface{} {
+	result := map[string]interface{}{
+		"alg": header.Algorithm,
+	}
+	if header.KeyID != "" {
+		result["kid"] = header.KeyID
+	}
+	for k, v := range header.ExtraHeaders {
+		result[string(k)] = v
+	}
+	return result
+}
+
+// decodePayload returns the payload as raw JSON when possible, otherwise as a string
+func decodePayload(payload []byte) interface{} {
+	if json.Valid(payload) {
+		return json.RawMessage(payload)
+	}
+	return string(payload)
+}
@@ -0,0 +1,75 @@
+package cypher
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+)
+
+// newTestCertificate returns an active certificate of the NIT with a fresh P-256 key
+func newTestCertificate(t *testing.T, nit, id string) *models.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &models.Certificate{ID: id, NIT: nit, Active: true, DecodedPrivateKey: key}
+}
+
+func TestJWSVerifierVerifyMultiTriesEveryCertificateOfASigner(t *testing.T) {
+	ctx := context.Background()
+	rotated := newTestCertificate(t, "06140101780010", "rotated")
+	current := newTestCertificate(t, "06140101780010", "current")
+	other := newTestCertificate(t, "06142803901121", "other")
+	stranger := newTestCertificate(t, "06142803901121", "stranger")
+
+	// The document was signed with the certificate the first NIT used before its rotation
+	signer := NewJWSSigner(DefaultAlgorithmRegistry(), HeaderPolicy{}, NewSignerCache(time.Minute, 10))
+	signed, err := signer.SignMulti(ctx, []*models.Certificate{rotated, other}, []byte(`{"a":1}`), models.SigningOptions{})
+	if err != nil {
+		t.Fatalf("SignMulti() error = %v", err)
+	}
+
+	verifier := NewJWSVerifier(DefaultAlgorithmRegistry())
+	tests := []struct {
+		name    string
+		signers [][]*models.Certificate
+		wantErr string
+		wantIDs []string
+	}{
+		{"rotated certificate among the candidates", [][]*models.Certificate{{current, rotated}, {other}}, "", []string{"rotated", "other"}},
+		{"signers in another order", [][]*models.Certificate{{other}, {current, rotated}}, "", []string{"rotated", "other"}},
+		{"only the current certificate", [][]*models.Certificate{{current}, {other}}, "signature_invalid", nil},
+		{"a signer that did not sign", [][]*models.Certificate{{current, rotated}, {stranger}}, "signature_invalid", nil},
+		{"the same certificate for both signers", [][]*models.Certificate{{rotated}, {rotated}}, "signature_invalid", nil},
+		{"missing signer", [][]*models.Certificate{{current, rotated}}, "signature_invalid", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := verifier.VerifyMulti(ctx, tt.signers, signed.Serialized, nil)
+			if tt.wantErr != "" {
+				if domainErr, ok := err.(domainErrors.DomainError); !ok || domainErr.Message != tt.wantErr {
+					t.Fatalf("VerifyMulti() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyMulti() error = %v", err)
+			}
+			if !result.Valid || len(result.Signatures) != len(tt.wantIDs) {
+				t.Fatalf("VerifyMulti() = %+v", result)
+			}
+			for i, id := range tt.wantIDs {
+				if result.Signatures[i].CertificateID != id {
+					t.Errorf("signature %d matched certificate %q, want %q", i, result.Signatures[i].CertificateID, id)
+				}
+			}
+		})
+	}
+}
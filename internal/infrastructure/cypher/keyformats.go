@@ -0,0 +1,126 @@
+package cypher
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+
+	"github.com/youmark/pkcs8"
+	"software.sslmate.com/src/go-pkcs12"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+)
+
+// KeyBundle holds the keys imported from a PKCS#12 or PEM file
+type KeyBundle struct {
+	PrivateKey crypto.Signer
+	// Certificate is the X.509 certificate of the key, when the file carries one
+	Certificate *x509.Certificate
+}
+
+// PublicKey returns the public key of the bundle, taken from its certificate when present
+func (b *KeyBundle) PublicKey() crypto.PublicKey {
+	if b.Certificate != nil {
+		return b.Certificate.PublicKey
+	}
+	return b.PrivateKey.Public()
+}
+
+// PKCS12ToKeys decodes a PKCS#12 (.p12/.pfx) file protected with the given password
+func (k *KeyProcessor) PKCS12ToKeys(data []byte, password string) (*KeyBundle, error) {
+	privateKey, certificate, _, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+			return nil, domainErrors.NewDomainError("key_password_invalid", domainErrors.CodeInvalid)
+		}
+		return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+
+	signer, err := supportedSigner(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyBundle{PrivateKey: signer, Certificate: certificate}, nil
+}
+
+// PEMToKeys decodes a PEM file holding a private key, either PKCS#8 (optionally encrypted),
+// PKCS#1 or SEC 1, and optionally its X.509 certificate. The password is only used for encrypted keys.
+func (k *KeyProcessor) PEMToKeys(data []byte, password string) (*KeyBundle, error) {
+	bundle := &KeyBundle{}
+
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case "CERTIFICATE":
+			if bundle.Certificate != nil {
+				// Only the first certificate belongs to the key, the rest is the chain
+				continue
+			}
+			certificate, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+			}
+			bundle.Certificate = certificate
+		case "PRIVATE KEY", "ENCRYPTED PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY":
+			if bundle.PrivateKey != nil {
+				return nil, domainErrors.NewDomainError("multiple private keys", domainErrors.CodeInvalid)
+			}
+			signer, err := pemPrivateKey(block, password)
+			if err != nil {
+				return nil, err
+			}
+			bundle.PrivateKey = signer
+		}
+	}
+
+	if bundle.PrivateKey == nil {
+		return nil, domainErrors.NewDomainError("invalid", domainErrors.CodeInvalid)
+	}
+
+	return bundle, nil
+}
+
+// pemPrivateKey parses the private key of a PEM block
+func pemPrivateKey(block *pem.Block, password string) (crypto.Signer, error) {
+	der := block.Bytes
+
+	// Legacy OpenSSL encryption of PKCS#1 and SEC 1 keys (Proc-Type: 4,ENCRYPTED), still produced by older tooling
+	if x509.IsEncryptedPEMBlock(block) {
+		decrypted, err := x509.DecryptPEMBlock(block, []byte(password))
+		if err != nil {
+			return nil, domainErrors.NewDomainError("key_password_invalid", domainErrors.CodeInvalid)
+		}
+		der = decrypted
+	}
+
+	var privateKey interface{}
+	var err error
+	switch block.Type {
+	case "ENCRYPTED PRIVATE KEY":
+		privateKey, err = pkcs8.ParsePKCS8PrivateKey(der, []byte(password))
+		if err != nil {
+			return nil, domainErrors.NewDomainError("key_password_invalid", domainErrors.CodeInvalid)
+		}
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(der)
+	case "EC PRIVATE KEY":
+		privateKey, err = x509.ParseECPrivateKey(der)
+	default:
+		privateKey, err = x509.ParsePKCS8PrivateKey(der)
+	}
+	if err != nil {
+		return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+
+	return supportedSigner(privateKey)
+}
+
+// supportedSigner checks the private key is an RSA, ECDSA or Ed25519 signer
+func supportedSigner(privateKey interface{}) (crypto.Signer, error) {
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok || KeyFamily(signer.Public()) == "" {
+		return nil, domainErrors.NewDomainError("unsupported key type", domainErrors.CodeInvalid)
+	}
+	return signer, nil
+}
@@ -0,0 +1,150 @@
+package cypher
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/youmark/pkcs8"
+	"software.sslmate.com/src/go-pkcs12"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+)
+
+// newTestX509Certificate returns a self-signed certificate of a key, valid for a year from now
+func newTestX509Certificate(t *testing.T, key crypto.Signer) *x509.Certificate {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "06140101780010"},
+		NotBefore:    time.Now().Add(-time.Hour).Truncate(time.Second),
+		NotAfter:     time.Now().AddDate(1, 0, 0).Truncate(time.Second),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certificate
+}
+
+// pemBlocks encodes blocks as a PEM file
+func pemBlocks(blocks ...*pem.Block) []byte {
+	var content []byte
+	for _, block := range blocks {
+		content = append(content, pem.EncodeToMemory(block)...)
+	}
+	return content
+}
+
+func TestPKCS12ToKeys(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate := newTestX509Certificate(t, key)
+	content, err := pkcs12.Modern.Encode(key, certificate, nil, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	processor := NewKeyProcessor()
+
+	bundle, err := processor.PKCS12ToKeys(content, "secret")
+	if err != nil {
+		t.Fatalf("PKCS12ToKeys() error = %v", err)
+	}
+	if !key.Equal(bundle.PrivateKey) || bundle.Certificate == nil || bundle.Certificate.SerialNumber.Int64() != 42 {
+		t.Errorf("PKCS12ToKeys() = %+v, want the key and certificate of the file", bundle)
+	}
+	if !key.PublicKey.Equal(bundle.PublicKey()) {
+		t.Error("PublicKey() is not the public key of the certificate")
+	}
+
+	_, err = processor.PKCS12ToKeys(content, "wrong")
+	if domainErr, ok := err.(domainErrors.DomainError); !ok || domainErr.Message != "key_password_invalid" {
+		t.Errorf("PKCS12ToKeys() with a wrong password error = %v, want key_password_invalid", err)
+	}
+}
+
+func TestPEMToKeys(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8DER, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec1DER, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedDER, err := pkcs8.MarshalPrivateKey(ecKey, []byte("secret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate := newTestX509Certificate(t, ecKey)
+	certificateBlock := &pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw}
+	otherCertificate := newTestX509Certificate(t, rsaKey)
+
+	tests := []struct {
+		name            string
+		content         []byte
+		password        string
+		wantKey         crypto.Signer
+		wantCertificate bool
+		wantMessage     string
+	}{
+		{name: "PKCS#8 key", content: pemBlocks(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8DER}), wantKey: ecKey},
+		{name: "PKCS#1 key", content: pemBlocks(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}), wantKey: rsaKey},
+		{name: "SEC 1 key", content: pemBlocks(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1DER}), wantKey: ecKey},
+		{name: "encrypted PKCS#8 key", content: pemBlocks(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encryptedDER}), password: "secret", wantKey: ecKey},
+		{name: "encrypted PKCS#8 key with a wrong password", content: pemBlocks(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encryptedDER}), password: "wrong", wantMessage: "key_password_invalid"},
+		{
+			name:    "key with its certificate and chain",
+			content: pemBlocks(certificateBlock, &pem.Block{Type: "CERTIFICATE", Bytes: otherCertificate.Raw}, &pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8DER}),
+			wantKey: ecKey, wantCertificate: true,
+		},
+		{name: "several keys", content: pemBlocks(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8DER}, &pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1DER}), wantMessage: "multiple private keys"},
+		{name: "certificate without key", content: pemBlocks(certificateBlock), wantMessage: "invalid"},
+		{name: "not PEM", content: []byte("<CertificadoMH/>"), wantMessage: "invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundle, err := NewKeyProcessor().PEMToKeys(tt.content, tt.password)
+			if tt.wantMessage != "" {
+				if domainErr, ok := err.(domainErrors.DomainError); !ok || domainErr.Message != tt.wantMessage {
+					t.Fatalf("PEMToKeys() error = %v, want %s", err, tt.wantMessage)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PEMToKeys() error = %v", err)
+			}
+			if !tt.wantKey.(interface{ Equal(crypto.PrivateKey) bool }).Equal(bundle.PrivateKey) {
+				t.Error("PEMToKeys() returned another private key")
+			}
+			if (bundle.Certificate != nil) != tt.wantCertificate {
+				t.Fatalf("certificate = %v, want one %v", bundle.Certificate, tt.wantCertificate)
+			}
+			if tt.wantCertificate && !bundle.Certificate.Equal(certificate) {
+				t.Error("PEMToKeys() returned a certificate of the chain instead of the first one")
+			}
+		})
+	}
+}
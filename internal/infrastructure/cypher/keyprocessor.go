@@ -1,11 +1,16 @@
 package cypher
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/sha512"
 	"crypto/x509"
 	"encoding/hex"
 	"errors"
+	"fmt"
 
 	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
 	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
@@ -19,25 +24,99 @@ func NewKeyProcessor() *KeyProcessor {
 	return &KeyProcessor{}
 }
 
-// BytesToPrivateKey converts a byte array to an RSA private key
+// BytesToPrivateKey converts a PKCS#8 byte array to an RSA, ECDSA or Ed25519 private key
 func (k *KeyProcessor) BytesToPrivateKey(bytes []byte) (*models.Certificate, error) {
 	priv, err := x509.ParsePKCS8PrivateKey(bytes)
 	if err != nil {
 		return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
 	}
 
-	rsaPriv, ok := priv.(*rsa.PrivateKey)
-	if !ok {
-		return nil, domainErrors.NewDomainError("key is not RSA type", domainErrors.CodeInvalid)
+	signer, ok := priv.(crypto.Signer)
+	if !ok || KeyFamily(signer.Public()) == "" {
+		return nil, domainErrors.NewDomainError("unsupported key type", domainErrors.CodeInvalid)
 	}
 
 	cert := &models.Certificate{
-		DecodedPrivateKey: rsaPriv,
+		DecodedPrivateKey: signer,
 	}
 
 	return cert, nil
 }
 
+// BytesToPublicKey converts a DER-encoded X.509 SubjectPublicKeyInfo to an RSA, ECDSA or Ed25519 public key
+func (k *KeyProcessor) BytesToPublicKey(bytes []byte) (crypto.PublicKey, error) {
+	pub, err := x509.ParsePKIXPublicKey(bytes)
+	if err != nil {
+		return nil, domainErrors.NewDomainError(err.Error(), domainErrors.CodeUncatalogued)
+	}
+
+	if KeyFamily(pub) == "" {
+		return nil, domainErrors.NewDomainError("unsupported key type", domainErrors.CodeInvalid)
+	}
+
+	return pub, nil
+}
+
+// KeysMatch checks whether the public key belongs to the private key
+func (k *KeyProcessor) KeysMatch(privateKey crypto.Signer, publicKey crypto.PublicKey) bool {
+	comparable, ok := privateKey.Public().(interface{ Equal(crypto.PublicKey) bool })
+	return ok && comparable.Equal(publicKey)
+}
+
+// CheckKeys validates the private key of a certificate and checks that it belongs to its public key.
+// Certificates without a private key in memory, signing through an external key, are left to the signer.
+func (k *KeyProcessor) CheckKeys(certificate *models.Certificate) error {
+	// 1: Validate the private key
+	switch key := certificate.DecodedPrivateKey.(type) {
+	case nil:
+		return nil
+	case *rsa.PrivateKey:
+		if err := key.Validate(); err != nil {
+			return domainErrors.NewDomainError("private_key_invalid", domainErrors.CodeInvalid)
+		}
+		key.Precompute()
+	case *ecdsa.PrivateKey:
+		if _, err := key.ECDH(); err != nil {
+			return domainErrors.NewDomainError("private_key_invalid", domainErrors.CodeInvalid)
+		}
+	case ed25519.PrivateKey:
+		if len(key) != ed25519.PrivateKeySize {
+			return domainErrors.NewDomainError("private_key_invalid", domainErrors.CodeInvalid)
+		}
+	}
+
+	// 2: Check the private key belongs to the public key
+	if certificate.DecodedPublicKey != nil && !k.KeysMatch(certificate.DecodedPrivateKey, certificate.DecodedPublicKey) {
+		return domainErrors.NewDomainError("public_key_mismatch", domainErrors.CodeNoPublicKey)
+	}
+
+	return nil
+}
+
+// DescribeKey returns the key type of a public key along with its size or curve, e.g. RSA-2048 or EC-P256
+func DescribeKey(publicKey crypto.PublicKey) string {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA-%d", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "EC-" + key.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return ""
+	}
+}
+
+// PublicKeyFingerprint returns the hex SHA-256 digest of the DER-encoded public key
+func PublicKeyFingerprint(publicKey crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(der)
+	return hex.EncodeToString(digest[:]), nil
+}
+
 // HashPassword hashes a password using SHA-512
 func (k *KeyProcessor) HashPassword(password string) (string, error) {
 	hasher := sha512.New()
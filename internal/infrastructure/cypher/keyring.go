@@ -0,0 +1,137 @@
+package cypher
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// masterKeySize is the size of the AES-256 master keys
+const masterKeySize = 32
+
+// Keyring holds the master keys of the key encryption service, base64 encoded by ID. The active
+// key wraps new data keys; the rest are kept to open the envelopes they wrapped.
+type Keyring struct {
+	Active string            `json:"active"`
+	Keys   map[string]string `json:"keys"`
+}
+
+// LoadKeyring builds the keyring from a keyring file and a master key given as base64, usually
+// taken from the environment. Either may be empty. The master key is the active one unless the
+// keyring file is given, whose active key prevails.
+func LoadKeyring(path string, masterKey string, masterKeyID string) (*Keyring, error) {
+	keyring := &Keyring{Keys: make(map[string]string)}
+	if path != "" {
+		read, err := ReadKeyring(path)
+		if err != nil {
+			return nil, err
+		}
+		keyring = read
+	}
+
+	if masterKey != "" {
+		if masterKeyID == "" {
+			return nil, fmt.Errorf("master key ID is required")
+		}
+		if existing, ok := keyring.Keys[masterKeyID]; ok && existing != masterKey {
+			return nil, fmt.Errorf("master key %q differs from the one of the keyring file", masterKeyID)
+		}
+		keyring.Keys[masterKeyID] = masterKey
+		if path == "" {
+			keyring.Active = masterKeyID
+		}
+	}
+
+	if len(keyring.Keys) == 0 {
+		return nil, fmt.Errorf("no master key configured")
+	}
+	if _, err := keyring.decode(); err != nil {
+		return nil, err
+	}
+	return keyring, nil
+}
+
+// ReadKeyring reads a keyring file
+func ReadKeyring(path string) (*Keyring, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring: %w", err)
+	}
+
+	var keyring Keyring
+	if err := json.Unmarshal(content, &keyring); err != nil {
+		return nil, fmt.Errorf("invalid keyring %s: %w", path, err)
+	}
+	if keyring.Keys == nil {
+		keyring.Keys = make(map[string]string)
+	}
+	return &keyring, nil
+}
+
+// Write stores the keyring in a file readable only by its owner, replacing it atomically
+func (k *Keyring) Write(path string) error {
+	content, err := json.MarshalIndent(k, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), ".keyring-*")
+	if err != nil {
+		return fmt.Errorf("failed to write keyring: %w", err)
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(append(content, '\n')); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write keyring: %w", err)
+	}
+	if err := temp.Chmod(0600); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write keyring: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write keyring: %w", err)
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+// Generate adds a new random master key under the given ID and makes it the active one
+func (k *Keyring) Generate(id string) error {
+	if id == "" {
+		return fmt.Errorf("master key ID is required")
+	}
+	if _, ok := k.Keys[id]; ok {
+		return fmt.Errorf("master key %q already exists", id)
+	}
+
+	key := make([]byte, masterKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return fmt.Errorf("failed to generate master key: %w", err)
+	}
+	if k.Keys == nil {
+		k.Keys = make(map[string]string)
+	}
+	k.Keys[id] = base64.StdEncoding.EncodeToString(key)
+	k.Active = id
+	return nil
+}
+
+// decode decodes the master keys, checking their size
+func (k *Keyring) decode() (map[string][]byte, error) {
+	keys := make(map[string][]byte, len(k.Keys))
+	for id, encoded := range k.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid master key %q: %w", id, err)
+		}
+		if len(key) != masterKeySize {
+			return nil, fmt.Errorf("invalid master key %q: must be %d bytes", id, masterKeySize)
+		}
+		keys[id] = key
+	}
+	return keys, nil
+}
@@ -0,0 +1,114 @@
+package cypher
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+
+	"github.com/go-jose/go-jose/v3"
+)
+
+// algorithmHashes maps every RSA and ECDSA algorithm to the digest it signs
+var algorithmHashes = map[jose.SignatureAlgorithm]crypto.Hash{
+	jose.RS256: crypto.SHA256,
+	jose.RS384: crypto.SHA384,
+	jose.RS512: crypto.SHA512,
+	jose.PS256: crypto.SHA256,
+	jose.PS384: crypto.SHA384,
+	jose.PS512: crypto.SHA512,
+	jose.ES256: crypto.SHA256,
+	jose.ES384: crypto.SHA384,
+	jose.ES512: crypto.SHA512,
+}
+
+// opaqueSigner adapts a crypto.Signer whose key is not held in memory, such as a hardware token
+// handle, to go-jose, which only signs directly with in-memory keys
+type opaqueSigner struct {
+	signer crypto.Signer
+}
+
+// signingKey returns the key handed to go-jose for a private key: in-memory keys as they are,
+// any other crypto.Signer wrapped as an opaque signer
+func signingKey(privateKey crypto.Signer) interface{} {
+	switch privateKey.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		return privateKey
+	default:
+		return &opaqueSigner{signer: privateKey}
+	}
+}
+
+// Public returns the public key of the signer
+func (s *opaqueSigner) Public() *jose.JSONWebKey {
+	return &jose.JSONWebKey{Key: s.signer.Public()}
+}
+
+// Algs returns the algorithms supported by the key family of the signer
+func (s *opaqueSigner) Algs() []jose.SignatureAlgorithm {
+	family := KeyFamily(s.signer.Public())
+	var algorithms []jose.SignatureAlgorithm
+	for algorithm, algorithmFamily := range supportedAlgorithms {
+		if algorithmFamily == family {
+			algorithms = append(algorithms, algorithm)
+		}
+	}
+	return algorithms
+}
+
+// SignPayload signs the JWS signing input with the given algorithm
+func (s *opaqueSigner) SignPayload(payload []byte, algorithm jose.SignatureAlgorithm) ([]byte, error) {
+	// Ed25519 signs the message itself
+	if algorithm == jose.EdDSA {
+		return s.signer.Sign(rand.Reader, payload, crypto.Hash(0))
+	}
+
+	hash, ok := algorithmHashes[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported algorithm %s", algorithm)
+	}
+	hasher := hash.New()
+	hasher.Write(payload)
+	digest := hasher.Sum(nil)
+
+	switch algorithm {
+	case jose.PS256, jose.PS384, jose.PS512:
+		return s.signer.Sign(rand.Reader, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash})
+	case jose.ES256, jose.ES384, jose.ES512:
+		// crypto.Signer returns ASN.1 encoded ECDSA signatures, JWS expects the fixed size R || S
+		signature, err := s.signer.Sign(rand.Reader, digest, hash)
+		if err != nil {
+			return nil, err
+		}
+		publicKey, ok := s.signer.Public().(*ecdsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("algorithm %s requires an EC key", algorithm)
+		}
+		return ecdsaRawSignature(signature, (publicKey.Curve.Params().BitSize+7)/8)
+	default:
+		return s.signer.Sign(rand.Reader, digest, hash)
+	}
+}
+
+// ecdsaRawSignature converts an ASN.1 ECDSA signature to the R || S form, each padded to the curve size
+func ecdsaRawSignature(signature []byte, size int) ([]byte, error) {
+	var parsed struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(signature, &parsed); err != nil {
+		// Some tokens already return the raw form
+		if len(signature) == 2*size {
+			return signature, nil
+		}
+		return nil, fmt.Errorf("invalid ECDSA signature: %w", err)
+	}
+
+	raw := make([]byte, 2*size)
+	parsed.R.FillBytes(raw[:size])
+	parsed.S.FillBytes(raw[size:])
+	return raw, nil
+}
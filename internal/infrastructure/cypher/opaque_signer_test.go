@@ -0,0 +1,106 @@
+package cypher
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+
+	"github.com/go-jose/go-jose/v3"
+)
+
+// handleKey stands for the handle of any key held outside the process
+type handleKey struct {
+	crypto.Signer
+}
+
+func TestOpaqueSignerSignsWithEveryAlgorithm(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKeys := make(map[elliptic.Curve]*ecdsa.PrivateKey)
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		if ecKeys[curve], err = ecdsa.GenerateKey(curve, rand.Reader); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		algorithm jose.SignatureAlgorithm
+		key       crypto.Signer
+	}{
+		{jose.RS256, rsaKey},
+		{jose.RS512, rsaKey},
+		{jose.PS256, rsaKey},
+		{jose.PS384, rsaKey},
+		{jose.ES256, ecKeys[elliptic.P256()]},
+		{jose.ES384, ecKeys[elliptic.P384()]},
+		{jose.ES512, ecKeys[elliptic.P521()]},
+		{jose.EdDSA, edKey},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.algorithm), func(t *testing.T) {
+			// 1: A handle is wrapped, while the in-memory key is handed to go-jose as it is
+			key := signingKey(handleKey{tt.key})
+			if _, ok := key.(*opaqueSigner); !ok {
+				t.Fatalf("signingKey() = %T, want an opaque signer", key)
+			}
+			if _, ok := signingKey(tt.key).(*opaqueSigner); ok {
+				t.Errorf("signingKey() wrapped an in-memory key")
+			}
+			found := false
+			for _, algorithm := range key.(*opaqueSigner).Algs() {
+				found = found || algorithm == tt.algorithm
+			}
+			if !found {
+				t.Errorf("Algs() = %v, want %s", key.(*opaqueSigner).Algs(), tt.algorithm)
+			}
+
+			// 2: The signature of the handle verifies with the public key
+			signer, err := jose.NewSigner(jose.SigningKey{Algorithm: tt.algorithm, Key: key}, nil)
+			if err != nil {
+				t.Fatalf("NewSigner() error = %v", err)
+			}
+			object, err := signer.Sign([]byte(`{"a":1}`))
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+			if payload, err := object.Verify(tt.key.Public()); err != nil || string(payload) != `{"a":1}` {
+				t.Errorf("Verify() = %s, %v", payload, err)
+			}
+		})
+	}
+}
+
+func TestECDSARawSignature(t *testing.T) {
+	// A short R is left padded to the curve size
+	signature, err := asn1.Marshal(struct{ R, S *big.Int }{big.NewInt(1), big.NewInt(0x0203)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := ecdsaRawSignature(signature, 4)
+	if err != nil || !bytes.Equal(raw, []byte{0, 0, 0, 1, 0, 0, 2, 3}) {
+		t.Errorf("ecdsaRawSignature() = %x, %v, want 0000000100000203", raw, err)
+	}
+
+	// Signatures of tokens already in the raw form are kept
+	raw, err = ecdsaRawSignature([]byte{1, 2, 3, 4, 5, 6, 7, 8}, 4)
+	if err != nil || !bytes.Equal(raw, []byte{1, 2, 3, 4, 5, 6, 7, 8}) {
+		t.Errorf("ecdsaRawSignature() of a raw signature = %x, %v", raw, err)
+	}
+
+	if _, err := ecdsaRawSignature([]byte{1, 2, 3}, 4); err == nil {
+		t.Error("ecdsaRawSignature() of an invalid signature succeeded")
+	}
+}
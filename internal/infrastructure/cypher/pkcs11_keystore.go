@@ -0,0 +1,65 @@
+//go:build pkcs11
+
+package cypher
+
+import (
+	"crypto"
+	"fmt"
+	"sync"
+
+	"github.com/ThalesIgnite/crypto11"
+)
+
+// PKCS11KeyStore provides signing handles for the key pairs of a PKCS#11 token. Private keys never
+// leave the token: every signature is computed by the token through its handle.
+type PKCS11KeyStore struct {
+	context *crypto11.Context
+
+	mutex   sync.Mutex
+	signers map[string]crypto.Signer
+}
+
+// NewPKCS11KeyStore opens a session on the token selected by the options
+func NewPKCS11KeyStore(options PKCS11Options) (*PKCS11KeyStore, error) {
+	context, err := crypto11.Configure(&crypto11.Config{
+		Path:        options.Module,
+		TokenLabel:  options.TokenLabel,
+		Pin:         options.Pin,
+		MaxSessions: options.MaxSessions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PKCS#11 token %q: %w", options.TokenLabel, err)
+	}
+
+	return &PKCS11KeyStore{
+		context: context,
+		signers: make(map[string]crypto.Signer),
+	}, nil
+}
+
+// Signer returns the signing handle of the key pair with the given label
+func (s *PKCS11KeyStore) Signer(label string) (crypto.Signer, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Handles are kept, so signers built for them can be cached
+	if signer, ok := s.signers[label]; ok {
+		return signer, nil
+	}
+
+	signer, err := s.context.FindKeyPair(nil, []byte(label))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find key pair %q: %w", label, err)
+	}
+	if signer == nil {
+		return nil, fmt.Errorf("key pair %q not found on token", label)
+	}
+
+	s.signers[label] = signer
+	return signer, nil
+}
+
+// Close closes the session on the token
+func (s *PKCS11KeyStore) Close() error {
+	return s.context.Close()
+}
@@ -0,0 +1,23 @@
+//go:build !pkcs11
+
+package cypher
+
+import "crypto"
+
+// PKCS11KeyStore is unavailable in binaries built without the pkcs11 build tag
+type PKCS11KeyStore struct{}
+
+// NewPKCS11KeyStore fails, since PKCS#11 support requires the pkcs11 build tag and cgo
+func NewPKCS11KeyStore(options PKCS11Options) (*PKCS11KeyStore, error) {
+	return nil, ErrPKCS11Unsupported
+}
+
+// Signer fails, since PKCS#11 support requires the pkcs11 build tag and cgo
+func (s *PKCS11KeyStore) Signer(label string) (crypto.Signer, error) {
+	return nil, ErrPKCS11Unsupported
+}
+
+// Close does nothing
+func (s *PKCS11KeyStore) Close() error {
+	return nil
+}
@@ -0,0 +1,23 @@
+//go:build !pkcs11
+
+package cypher
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPKCS11KeyStoreRequiresTheBuildTag(t *testing.T) {
+	_, err := NewPKCS11KeyStore(PKCS11Options{Module: "/usr/lib/softhsm/libsofthsm2.so", TokenLabel: "firma"})
+	if !errors.Is(err, ErrPKCS11Unsupported) {
+		t.Errorf("NewPKCS11KeyStore() error = %v, want %v", err, ErrPKCS11Unsupported)
+	}
+
+	store := &PKCS11KeyStore{}
+	if _, err := store.Signer("firma"); !errors.Is(err, ErrPKCS11Unsupported) {
+		t.Errorf("Signer() error = %v, want %v", err, ErrPKCS11Unsupported)
+	}
+	if err := store.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
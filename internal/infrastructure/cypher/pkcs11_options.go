@@ -0,0 +1,18 @@
+package cypher
+
+import "errors"
+
+// ErrPKCS11Unsupported is returned when PKCS#11 is requested from a binary built without the pkcs11 build tag
+var ErrPKCS11Unsupported = errors.New("PKCS#11 support not built in, rebuild with -tags pkcs11")
+
+// PKCS11Options selects the PKCS#11 module and token holding the signing keys
+type PKCS11Options struct {
+	// Module is the path of the PKCS#11 library, e.g. /usr/lib/softhsm/libsofthsm2.so
+	Module string
+	// TokenLabel is the label of the token holding the keys
+	TokenLabel string
+	// Pin is the user PIN of the token
+	Pin string
+	// MaxSessions limits the sessions opened on the token, zero uses the module default
+	MaxSessions int
+}
@@ -0,0 +1,160 @@
+package cypher
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/go-jose/go-jose/v3"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+)
+
+// errMalformedJWS is returned when a serialized JWS cannot be parsed
+var errMalformedJWS = errors.New("malformed JWS")
+
+// serializeJWS serializes a signed object in the requested format
+func serializeJWS(object *jose.JSONWebSignature, serialization string, detached bool) (string, error) {
+	switch serialization {
+	case "", models.SerializationCompact:
+		if detached {
+			return object.DetachedCompactSerialize()
+		}
+		return object.CompactSerialize()
+	case models.SerializationFlattened, models.SerializationGeneral:
+		var document map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(object.FullSerialize()), &document); err != nil {
+			return "", err
+		}
+		if serialization == models.SerializationGeneral {
+			document = toGeneral(document)
+		}
+		if detached {
+			delete(document, "payload")
+		}
+		serialized, err := json.Marshal(document)
+		if err != nil {
+			return "", err
+		}
+		return string(serialized), nil
+	default:
+		return "", errors.New("unsupported serialization: " + serialization)
+	}
+}
+
+// mergeJWS combines single-signature objects over the same payload into a general JWS JSON
+func mergeJWS(objects []*jose.JSONWebSignature, detached bool) (string, error) {
+	document := make(map[string]json.RawMessage)
+	signatures := make([]map[string]json.RawMessage, 0, len(objects))
+
+	for _, object := range objects {
+		var flattened map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(object.FullSerialize()), &flattened); err != nil {
+			return "", err
+		}
+		if _, ok := document["payload"]; !ok && !detached {
+			document["payload"] = flattened["payload"]
+		}
+
+		signature := make(map[string]json.RawMessage)
+		for _, member := range []string{"protected", "header", "signature"} {
+			if value, ok := flattened[member]; ok {
+				signature[member] = value
+			}
+		}
+		signatures = append(signatures, signature)
+	}
+
+	encoded, err := json.Marshal(signatures)
+	if err != nil {
+		return "", err
+	}
+	document["signatures"] = encoded
+
+	serialized, err := json.Marshal(document)
+	if err != nil {
+		return "", err
+	}
+	return string(serialized), nil
+}
+
+// toGeneral converts a flattened JWS JSON document into the general syntax
+func toGeneral(document map[string]json.RawMessage) map[string]json.RawMessage {
+	if _, ok := document["signatures"]; ok {
+		return document
+	}
+
+	signature := make(map[string]json.RawMessage)
+	for _, member := range []string{"protected", "header", "signature"} {
+		if value, ok := document[member]; ok {
+			signature[member] = value
+			delete(document, member)
+		}
+	}
+
+	signatures, _ := json.Marshal([]map[string]json.RawMessage{signature})
+	document["signatures"] = signatures
+	return document
+}
+
+// parseJWS parses a compact or JSON serialized JWS, attaching the payload when it was detached.
+// It returns the parsed object along with the detected serialization and whether it was detached.
+func parseJWS(serialized string, detachedPayload []byte) (*jose.JSONWebSignature, string, bool, error) {
+	serialized = strings.TrimSpace(serialized)
+
+	// JSON serializations
+	if strings.HasPrefix(serialized, "{") {
+		var document map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(serialized), &document); err != nil {
+			return nil, "", false, errMalformedJWS
+		}
+
+		serialization := models.SerializationFlattened
+		if _, ok := document["signatures"]; ok {
+			serialization = models.SerializationGeneral
+		}
+
+		_, hasPayload := document["payload"]
+		if !hasPayload {
+			if detachedPayload == nil {
+				return nil, "", false, errMalformedJWS
+			}
+			encoded, _ := json.Marshal(base64.RawURLEncoding.EncodeToString(detachedPayload))
+			document["payload"] = encoded
+			reassembled, err := json.Marshal(document)
+			if err != nil {
+				return nil, "", false, errMalformedJWS
+			}
+			serialized = string(reassembled)
+		}
+
+		object, err := jose.ParseSigned(serialized)
+		if err != nil {
+			return nil, "", false, errMalformedJWS
+		}
+		return object, serialization, !hasPayload, nil
+	}
+
+	// Compact serialization
+	parts := strings.Split(serialized, ".")
+	if len(parts) != 3 {
+		return nil, "", false, errMalformedJWS
+	}
+	if parts[1] == "" {
+		if detachedPayload == nil {
+			return nil, "", false, errMalformedJWS
+		}
+		object, err := jose.ParseDetached(serialized, detachedPayload)
+		if err != nil {
+			return nil, "", false, errMalformedJWS
+		}
+		return object, models.SerializationCompact, true, nil
+	}
+
+	object, err := jose.ParseSigned(serialized)
+	if err != nil {
+		return nil, "", false, errMalformedJWS
+	}
+	return object, models.SerializationCompact, false, nil
+}
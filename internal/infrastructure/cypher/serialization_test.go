@@ -0,0 +1,146 @@
+package cypher
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+)
+
+// serializedMembers returns the top-level members of a JWS JSON serialization, or nil for a compact one
+func serializedMembers(t *testing.T, serialized string) map[string]json.RawMessage {
+	t.Helper()
+	if !strings.HasPrefix(serialized, "{") {
+		return nil
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(serialized), &members); err != nil {
+		t.Fatalf("invalid JWS JSON %s: %v", serialized, err)
+	}
+	return members
+}
+
+func TestJWSSerializationsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	certificate := newTestCertificate(t, "06140101780010", "06140101780010-id")
+	payload := []byte(`{"identificacion":{"tipoDte":"01"},"resumen":{"totalPagar":1.10}}`)
+	signer := NewJWSSigner(DefaultAlgorithmRegistry(), HeaderPolicy{}, nil)
+	verifier := NewJWSVerifier(DefaultAlgorithmRegistry())
+
+	tests := []struct {
+		name    string
+		options models.SigningOptions
+		// wantMembers are the top-level members of JSON serializations, nil for compact ones
+		wantMembers []string
+	}{
+		{name: "compact", options: models.SigningOptions{}},
+		{name: "detached compact", options: models.SigningOptions{Serialization: models.SerializationCompact, Detached: true}},
+		{name: "unencoded detached compact", options: models.SigningOptions{Detached: true, Unencoded: true}},
+		{name: "flattened", options: models.SigningOptions{Serialization: models.SerializationFlattened}, wantMembers: []string{"payload", "protected", "signature"}},
+		{name: "detached flattened", options: models.SigningOptions{Serialization: models.SerializationFlattened, Detached: true}, wantMembers: []string{"protected", "signature"}},
+		{name: "general", options: models.SigningOptions{Serialization: models.SerializationGeneral}, wantMembers: []string{"payload", "signatures"}},
+		{name: "unencoded detached general", options: models.SigningOptions{Serialization: models.SerializationGeneral, Detached: true, Unencoded: true}, wantMembers: []string{"signatures"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := signer.Sign(ctx, certificate, payload, tt.options)
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+
+			// 1: The JWS has the requested shape
+			members := serializedMembers(t, signed.Serialized)
+			if tt.wantMembers == nil {
+				parts := strings.Split(signed.Serialized, ".")
+				if members != nil || len(parts) != 3 {
+					t.Fatalf("Serialized = %s, want a compact JWS", signed.Serialized)
+				}
+				if detached := parts[1] == ""; detached != tt.options.Detached {
+					t.Errorf("compact payload detached = %v, want %v", detached, tt.options.Detached)
+				}
+			} else {
+				if len(members) != len(tt.wantMembers) {
+					t.Errorf("members of %s, want %v", signed.Serialized, tt.wantMembers)
+				}
+				for _, member := range tt.wantMembers {
+					if _, ok := members[member]; !ok {
+						t.Errorf("member %q missing from %s", member, signed.Serialized)
+					}
+				}
+			}
+
+			// 2: Unencoded payloads are announced as critical b64=false headers
+			object, _, _, err := parseJWS(signed.Serialized, payload)
+			if err != nil {
+				t.Fatalf("parseJWS() error = %v", err)
+			}
+			header := object.Signatures[0].Protected.ExtraHeaders
+			b64, ok := header["b64"]
+			if ok != tt.options.Unencoded || (ok && b64 != false) {
+				t.Errorf("b64 header = %v, present %v, want it false only for unencoded payloads", b64, ok)
+			}
+			if _, ok := header["crit"]; ok != tt.options.Unencoded {
+				t.Errorf("crit header present = %v, want %v", ok, tt.options.Unencoded)
+			}
+
+			// 3: The JWS verifies, with its payload given separately when detached
+			var detachedPayload []byte
+			if tt.options.Detached {
+				detachedPayload = payload
+			}
+			result, err := verifier.Verify(ctx, certificate, signed.Serialized, detachedPayload)
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			wantSerialization := tt.options.Serialization
+			if wantSerialization == "" {
+				wantSerialization = models.SerializationCompact
+			}
+			if result.Serialization != wantSerialization || result.Detached != tt.options.Detached {
+				t.Errorf("Verify() = %s detached %v, want %s detached %v", result.Serialization, result.Detached, wantSerialization, tt.options.Detached)
+			}
+
+			// 4: A detached JWS only verifies with the exact payload it signed
+			if tt.options.Detached {
+				_, err := verifier.Verify(ctx, certificate, signed.Serialized, []byte(`{"resumen":{"totalPagar":9.99}}`))
+				if domainErr, ok := err.(domainErrors.DomainError); !ok || domainErr.Code != domainErrors.CodeSignatureInvalid {
+					t.Errorf("Verify() of another payload error = %v, want %s", err, domainErrors.CodeSignatureInvalid)
+				}
+				if _, err := verifier.Verify(ctx, certificate, signed.Serialized, nil); err == nil {
+					t.Error("Verify() of a detached JWS without its payload succeeded")
+				}
+			}
+		})
+	}
+}
+
+func TestJWSSerializationAttachesTheEncodedPayload(t *testing.T) {
+	certificate := newTestCertificate(t, "06140101780010", "06140101780010-id")
+	payload := []byte(`{"a":1}`)
+	signed, err := NewJWSSigner(DefaultAlgorithmRegistry(), HeaderPolicy{}, nil).Sign(context.Background(), certificate, payload,
+		models.SigningOptions{Serialization: models.SerializationFlattened})
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	var encoded string
+	if err := json.Unmarshal(serializedMembers(t, signed.Serialized)["payload"], &encoded); err != nil {
+		t.Fatal(err)
+	}
+	if encoded != base64.RawURLEncoding.EncodeToString(payload) {
+		t.Errorf("payload = %s, want the base64url encoded document", encoded)
+	}
+}
+
+func TestSerializeJWSRejectsUnknownSerializations(t *testing.T) {
+	certificate := newTestCertificate(t, "06140101780010", "06140101780010-id")
+	_, err := NewJWSSigner(DefaultAlgorithmRegistry(), HeaderPolicy{}, nil).Sign(context.Background(), certificate, []byte(`{"a":1}`),
+		models.SigningOptions{Serialization: "pretty"})
+	if err == nil {
+		t.Error("Sign() with an unknown serialization succeeded")
+	}
+}
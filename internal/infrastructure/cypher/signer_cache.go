@@ -0,0 +1,73 @@
+package cypher
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+
+	"github.com/chainedpixel/go-dte-signer/pkg/cache"
+)
+
+// signerCacheKey identifies a signer built for a certificate
+type signerCacheKey struct {
+	nit         string
+	fingerprint string
+}
+
+// cachedSigner is the go-jose signer built for a certificate. Keys signing with the context of each
+// request only keep the options of their signer, which is built around the key bound to the request.
+type cachedSigner struct {
+	signer  jose.Signer
+	options *jose.SignerOptions
+}
+
+// SignerCache keeps the go-jose signers built for each certificate, so requests whose
+// protected headers do not change skip the signer construction
+type SignerCache struct {
+	cache *cache.Cache[signerCacheKey, cachedSigner]
+}
+
+// NewSignerCache creates a new signer cache
+func NewSignerCache(ttl time.Duration, maxEntries int) *SignerCache {
+	return &SignerCache{
+		cache: cache.New[signerCacheKey, cachedSigner](ttl, maxEntries),
+	}
+}
+
+// get returns the cached signer for the key
+func (c *SignerCache) get(key signerCacheKey) (cachedSigner, bool) {
+	return c.cache.Get(key)
+}
+
+// set stores the signer for the key
+func (c *SignerCache) set(key signerCacheKey, signer cachedSigner) {
+	c.cache.Set(key, signer)
+}
+
+// Invalidate removes every cached signer of a NIT
+func (c *SignerCache) Invalidate(nit string) {
+	c.cache.DeleteFunc(func(key signerCacheKey) bool {
+		return key.nit == nit
+	})
+}
+
+// InvalidateAll removes every cached signer
+func (c *SignerCache) InvalidateAll() {
+	c.cache.Purge()
+}
+
+// Stats returns the cache usage counters
+func (c *SignerCache) Stats() cache.Stats {
+	return c.cache.Stats()
+}
+
+// HealthName returns the key under which the cache is reported in the health check
+func (c *SignerCache) HealthName() string {
+	return "signerCache"
+}
+
+// HealthDetails returns the cache usage counters for the health check
+func (c *SignerCache) HealthDetails(ctx context.Context) interface{} {
+	return c.Stats()
+}
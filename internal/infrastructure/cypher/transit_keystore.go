@@ -0,0 +1,344 @@
+package cypher
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chainedpixel/go-dte-signer/pkg/logs"
+)
+
+// transitHashes maps the digests signed by the service to the names of the transit API
+var transitHashes = map[crypto.Hash]string{
+	crypto.SHA256: "sha2-256",
+	crypto.SHA384: "sha2-384",
+	crypto.SHA512: "sha2-512",
+}
+
+// TransitOptions configures the connection to a key service speaking the Vault Transit API
+type TransitOptions struct {
+	// Address is the base URL of the service, e.g. https://vault.example.com:8200
+	Address string
+	// Mount is the path where the transit engine is mounted, "transit" by default
+	Mount string
+	// Token is sent in the X-Vault-Token header
+	Token string
+	// Timeout bounds every request
+	Timeout time.Duration
+	// Retries is the number of times a failed request is retried
+	Retries int
+	// RetryBackoff is the wait before the first retry, doubled on every following one
+	RetryBackoff time.Duration
+	// KeyRefresh is how long a key handle is reused before the latest version of the key is read
+	// again, so rotated keys are picked up; zero reads it on every request for a handle
+	KeyRefresh time.Duration
+}
+
+// TransitKeyStore provides signing handles for the keys of a Vault Transit compatible service.
+// The service computes every signature; only the digest of the JWS signing input is sent to it.
+// Every handle signs with the version of the key whose public key it holds.
+type TransitKeyStore struct {
+	options TransitOptions
+	client  *http.Client
+
+	// ctx ends with the store, interrupting the requests in flight and their retries
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mutex   sync.Mutex
+	signers map[string]*transitHandle
+	now     func() time.Time
+}
+
+// transitHandle is the signing handle of a key along with the time its latest version was read
+type transitHandle struct {
+	signer *transitSigner
+	readAt time.Time
+}
+
+// transitSigner signs through a version of a key of the transit service
+type transitSigner struct {
+	store     *TransitKeyStore
+	name      string
+	version   int
+	publicKey crypto.PublicKey
+}
+
+// transitError is an error answered by the transit service
+type transitError struct {
+	status int
+	errors []string
+}
+
+// NewTransitKeyStore creates a new transit key store
+func NewTransitKeyStore(options TransitOptions) *TransitKeyStore {
+	if options.Mount == "" {
+		options.Mount = "transit"
+	}
+	options.Address = strings.TrimRight(options.Address, "/")
+	options.Mount = strings.Trim(options.Mount, "/")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &TransitKeyStore{
+		options: options,
+		client:  &http.Client{Timeout: options.Timeout},
+		ctx:     ctx,
+		cancel:  cancel,
+		signers: make(map[string]*transitHandle),
+		now:     time.Now,
+	}
+}
+
+// Close interrupts the requests in flight; the store must not be used afterwards
+func (s *TransitKeyStore) Close() error {
+	s.cancel()
+	return nil
+}
+
+// Signer returns the signing handle of the latest version of the named key, reading its public key
+// from the service once the handle is older than the refresh interval
+func (s *TransitKeyStore) Signer(name string) (crypto.Signer, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// 1: Reuse a recent handle, so the options of the signers built for it can be cached
+	handle, ok := s.signers[name]
+	now := s.now()
+	if ok && now.Sub(handle.readAt) < s.options.KeyRefresh {
+		return handle.signer, nil
+	}
+
+	// 2: Read the latest version, keeping the known handle while the service is unreachable
+	version, publicKey, err := s.publicKey(s.ctx, name)
+	if err != nil {
+		if ok {
+			logs.Warn(fmt.Sprintf("Failed to refresh transit key %q, signing with version %d: %v", name, handle.signer.version, err))
+			return handle.signer, nil
+		}
+		return nil, err
+	}
+
+	// 3: Keep the handle while the version is the same, replace it once the key is rotated
+	if ok && handle.signer.version == version {
+		handle.readAt = now
+		return handle.signer, nil
+	}
+	if ok {
+		logs.Info(fmt.Sprintf("Transit key %q rotated from version %d to %d", name, handle.signer.version, version))
+	}
+	signer := &transitSigner{store: s, name: name, version: version, publicKey: publicKey}
+	s.signers[name] = &transitHandle{signer: signer, readAt: now}
+	return signer, nil
+}
+
+// publicKey reads the latest version of a key and its public key
+func (s *TransitKeyStore) publicKey(ctx context.Context, name string) (int, crypto.PublicKey, error) {
+	var response struct {
+		Data struct {
+			LatestVersion int `json:"latest_version"`
+			Keys          map[string]struct {
+				PublicKey string `json:"public_key"`
+			} `json:"keys"`
+		} `json:"data"`
+	}
+	if err := s.call(ctx, http.MethodGet, "keys/"+url.PathEscape(name), nil, &response); err != nil {
+		return 0, nil, fmt.Errorf("failed to read transit key %q: %w", name, err)
+	}
+
+	version, ok := response.Data.Keys[strconv.Itoa(response.Data.LatestVersion)]
+	if !ok || version.PublicKey == "" {
+		return 0, nil, fmt.Errorf("transit key %q has no public key, it must be an asymmetric signing key", name)
+	}
+
+	// Ed25519 keys are given as raw base64, the rest as PEM
+	block, _ := pem.Decode([]byte(version.PublicKey))
+	if block == nil {
+		raw, err := base64.StdEncoding.DecodeString(version.PublicKey)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			return 0, nil, fmt.Errorf("invalid public key of transit key %q", name)
+		}
+		return response.Data.LatestVersion, ed25519.PublicKey(raw), nil
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid public key of transit key %q: %w", name, err)
+	}
+	if KeyFamily(publicKey) == "" {
+		return 0, nil, fmt.Errorf("unsupported key type of transit key %q", name)
+	}
+	return response.Data.LatestVersion, publicKey, nil
+}
+
+// sign asks the service to sign an input, a digest unless the hash is zero. The request is
+// abandoned once the context ends or the store is closed.
+func (s *TransitKeyStore) sign(ctx context.Context, name string, version int, input []byte, opts crypto.SignerOpts) ([]byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(s.ctx, cancel)()
+
+	// 1: Describe the signature expected from the service
+	request := map[string]interface{}{
+		"input":       base64.StdEncoding.EncodeToString(input),
+		"key_version": version,
+	}
+	path := "sign/" + url.PathEscape(name)
+	if hash := opts.HashFunc(); hash != 0 {
+		hashName, ok := transitHashes[hash]
+		if !ok {
+			return nil, fmt.Errorf("unsupported hash %s", hash)
+		}
+		path += "/" + hashName
+		request["prehashed"] = true
+		request["signature_algorithm"] = "pkcs1v15"
+		if pss, ok := opts.(*rsa.PSSOptions); ok {
+			if pss.SaltLength != rsa.PSSSaltLengthEqualsHash {
+				return nil, errors.New("only PSS salts of the hash length are supported")
+			}
+			request["signature_algorithm"] = "pss"
+			request["salt_length"] = "hash"
+		}
+		request["marshaling_algorithm"] = "asn1"
+	}
+
+	// 2: Sign and decode the vault:v<version>:<base64> signature
+	var response struct {
+		Data struct {
+			Signature string `json:"signature"`
+		} `json:"data"`
+	}
+	if err := s.call(ctx, http.MethodPost, path, request, &response); err != nil {
+		return nil, fmt.Errorf("failed to sign with transit key %q: %w", name, err)
+	}
+	parts := strings.SplitN(response.Data.Signature, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" {
+		return nil, fmt.Errorf("invalid signature returned for transit key %q", name)
+	}
+	if parts[1] != "v"+strconv.Itoa(version) {
+		return nil, fmt.Errorf("transit key %q signed with %s instead of version %d", name, parts[1], version)
+	}
+	return base64.StdEncoding.DecodeString(parts[2])
+}
+
+// call sends a request to the service, retrying on network errors, throttling and server errors until the context ends
+func (s *TransitKeyStore) call(ctx context.Context, method string, path string, body interface{}, result interface{}) error {
+	var payload []byte
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = encoded
+	}
+
+	backoff := s.options.RetryBackoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retryable bool
+		retryable, err = s.send(ctx, method, path, payload, result)
+		if err == nil || !retryable || attempt >= s.options.Retries || ctx.Err() != nil {
+			return err
+		}
+
+		logs.Warn(fmt.Sprintf("Transit request %s %s failed, retrying in %s: %v", method, path, backoff, err))
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// send sends a single request to the service, reporting whether a failure may be retried
+func (s *TransitKeyStore) send(ctx context.Context, method string, path string, payload []byte, result interface{}) (bool, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, s.options.Address+"/v1/"+s.options.Mount+"/"+path, body)
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("X-Vault-Token", s.options.Token)
+	if payload != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+
+	content, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return true, err
+	}
+	if response.StatusCode != http.StatusOK {
+		failure := &transitError{status: response.StatusCode}
+		var answer struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(content, &answer) == nil {
+			failure.errors = answer.Errors
+		}
+		retryable := response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= http.StatusInternalServerError
+		return retryable, failure
+	}
+
+	if err := json.Unmarshal(content, result); err != nil {
+		return false, fmt.Errorf("invalid transit response: %w", err)
+	}
+	return false, nil
+}
+
+// Public returns the public key of the transit key
+func (s *transitSigner) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign signs a digest, or the message itself for Ed25519 keys, with the transit key
+func (s *transitSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.SignContext(s.store.ctx, digest, opts)
+}
+
+// SignContext signs a digest like Sign, abandoning the request and its retries once the context ends
+func (s *transitSigner) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	switch s.publicKey.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		if opts.HashFunc() == 0 {
+			return nil, errors.New("RSA and ECDSA transit keys sign digests")
+		}
+	case ed25519.PublicKey:
+		if opts.HashFunc() != 0 {
+			return nil, errors.New("Ed25519 transit keys sign messages")
+		}
+	}
+	return s.store.sign(ctx, s.name, s.version, digest, opts)
+}
+
+// Error describes the error answered by the service
+func (e *transitError) Error() string {
+	if len(e.errors) == 0 {
+		return fmt.Sprintf("transit service answered %d", e.status)
+	}
+	return fmt.Sprintf("transit service answered %d: %s", e.status, strings.Join(e.errors, "; "))
+}
@@ -0,0 +1,329 @@
+package cypher
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/internal/infrastructure/cypher/transitstub"
+)
+
+const transitTestToken = "test-token"
+
+// transitTestServer serves a transit stub
+type transitTestServer struct {
+	*httptest.Server
+	stub *transitstub.Stub
+}
+
+// newTransitTestServer starts a transit service with an RSA, an EC P-256 and an Ed25519 key, answering
+// 503 to the first fail sign requests
+func newTransitTestServer(t *testing.T, fail int64) *transitTestServer {
+	t.Helper()
+	stub := transitstub.New(transitstub.Options{Token: transitTestToken, Fail: fail})
+	for name, keyType := range map[string]string{"rsa": "rsa", "ec": "ec", "ed": "ed25519"} {
+		key, err := transitstub.GenerateKey(keyType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stub.AddKey(name, key)
+	}
+
+	server := &transitTestServer{Server: httptest.NewServer(stub.Handler()), stub: stub}
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestTransitKeyStore returns a store for the server with the given token and retries
+func newTestTransitKeyStore(server *transitTestServer, token string, retries int, backoff time.Duration) *TransitKeyStore {
+	return NewTransitKeyStore(TransitOptions{
+		Address:      server.URL,
+		Token:        token,
+		Timeout:      5 * time.Second,
+		Retries:      retries,
+		RetryBackoff: backoff,
+	})
+}
+
+func TestTransitKeyStoreSignatures(t *testing.T) {
+	server := newTransitTestServer(t, 0)
+	store := newTestTransitKeyStore(server, transitTestToken, 0, 0)
+	defer store.Close()
+
+	digest := sha256.Sum256([]byte("documento"))
+	tests := []struct {
+		name   string
+		key    string
+		opts   crypto.SignerOpts
+		verify func(publicKey crypto.PublicKey, signature []byte) bool
+	}{
+		{
+			name: "RSA PKCS#1 v1.5",
+			key:  "rsa",
+			opts: crypto.SHA256,
+			verify: func(publicKey crypto.PublicKey, signature []byte) bool {
+				return rsa.VerifyPKCS1v15(publicKey.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+			},
+		},
+		{
+			name: "RSA PSS",
+			key:  "rsa",
+			opts: &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256},
+			verify: func(publicKey crypto.PublicKey, signature []byte) bool {
+				return rsa.VerifyPSS(publicKey.(*rsa.PublicKey), crypto.SHA256, digest[:], signature,
+					&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+			},
+		},
+		{
+			name: "ECDSA P-256",
+			key:  "ec",
+			opts: crypto.SHA256,
+			verify: func(publicKey crypto.PublicKey, signature []byte) bool {
+				return ecdsa.VerifyASN1(publicKey.(*ecdsa.PublicKey), digest[:], signature)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := store.Signer(tt.key)
+			if err != nil {
+				t.Fatalf("Signer() error = %v", err)
+			}
+			if !signer.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(server.stub.Key(tt.key).Public()) {
+				t.Fatal("Signer() public key differs from the key of the service")
+			}
+			signature, err := signer.Sign(rand.Reader, digest[:], tt.opts)
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+			if !tt.verify(signer.Public(), signature) {
+				t.Error("signature does not verify with the public key")
+			}
+		})
+	}
+
+	t.Run("Ed25519 signs the message", func(t *testing.T) {
+		signer, err := store.Signer("ed")
+		if err != nil {
+			t.Fatalf("Signer() error = %v", err)
+		}
+		signature, err := signer.Sign(rand.Reader, []byte("documento"), crypto.Hash(0))
+		if err != nil {
+			t.Fatalf("Sign() error = %v", err)
+		}
+		if !ed25519.Verify(signer.Public().(ed25519.PublicKey), []byte("documento"), signature) {
+			t.Error("signature does not verify with the public key")
+		}
+		if _, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256); err == nil {
+			t.Error("Sign() of a digest with an Ed25519 key succeeded")
+		}
+	})
+}
+
+func TestTransitKeyStoreRetries(t *testing.T) {
+	digest := sha256.Sum256([]byte("documento"))
+	tests := []struct {
+		name      string
+		fail      int64
+		retries   int
+		wantErr   bool
+		wantCalls int64
+	}{
+		{name: "succeeds after retrying", fail: 2, retries: 2, wantCalls: 3},
+		{name: "fails once the retries are spent", fail: 3, retries: 2, wantErr: true, wantCalls: 3},
+		{name: "fails without retries", fail: 1, retries: 0, wantErr: true, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTransitTestServer(t, tt.fail)
+			store := newTestTransitKeyStore(server, transitTestToken, tt.retries, time.Millisecond)
+			defer store.Close()
+			signer, err := store.Signer("rsa")
+			if err != nil {
+				t.Fatalf("Signer() error = %v", err)
+			}
+
+			_, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Sign() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if calls := server.stub.Calls(); calls != tt.wantCalls {
+				t.Errorf("service received %d sign requests, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestTransitKeyStoreAuthFailure(t *testing.T) {
+	server := newTransitTestServer(t, 0)
+	store := newTestTransitKeyStore(server, "wrong-token", 3, time.Millisecond)
+	defer store.Close()
+
+	_, err := store.Signer("rsa")
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("Signer() error = %v, want the 403 of the service", err)
+	}
+
+	// A signer obtained with a valid token fails, without retrying, once the token is rejected
+	valid := newTestTransitKeyStore(server, transitTestToken, 3, time.Millisecond)
+	defer valid.Close()
+	signer, err := valid.Signer("rsa")
+	if err != nil {
+		t.Fatalf("Signer() error = %v", err)
+	}
+	valid.options.Token = "revoked"
+	digest := sha256.Sum256([]byte("documento"))
+	if _, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Sign() error = %v, want the 403 of the service", err)
+	}
+	if calls := server.stub.Calls(); calls != 0 {
+		t.Errorf("service signed %d times with a rejected token", calls)
+	}
+}
+
+func TestTransitKeyStoreRetriesFollowContext(t *testing.T) {
+	server := newTransitTestServer(t, 1000)
+	store := newTestTransitKeyStore(server, transitTestToken, 10, time.Second)
+	defer store.Close()
+	signer, err := store.Signer("rsa")
+	if err != nil {
+		t.Fatalf("Signer() error = %v", err)
+	}
+	digest := sha256.Sum256([]byte("documento"))
+
+	t.Run("cancelled request", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		if _, err := signer.(contextSigner).SignContext(ctx, digest[:], crypto.SHA256); err == nil {
+			t.Fatal("SignContext() succeeded against a failing service")
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("SignContext() returned after %s, want it to stop retrying once the request is cancelled", elapsed)
+		}
+	})
+
+	t.Run("closed store", func(t *testing.T) {
+		time.AfterFunc(50*time.Millisecond, func() { store.Close() })
+		start := time.Now()
+		if _, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256); err == nil {
+			t.Fatal("Sign() succeeded against a failing service")
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("Sign() returned after %s, want it to stop retrying once the store is closed", elapsed)
+		}
+	})
+}
+
+func TestExternalKeySignerWithTransitKeys(t *testing.T) {
+	server := newTransitTestServer(t, 0)
+	store := newTestTransitKeyStore(server, transitTestToken, 0, 0)
+	defer store.Close()
+
+	cache := NewSignerCache(time.Minute, 10)
+	algorithms, err := NewAlgorithmRegistry(nil, map[string]string{"06142803901121": "PS256"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := NewExternalKeySigner(NewJWSSigner(algorithms, HeaderPolicy{IncludeKeyID: true}, cache), store,
+		map[string]string{"06140101780010": "rsa", "06142803901121": "rsa", "02101601741065": "ec"}, NewKeyProcessor())
+
+	for _, nit := range []string{"06140101780010", "06142803901121", "02101601741065"} {
+		t.Run(nit, func(t *testing.T) {
+			certificate := &models.Certificate{ID: "cert-" + nit, NIT: nit, Active: true}
+			for i := 0; i < 3; i++ {
+				signed, err := signer.Sign(context.Background(), certificate, []byte(`{"a":1}`), models.SigningOptions{})
+				if err != nil {
+					t.Fatalf("Sign() error = %v", err)
+				}
+				object, err := jose.ParseSigned(signed.Serialized)
+				if err != nil {
+					t.Fatalf("ParseSigned() error = %v", err)
+				}
+				label := map[string]string{"06140101780010": "rsa", "06142803901121": "rsa", "02101601741065": "ec"}[nit]
+				if _, err := object.Verify(server.stub.Key(label).Public()); err != nil {
+					t.Errorf("Verify() error = %v", err)
+				}
+			}
+		})
+	}
+
+	// Every NIT builds its signer options once, then reuses them
+	stats := cache.Stats()
+	if stats.Entries != 3 || stats.Hits != 6 {
+		t.Errorf("signer cache has %d entries and %d hits, want 3 entries and 6 hits", stats.Entries, stats.Hits)
+	}
+}
+
+func TestTransitKeyStorePicksUpRotatedKeys(t *testing.T) {
+	server := newTransitTestServer(t, 0)
+	store := newTestTransitKeyStore(server, transitTestToken, 0, 0)
+	store.options.KeyRefresh = time.Minute
+	defer store.Close()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	digest := sha256.Sum256([]byte("documento"))
+
+	first, err := store.Signer("rsa")
+	if err != nil {
+		t.Fatalf("Signer() error = %v", err)
+	}
+	rotated, err := transitstub.GenerateKey("rsa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.stub.AddKey("rsa", rotated)
+
+	// 1: Within the refresh interval the known version keeps signing, with signatures matching its public key
+	if signer, err := store.Signer("rsa"); err != nil || signer != first {
+		t.Fatalf("Signer() = %p, %v, want the cached handle %p", signer, err, first)
+	}
+	signature, err := first.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if rsa.VerifyPKCS1v15(first.Public().(*rsa.PublicKey), crypto.SHA256, digest[:], signature) != nil {
+		t.Error("signature of the cached handle does not verify with its public key")
+	}
+
+	// 2: Once it expires, the latest version replaces it
+	now = now.Add(time.Minute)
+	second, err := store.Signer("rsa")
+	if err != nil {
+		t.Fatalf("Signer() error = %v", err)
+	}
+	if !second.Public().(*rsa.PublicKey).Equal(rotated.Public()) {
+		t.Fatal("Signer() after the refresh interval does not hold the rotated key")
+	}
+	signature, err = second.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if rsa.VerifyPKCS1v15(rotated.Public().(*rsa.PublicKey), crypto.SHA256, digest[:], signature) != nil {
+		t.Error("signature of the rotated handle does not verify with the rotated key")
+	}
+
+	// 3: An unchanged version keeps the handle, and an unreachable service keeps the known one
+	now = now.Add(time.Minute)
+	if signer, err := store.Signer("rsa"); err != nil || signer != second {
+		t.Errorf("Signer() of an unchanged key = %p, %v, want the known handle %p", signer, err, second)
+	}
+	server.Close()
+	now = now.Add(time.Minute)
+	if signer, err := store.Signer("rsa"); err != nil || signer != second {
+		t.Errorf("Signer() with the service down = %p, %v, want the known handle %p", signer, err, second)
+	}
+}
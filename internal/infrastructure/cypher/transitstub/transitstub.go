@@ -0,0 +1,311 @@
+// Package transitstub provides a minimal key service answering the key and sign endpoints of the
+// Vault Transit API with in-memory keys, meant for testing the transit signer locally and in tests.
+package transitstub
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// transitHashes maps the hash names of the transit API to their digests
+var transitHashes = map[string]crypto.Hash{
+	"sha2-256": crypto.SHA256,
+	"sha2-384": crypto.SHA384,
+	"sha2-512": crypto.SHA512,
+}
+
+// Options configures a stub
+type Options struct {
+	// Mount is the path where the transit engine is served, "transit" by default
+	Mount string
+	// Token is expected in the X-Vault-Token header; any is accepted when empty
+	Token string
+	// Fail is the number of sign requests answered with 503 before succeeding, to exercise retries
+	Fail int64
+	// Delay is added to every sign request, to exercise timeouts
+	Delay time.Duration
+}
+
+// Stub answers the transit requests with its keys. Every key keeps its versions, the last one
+// being the one that signs.
+type Stub struct {
+	options Options
+	calls   atomic.Int64
+
+	mutex sync.RWMutex
+	keys  map[string][]crypto.Signer
+}
+
+// signRequest is the body of a sign request
+type signRequest struct {
+	Input               string `json:"input"`
+	Prehashed           bool   `json:"prehashed"`
+	SignatureAlgorithm  string `json:"signature_algorithm"`
+	SaltLength          string `json:"salt_length"`
+	MarshalingAlgorithm string `json:"marshaling_algorithm"`
+	KeyVersion          int    `json:"key_version"`
+}
+
+// New creates a new stub without keys
+func New(options Options) *Stub {
+	if options.Mount == "" {
+		options.Mount = "transit"
+	}
+	options.Mount = strings.Trim(options.Mount, "/")
+
+	return &Stub{
+		options: options,
+		keys:    make(map[string][]crypto.Signer),
+	}
+}
+
+// GenerateKey generates a key of a type: rsa, ec or ed25519
+func GenerateKey(keyType string) (crypto.Signer, error) {
+	switch keyType {
+	case "rsa":
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case "ec":
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ed25519":
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return nil, fmt.Errorf("unknown key type %q", keyType)
+	}
+}
+
+// AddKey adds a key as the latest version of the named key, creating it or rotating it
+func (s *Stub) AddKey(name string, key crypto.Signer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.keys[name] = append(s.keys[name], key)
+}
+
+// Key returns the latest version of the named key, or nil when it does not exist
+func (s *Stub) Key(name string) crypto.Signer {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	versions := s.keys[name]
+	if len(versions) == 0 {
+		return nil
+	}
+	return versions[len(versions)-1]
+}
+
+// Calls returns the number of sign requests received, failed ones included
+func (s *Stub) Calls() int64 {
+	return s.calls.Load()
+}
+
+// Prefix returns the path under which the transit engine is served
+func (s *Stub) Prefix() string {
+	return "/v1/" + s.options.Mount
+}
+
+// Handler returns the handler of the transit routes
+func (s *Stub) Handler() http.Handler {
+	router := mux.NewRouter()
+	router.HandleFunc(s.Prefix()+"/keys/{name}", s.readKey).Methods(http.MethodGet)
+	router.HandleFunc(s.Prefix()+"/sign/{name}", s.sign).Methods(http.MethodPost)
+	router.HandleFunc(s.Prefix()+"/sign/{name}/{hash}", s.sign).Methods(http.MethodPost)
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "unknown path")
+	})
+	return router
+}
+
+// readKey answers the public keys of every version of a key
+func (s *Stub) readKey(w http.ResponseWriter, r *http.Request) {
+	versions, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	keys := make(map[string]interface{}, len(versions))
+	for i, key := range versions {
+		publicKey, err := encodePublicKey(key.Public())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		keys[strconv.Itoa(i+1)] = map[string]string{"public_key": publicKey}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"name":           mux.Vars(r)["name"],
+			"type":           keyType(versions[0].Public()),
+			"latest_version": len(versions),
+			"keys":           keys,
+		},
+	})
+}
+
+// sign answers the signature of an input
+func (s *Stub) sign(w http.ResponseWriter, r *http.Request) {
+	versions, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	time.Sleep(s.options.Delay)
+	if call := s.calls.Add(1); call <= s.options.Fail {
+		writeError(w, http.StatusServiceUnavailable, fmt.Sprintf("simulated failure %d of %d", call, s.options.Fail))
+		return
+	}
+
+	// 1: Decode the request
+	var request signRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	input, err := base64.StdEncoding.DecodeString(request.Input)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "input must be base64")
+		return
+	}
+
+	// 2: Pick the requested version, the latest by default
+	version := len(versions)
+	if request.KeyVersion != 0 {
+		if request.KeyVersion < 0 || request.KeyVersion > len(versions) {
+			writeError(w, http.StatusBadRequest, "invalid key version")
+			return
+		}
+		version = request.KeyVersion
+	}
+
+	// 3: Sign the message or digest
+	signature, err := signInput(versions[version-1], input, mux.Vars(r)["hash"], request)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"signature":   fmt.Sprintf("vault:v%d:%s", version, base64.StdEncoding.EncodeToString(signature)),
+			"key_version": version,
+		},
+	})
+}
+
+// authorize checks the token and returns the versions of the key named by the request
+func (s *Stub) authorize(w http.ResponseWriter, r *http.Request) ([]crypto.Signer, bool) {
+	if s.options.Token != "" && r.Header.Get("X-Vault-Token") != s.options.Token {
+		writeError(w, http.StatusForbidden, "permission denied")
+		return nil, false
+	}
+	s.mutex.RLock()
+	versions := s.keys[mux.Vars(r)["name"]]
+	s.mutex.RUnlock()
+	if len(versions) == 0 {
+		writeError(w, http.StatusNotFound, "key not found")
+		return nil, false
+	}
+	return versions, true
+}
+
+// signInput signs an input as the transit engine does
+func signInput(key crypto.Signer, input []byte, hashName string, request signRequest) ([]byte, error) {
+	if _, ok := key.(ed25519.PrivateKey); ok {
+		return key.Sign(rand.Reader, input, crypto.Hash(0))
+	}
+
+	if hashName == "" {
+		hashName = "sha2-256"
+	}
+	hash, ok := transitHashes[hashName]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash %s", hashName)
+	}
+	digest := input
+	if !request.Prehashed {
+		hasher := hash.New()
+		hasher.Write(input)
+		digest = hasher.Sum(nil)
+	}
+	if len(digest) != hash.Size() {
+		return nil, fmt.Errorf("digest must be %d bytes", hash.Size())
+	}
+
+	var opts crypto.SignerOpts = hash
+	if request.SignatureAlgorithm == "pss" {
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash}
+	}
+	signature, err := key.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// ECDSA signatures are ASN.1 encoded unless the JWS form is requested
+	if ecKey, ok := key.(*ecdsa.PrivateKey); ok && request.MarshalingAlgorithm == "jws" {
+		var parsed struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(signature, &parsed); err != nil {
+			return nil, err
+		}
+		size := (ecKey.Curve.Params().BitSize + 7) / 8
+		raw := make([]byte, 2*size)
+		parsed.R.FillBytes(raw[:size])
+		parsed.S.FillBytes(raw[size:])
+		return raw, nil
+	}
+	return signature, nil
+}
+
+// encodePublicKey encodes a public key as the transit engine does: Ed25519 keys as raw base64, the rest as PEM
+func encodePublicKey(publicKey crypto.PublicKey) (string, error) {
+	if edKey, ok := publicKey.(ed25519.PublicKey); ok {
+		return base64.StdEncoding.EncodeToString(edKey), nil
+	}
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// keyType returns the transit name of the type of a key
+func keyType(publicKey crypto.PublicKey) string {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("rsa-%d", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ecdsa-p" + strconv.Itoa(key.Curve.Params().BitSize)
+	case ed25519.PublicKey:
+		return "ed25519"
+	default:
+		return "unknown"
+	}
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError writes an error response in the format of the transit API
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string][]string{"errors": {message}})
+}
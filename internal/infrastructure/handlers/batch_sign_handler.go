@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+
+	"github.com/chainedpixel/go-dte-signer/internal/application/usecases"
+	"github.com/chainedpixel/go-dte-signer/pkg/logs"
+)
+
+// BatchSignHandler handles batch document signing requests
+type BatchSignHandler struct {
+	path                   string
+	maxBodySize            int64
+	documentSigningUseCase *usecases.DocumentSigningUseCase
+}
+
+// RegisterRoutes registers the handler routes with the router
+func (h *BatchSignHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc(h.path, h.Handle).Methods(http.MethodPost)
+}
+
+// NewBatchSignHandler creates a new batch sign handler that rejects request bodies larger than
+// maxBodySize bytes; a non-positive maxBodySize disables the limit
+func NewBatchSignHandler(documentSigningUseCase *usecases.DocumentSigningUseCase, path string, maxBodySize int64) *BatchSignHandler {
+	return &BatchSignHandler{
+		path:                   path,
+		maxBodySize:            maxBodySize,
+		documentSigningUseCase: documentSigningUseCase,
+	}
+}
+
+// Handle handles batch document signing requests
+func (h *BatchSignHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	// Set the response content type
+	w.Header().Set("Content-Type", "application/json")
+
+	// 1: Parse the request body, an array of signing requests
+	var inputs []usecases.DocumentSigningInput
+	if !decodeBody(w, r, h.maxBodySize, &inputs) {
+		return
+	}
+	metadata := wantsMetadata(r)
+	for i := range inputs {
+		inputs[i].Metadata = metadata
+	}
+
+	// 2: Execute the use case
+	resp, err := h.documentSigningUseCase.ExecuteBatch(r.Context(), inputs)
+	if err != nil {
+		logs.Error(fmt.Sprintf("ERROR: Unexpected error in batch signing use case: %v", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Internal server error",
+		})
+		return
+	}
+
+	// 3: Determine HTTP status code based on response; item errors do not fail the batch
+	statusCode := http.StatusOK
+	if resp.Status != "OK" {
+		statusCode = http.StatusBadRequest
+	}
+
+	// 4: Write response
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logs.Error(fmt.Sprintf("ERROR: Failed to encode response: %v", err))
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/chainedpixel/go-dte-signer/pkg/logs"
+)
+
+// bearerAuth returns a middleware rejecting the requests without the given bearer token; the
+// description names the protected requests in the log
+func bearerAuth(token string, description string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			given, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !found || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				logs.Warn(fmt.Sprintf("Unauthorized %s request from %s", description, r.RemoteAddr))
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Unauthorized",
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/chainedpixel/go-dte-signer/internal/application/usecases"
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/pkg/logs"
+	"github.com/chainedpixel/go-dte-signer/pkg/response"
+)
+
+// maxCertificateSize is the largest certificate file accepted by the upload route
+const maxCertificateSize = 1 << 20
+
+// CertificateAdminHandler handles the authenticated certificate administration requests
+type CertificateAdminHandler struct {
+	path                    string
+	token                   string
+	maxBodySize             int64
+	certificateAdminUseCase *usecases.CertificateAdminUseCase
+}
+
+// RegisterRoutes registers the handler routes with the router
+func (h *CertificateAdminHandler) RegisterRoutes(router *mux.Router) {
+	admin := router.PathPrefix(h.path).Subrouter()
+	admin.Use(bearerAuth(h.token, "certificate administration"))
+	admin.HandleFunc("", h.List).Methods(http.MethodGet)
+	admin.HandleFunc("", h.Upload).Methods(http.MethodPost)
+	admin.HandleFunc("/{nit}/activate", h.Activate).Methods(http.MethodPost)
+	admin.HandleFunc("/{nit}/deactivate", h.Deactivate).Methods(http.MethodPost)
+	admin.HandleFunc("/{nit}/expiry", h.SetExpiry).Methods(http.MethodPut)
+	admin.HandleFunc("/{nit}", h.Delete).Methods(http.MethodDelete)
+}
+
+// NewCertificateAdminHandler creates a new certificate administration handler protected by a bearer token.
+// JSON request bodies larger than maxBodySize bytes are rejected; a non-positive maxBodySize disables the limit.
+func NewCertificateAdminHandler(certificateAdminUseCase *usecases.CertificateAdminUseCase, path string, token string, maxBodySize int64) *CertificateAdminHandler {
+	return &CertificateAdminHandler{
+		path:                    path,
+		token:                   token,
+		maxBodySize:             maxBodySize,
+		certificateAdminUseCase: certificateAdminUseCase,
+	}
+}
+
+// List handles the certificate listing requests
+func (h *CertificateAdminHandler) List(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.certificateAdminUseCase.List(r.Context())
+	h.writeResponse(w, resp, err)
+}
+
+// Upload handles the certificate upload requests. The .crt file is taken from the
+// "file" field of a multipart form or, otherwise, from the raw request body.
+func (h *CertificateAdminHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	// Set the response content type
+	w.Header().Set("Content-Type", "application/json")
+
+	// 1: Read the certificate file
+	r.Body = http.MaxBytesReader(w, r.Body, maxCertificateSize)
+	content, err := readCertificateFile(r)
+	if err != nil {
+		logs.Error(fmt.Sprintf("ERROR: Failed to read certificate file: %v", err))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Invalid request format",
+		})
+		return
+	}
+
+	// 2: Execute the use case
+	replace, _ := strconv.ParseBool(r.URL.Query().Get("replace"))
+	resp, err := h.certificateAdminUseCase.Upload(r.Context(), content, replace)
+	h.writeResponse(w, resp, err)
+}
+
+// Activate handles the certificate activation requests
+func (h *CertificateAdminHandler) Activate(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.certificateAdminUseCase.SetActive(r.Context(), mux.Vars(r)["nit"], r.URL.Query().Get("id"), true)
+	h.writeResponse(w, resp, err)
+}
+
+// Deactivate handles the certificate deactivation requests
+func (h *CertificateAdminHandler) Deactivate(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.certificateAdminUseCase.SetActive(r.Context(), mux.Vars(r)["nit"], r.URL.Query().Get("id"), false)
+	h.writeResponse(w, resp, err)
+}
+
+// SetExpiry handles the requests setting the expiry date of a certificate
+func (h *CertificateAdminHandler) SetExpiry(w http.ResponseWriter, r *http.Request) {
+	// Set the response content type
+	w.Header().Set("Content-Type", "application/json")
+
+	// 1: Parse the request body
+	var input usecases.CertificateExpiryInput
+	if !decodeBody(w, r, h.maxBodySize, &input) {
+		return
+	}
+
+	// 2: Execute the use case
+	resp, err := h.certificateAdminUseCase.SetExpiry(r.Context(), mux.Vars(r)["nit"], r.URL.Query().Get("id"), input)
+	h.writeResponse(w, resp, err)
+}
+
+// Delete handles the certificate deletion requests
+func (h *CertificateAdminHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.certificateAdminUseCase.Delete(r.Context(), mux.Vars(r)["nit"], r.URL.Query().Get("id"))
+	h.writeResponse(w, resp, err)
+}
+
+// writeResponse writes the response of a certificate administration use case
+func (h *CertificateAdminHandler) writeResponse(w http.ResponseWriter, resp *response.Response, err error) {
+	// Set the response content type
+	w.Header().Set("Content-Type", "application/json")
+
+	// 1: Handle unexpected errors
+	if err != nil {
+		logs.Error(fmt.Sprintf("ERROR: Unexpected error in certificate administration use case: %v", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Internal server error",
+		})
+		return
+	}
+
+	// 2: Write response
+	w.WriteHeader(adminStatusCode(resp))
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logs.Error(fmt.Sprintf("ERROR: Failed to encode response: %v", err))
+	}
+}
+
+// adminStatusCode returns the HTTP status code of a certificate administration response:
+// 404 when the certificate does not exist, 409 when it already does and 400 for the other errors
+func adminStatusCode(resp *response.Response) int {
+	if resp.Status == "OK" {
+		return http.StatusOK
+	}
+	body, _ := resp.Body.(response.ErrorBody)
+	switch body.Code {
+	case domainErrors.CodeCertNotFound, domainErrors.CodeFileNotFound:
+		return http.StatusNotFound
+	case domainErrors.CodeCertificateExists:
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// readCertificateFile reads the uploaded certificate from a multipart form or from the raw body
+func readCertificateFile(r *http.Request) ([]byte, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return io.ReadAll(file)
+	}
+	return io.ReadAll(r.Body)
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/chainedpixel/go-dte-signer/internal/application/usecases"
+	domainErrors "github.com/chainedpixel/go-dte-signer/internal/domain/errors"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/pkg/i18n"
+)
+
+// testAdminToken is the bearer token of the test certificate administration routes
+const testAdminToken = "admin-token"
+
+// failingAdminService answers every certificate administration request with the same error, counting them
+type failingAdminService struct {
+	err   error
+	calls int
+}
+
+func (s *failingAdminService) ListCertificates(ctx context.Context) ([]models.CertificateSummary, error) {
+	s.calls++
+	return nil, s.err
+}
+
+func (s *failingAdminService) UploadCertificate(ctx context.Context, content []byte, replace bool) (*models.CertificateSummary, error) {
+	s.calls++
+	return nil, s.err
+}
+
+func (s *failingAdminService) SetCertificateActive(ctx context.Context, nit string, id string, active bool) (*models.CertificateSummary, error) {
+	s.calls++
+	return nil, s.err
+}
+
+func (s *failingAdminService) SetCertificateExpiry(ctx context.Context, nit string, id string, expiresAt *time.Time) (*models.CertificateSummary, error) {
+	s.calls++
+	return nil, s.err
+}
+
+func (s *failingAdminService) DeleteCertificate(ctx context.Context, nit string, id string) error {
+	s.calls++
+	return s.err
+}
+
+// newTestAdminRouter returns a router serving the certificate administration routes of a service
+func newTestAdminRouter(t *testing.T, service *failingAdminService) *mux.Router {
+	t.Helper()
+	translator, err := i18n.NewTranslator("../../../configs/locales", "en")
+	if err != nil {
+		t.Fatalf("NewTranslator() error = %v", err)
+	}
+	router := mux.NewRouter()
+	NewCertificateAdminHandler(usecases.NewCertificateAdminUseCase(service, translator), "/admin/certificates", testAdminToken, 1024).RegisterRoutes(router)
+	return router
+}
+
+func TestCertificateAdminHandlerStatusCodes(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		err        error
+		wantStatus int
+	}{
+		{
+			name:   "upload of an existing certificate",
+			method: http.MethodPost, path: "/admin/certificates", body: "<CertificadoMH/>",
+			err:        domainErrors.NewDomainError("certificate_exists", domainErrors.CodeCertificateExists),
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:   "upload of an invalid certificate",
+			method: http.MethodPost, path: "/admin/certificates", body: "<CertificadoMH/>",
+			err:        domainErrors.NewDomainError("invalid", domainErrors.CodeInvalid),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "activation of a NIT without certificate",
+			method: http.MethodPost, path: "/admin/certificates/06140101780010/activate",
+			err:        domainErrors.NewDomainError("file_not_found", domainErrors.CodeFileNotFound),
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:   "expiry of an unknown certificate id",
+			method: http.MethodPut, path: "/admin/certificates/06140101780010/expiry?id=other", body: `{"expiresAt": null}`,
+			err:        domainErrors.NewDomainError("certificate_id_not_found", domainErrors.CodeCertNotFound),
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:   "deletion without the id of a NIT with several certificates",
+			method: http.MethodDelete, path: "/admin/certificates/06140101780010",
+			err:        domainErrors.NewDomainError("certificate_id_required", domainErrors.CodeRequiredData),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "deletion of a stored certificate",
+			method: http.MethodDelete, path: "/admin/certificates/06140101780010",
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			request.Header.Set("Authorization", "Bearer "+testAdminToken)
+			recorder := httptest.NewRecorder()
+			newTestAdminRouter(t, &failingAdminService{err: tt.err}).ServeHTTP(recorder, request)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d; body %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+		})
+	}
+}
+
+func TestCertificateAdminHandlerRequiresTheToken(t *testing.T) {
+	routes := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/admin/certificates", ""},
+		{http.MethodPost, "/admin/certificates", "<CertificadoMH/>"},
+		{http.MethodPost, "/admin/certificates/06140101780010/activate", ""},
+		{http.MethodPost, "/admin/certificates/06140101780010/deactivate", ""},
+		{http.MethodPut, "/admin/certificates/06140101780010/expiry", `{"expiresAt": null}`},
+		{http.MethodDelete, "/admin/certificates/06140101780010", ""},
+	}
+	authorizations := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+	}{
+		{"without token", "", http.StatusUnauthorized},
+		{"with a wrong token", "Bearer other-token", http.StatusUnauthorized},
+		{"with a token prefix", "Bearer admin", http.StatusUnauthorized},
+		{"with another scheme", "Basic " + testAdminToken, http.StatusUnauthorized},
+		{"with the token", "Bearer " + testAdminToken, http.StatusOK},
+	}
+	for _, route := range routes {
+		for _, auth := range authorizations {
+			t.Run(route.method+" "+route.path+" "+auth.name, func(t *testing.T) {
+				request := httptest.NewRequest(route.method, route.path, strings.NewReader(route.body))
+				if auth.authorization != "" {
+					request.Header.Set("Authorization", auth.authorization)
+				}
+				recorder := httptest.NewRecorder()
+				service := &failingAdminService{}
+				newTestAdminRouter(t, service).ServeHTTP(recorder, request)
+
+				if recorder.Code != auth.wantStatus {
+					t.Errorf("status = %d, want %d; body %s", recorder.Code, auth.wantStatus, recorder.Body)
+				}
+				if auth.wantStatus != http.StatusUnauthorized {
+					return
+				}
+				if service.calls != 0 {
+					t.Error("an unauthorized request reached the certificate administration service")
+				}
+				if recorder.Header().Get("WWW-Authenticate") != "Bearer" {
+					t.Errorf("WWW-Authenticate = %q, want Bearer", recorder.Header().Get("WWW-Authenticate"))
+				}
+			})
+		}
+	}
+}
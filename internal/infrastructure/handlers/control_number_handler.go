@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+
+	"github.com/chainedpixel/go-dte-signer/internal/application/usecases"
+	"github.com/chainedpixel/go-dte-signer/pkg/logs"
+	"github.com/chainedpixel/go-dte-signer/pkg/response"
+)
+
+// ControlNumberHandler handles the authenticated numeroControl allocation requests
+type ControlNumberHandler struct {
+	path                 string
+	token                string
+	maxBodySize          int64
+	controlNumberUseCase *usecases.ControlNumberUseCase
+}
+
+// RegisterRoutes registers the handler routes with the router
+func (h *ControlNumberHandler) RegisterRoutes(router *mux.Router) {
+	controlNumbers := router.PathPrefix(h.path).Subrouter()
+	controlNumbers.Use(bearerAuth(h.token, "control number"))
+	controlNumbers.HandleFunc("/{nit}", h.Current).Methods(http.MethodGet)
+	controlNumbers.HandleFunc("/{nit}", h.Advance).Methods(http.MethodPut)
+	controlNumbers.HandleFunc("/{nit}/next", h.Next).Methods(http.MethodPost)
+}
+
+// NewControlNumberHandler creates a new control number handler protected by a bearer token.
+// Request bodies larger than maxBodySize bytes are rejected; a non-positive maxBodySize disables the limit.
+func NewControlNumberHandler(controlNumberUseCase *usecases.ControlNumberUseCase, path string, token string, maxBodySize int64) *ControlNumberHandler {
+	return &ControlNumberHandler{
+		path:                 path,
+		token:                token,
+		maxBodySize:          maxBodySize,
+		controlNumberUseCase: controlNumberUseCase,
+	}
+}
+
+// Next handles the requests allocating the next numeroControl of a series
+func (h *ControlNumberHandler) Next(w http.ResponseWriter, r *http.Request) {
+	input, ok := h.decodeInput(w, r)
+	if !ok {
+		return
+	}
+	resp, err := h.controlNumberUseCase.Next(r.Context(), mux.Vars(r)["nit"], input)
+	h.writeResponse(w, resp, err)
+}
+
+// Current handles the requests reading the last numeroControl of a series, given in the query
+func (h *ControlNumberHandler) Current(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	input := usecases.ControlNumberInput{
+		TipoDte:         query.Get("tipoDte"),
+		Establecimiento: query.Get("establecimiento"),
+		PuntoVenta:      query.Get("puntoVenta"),
+	}
+	resp, err := h.controlNumberUseCase.Current(r.Context(), mux.Vars(r)["nit"], input)
+	h.writeResponse(w, resp, err)
+}
+
+// Advance handles the requests raising the correlative of a series
+func (h *ControlNumberHandler) Advance(w http.ResponseWriter, r *http.Request) {
+	input, ok := h.decodeInput(w, r)
+	if !ok {
+		return
+	}
+	resp, err := h.controlNumberUseCase.Advance(r.Context(), mux.Vars(r)["nit"], input)
+	h.writeResponse(w, resp, err)
+}
+
+// decodeInput parses the series of the request body, answering the malformed ones
+func (h *ControlNumberHandler) decodeInput(w http.ResponseWriter, r *http.Request) (usecases.ControlNumberInput, bool) {
+	var input usecases.ControlNumberInput
+	w.Header().Set("Content-Type", "application/json")
+	return input, decodeBody(w, r, h.maxBodySize, &input)
+}
+
+// writeResponse writes the response of a control number use case
+func (h *ControlNumberHandler) writeResponse(w http.ResponseWriter, resp *response.Response, err error) {
+	// Set the response content type
+	w.Header().Set("Content-Type", "application/json")
+
+	// 1: Handle unexpected errors
+	if err != nil {
+		logs.Error(fmt.Sprintf("ERROR: Unexpected error in control number use case: %v", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Internal server error",
+		})
+		return
+	}
+
+	// 2: Determine HTTP status code based on response
+	statusCode := http.StatusOK
+	if resp.Status != "OK" {
+		statusCode = http.StatusBadRequest
+	}
+
+	// 3: Write response
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logs.Error(fmt.Sprintf("ERROR: Failed to encode response: %v", err))
+	}
+}
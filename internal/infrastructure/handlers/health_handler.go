@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/gorilla/mux"
 	"net/http"
 
@@ -36,7 +37,7 @@ func (h *HealthHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	// 2: Execute health check use case
 	resp, err := h.healthCheckUseCase.Execute(r.Context())
 	if err != nil {
-		logs.Error("ERROR: Unexpected error in health check use case: %v", err)
+		logs.Error(fmt.Sprintf("ERROR: Unexpected error in health check use case: %v", err))
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
 			"error": "Internal server error",
@@ -47,6 +48,6 @@ func (h *HealthHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	// 3: Write successful response
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		logs.Error("ERROR: Failed to encode response: %v", err)
+		logs.Error(fmt.Sprintf("ERROR: Failed to encode response: %v", err))
 	}
 }
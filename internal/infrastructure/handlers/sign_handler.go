@@ -2,16 +2,23 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gorilla/mux"
 	"net/http"
+	"strconv"
 
 	"github.com/chainedpixel/go-dte-signer/internal/application/usecases"
 	"github.com/chainedpixel/go-dte-signer/pkg/logs"
 )
 
+// MetadataHeader is the request header that opts in to the signature metadata response
+const MetadataHeader = "X-Signature-Metadata"
+
 // SignHandler handles document signing requests
 type SignHandler struct {
 	path                   string
+	maxBodySize            int64
 	documentSigningUseCase *usecases.DocumentSigningUseCase
 }
 
@@ -20,10 +27,12 @@ func (h *SignHandler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc(h.path, h.Handle).Methods(http.MethodPost)
 }
 
-// NewSignHandler creates a new sign handler
-func NewSignHandler(documentSigningUseCase *usecases.DocumentSigningUseCase, path string) *SignHandler {
+// NewSignHandler creates a new sign handler that rejects request bodies larger than maxBodySize bytes;
+// a non-positive maxBodySize disables the limit
+func NewSignHandler(documentSigningUseCase *usecases.DocumentSigningUseCase, path string, maxBodySize int64) *SignHandler {
 	return &SignHandler{
 		path:                   path,
+		maxBodySize:            maxBodySize,
 		documentSigningUseCase: documentSigningUseCase,
 	}
 }
@@ -35,19 +44,15 @@ func (h *SignHandler) Handle(w http.ResponseWriter, r *http.Request) {
 
 	// 1: Parse the request body
 	var input usecases.DocumentSigningInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		logs.Error("ERROR: Failed to decode request body: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Invalid request format",
-		})
+	if !decodeBody(w, r, h.maxBodySize, &input) {
 		return
 	}
+	input.Metadata = wantsMetadata(r)
 
 	// 2: Execute the use case
 	resp, err := h.documentSigningUseCase.Execute(r.Context(), input)
 	if err != nil {
-		logs.Error("ERROR: Unexpected error in document signing use case: %v", err)
+		logs.Error(fmt.Sprintf("ERROR: Unexpected error in document signing use case: %v", err))
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
 			"error": "Internal server error",
@@ -64,6 +69,46 @@ func (h *SignHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	// 4: Write response
 	w.WriteHeader(statusCode)
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		logs.Error("ERROR: Failed to encode response: %v", err)
+		logs.Error(fmt.Sprintf("ERROR: Failed to encode response: %v", err))
+	}
+}
+
+// wantsMetadata reports whether the client opted in to the signature metadata,
+// through the metadata query parameter or the X-Signature-Metadata header
+func wantsMetadata(r *http.Request) bool {
+	value := r.URL.Query().Get("metadata")
+	if value == "" {
+		value = r.Header.Get(MetadataHeader)
 	}
+	enabled, _ := strconv.ParseBool(value)
+	return enabled
+}
+
+// decodeBody decodes a JSON request body of at most maxBodySize bytes, writing the error response
+// when it cannot. Larger bodies are rejected as soon as the limit is reached, without reading them whole.
+func decodeBody(w http.ResponseWriter, r *http.Request, maxBodySize int64, v interface{}) bool {
+	if maxBodySize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+	}
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil {
+		return true
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		logs.Error(fmt.Sprintf("ERROR: Request body exceeds %d bytes", tooLarge.Limit))
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Request body too large",
+		})
+		return false
+	}
+
+	logs.Error(fmt.Sprintf("ERROR: Failed to decode request body: %v", err))
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": "Invalid request format",
+	})
+	return false
 }
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/chainedpixel/go-dte-signer/internal/application/usecases"
+	"github.com/chainedpixel/go-dte-signer/internal/domain/models"
+	"github.com/chainedpixel/go-dte-signer/pkg/i18n"
+)
+
+// countingSigningService signs every document with a fixed JWS, counting the requests it receives
+type countingSigningService struct {
+	requests atomic.Int32
+}
+
+func (s *countingSigningService) SignDocument(ctx context.Context, request *models.CertificateRequest) (*models.SignedDocument, error) {
+	s.requests.Add(1)
+	return &models.SignedDocument{Serialized: "header.payload.signature", Serialization: models.SerializationCompact}, nil
+}
+
+func (s *countingSigningService) VerifyDocument(ctx context.Context, request *models.CertificateRequest) (*models.VerificationResult, error) {
+	return &models.VerificationResult{Valid: true}, nil
+}
+
+// endlessReader is a request body that never ends, made of repeated JSON array items
+type endlessReader struct {
+	read atomic.Int64
+}
+
+func (r *endlessReader) Read(p []byte) (int, error) {
+	const item = `{"nit":"06140101780010","passwordPri":"secret","dteJson":{}},`
+	for i := range p {
+		offset := r.read.Add(1) - 1
+		if offset == 0 {
+			p[i] = '['
+			continue
+		}
+		p[i] = item[(offset-1)%int64(len(item))]
+	}
+	return len(p), nil
+}
+
+func newTestSigningUseCase(t *testing.T, service *countingSigningService) *usecases.DocumentSigningUseCase {
+	t.Helper()
+	translator, err := i18n.NewTranslator("../../../configs/locales", "en")
+	if err != nil {
+		t.Fatalf("NewTranslator() error = %v", err)
+	}
+	return usecases.NewDocumentSigningUseCase(service, translator, usecases.BatchOptions{MaxItems: 10})
+}
+
+func TestSignHandlersLimitRequestBodies(t *testing.T) {
+	const document = `{"nit":"06140101780010","passwordPri":"secret","dteJson":{"a":1}}`
+	const limit = 1024
+	tests := []struct {
+		name       string
+		handler    func(useCase *usecases.DocumentSigningUseCase) http.HandlerFunc
+		body       io.Reader
+		wantStatus int
+		wantSigned int32
+	}{
+		{
+			name: "document within the limit",
+			handler: func(uc *usecases.DocumentSigningUseCase) http.HandlerFunc {
+				return NewSignHandler(uc, "/sign", limit).Handle
+			},
+			body:       strings.NewReader(document),
+			wantStatus: http.StatusOK,
+			wantSigned: 1,
+		},
+		{
+			name: "document over the limit",
+			handler: func(uc *usecases.DocumentSigningUseCase) http.HandlerFunc {
+				return NewSignHandler(uc, "/sign", limit).Handle
+			},
+			body:       strings.NewReader(`{"nit":"06140101780010","passwordPri":"secret","dteJson":"` + strings.Repeat("a", limit) + `"}`),
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "document over a disabled limit",
+			handler: func(uc *usecases.DocumentSigningUseCase) http.HandlerFunc {
+				return NewSignHandler(uc, "/sign", 0).Handle
+			},
+			body:       strings.NewReader(`{"nit":"06140101780010","passwordPri":"secret","dteJson":{"a":"` + strings.Repeat("a", limit) + `"}}`),
+			wantStatus: http.StatusOK,
+			wantSigned: 1,
+		},
+		{
+			name: "malformed document",
+			handler: func(uc *usecases.DocumentSigningUseCase) http.HandlerFunc {
+				return NewSignHandler(uc, "/sign", limit).Handle
+			},
+			body:       strings.NewReader(`{"nit":`),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "batch within the limit",
+			handler: func(uc *usecases.DocumentSigningUseCase) http.HandlerFunc {
+				return NewBatchSignHandler(uc, "/sign/batch", limit).Handle
+			},
+			body:       strings.NewReader("[" + document + "," + document + "]"),
+			wantStatus: http.StatusOK,
+			wantSigned: 2,
+		},
+		{
+			name: "endless batch",
+			handler: func(uc *usecases.DocumentSigningUseCase) http.HandlerFunc {
+				return NewBatchSignHandler(uc, "/sign/batch", limit).Handle
+			},
+			body:       &endlessReader{},
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &countingSigningService{}
+			recorder := httptest.NewRecorder()
+			tt.handler(newTestSigningUseCase(t, service))(recorder, httptest.NewRequest(http.MethodPost, "/", tt.body))
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d; body %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if got := service.requests.Load(); got != tt.wantSigned {
+				t.Errorf("signed %d documents, want %d", got, tt.wantSigned)
+			}
+			if endless, ok := tt.body.(*endlessReader); ok && endless.read.Load() > 64*limit {
+				t.Errorf("read %d bytes of an endless body with a limit of %d", endless.read.Load(), limit)
+			}
+		})
+	}
+}
+
+func TestJSONHandlersLimitRequestBodies(t *testing.T) {
+	const limit = 1024
+	// The bodies are rejected before reaching the use cases, so none is needed
+	handlers := map[string]http.HandlerFunc{
+		"verify":                 NewVerifyHandler(nil, "/verify", limit).Handle,
+		"certificate expiry":     NewCertificateAdminHandler(nil, "/admin/certificates", "token", limit).SetExpiry,
+		"control number next":    NewControlNumberHandler(nil, "/control-numbers", "token", limit).Next,
+		"control number advance": NewControlNumberHandler(nil, "/control-numbers", "token", limit).Advance,
+	}
+	for name, handler := range handlers {
+		t.Run(name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"a":"`+strings.Repeat("a", limit)+`"}`)))
+			if recorder.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("status of an oversized body = %d, want %d", recorder.Code, http.StatusRequestEntityTooLarge)
+			}
+
+			recorder = httptest.NewRecorder()
+			handler(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"a":`)))
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("status of a malformed body = %d, want %d", recorder.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
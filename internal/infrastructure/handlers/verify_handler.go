@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+
+	"github.com/chainedpixel/go-dte-signer/internal/application/usecases"
+	"github.com/chainedpixel/go-dte-signer/pkg/logs"
+)
+
+// VerifyHandler handles signed document verification requests
+type VerifyHandler struct {
+	path                        string
+	maxBodySize                 int64
+	documentVerificationUseCase *usecases.DocumentVerificationUseCase
+}
+
+// RegisterRoutes registers the handler routes with the router
+func (h *VerifyHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc(h.path, h.Handle).Methods(http.MethodPost)
+}
+
+// NewVerifyHandler creates a new verify handler that rejects request bodies larger than maxBodySize bytes;
+// a non-positive maxBodySize disables the limit
+func NewVerifyHandler(documentVerificationUseCase *usecases.DocumentVerificationUseCase, path string, maxBodySize int64) *VerifyHandler {
+	return &VerifyHandler{
+		path:                        path,
+		maxBodySize:                 maxBodySize,
+		documentVerificationUseCase: documentVerificationUseCase,
+	}
+}
+
+// Handle handles signed document verification requests
+func (h *VerifyHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	// Set the response content type
+	w.Header().Set("Content-Type", "application/json")
+
+	// 1: Parse the request body
+	var input usecases.DocumentVerificationInput
+	if !decodeBody(w, r, h.maxBodySize, &input) {
+		return
+	}
+
+	// 2: Execute the use case
+	resp, err := h.documentVerificationUseCase.Execute(r.Context(), input)
+	if err != nil {
+		logs.Error(fmt.Sprintf("ERROR: Unexpected error in document verification use case: %v", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Internal server error",
+		})
+		return
+	}
+
+	// 3: Determine HTTP status code based on response
+	statusCode := http.StatusOK
+	if resp.Status != "OK" {
+		statusCode = http.StatusBadRequest
+	}
+
+	// 4: Write response
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logs.Error(fmt.Sprintf("ERROR: Failed to encode response: %v", err))
+	}
+}
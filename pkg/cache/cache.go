@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Stats holds the usage counters of a cache
+type Stats struct {
+	Hits       uint64 `json:"hits"`
+	Misses     uint64 `json:"misses"`
+	Evictions  uint64 `json:"evictions"`
+	Entries    int    `json:"entries"`
+	MaxEntries int    `json:"maxEntries"`
+	TTL        string `json:"ttl"`
+}
+
+// Cache is a size-bounded, least recently used cache whose entries expire after a TTL.
+// It is safe for concurrent use.
+type Cache[K comparable, V any] struct {
+	mutex      sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[K]*list.Element
+	order      *list.List
+	hits       uint64
+	misses     uint64
+	evictions  uint64
+}
+
+// entry is a cached value along with its expiration time
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+// New creates a new cache. A non-positive ttl disables expiration and a
+// non-positive maxEntries disables the size bound.
+func New[K comparable, V any](ttl time.Duration, maxEntries int) *Cache[K, V] {
+	return &Cache[K, V]{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[K]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Get returns the value stored for the key, if present and not expired
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var zero V
+	element, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return zero, false
+	}
+
+	e := element.Value.(*entry[K, V])
+	if c.expired(e) {
+		c.remove(element)
+		c.misses++
+		return zero, false
+	}
+
+	c.order.MoveToFront(element)
+	c.hits++
+	return e.value, true
+}
+
+// Set stores a value for the key, evicting the least recently used entry when the cache is full
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var expiresAt time.Time
+	if c.ttl > 0 {
+		expiresAt = time.Now().Add(c.ttl)
+	}
+
+	if element, ok := c.entries[key]; ok {
+		e := element.Value.(*entry[K, V])
+		e.value = value
+		e.expiresAt = expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expiresAt: expiresAt})
+
+	if c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+		c.evictions++
+	}
+}
+
+// Delete removes the entry stored for the key
+func (c *Cache[K, V]) Delete(key K) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+}
+
+// DeleteFunc removes every entry whose key matches and returns how many were removed
+func (c *Cache[K, V]) DeleteFunc(match func(key K) bool) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	removed := 0
+	for key, element := range c.entries {
+		if match(key) {
+			c.remove(element)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Purge removes every entry
+func (c *Cache[K, V]) Purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries = make(map[K]*list.Element)
+	c.order.Init()
+}
+
+// Stats returns the current usage counters
+func (c *Cache[K, V]) Stats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return Stats{
+		Hits:       c.hits,
+		Misses:     c.misses,
+		Evictions:  c.evictions,
+		Entries:    c.order.Len(),
+		MaxEntries: c.maxEntries,
+		TTL:        c.ttl.String(),
+	}
+}
+
+// expired reports whether the entry is past its expiration time
+func (c *Cache[K, V]) expired(e *entry[K, V]) bool {
+	return !e.expiresAt.IsZero() && time.Now().After(e.expiresAt)
+}
+
+// remove deletes an element from both the index and the recency list
+func (c *Cache[K, V]) remove(element *list.Element) {
+	e := element.Value.(*entry[K, V])
+	delete(c.entries, e.key)
+	c.order.Remove(element)
+}
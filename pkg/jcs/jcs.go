@@ -0,0 +1,219 @@
+package jcs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// ErrDuplicateKey is returned when an object contains the same member name twice
+var ErrDuplicateKey = errors.New("jcs: duplicate object member")
+
+// Transform returns the RFC 8785 (JSON Canonicalization Scheme) form of a JSON document:
+// no insignificant whitespace, object members sorted by their UTF-16 code units, strings
+// escaped as ECMAScript does and numbers serialized as ECMAScript IEEE 754 doubles.
+func Transform(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var buffer bytes.Buffer
+	if err := writeValue(decoder, &buffer); err != nil {
+		return nil, err
+	}
+
+	// Only a single top-level value is allowed
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("jcs: unexpected data after top-level value")
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// member is an object member already serialized in canonical form
+type member struct {
+	name  string
+	value []byte
+}
+
+// writeValue reads the next value from the decoder and writes its canonical form
+func writeValue(decoder *json.Decoder, buffer *bytes.Buffer) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	switch v := token.(type) {
+	case json.Delim:
+		if v == '{' {
+			return writeObject(decoder, buffer)
+		}
+		if v == '[' {
+			return writeArray(decoder, buffer)
+		}
+		return fmt.Errorf("jcs: unexpected delimiter %q", v)
+	case string:
+		writeString(buffer, v)
+	case json.Number:
+		number, err := formatNumber(v)
+		if err != nil {
+			return err
+		}
+		buffer.WriteString(number)
+	case bool:
+		buffer.WriteString(strconv.FormatBool(v))
+	case nil:
+		buffer.WriteString("null")
+	}
+	return nil
+}
+
+// writeObject writes the members of an object sorted by name
+func writeObject(decoder *json.Decoder, buffer *bytes.Buffer) error {
+	var members []member
+	seen := make(map[string]bool)
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		name := token.(string)
+		if seen[name] {
+			return ErrDuplicateKey
+		}
+		seen[name] = true
+
+		var value bytes.Buffer
+		if err := writeValue(decoder, &value); err != nil {
+			return err
+		}
+		members = append(members, member{name: name, value: value.Bytes()})
+	}
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return lessUTF16(members[i].name, members[j].name)
+	})
+
+	buffer.WriteByte('{')
+	for i, m := range members {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		writeString(buffer, m.name)
+		buffer.WriteByte(':')
+		buffer.Write(m.value)
+	}
+	buffer.WriteByte('}')
+	return nil
+}
+
+// writeArray writes the elements of an array in their original order
+func writeArray(decoder *json.Decoder, buffer *bytes.Buffer) error {
+	buffer.WriteByte('[')
+	for i := 0; decoder.More(); i++ {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		if err := writeValue(decoder, buffer); err != nil {
+			return err
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	buffer.WriteByte(']')
+	return nil
+}
+
+// writeString writes a string escaping only what ECMAScript JSON.stringify escapes
+func writeString(buffer *bytes.Buffer, s string) {
+	buffer.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buffer.WriteString(`\"`)
+		case '\\':
+			buffer.WriteString(`\\`)
+		case '\b':
+			buffer.WriteString(`\b`)
+		case '\f':
+			buffer.WriteString(`\f`)
+		case '\n':
+			buffer.WriteString(`\n`)
+		case '\r':
+			buffer.WriteString(`\r`)
+		case '\t':
+			buffer.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buffer, `\u%04x`, r)
+			} else {
+				buffer.WriteRune(r)
+			}
+		}
+	}
+	buffer.WriteByte('"')
+}
+
+// formatNumber serializes a number as ECMAScript Number.prototype.toString does for doubles
+func formatNumber(number json.Number) (string, error) {
+	value, err := strconv.ParseFloat(string(number), 64)
+	if err != nil || math.IsInf(value, 0) || math.IsNaN(value) {
+		return "", fmt.Errorf("jcs: number out of range: %s", number)
+	}
+	if value == 0 {
+		return "0", nil
+	}
+
+	format := byte('f')
+	if abs := math.Abs(value); abs < 1e-6 || abs >= 1e21 {
+		format = 'e'
+	}
+
+	s := strconv.FormatFloat(value, format, -1, 64)
+	if format == 'e' {
+		// Go pads the exponent to two digits (1e-07), ECMAScript does not (1e-7)
+		n := len(s)
+		if n >= 4 && s[n-4] == 'e' && s[n-3] == '-' && s[n-2] == '0' {
+			s = s[:n-2] + s[n-1:]
+		}
+	}
+	return s, nil
+}
+
+// lessUTF16 compares two strings by their UTF-16 code units
+func lessUTF16(a, b string) bool {
+	if utf8.ValidString(a) && utf8.ValidString(b) && isBMP(a) && isBMP(b) {
+		// Without surrogates the UTF-16 order equals the code point order
+		return a < b
+	}
+
+	ua := utf16.Encode([]rune(a))
+	ub := utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+// isBMP reports whether every rune of the string is in the Basic Multilingual Plane
+func isBMP(s string) bool {
+	for _, r := range s {
+		if r > 0xFFFF {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,213 @@
+package jcs
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestTransform(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			// RFC 8785, section 3.2.2
+			name: "rfc example",
+			input: `{
+				"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+				"string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+				"literals": [null, true, false]
+			}`,
+			want: `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+		},
+		{
+			// RFC 8785, section 3.2.3
+			name: "members sorted by UTF-16 code units",
+			input: `{
+				"\u20ac": "Euro Sign",
+				"\r": "Carriage Return",
+				"\ufb33": "Hebrew Letter Dalet With Dagesh",
+				"1": "One",
+				"\ud83d\ude00": "Emoji: Grinning Face",
+				"\u0080": "Control",
+				"\u00f6": "Latin Small Letter O With Diaeresis"
+			}`,
+			want: "{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"\u00f6\":\"Latin Small Letter O With Diaeresis\"," +
+				"\"\u20ac\":\"Euro Sign\",\"\U0001f600\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}",
+		},
+		{
+			name:  "nested members sorted, arrays kept in order",
+			input: `{"b": [3, {"z": 1, "a": 2}, 1], "a": {"d": null, "c": {}}}`,
+			want:  `{"a":{"c":{},"d":null},"b":[3,{"a":2,"z":1},1]}`,
+		},
+		{
+			name:  "control characters",
+			input: `"\u0000\u0001\b\t\n\u000b\f\r\u001f\u007f"`,
+			want:  "\"\\u0000\\u0001\\b\\t\\n\\u000b\\f\\r\\u001f\u007f\"",
+		},
+		{
+			name:  "quotes, backslashes and slashes",
+			input: `"\"a\\b\/c"`,
+			want:  `"\"a\\b/c"`,
+		},
+		{
+			name:  "non-ASCII characters unescaped",
+			input: `"Programaci\u00f3n inform\u00e1tica, \u00a0\u2028\ud83d\ude00"`,
+			want:  "\"Programación informática, \u00a0\u2028😀\"",
+		},
+		{
+			name:  "whitespace removed",
+			input: " [ 1 , \"a b\" , { } , [ ] ] \n",
+			want:  `[1,"a b",{},[]]`,
+		},
+		{
+			name:  "scalar document",
+			input: `true`,
+			want:  `true`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Transform([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Transform() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Transform() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformNumbers(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"0", "0"},
+		{"-0", "0"},
+		{"0.0", "0"},
+		{"1", "1"},
+		{"-1", "-1"},
+		{"4.50", "4.5"},
+		{"2e-3", "0.002"},
+		{"1E30", "1e+30"},
+		{"1e21", "1e+21"},
+		{"999999999999999999999", "1e+21"},
+		{"100000000000000000000", "100000000000000000000"},
+		{"123456789012345678901", "123456789012345680000"},
+		{"0.000001", "0.000001"},
+		{"0.0000001", "1e-7"},
+		{"1e-7", "1e-7"},
+		{"-1.5e-10", "-1.5e-10"},
+		{"1e-100", "1e-100"},
+		{"9007199254740993", "9007199254740992"},
+		{"333333333.33333329", "333333333.3333333"},
+		{"56.50000001", "56.50000001"},
+		{"0.1", "0.1"},
+		{"113.00", "113"},
+	}
+	for _, tt := range tests {
+		got, err := Transform([]byte(tt.input))
+		if err != nil {
+			t.Errorf("Transform(%s) error = %v", tt.input, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("Transform(%s) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestTransformIEEE754Doubles(t *testing.T) {
+	// RFC 8785, appendix B
+	tests := []struct {
+		bits uint64
+		want string
+	}{
+		{0x0000000000000000, "0"},
+		{0x8000000000000000, "0"},
+		{0x0000000000000001, "5e-324"},
+		{0x8000000000000001, "-5e-324"},
+		{0x7fefffffffffffff, "1.7976931348623157e+308"},
+		{0xffefffffffffffff, "-1.7976931348623157e+308"},
+		{0x4340000000000000, "9007199254740992"},
+		{0xc340000000000000, "-9007199254740992"},
+		{0x4430000000000000, "295147905179352830000"},
+		{0x44b52d02c7e14af5, "9.999999999999997e+22"},
+		{0x44b52d02c7e14af6, "1e+23"},
+		{0x44b52d02c7e14af7, "1.0000000000000001e+23"},
+		{0x444b1ae4d6e2ef4e, "999999999999999700000"},
+		{0x444b1ae4d6e2ef4f, "999999999999999900000"},
+		{0x444b1ae4d6e2ef50, "1e+21"},
+		{0x3eb0c6f7a0b5ed8c, "9.999999999999997e-7"},
+		{0x3eb0c6f7a0b5ed8d, "0.000001"},
+		{0x41b3de4355555553, "333333333.3333332"},
+		{0x41b3de4355555554, "333333333.33333325"},
+		{0x41b3de4355555555, "333333333.3333333"},
+		{0x41b3de4355555556, "333333333.3333334"},
+		{0x41b3de4355555557, "333333333.33333343"},
+		{0xbecbf647612f3696, "-0.0000033333333333333333"},
+		{0x43143ff3c1cb0959, "1424953923781206.2"},
+	}
+	for _, tt := range tests {
+		input := strconv.FormatFloat(math.Float64frombits(tt.bits), 'g', -1, 64)
+		got, err := Transform([]byte(input))
+		if err != nil {
+			t.Errorf("Transform(%s) of %016x error = %v", input, tt.bits, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("Transform(%s) of %016x = %s, want %s", input, tt.bits, got, tt.want)
+		}
+	}
+}
+
+func TestTransformRejectsInvalidDocuments(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"duplicate member", `{"a": 1, "b": 2, "a": 3}`},
+		{"nested duplicate member", `[{"a": {"x": 1, "x": 1}}]`},
+		{"number out of range", `[1e400]`},
+		{"data after the value", `{"a": 1} {"b": 2}`},
+		{"truncated document", `{"a": [1, 2`},
+		{"empty document", ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := Transform([]byte(tt.input)); err == nil {
+				t.Errorf("Transform() = %s, want an error", got)
+			}
+		})
+	}
+
+	if _, err := Transform([]byte(`{"a": 1, "a": 1}`)); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("Transform() error = %v, want ErrDuplicateKey", err)
+	}
+}
+
+func TestLessUTF16(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"a", "b", true},
+		{"a", "ab", true},
+		{"ab", "a", false},
+		{"a", "a", false},
+		// U+1F600 is encoded as the surrogates D83D DE00, which sort before U+FB33
+		{"😀", "\ufb33", true},
+		{"\ufb33", "😀", false},
+		{"€", "😀", true},
+	}
+	for _, tt := range tests {
+		if got := lessUTF16(tt.a, tt.b); got != tt.want {
+			t.Errorf("lessUTF16(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -75,7 +75,7 @@ func InitLogger(logLevel string) error {
 	writer := io.Writer(os.Stdout)
 	Logger.SetOutput(writer)
 
-	Debug("Logger initialized with level: %s", logLevel)
+	Debug(fmt.Sprintf("Logger initialized with level: %s", logLevel))
 
 	return nil
 }